@@ -0,0 +1,80 @@
+package jsmachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// abortResult is the panic value used to end a script with the value an onAbort handler returned
+type abortResult struct {
+	value otto.Value
+}
+
+// abort marks the execution as timed out or cancelled
+func (e *execution) abort(reason error) {
+	e.abortReason = reason
+	e.aborted.Store(true)
+}
+
+// isAborted reports whether the execution timed out or was cancelled
+func (e *execution) isAborted() bool {
+	return e.aborted.Load()
+}
+
+// abortError describes why an aborted execution ended
+func (e *execution) abortError(timeout time.Duration) error {
+	if errors.Is(e.abortReason, context.Canceled) {
+		return fmt.Errorf("execution cancelled")
+	}
+	return fmt.Errorf("execution timeout after %v", timeout)
+}
+
+// watchdog aborts the execution when execCtx ends before the script finished: scripts see ctx.aborted() and
+// their onAbort handlers run, then the VM is interrupted once the grace period has passed
+// ran is closed when the script stopped running; interrupts are then dropped, and they are no-ops for later executions
+func (p *Plugin) watchdog(execCtx context.Context, vm *otto.Otto, exec *execution, finished <-chan struct{}, killed chan<- struct{}, ran <-chan struct{}) {
+	select {
+	case <-finished:
+		return
+	case <-execCtx.Done():
+	}
+
+	exec.abort(execCtx.Err())
+
+	if grace := time.Duration(p.cfg.AbortGraceMs) * time.Millisecond; grace > 0 {
+		interrupt(vm, ran, func() {
+			// The script may have finished and the VM moved on to another execution
+			if p.executionFor(vm) == exec {
+				p.bindings.ctx.runAbortHandlers(exec)
+			}
+		})
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-finished:
+			return
+		}
+	}
+
+	close(killed)
+	interrupt(vm, ran, func() {
+		if p.executionFor(vm) == exec {
+			panic("execution timeout")
+		}
+	})
+}
+
+// interrupt queues fn on the VM unless the script stopped running first
+func interrupt(vm *otto.Otto, ran <-chan struct{}, fn func()) {
+	select {
+	case vm.Interrupt <- fn:
+	case <-ran:
+	}
+}
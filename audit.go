@@ -0,0 +1,228 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AuditRecord describes a completed execution
+type AuditRecord struct {
+	ID         string      `json:"id"`
+	Time       time.Time   `json:"time"`
+	RequestID  string      `json:"request_id,omitempty"`
+	Caller     string      `json:"caller,omitempty"`
+	Tag        string      `json:"tag,omitempty"`
+	ScriptHash string      `json:"script_hash"`
+	Script     string      `json:"script,omitempty"`
+	Code       string      `json:"code"`
+	Input      string      `json:"input,omitempty"`
+	TimeoutMs  int         `json:"timeout_ms,omitempty"`
+	Status     string      `json:"status"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+	Attempts   int         `json:"attempts"`
+
+	// Code or input was changed by js.redaction; such records cannot be replayed
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// auditLog keeps the most recent records in memory (for replay) and optionally appends them to a JSON lines file
+type auditLog struct {
+	mu       sync.Mutex
+	log      *zap.Logger
+	capacity int
+
+	// Ring buffer of recent records plus an index by ID
+	ring []*AuditRecord
+	next int
+	byID map[string]*AuditRecord
+	file *os.File
+	enc  *json.Encoder
+}
+
+// newAuditLog creates the audit log, opening the file sink if configured
+func newAuditLog(cfg *AuditConfig, log *zap.Logger) (*auditLog, error) {
+	a := &auditLog{
+		log:      log,
+		capacity: cfg.Capacity,
+		ring:     make([]*AuditRecord, cfg.Capacity),
+		byID:     make(map[string]*AuditRecord, cfg.Capacity),
+	}
+
+	if cfg.Path != "" {
+		f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit file: %w", err)
+		}
+		a.file = f
+		a.enc = json.NewEncoder(f)
+	}
+
+	return a, nil
+}
+
+// record stores a record, evicting the oldest one when the ring is full
+func (a *auditLog) record(rec *AuditRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if old := a.ring[a.next]; old != nil {
+		delete(a.byID, old.ID)
+	}
+	a.ring[a.next] = rec
+	a.byID[rec.ID] = rec
+	a.next = (a.next + 1) % a.capacity
+
+	if a.enc != nil {
+		if err := a.enc.Encode(rec); err != nil {
+			a.log.Error("failed to write audit record", zap.String("id", rec.ID), zap.Error(err))
+		}
+	}
+}
+
+// get returns a recent record by ID
+func (a *auditLog) get(id string) (*AuditRecord, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec, ok := a.byID[id]
+	return rec, ok
+}
+
+// close closes the file sink
+func (a *auditLog) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil {
+		_ = a.file.Close()
+		a.file = nil
+		a.enc = nil
+	}
+}
+
+// audit records the outcome of an execute request
+func (p *Plugin) audit(req *ExecuteRequest, resp *ExecuteResponse, status string) {
+	rec := &AuditRecord{
+		ID:         newRecordID(),
+		Time:       time.Now(),
+		RequestID:  req.RequestID,
+		Caller:     req.Caller,
+		Tag:        req.Tag,
+		ScriptHash: scriptHash(req.Code),
+		Script:     req.Script,
+		Code:       p.redactor.string(req.Code),
+		Input:      p.redactor.json(req.input),
+		TimeoutMs:  req.TimeoutMs,
+		Status:     status,
+		Result:     p.redactor.value(resp.Result),
+		Error:      p.redactor.string(resp.Error),
+		DurationMs: resp.DurationMs,
+		Attempts:   resp.Attempts,
+	}
+
+	rec.Redacted = rec.Code != req.Code || rec.Input != req.input
+
+	p.auditLog.record(rec)
+	resp.AuditID = rec.ID
+}
+
+// ReplayRequest re-executes an audited run
+type ReplayRequest struct {
+	AuditID string `json:"audit_id"`
+
+	// Run with side-effecting bindings (logging, metrics) enabled; mock mode is the default
+	Live bool `json:"live"`
+}
+
+// ReplayResponse compares the replayed run with the original one
+type ReplayResponse struct {
+	Original *AuditRecord     `json:"original"`
+	Replayed *ExecuteResponse `json:"replayed"`
+
+	// Status of the replayed run (success, error, timeout)
+	Status string `json:"status"`
+
+	// True when status, result and error match the original run
+	Identical bool `json:"identical"`
+
+	// Result differences (empty when results match)
+	Diff []ValueDiff `json:"diff,omitempty"`
+}
+
+// Replay re-executes an audited run with the same code, input and timeout and diffs the outcome
+func (r *rpc) Replay(req *ReplayRequest, resp *ReplayResponse) error {
+	if r.plugin.auditLog == nil {
+		return fmt.Errorf("audit log is not configured")
+	}
+
+	if !r.plugin.lifecycle.serving() {
+		return errNotReady
+	}
+
+	original, ok := r.plugin.auditLog.get(req.AuditID)
+	if !ok {
+		return fmt.Errorf("audit record %q not found (only the last %d records are kept)", req.AuditID, r.plugin.cfg.Audit.Capacity)
+	}
+
+	if original.Redacted {
+		return fmt.Errorf("audit record %q cannot be replayed, its code or input was redacted", req.AuditID)
+	}
+
+	timeout, _ := r.plugin.poolFor(original.Script).timeout(original.TimeoutMs)
+
+	exec := &execution{
+		requestID: "replay-" + original.ID,
+		script:    original.Script,
+		caller:    original.Caller,
+		tag:       original.Tag,
+		attempt:   1,
+		mock:      !req.Live,
+		input:     original.Input,
+	}
+	if original.Script != "" {
+		exec.version = original.ScriptHash
+	}
+
+	start := time.Now()
+	result, err := r.plugin.execute(context.Background(), exec, original.Code, timeout)
+
+	// Compare like with like: the original result was redacted when it was recorded
+	result = r.plugin.redactor.value(result)
+
+	replayed := &ExecuteResponse{
+		Result:     result,
+		DurationMs: time.Since(start).Milliseconds(),
+		RequestID:  exec.requestID,
+		Attempts:   1,
+	}
+	if err != nil {
+		replayed.Error = r.plugin.redactor.string(err.Error())
+	}
+
+	diff, err := diffValues(original.Result, result)
+	if err != nil {
+		return fmt.Errorf("failed to compare results: %w", err)
+	}
+
+	resp.Original = original
+	resp.Replayed = replayed
+	resp.Status = exec.status
+	resp.Diff = diff
+	resp.Identical = len(diff) == 0 && exec.status == original.Status && replayed.Error == original.Error
+
+	r.log.Debug("replayed audited execution",
+		zap.String("audit_id", original.ID),
+		zap.Bool("identical", resp.Identical),
+		zap.Bool("mock", exec.mock),
+	)
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package jsmachine
+
+import "testing"
+
+func TestReplayRestoresInput(t *testing.T) {
+	p := serveTestPlugin(t, &Config{
+		PoolSize:  1,
+		Audit:     &AuditConfig{},
+		Redaction: &RedactionConfig{Fields: []string{"password"}},
+	})
+	r := p.RPC().(*rpc)
+
+	tests := []struct {
+		name         string
+		input        string
+		wantRedacted bool
+	}{
+		{name: "input recorded", input: `{"price":21}`},
+		{name: "input redacted", input: `{"price":21,"password":"hunter2"}`, wantRedacted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map/reduce steps and chained scripts receive their input the same way
+			resp := execute(t, p, &ExecuteRequest{Code: `ctx.input.price * 2`, input: tt.input})
+			if resp.AuditID == "" {
+				t.Fatal("execution was not audited")
+			}
+
+			replay := &ReplayResponse{}
+			err := r.Replay(&ReplayRequest{AuditID: resp.AuditID}, replay)
+			if tt.wantRedacted {
+				if err == nil {
+					t.Error("replayed a record whose input was redacted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replay.Original.Input != tt.input {
+				t.Errorf("got recorded input %q, want %q", replay.Original.Input, tt.input)
+			}
+			if !replay.Identical {
+				t.Errorf("replay differs from the original run: %+v, diff %+v", replay.Replayed, replay.Diff)
+			}
+		})
+	}
+}
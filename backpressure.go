@@ -0,0 +1,34 @@
+package jsmachine
+
+import (
+	"time"
+)
+
+// durationSmoothing is the weight of a new sample in the moving average of execution durations (1/8)
+const durationSmoothing = 8
+
+// recordDuration updates the moving average of execution durations used for retry hints
+func (p *Plugin) recordDuration(d time.Duration) {
+	for {
+		old := p.avgDuration.Load()
+		next := int64(d)
+		if old > 0 {
+			next = old + (int64(d)-old)/durationSmoothing
+		}
+		if p.avgDuration.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// retryAfter estimates when a VM of pool frees up for a request that had queueDepth executions queued
+// Returns 0 when the request did not have to queue
+func (p *Plugin) retryAfter(pool *vmPool, queueDepth int) time.Duration {
+	if queueDepth == 0 {
+		return 0
+	}
+
+	// Every pool "round" serves pool.size queued executions
+	rounds := (queueDepth + pool.size - 1) / pool.size
+	return max(time.Duration(rounds)*time.Duration(p.avgDuration.Load()), time.Millisecond)
+}
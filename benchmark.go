@@ -0,0 +1,210 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Defaults and ceilings of benchmark runs
+	defaultBenchmarkIterations = 100
+	maxBenchmarkIterations     = 100000
+	maxBenchmarkConcurrency    = 64
+)
+
+// BenchmarkRequest selects the code to benchmark and the load to apply
+type BenchmarkRequest struct {
+	// JavaScript code or the name of a registered script (mutually exclusive)
+	Code   string `json:"code"`
+	Script string `json:"script,omitempty"`
+
+	// Number of executions (default: 100, maximum: 100000)
+	Iterations int `json:"iterations"`
+
+	// Executions running at the same time (default: 1, maximum: 64)
+	Concurrency int `json:"concurrency"`
+
+	// Timeout of each execution in milliseconds (0 = use default)
+	TimeoutMs int `json:"timeout_ms"`
+
+	// Run with side-effecting bindings (logging, metrics) enabled; mock mode is the default
+	Live bool `json:"live"`
+}
+
+// BenchmarkLatency summarizes execution latencies in milliseconds (including time queued for a VM)
+type BenchmarkLatency struct {
+	MinMs  float64 `json:"min_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// BenchmarkResponse reports the outcome of a benchmark run
+type BenchmarkResponse struct {
+	Iterations  int `json:"iterations"`
+	Concurrency int `json:"concurrency"`
+
+	// Executions by outcome
+	Succeeded int `json:"succeeded"`
+	Errors    int `json:"errors"`
+	Timeouts  int `json:"timeouts"`
+
+	// First error encountered, to tell broken code from an undersized pool
+	FirstError string `json:"first_error,omitempty"`
+
+	// Wall-clock duration of the run and completed executions per second
+	DurationMs int64   `json:"duration_ms"`
+	Throughput float64 `json:"throughput"`
+
+	Latency BenchmarkLatency `json:"latency"`
+}
+
+// Benchmark runs code repeatedly on the plugin's pools and reports latency percentiles and error counts
+// Executions compete with regular traffic for VMs and are counted in the execution metrics
+func (r *rpc) Benchmark(req *BenchmarkRequest, resp *BenchmarkResponse) error {
+	if !r.plugin.lifecycle.serving() {
+		return errNotReady
+	}
+	if req.Iterations == 0 {
+		req.Iterations = defaultBenchmarkIterations
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+	if req.Iterations < 1 || req.Iterations > maxBenchmarkIterations {
+		return fmt.Errorf("iterations must be between 1 and %d, got %d", maxBenchmarkIterations, req.Iterations)
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxBenchmarkConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d, got %d", maxBenchmarkConcurrency, req.Concurrency)
+	}
+
+	// Apply the same checks and script resolution as Execute
+	exreq := &ExecuteRequest{Code: req.Code, Script: req.Script, TimeoutMs: req.TimeoutMs}
+	if exreq.Code != "" {
+		exreq.Code = normalizeCode(exreq.Code, r.plugin.cfg.StrictEncoding)
+	}
+	if err := r.plugin.validateRequest(exreq); err != nil {
+		return err
+	}
+	if exreq.Script != "" {
+		if err := r.plugin.resolveScript(exreq); err != nil {
+			return err
+		}
+	}
+	timeout := r.plugin.requestTimeout(r.plugin.poolFor(exreq.Script), exreq)
+
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, req.Iterations)
+		wg        sync.WaitGroup
+	)
+	jobs := make(chan int)
+
+	start := time.Now()
+	for i := 0; i < req.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				exec := &execution{script: exreq.Script, attempt: 1, mock: !req.Live, program: exreq.program}
+				if exreq.Script != "" {
+					exec.version = scriptHash(exreq.Code)
+				}
+
+				began := time.Now()
+				_, err := r.plugin.execute(context.Background(), exec, exreq.Code, timeout)
+				elapsed := time.Since(began)
+
+				mu.Lock()
+				latencies = append(latencies, elapsed)
+				switch {
+				case err == nil:
+					resp.Succeeded++
+				case exec.status == "timeout":
+					resp.Timeouts++
+				default:
+					resp.Errors++
+				}
+				if err != nil && resp.FirstError == "" {
+					resp.FirstError = err.Error()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < req.Iterations; i++ {
+		select {
+		case jobs <- i:
+		case <-r.plugin.stopCh:
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	duration := time.Since(start)
+
+	resp.Iterations = len(latencies)
+	resp.Concurrency = req.Concurrency
+	resp.DurationMs = duration.Milliseconds()
+	if duration > 0 {
+		resp.Throughput = float64(len(latencies)) / duration.Seconds()
+	}
+	resp.Latency = summarizeLatencies(latencies)
+
+	r.log.Debug("benchmark completed",
+		zap.String("script", req.Script),
+		zap.Int("iterations", resp.Iterations),
+		zap.Int("concurrency", resp.Concurrency),
+		zap.Int("errors", resp.Errors+resp.Timeouts),
+		zap.Duration("duration", duration),
+	)
+
+	return nil
+}
+
+// summarizeLatencies computes the latency summary (sorts latencies in place)
+func summarizeLatencies(latencies []time.Duration) BenchmarkLatency {
+	if len(latencies) == 0 {
+		return BenchmarkLatency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+
+	return BenchmarkLatency{
+		MinMs:  durationMs(latencies[0]),
+		MeanMs: durationMs(total / time.Duration(len(latencies))),
+		P50Ms:  durationMs(percentile(latencies, 50)),
+		P90Ms:  durationMs(percentile(latencies, 90)),
+		P95Ms:  durationMs(percentile(latencies, 95)),
+		P99Ms:  durationMs(percentile(latencies, 99)),
+		MaxMs:  durationMs(latencies[len(latencies)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
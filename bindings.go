@@ -3,6 +3,7 @@ package jsmachine
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robertkrimen/otto"
@@ -11,42 +12,220 @@ import (
 
 // Bindings represents all Go functions exposed to JavaScript
 type Bindings struct {
-	log     *LogBinding
-	metrics *MetricsBinding
+	log      *LogBinding
+	metrics  *MetricsBinding
+	progress *ProgressBinding
+	lock     *LockBinding
+	flags    *FlagsBinding
+	ua       *UABinding
+	net      *NetBinding
+	fetch    *FetchBinding
+	signing  *SigningBinding
+	mask     *MaskBinding
+	oauth    *OAuthBinding
+	mail     *MailBinding
+	webhook  *WebhookBinding
+	services *DiscoveryBinding
+	grpc     *GRPCBinding
+	graphql  *GraphQLBinding
+	parallel *ParallelBinding
+	mqtt     *MQTTBinding
+	prom     *PromBinding
+	intl     *IntlBinding
+	decimal  *DecimalBinding
+	strings  *StringsBinding
+	ndjson   *NDJSONBinding
+	query    *QueryBinding
+	patch    *JSONPatchBinding
+	faker    *FakerBinding
+	pdf      *PDFBinding
+	qrcode   *QRCodeBinding
+	validate *ValidateBinding
+	id       *IDBinding
+	semver   *SemverBinding
+	money    *MoneyBinding
+	ref      *RefBinding
+	i18n     *I18nBinding
+	template *TemplateBinding
+	require  *RequireBinding
+	cache    *CacheBinding
+	limit    *RateLimitBinding
+	ctx      *CtxBinding
+	control  *ControlBinding
+	emit     *EmitBinding
 }
 
 // newBindings creates a new bindings instance
 func newBindings(logger *zap.Logger, plugin *Plugin) *Bindings {
 	return &Bindings{
-		log:     newLogBinding(logger),
-		metrics: newMetricsBinding(plugin),
+		log:      newLogBinding(logger, plugin),
+		metrics:  newMetricsBinding(plugin),
+		progress: newProgressBinding(plugin),
+		lock:     newLockBinding(plugin),
+		flags:    newFlagsBinding(plugin),
+		ua:       newUABinding(),
+		net:      newNetBinding(plugin),
+		fetch:    newFetchBinding(plugin),
+		signing:  newSigningBinding(plugin),
+		mask:     newMaskBinding(plugin),
+		oauth:    newOAuthBinding(plugin),
+		mail:     newMailBinding(plugin),
+		webhook:  newWebhookBinding(plugin),
+		services: newDiscoveryBinding(plugin),
+		grpc:     newGRPCBinding(plugin),
+		graphql:  newGraphQLBinding(plugin),
+		parallel: newParallelBinding(plugin),
+		mqtt:     newMQTTBinding(plugin),
+		prom:     newPromBinding(plugin),
+		intl:     newIntlBinding(),
+		decimal:  newDecimalBinding(),
+		strings:  newStringsBinding(),
+		ndjson:   newNDJSONBinding(),
+		query:    newQueryBinding(),
+		patch:    newJSONPatchBinding(),
+		faker:    newFakerBinding(plugin),
+		pdf:      newPDFBinding(plugin),
+		qrcode:   newQRCodeBinding(),
+		validate: newValidateBinding(),
+		id:       newIDBinding(plugin),
+		semver:   newSemverBinding(),
+		money:    newMoneyBinding(plugin),
+		ref:      newRefBinding(),
+		i18n:     newI18nBinding(plugin),
+		template: newTemplateBinding(),
+		require:  newRequireBinding(plugin),
+		cache:    newCacheBinding(plugin),
+		limit:    newRateLimitBinding(plugin),
+		ctx:      newCtxBinding(plugin),
+		control:  newControlBinding(plugin),
+		emit:     newEmitBinding(plugin),
 	}
 }
 
-// injectIntoVM injects all bindings into the Otto VM
-func (b *Bindings) injectIntoVM(vm *otto.Otto) error {
-	// Inject log binding
-	if err := b.log.inject(vm); err != nil {
-		return fmt.Errorf("failed to inject log binding: %w", err)
+// bindingInjector injects one global object into a VM
+type bindingInjector struct {
+	name   string
+	inject func(vm *otto.Otto) error
+}
+
+// injectors lists the global objects in bindingNames order
+func (b *Bindings) injectors() []bindingInjector {
+	return []bindingInjector{
+		{"log", b.log.inject},
+		{"metrics", b.metrics.inject},
+		{"progress", b.progress.inject},
+		{"lock", b.lock.inject},
+		{"flags", b.flags.inject},
+		{"ua", b.ua.inject},
+		{"net", b.net.inject},
+		{"fetch", b.fetch.inject},
+		{"signing", b.signing.inject},
+		{"mask", b.mask.inject},
+		{"oauth", b.oauth.inject},
+		{"mail", b.mail.inject},
+		{"webhook", b.webhook.inject},
+		{"discovery", b.services.inject},
+		{"grpc", b.grpc.inject},
+		{"graphql", b.graphql.inject},
+		{"parallel", b.parallel.inject},
+		{"mqtt", b.mqtt.inject},
+		{"prom", b.prom.inject},
+		{"intl", b.intl.inject},
+		{"decimal", b.decimal.inject},
+		{"strings", b.strings.inject},
+		{"ndjson", b.ndjson.inject},
+		{"jsonpath", b.query.injectJSONPath},
+		{"jmespath", b.query.injectJMESPath},
+		{"jsondiff", b.patch.injectDiff},
+		{"jsonpatch", b.patch.injectPatch},
+		{"faker", b.faker.inject},
+		{"pdf", b.pdf.inject},
+		{"qrcode", b.qrcode.inject},
+		{"validate", b.validate.inject},
+		{"id", b.id.inject},
+		{"semver", b.semver.inject},
+		{"money", b.money.inject},
+		{"ref", b.ref.inject},
+		{"i18n", b.i18n.inject},
+		{"template", b.template.inject},
+		{"helpers", b.template.injectHelpers},
+		{"require", b.require.inject},
+		{"cache", b.cache.inject},
+		{"ratelimit", b.limit.inject},
+		{"ctx", b.ctx.inject},
+		{"control", b.control.inject},
+		{"emit", b.emit.inject},
 	}
+}
 
-	// Inject metrics binding
-	if err := b.metrics.inject(vm); err != nil {
-		return fmt.Errorf("failed to inject metrics binding: %w", err)
+// injectIntoVM injects the bindings accepted by enabled into the Otto VM
+func (b *Bindings) injectIntoVM(vm *otto.Otto, enabled func(name string) bool) error {
+	for _, binding := range b.injectors() {
+		if !enabled(binding.name) {
+			continue
+		}
+		if err := binding.inject(vm); err != nil {
+			return fmt.Errorf("failed to inject %s binding: %w", binding.name, err)
+		}
 	}
 
 	return nil
 }
 
+// bindingNames are the global objects injected by injectIntoVM
+var bindingNames = []string{"log", "metrics", "progress", "lock", "flags", "ua", "net", "fetch", "signing", "mask", "oauth", "mail", "webhook", "discovery", "grpc", "graphql", "parallel", "mqtt", "prom", "intl", "decimal", "strings", "ndjson", "jsonpath", "jmespath", "jsondiff", "jsonpatch", "faker", "pdf", "qrcode", "validate", "id", "semver", "money", "ref", "i18n", "template", "helpers", "require", "cache", "ratelimit", "ctx", "control", "emit"}
+
+// bindingNamespace is the global holding the versioned binding namespaces, e.g. rr.v1.log
+const bindingNamespace = "rr"
+
+// bindingAPIVersions are the binding API versions served, oldest first; every binding exists in each of them
+// A breaking change to binding signatures adds a version holding the new API, while the earlier namespaces and the
+// legacy globals (aliases of v1) keep serving the API deployed scripts were written against
+var bindingAPIVersions = []string{"v1"}
+
+// namespaceScript defines rr.<version> holding the injected bindings
+const namespaceScript = `(function (names, version) {
+	var global = Function("return this")();
+	var rr = global.rr || {};
+	rr[version] = {};
+	for (var i = 0; i < names.length; i++) {
+		if (names[i] in global) {
+			rr[version][names[i]] = global[names[i]];
+		}
+	}
+	global.rr = rr;
+})`
+
+// injectNamespace exposes the bindings injected into vm under rr.v1 as well; the legacy globals stay defined
+func injectNamespace(vm *otto.Otto) error {
+	fn, err := vm.Run(namespaceScript)
+	if err != nil {
+		return err
+	}
+	_, err = fn.Call(otto.UndefinedValue(), bindingNames, bindingAPIVersions[0])
+	return err
+}
+
+// capabilityVersions reports the API versions every binding is available in
+func capabilityVersions() map[string][]string {
+	versions := make(map[string][]string, len(bindingNames))
+	for _, name := range bindingNames {
+		versions[name] = bindingAPIVersions
+	}
+	return versions
+}
+
 // LogBinding provides logging functions to JavaScript
 type LogBinding struct {
 	logger *zap.Logger
+	plugin *Plugin
 }
 
 // newLogBinding creates a new log binding
-func newLogBinding(logger *zap.Logger) *LogBinding {
+func newLogBinding(logger *zap.Logger, plugin *Plugin) *LogBinding {
 	return &LogBinding{
 		logger: logger,
+		plugin: plugin,
 	}
 }
 
@@ -82,45 +261,109 @@ func (l *LogBinding) inject(vm *otto.Otto) error {
 
 // info logs an info message
 func (l *LogBinding) info(call otto.FunctionCall) otto.Value {
+	if l.plugin.mocked(call.Otto) {
+		return otto.UndefinedValue()
+	}
+
 	message := l.getMessage(call)
 	fields := l.getFields(call)
 	l.logger.Info(message, fields...)
+	l.forward(call, "info", message)
 	return otto.UndefinedValue()
 }
 
 // error logs an error message
 func (l *LogBinding) error(call otto.FunctionCall) otto.Value {
+	if l.plugin.mocked(call.Otto) {
+		return otto.UndefinedValue()
+	}
+
 	message := l.getMessage(call)
 	fields := l.getFields(call)
 	l.logger.Error(message, fields...)
+	l.forward(call, "error", message)
 	return otto.UndefinedValue()
 }
 
 // warn logs a warning message
 func (l *LogBinding) warn(call otto.FunctionCall) otto.Value {
+	if l.plugin.mocked(call.Otto) {
+		return otto.UndefinedValue()
+	}
+
 	message := l.getMessage(call)
 	fields := l.getFields(call)
 	l.logger.Warn(message, fields...)
+	l.forward(call, "warn", message)
 	return otto.UndefinedValue()
 }
 
 // debug logs a debug message
 func (l *LogBinding) debug(call otto.FunctionCall) otto.Value {
+	if l.plugin.mocked(call.Otto) {
+		return otto.UndefinedValue()
+	}
+
 	message := l.getMessage(call)
 	fields := l.getFields(call)
 	l.logger.Debug(message, fields...)
+	l.forward(call, "debug", message)
 	return otto.UndefinedValue()
 }
 
-// getMessage extracts the message from the function call
+// forward sends the log entry to stream subscribers and the OTLP exporter
+func (l *LogBinding) forward(call otto.FunctionCall, level, message string) {
+	exec := l.plugin.executionFor(call.Otto)
+	if exec == nil {
+		return
+	}
+
+	streaming := l.plugin.streams.hasSubscribers(exec.requestID)
+	if !streaming && l.plugin.otlp == nil {
+		return
+	}
+
+	var fields map[string]interface{}
+	if len(call.ArgumentList) > 1 && call.Argument(1).IsObject() {
+		if exported, err := call.Argument(1).Export(); err == nil {
+			fields, _ = exported.(map[string]interface{})
+			fields = l.plugin.redactor.object(fields)
+		}
+	}
+
+	if streaming {
+		l.plugin.streams.publish(streamEvent{
+			Type:      streamEventLog,
+			RequestID: exec.requestID,
+			Level:     level,
+			Message:   message,
+			Fields:    fields,
+		})
+	}
+
+	if l.plugin.otlp != nil {
+		l.plugin.otlp.emit(otlpRecord{
+			time:      time.Now(),
+			level:     level,
+			message:   message,
+			fields:    fields,
+			requestID: exec.requestID,
+			tag:       exec.tag,
+			traceID:   exec.traceID,
+			spanID:    exec.spanID,
+		})
+	}
+}
+
+// getMessage extracts the (redacted) message from the function call
 func (l *LogBinding) getMessage(call otto.FunctionCall) string {
 	if len(call.ArgumentList) == 0 {
 		return ""
 	}
-	return call.Argument(0).String()
+	return l.plugin.redactor.string(call.Argument(0).String())
 }
 
-// getFields extracts structured fields from the function call
+// getFields extracts structured (redacted) fields from the function call
 func (l *LogBinding) getFields(call otto.FunctionCall) []zap.Field {
 	if len(call.ArgumentList) < 2 {
 		return nil
@@ -148,7 +391,11 @@ func (l *LogBinding) getFields(call otto.FunctionCall) []zap.Field {
 			continue
 		}
 
-		fields = append(fields, zap.Any(key, exported))
+		if l.plugin.redactor.field(key) {
+			fields = append(fields, zap.String(key, redactedPlaceholder))
+			continue
+		}
+		fields = append(fields, zap.Any(key, l.plugin.redactor.value(exported)))
 	}
 
 	return fields
@@ -159,17 +406,18 @@ func (l *LogBinding) getFields(call otto.FunctionCall) []zap.Field {
 // JavaScript code can only manipulate existing metrics through the metrics plugin's collectors sync.Map
 type MetricsBinding struct {
 	plugin *Plugin
-	mu     sync.RWMutex
 
-	// Cache of collectors loaded from metrics plugin
-	// These are fetched from the metrics plugin's collectors sync.Map
-	cachedCollectors sync.Map // map[string]prometheus.Collector
+	// Collectors looked up in the metrics plugin, valid while its generation is unchanged
+	mu               sync.RWMutex
+	cachedCollectors map[string]prometheus.Collector
+	generation       uint64
 }
 
 // newMetricsBinding creates a new metrics binding
 func newMetricsBinding(plugin *Plugin) *MetricsBinding {
 	return &MetricsBinding{
-		plugin: plugin,
+		plugin:           plugin,
+		cachedCollectors: make(map[string]prometheus.Collector),
 	}
 }
 
@@ -198,39 +446,52 @@ func (m *MetricsBinding) inject(vm *otto.Otto) error {
 	return vm.Set("metrics", metricsObj)
 }
 
-// getCollector retrieves a collector from the metrics plugin
-// This follows the same pattern as metrics plugin's rpc.go: c, exist := r.p.collectors.Load(m.Name)
+// getCollector retrieves a collector from the metrics plugin, caching it until the plugin's collectors change
 func (m *MetricsBinding) getCollector(name string) (prometheus.Collector, bool) {
-	// Check cache first
-	if cached, exists := m.cachedCollectors.Load(name); exists {
-		return cached.(prometheus.Collector), true
-	}
-
-	// Get from metrics plugin
-	if m.plugin.metricsPlugin == nil {
+	registry := m.plugin.metricsPlugin
+	if registry == nil {
 		m.plugin.log.Warn("metrics plugin not available", zap.String("metric", name))
 		return nil, false
 	}
+	generation := registry.Generation()
+
+	// Check cache first
+	m.mu.RLock()
+	collector, exists := m.cachedCollectors[name]
+	current := m.generation == generation
+	m.mu.RUnlock()
+	if exists && current {
+		return collector, true
+	}
+
+	collector, exists = registry.Collector(name)
 
-	// Load from metrics plugin's collectors sync.Map
-	collector, exists := m.plugin.metricsPlugin.collectors.Load(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Collectors cached under an older generation may have been unregistered
+	if m.generation != generation {
+		clear(m.cachedCollectors)
+		m.generation = generation
+	}
 	if !exists {
 		return nil, false
 	}
+	m.cachedCollectors[name] = collector
 
-	// Extract the actual collector from the wrapper
-	col := collector.(*metricsCollector)
-	actualCollector := col.col
-
-	// Cache it for future use
-	m.cachedCollectors.Store(name, actualCollector)
+	return collector, true
+}
 
-	return actualCollector, true
+// invalidate drops all cached collectors
+func (m *MetricsBinding) invalidate() {
+	m.mu.Lock()
+	clear(m.cachedCollectors)
+	m.mu.Unlock()
 }
 
 // add adds value to a counter or gauge (follows metrics plugin rpc.go pattern)
 func (m *MetricsBinding) add(call otto.FunctionCall) otto.Value {
-	if len(call.ArgumentList) < 2 {
+	if len(call.ArgumentList) < 2 || m.plugin.mocked(call.Otto) {
 		return otto.UndefinedValue()
 	}
 
@@ -304,7 +565,7 @@ func (m *MetricsBinding) add(call otto.FunctionCall) otto.Value {
 
 // set sets a gauge value (follows metrics plugin rpc.go pattern)
 func (m *MetricsBinding) set(call otto.FunctionCall) otto.Value {
-	if len(call.ArgumentList) < 2 {
+	if len(call.ArgumentList) < 2 || m.plugin.mocked(call.Otto) {
 		return otto.UndefinedValue()
 	}
 
@@ -359,7 +620,7 @@ func (m *MetricsBinding) set(call otto.FunctionCall) otto.Value {
 
 // observe records a histogram observation (follows metrics plugin rpc.go pattern)
 func (m *MetricsBinding) observe(call otto.FunctionCall) otto.Value {
-	if len(call.ArgumentList) < 2 {
+	if len(call.ArgumentList) < 2 || m.plugin.mocked(call.Otto) {
 		return otto.UndefinedValue()
 	}
 
@@ -463,10 +724,3 @@ func (m *MetricsBinding) extractLabelValues(call otto.FunctionCall, argIndex int
 
 	return nil
 }
-
-// metricsCollector is the internal collector wrapper used by metrics plugin
-// This mirrors the structure in metrics/plugin.go
-type metricsCollector struct {
-	col        prometheus.Collector
-	registered bool
-}
@@ -0,0 +1,318 @@
+package jsmachine
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/api/v4/plugins/v1/kv"
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// Cache key namespacing (js.cache.namespace)
+const (
+	cacheNamespaceScript = "script"
+	cacheNamespaceShared = "shared"
+
+	// Prefixes of the keys of registered scripts (followed by the script name) and of ad-hoc code
+	cacheScriptPrefix = "script:"
+	cacheAdHocPrefix  = "code:"
+)
+
+// cacheStore holds the JSON values of the cache binding
+type cacheStore interface {
+	// get returns the value of key unless it is missing or expired
+	get(key string) (string, bool, error)
+	// set stores value under key for ttl
+	set(key, value string, ttl time.Duration) error
+	// delete removes key, reporting whether it was cached
+	delete(key string) (bool, error)
+}
+
+// cacheEntry is a cached value, stored as JSON so VMs never share objects
+type cacheEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// memoryCache is a size-bounded LRU of JSON values with per-entry expiry
+type memoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+	maxEntries int
+}
+
+// newMemoryCache creates an empty cache holding at most maxEntries
+func newMemoryCache(maxEntries int) *memoryCache {
+	return &memoryCache{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// get implements cacheStore
+func (c *memoryCache) get(key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return "", false, nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+// set implements cacheStore, evicting the least recently used entries beyond maxEntries
+func (c *memoryCache) set(key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// delete implements cacheStore
+func (c *memoryCache) delete(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok {
+		c.remove(elem)
+	}
+	return ok, nil
+}
+
+// clear removes all entries
+func (c *memoryCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.order.Init()
+}
+
+// remove drops an entry (c.mu held)
+func (c *memoryCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// kvCache keeps values in a storage of the kv plugin under <prefix><key>, shared by the nodes using it
+// Entries expire through the storage's TTLs; there is no LRU bound
+type kvCache struct {
+	storage kv.Storage
+	prefix  string
+}
+
+// get implements cacheStore
+func (c *kvCache) get(key string) (string, bool, error) {
+	data, err := c.storage.Get(c.prefix + key)
+	if err != nil || data == nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// set implements cacheStore
+func (c *kvCache) set(key, value string, ttl time.Duration) error {
+	return c.storage.Set(&kvItem{key: c.prefix + key, value: []byte(value), timeout: time.Now().Add(ttl).Format(time.RFC3339)})
+}
+
+// delete implements cacheStore
+func (c *kvCache) delete(key string) (bool, error) {
+	found, err := c.storage.Has(c.prefix + key)
+	if err != nil || !found[c.prefix+key] {
+		return false, err
+	}
+	return true, c.storage.Delete(c.prefix + key)
+}
+
+// CacheBinding exposes a cache shared by all executions: in process memory of the node, or in a storage of the kv
+// plugin (js.cache.storage), shared by the nodes using it
+type CacheBinding struct {
+	plugin *Plugin
+	memory *memoryCache
+	store  cacheStore // memory until Serve opens the kv storage
+}
+
+// newCacheBinding creates a new cache binding
+func newCacheBinding(plugin *Plugin) *CacheBinding {
+	memory := newMemoryCache(plugin.cfg.Cache.MaxEntries)
+	return &CacheBinding{
+		plugin: plugin,
+		memory: memory,
+		store:  memory,
+	}
+}
+
+// inject injects the cache object into the VM
+func (c *CacheBinding) inject(vm *otto.Otto) error {
+	cacheObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// cache.get(key) - returns the cached value or undefined
+	if err := cacheObj.Set("get", c.get); err != nil {
+		return err
+	}
+
+	// cache.set(key, value, ttlMs) - stores a JSON-serializable value
+	if err := cacheObj.Set("set", c.set); err != nil {
+		return err
+	}
+
+	// cache.delete(key) - returns true if the key was cached
+	if err := cacheObj.Set("delete", c.delete); err != nil {
+		return err
+	}
+
+	// cache.shared.get/set/delete - the same operations on keys that are not namespaced
+	sharedObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+	if err := sharedObj.Set("get", c.sharedGet); err != nil {
+		return err
+	}
+	if err := sharedObj.Set("set", c.sharedSet); err != nil {
+		return err
+	}
+	if err := sharedObj.Set("delete", c.sharedDelete); err != nil {
+		return err
+	}
+	if err := cacheObj.Set("shared", sharedObj); err != nil {
+		return err
+	}
+
+	return vm.Set("cache", cacheObj)
+}
+
+// get returns a copy of the cached value in the execution's namespace
+func (c *CacheBinding) get(call otto.FunctionCall) otto.Value {
+	return c.read(call, c.plugin.cacheKey(call.Otto, call.Argument(0).String()))
+}
+
+// sharedGet returns a copy of the cached value of a key that is not namespaced
+func (c *CacheBinding) sharedGet(call otto.FunctionCall) otto.Value {
+	return c.read(call, call.Argument(0).String())
+}
+
+// read returns a copy of the value cached for key, undefined when missing or expired, or when the kv storage fails
+func (c *CacheBinding) read(call otto.FunctionCall, key string) otto.Value {
+	value, ok, err := c.store.get(key)
+	if err != nil {
+		c.plugin.log.Warn("cache read failed", zap.String("key", key), zap.Error(err))
+		return otto.UndefinedValue()
+	}
+	if !ok {
+		return otto.UndefinedValue()
+	}
+
+	result, err := call.Otto.Call("JSON.parse", nil, value)
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	return result
+}
+
+// set stores a value in the execution's namespace
+func (c *CacheBinding) set(call otto.FunctionCall) otto.Value {
+	return c.write(call, "cache.set", c.plugin.cacheKey(call.Otto, call.Argument(0).String()))
+}
+
+// sharedSet stores a value under a key that is not namespaced
+func (c *CacheBinding) sharedSet(call otto.FunctionCall) otto.Value {
+	return c.write(call, "cache.shared.set", call.Argument(0).String())
+}
+
+// write stores a value under key for ttlMs (default: js.cache.default_ttl_ms)
+// Throws a TypeError for values that are undefined, not JSON-serializable or too large, and an Error when the kv
+// storage fails
+// In mock mode nothing is stored and write returns false
+func (c *CacheBinding) write(call otto.FunctionCall, name, key string) otto.Value {
+	cfg := c.plugin.cfg.Cache
+
+	ttl := time.Duration(cfg.DefaultTTLMs) * time.Millisecond
+	if ttlArg := call.Argument(2); !ttlArg.IsUndefined() {
+		ms, err := ttlArg.ToInteger()
+		if err != nil || ms < 1 {
+			panic(call.Otto.MakeTypeError(name + ": ttlMs must be a positive number"))
+		}
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+
+	valueArg := call.Argument(1)
+	if valueArg.IsUndefined() {
+		panic(call.Otto.MakeTypeError(name + ": value must not be undefined"))
+	}
+	exported, err := valueArg.Export()
+	if err != nil {
+		panic(call.Otto.MakeTypeError(name + ": " + err.Error()))
+	}
+	encoded, err := json.Marshal(exported)
+	if err != nil {
+		panic(call.Otto.MakeTypeError(name + ": value is not JSON-serializable: " + err.Error()))
+	}
+	if len(encoded) > cfg.MaxValueBytes {
+		panic(call.Otto.MakeTypeError(name + ": value exceeds js.cache.max_value_bytes"))
+	}
+
+	if c.plugin.mocked(call.Otto) {
+		return otto.FalseValue()
+	}
+
+	if err := c.store.set(key, string(encoded), ttl); err != nil {
+		panic(call.Otto.MakeCustomError("Error", name+": "+err.Error()))
+	}
+	return otto.TrueValue()
+}
+
+// delete removes a key from the execution's namespace
+func (c *CacheBinding) delete(call otto.FunctionCall) otto.Value {
+	return c.remove(call, "cache.delete", c.plugin.cacheKey(call.Otto, call.Argument(0).String()))
+}
+
+// sharedDelete removes a key that is not namespaced
+func (c *CacheBinding) sharedDelete(call otto.FunctionCall) otto.Value {
+	return c.remove(call, "cache.shared.delete", call.Argument(0).String())
+}
+
+// remove removes key; in mock mode nothing is removed and remove returns false
+func (c *CacheBinding) remove(call otto.FunctionCall, name, key string) otto.Value {
+	if c.plugin.mocked(call.Otto) {
+		return otto.FalseValue()
+	}
+
+	deleted, err := c.store.delete(key)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", name+": "+err.Error()))
+	}
+	if deleted {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
@@ -0,0 +1,56 @@
+package jsmachine
+
+import (
+	"github.com/robertkrimen/otto"
+)
+
+// ControlBinding lets scripts publish intermediate results to callers
+type ControlBinding struct {
+	plugin *Plugin
+}
+
+// newControlBinding creates a new control binding
+func newControlBinding(plugin *Plugin) *ControlBinding {
+	return &ControlBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the control object into the VM
+func (c *ControlBinding) inject(vm *otto.Otto) error {
+	controlObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// control.partial(result)
+	if err := controlObj.Set("partial", c.partial); err != nil {
+		return err
+	}
+
+	return vm.Set("control", controlObj)
+}
+
+// partial publishes an intermediate result, retrievable with js.GetPartial and streamed to subscribers
+// It is a no-op for executions without a request ID
+func (c *ControlBinding) partial(call otto.FunctionCall) otto.Value {
+	exec := c.plugin.executionFor(call.Otto)
+	if exec == nil || exec.requestID == "" {
+		return otto.UndefinedValue()
+	}
+
+	result, err := call.Argument(0).Export()
+	if err != nil {
+		panic(call.Otto.MakeTypeError("control.partial: " + err.Error()))
+	}
+
+	partial := c.plugin.partials.publish(exec, result)
+	c.plugin.streams.publish(streamEvent{
+		Type:      streamEventPartial,
+		RequestID: exec.requestID,
+		Result:    partial.Result,
+		Sequence:  partial.Sequence,
+	})
+
+	return otto.UndefinedValue()
+}
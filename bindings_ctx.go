@@ -0,0 +1,162 @@
+package jsmachine
+
+import (
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// CtxBinding exposes metadata of the running execution to JavaScript as the read-only ctx global
+type CtxBinding struct {
+	plugin *Plugin
+}
+
+// newCtxBinding creates a new ctx binding
+func newCtxBinding(plugin *Plugin) *CtxBinding {
+	return &CtxBinding{
+		plugin: plugin,
+	}
+}
+
+// ctxProperty is a ctx field read from the execution running on the VM
+type ctxProperty struct {
+	name string
+	get  func(exec *execution) interface{}
+}
+
+// ctxProperties are the fields of ctx (zero values outside an execution)
+var ctxProperties = []ctxProperty{
+	{"requestId", func(exec *execution) interface{} { return exec.requestID }},
+	{"script", func(exec *execution) interface{} { return exec.script }},
+	{"scriptVersion", func(exec *execution) interface{} { return exec.version }},
+	{"caller", func(exec *execution) interface{} { return exec.caller }},
+	{"attempt", func(exec *execution) interface{} { return exec.attempt }},
+	{"remainingMs", func(exec *execution) interface{} { return exec.remaining().Milliseconds() }},
+}
+
+// ctxDefine defines an enumerable accessor property on obj
+const ctxDefine = `(function (obj, name, get) { Object.defineProperty(obj, name, {get: get, enumerable: true}); })`
+
+// ctxSeal freezes ctx and defines it as a non-writable global
+const ctxSeal = `(function (ctx) { Object.freeze(ctx); Object.defineProperty(this, 'ctx', {value: ctx}); })`
+
+// inject injects the ctx object into the VM; properties are evaluated on access, so one object serves every execution
+func (c *CtxBinding) inject(vm *otto.Otto) error {
+	ctxObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	define, err := vm.Run(ctxDefine)
+	if err != nil {
+		return err
+	}
+
+	// ctx.requestId, ctx.script, ctx.scriptVersion, ctx.caller, ctx.attempt, ctx.remainingMs
+	for _, prop := range ctxProperties {
+		if _, err := define.Call(otto.UndefinedValue(), ctxObj, prop.name, c.getter(prop)); err != nil {
+			return err
+		}
+	}
+
+	// ctx.input
+	if _, err := define.Call(otto.UndefinedValue(), ctxObj, "input", c.input); err != nil {
+		return err
+	}
+
+	// ctx.aborted()
+	if err := ctxObj.Set("aborted", c.aborted); err != nil {
+		return err
+	}
+
+	// ctx.onAbort(fn)
+	if err := ctxObj.Set("onAbort", c.onAbort); err != nil {
+		return err
+	}
+
+	seal, err := vm.Run(ctxSeal)
+	if err != nil {
+		return err
+	}
+	_, err = seal.Call(otto.UndefinedValue(), ctxObj)
+	return err
+}
+
+// getter returns the accessor function of a ctx property
+func (c *CtxBinding) getter(prop ctxProperty) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		exec := c.plugin.executionFor(call.Otto)
+		if exec == nil {
+			exec = &execution{}
+		}
+
+		value, err := call.Otto.ToValue(prop.get(exec))
+		if err != nil {
+			return otto.UndefinedValue()
+		}
+		return value
+	}
+}
+
+// input returns the execution's input document, parsed into a JavaScript value once per execution so scripts
+// see plain arrays and objects and repeated reads return the same value
+func (c *CtxBinding) input(call otto.FunctionCall) otto.Value {
+	exec := c.plugin.executionFor(call.Otto)
+	if exec == nil || exec.input == "" {
+		return otto.UndefinedValue()
+	}
+	if exec.inputValue == nil {
+		value, err := call.Otto.Call("JSON.parse", nil, exec.input)
+		if err != nil {
+			panic(call.Otto.MakeTypeError("ctx.input: " + err.Error()))
+		}
+		exec.inputValue = &value
+	}
+	return *exec.inputValue
+}
+
+// aborted reports whether the execution timed out or was cancelled
+func (c *CtxBinding) aborted(call otto.FunctionCall) otto.Value {
+	exec := c.plugin.executionFor(call.Otto)
+	if exec != nil && exec.isAborted() {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
+
+// onAbort registers a function called when the execution times out or is cancelled
+func (c *CtxBinding) onAbort(call otto.FunctionCall) otto.Value {
+	handler := call.Argument(0)
+	if !handler.IsFunction() {
+		panic(call.Otto.MakeTypeError("ctx.onAbort: handler must be a function"))
+	}
+
+	if exec := c.plugin.executionFor(call.Otto); exec != nil {
+		exec.abortHandlers = append(exec.abortHandlers, handler)
+	}
+	return otto.UndefinedValue()
+}
+
+// runAbortHandlers calls the execution's onAbort handlers on its VM; the first one returning
+// a value other than undefined ends the script with that value as a partial result
+func (c *CtxBinding) runAbortHandlers(exec *execution) {
+	for _, handler := range exec.abortHandlers {
+		value, err := handler.Call(otto.UndefinedValue())
+		if err != nil {
+			c.plugin.log.Debug("onAbort handler failed", zap.String("request_id", exec.requestID), zap.Error(err))
+			continue
+		}
+		if !value.IsUndefined() {
+			panic(abortResult{value: value})
+		}
+	}
+}
+
+// remaining returns the time left until the execution's deadline (0 when unknown or passed)
+func (e *execution) remaining() time.Duration {
+	if e.deadline.IsZero() {
+		return 0
+	}
+	return max(time.Until(e.deadline), 0)
+}
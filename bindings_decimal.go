@@ -0,0 +1,132 @@
+package jsmachine
+
+import (
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+	"github.com/shopspring/decimal"
+)
+
+// defaultDivisionPlaces is the number of decimal places kept by decimal.div when none are given
+const defaultDivisionPlaces = 16
+
+// DecimalBinding provides arbitrary-precision decimal arithmetic on string-represented numbers
+// Invalid operands and division by zero throw, since silently continuing with a wrong amount is worse
+type DecimalBinding struct{}
+
+// newDecimalBinding creates a new decimal binding
+func newDecimalBinding() *DecimalBinding {
+	return &DecimalBinding{}
+}
+
+// inject injects the decimal object into the VM
+func (d *DecimalBinding) inject(vm *otto.Otto) error {
+	decimalObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	methods := map[string]func(otto.FunctionCall) otto.Value{
+		"add":   d.add,   // decimal.add(a, b)
+		"sub":   d.sub,   // decimal.sub(a, b)
+		"mul":   d.mul,   // decimal.mul(a, b)
+		"div":   d.div,   // decimal.div(a, b, places)
+		"cmp":   d.cmp,   // decimal.cmp(a, b)
+		"round": d.round, // decimal.round(a, places, mode)
+	}
+	for name, fn := range methods {
+		if err := decimalObj.Set(name, fn); err != nil {
+			return err
+		}
+	}
+
+	return vm.Set("decimal", decimalObj)
+}
+
+func (d *DecimalBinding) add(call otto.FunctionCall) otto.Value {
+	a, b := decimalArgument(call, 0), decimalArgument(call, 1)
+	return stringValue(a.Add(b).String())
+}
+
+func (d *DecimalBinding) sub(call otto.FunctionCall) otto.Value {
+	a, b := decimalArgument(call, 0), decimalArgument(call, 1)
+	return stringValue(a.Sub(b).String())
+}
+
+func (d *DecimalBinding) mul(call otto.FunctionCall) otto.Value {
+	a, b := decimalArgument(call, 0), decimalArgument(call, 1)
+	return stringValue(a.Mul(b).String())
+}
+
+// div divides a by b, rounding half away from zero to places (default: 16)
+func (d *DecimalBinding) div(call otto.FunctionCall) otto.Value {
+	a, b := decimalArgument(call, 0), decimalArgument(call, 1)
+	if b.IsZero() {
+		panic(call.Otto.MakeRangeError("decimal.div: division by zero"))
+	}
+
+	places := int32(defaultDivisionPlaces)
+	if arg := call.Argument(2); !arg.IsUndefined() {
+		places = placesArgument(call, 2)
+	}
+	return stringValue(a.DivRound(b, places).String())
+}
+
+// cmp returns -1, 0 or 1
+func (d *DecimalBinding) cmp(call otto.FunctionCall) otto.Value {
+	a, b := decimalArgument(call, 0), decimalArgument(call, 1)
+	result, _ := otto.ToValue(a.Cmp(b))
+	return result
+}
+
+// round rounds to places (default: 0) using mode: half_up (default, away from zero on ties),
+// half_even, up (away from zero) or down (towards zero)
+func (d *DecimalBinding) round(call otto.FunctionCall) otto.Value {
+	a := decimalArgument(call, 0)
+
+	var places int32
+	if arg := call.Argument(1); !arg.IsUndefined() {
+		places = placesArgument(call, 1)
+	}
+
+	mode := "half_up"
+	if arg := call.Argument(2); !arg.IsUndefined() {
+		mode = arg.String()
+	}
+
+	var rounded decimal.Decimal
+	switch mode {
+	case "half_up":
+		rounded = a.Round(places)
+	case "half_even":
+		rounded = a.RoundBank(places)
+	case "up":
+		rounded = a.RoundUp(places)
+	case "down":
+		rounded = a.RoundDown(places)
+	default:
+		panic(call.Otto.MakeRangeError(fmt.Sprintf("decimal.round: unknown rounding mode %q", mode)))
+	}
+
+	return stringValue(rounded.StringFixed(places))
+}
+
+// decimalArgument parses a decimal from a string or number argument, throwing a TypeError if it is invalid
+func decimalArgument(call otto.FunctionCall, index int) decimal.Decimal {
+	arg := call.Argument(index)
+	if arg.IsString() || arg.IsNumber() {
+		if value, err := decimal.NewFromString(arg.String()); err == nil {
+			return value
+		}
+	}
+	panic(call.Otto.MakeTypeError(fmt.Sprintf("invalid decimal: %s", arg.String())))
+}
+
+// placesArgument returns a non-negative number of decimal places, throwing a RangeError otherwise
+func placesArgument(call otto.FunctionCall, index int) int32 {
+	places, err := call.Argument(index).ToInteger()
+	if err != nil || places < 0 || places > 64 {
+		panic(call.Otto.MakeRangeError("decimal places must be between 0 and 64"))
+	}
+	return int32(places)
+}
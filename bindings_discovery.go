@@ -0,0 +1,69 @@
+package jsmachine
+
+import (
+	"context"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// DiscoveryBinding resolves logical service names for scripts, so operators can repoint services without editing
+// scripts
+type DiscoveryBinding struct {
+	plugin *Plugin
+}
+
+// newDiscoveryBinding creates a new discovery binding
+func newDiscoveryBinding(plugin *Plugin) *DiscoveryBinding {
+	return &DiscoveryBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the discovery object into the VM
+func (d *DiscoveryBinding) inject(vm *otto.Otto) error {
+	discoveryObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// discovery.resolve(service) - returns an array of "host:port" endpoints
+	if err := discoveryObj.Set("resolve", d.resolve); err != nil {
+		return err
+	}
+
+	return vm.Set("discovery", discoveryObj)
+}
+
+// resolve returns the endpoints of a js.discovery.services service, an empty array when no instance is available
+// Throws a TypeError for unknown services and an Error when the service cannot be resolved
+func (d *DiscoveryBinding) resolve(call otto.FunctionCall) otto.Value {
+	name := call.Argument(0).String()
+	discovery := d.plugin.discovery
+	if discovery == nil || discovery.cfg.Services[name] == nil {
+		panic(call.Otto.MakeTypeError("discovery.resolve: unknown service " + name))
+	}
+
+	parent := context.Background()
+	if exec := d.plugin.executionFor(call.Otto); exec != nil {
+		parent = exec.ctx
+	}
+	ctx, cancel := context.WithTimeout(parent, time.Duration(d.plugin.cfg.Discovery.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	endpoints, err := discovery.resolve(ctx, name)
+	if err != nil {
+		if endpoints == nil {
+			panic(call.Otto.MakeCustomError("Error", "discovery.resolve: "+name+": "+err.Error()))
+		}
+		d.plugin.log.Warn("service discovery refresh failed, using the last known endpoints",
+			zap.String("service", name), zap.Error(err))
+	}
+
+	result, err := call.Otto.ToValue(append([]string{}, endpoints...))
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "discovery.resolve: "+err.Error()))
+	}
+	return result
+}
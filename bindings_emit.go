@@ -0,0 +1,66 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+)
+
+// EmitBinding lets scripts produce output records separately from their return value
+type EmitBinding struct {
+	plugin *Plugin
+}
+
+// newEmitBinding creates a new emit binding
+func newEmitBinding(plugin *Plugin) *EmitBinding {
+	return &EmitBinding{
+		plugin: plugin,
+	}
+}
+
+// inject defines emit in the VM
+func (e *EmitBinding) inject(vm *otto.Otto) error {
+	// emit(record)
+	return vm.Set("emit", e.emit)
+}
+
+// emit adds a record to the execution's output, returned in the response's records or, for scripts with an items
+// sink, pushed as jobs once the execution succeeded; records are copied as JSON when emitted, so later changes to
+// them are not part of the output, and streamed to the execution's subscribers as they are emitted
+// Throws a RangeError beyond max_records (the sink's max_items for scripts with an items sink)
+func (e *EmitBinding) emit(call otto.FunctionCall) otto.Value {
+	exec := e.plugin.executionFor(call.Otto)
+	if exec == nil {
+		return otto.UndefinedValue()
+	}
+
+	limit := e.plugin.cfg.MaxRecords
+	if cfg := e.plugin.sinkFor(exec.script); cfg != nil && cfg.Mode == sinkItems {
+		limit = cfg.MaxItems
+	}
+	if len(exec.records) >= limit {
+		panic(call.Otto.MakeRangeError(fmt.Sprintf("emit: more than %d records", limit)))
+	}
+
+	record, err := call.Argument(0).Export()
+	if err != nil {
+		panic(call.Otto.MakeTypeError("emit: " + err.Error()))
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		panic(call.Otto.MakeTypeError("emit: " + err.Error()))
+	}
+	exec.records = append(exec.records, data)
+
+	if e.plugin.streams.hasSubscribers(exec.requestID) {
+		e.plugin.streams.publish(streamEvent{
+			Type:      streamEventRecord,
+			RequestID: exec.requestID,
+			Data:      json.RawMessage(data),
+			Sequence:  len(exec.records),
+		})
+	}
+
+	return otto.UndefinedValue()
+}
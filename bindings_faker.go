@@ -0,0 +1,228 @@
+package jsmachine
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/robertkrimen/otto"
+)
+
+// fakerStrings are the faker functions returning one generated string, keyed by their script name
+var fakerStrings = map[string]func(f *gofakeit.Faker) string{
+	"firstName": (*gofakeit.Faker).FirstName,
+	"lastName":  (*gofakeit.Faker).LastName,
+	"name":      (*gofakeit.Faker).Name,
+	"email":     (*gofakeit.Faker).Email,
+	"username":  (*gofakeit.Faker).Username,
+	"phone":     (*gofakeit.Faker).PhoneFormatted,
+	"company":   (*gofakeit.Faker).Company,
+	"jobTitle":  (*gofakeit.Faker).JobTitle,
+	"uuid":      (*gofakeit.Faker).UUID,
+	"word":      (*gofakeit.Faker).Word,
+	"sentence":  func(f *gofakeit.Faker) string { return f.Sentence() },
+	"paragraph": func(f *gofakeit.Faker) string { return f.Paragraph() },
+	"url":       (*gofakeit.Faker).URL,
+	"ipv4":      (*gofakeit.Faker).IPv4Address,
+}
+
+// FakerBinding generates test data in Go: JavaScript faker libraries take seconds to parse under otto
+// Every execution draws from its own generator, so a seeded script produces the same data on every run
+type FakerBinding struct {
+	plugin *Plugin
+
+	// Generator of calls outside an execution, safe for concurrent use
+	shared *gofakeit.Faker
+}
+
+// newFakerBinding creates a new faker binding
+func newFakerBinding(plugin *Plugin) *FakerBinding {
+	return &FakerBinding{
+		plugin: plugin,
+		shared: gofakeit.New(0),
+	}
+}
+
+// inject injects the faker object into the VM
+func (b *FakerBinding) inject(vm *otto.Otto) error {
+	fakerObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// faker.seed(n) - makes the rest of the execution generate the same data on every run
+	if err := fakerObj.Set("seed", b.seed); err != nil {
+		return err
+	}
+
+	// faker.firstName(), faker.email(), ... - return a generated string
+	for name, generate := range fakerStrings {
+		if err := fakerObj.Set(name, func(call otto.FunctionCall) otto.Value {
+			value, _ := call.Otto.ToValue(generate(b.faker(call.Otto)))
+			return value
+		}); err != nil {
+			return err
+		}
+	}
+
+	// faker.address() - returns {street, city, state, zip, country, latitude, longitude}
+	if err := fakerObj.Set("address", b.address); err != nil {
+		return err
+	}
+
+	// faker.number(min, max) - returns an integer between min and max, inclusive
+	if err := fakerObj.Set("number", b.number); err != nil {
+		return err
+	}
+
+	// faker.float(min, max) - returns a number between min and max
+	if err := fakerObj.Set("float", b.float); err != nil {
+		return err
+	}
+
+	// faker.bool() - returns true or false
+	if err := fakerObj.Set("bool", func(call otto.FunctionCall) otto.Value {
+		value, _ := call.Otto.ToValue(b.faker(call.Otto).Bool())
+		return value
+	}); err != nil {
+		return err
+	}
+
+	// faker.date(from, to) - returns an ISO 8601 timestamp between two dates, formatted like Date.toISOString
+	if err := fakerObj.Set("date", b.date); err != nil {
+		return err
+	}
+
+	// faker.pick(array) - returns a random element
+	if err := fakerObj.Set("pick", b.pick); err != nil {
+		return err
+	}
+
+	return vm.Set("faker", fakerObj)
+}
+
+// faker returns the generator of the running execution, created with a random seed on first use
+func (b *FakerBinding) faker(vm *otto.Otto) *gofakeit.Faker {
+	exec := b.plugin.executionFor(vm)
+	if exec == nil {
+		return b.shared
+	}
+	if exec.faker == nil {
+		exec.faker = gofakeit.New(0)
+	}
+	return exec.faker
+}
+
+// seed replaces the generator of the running execution with one seeded by a positive integer
+func (b *FakerBinding) seed(call otto.FunctionCall) otto.Value {
+	exec := b.plugin.executionFor(call.Otto)
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", "faker.seed: no execution is running"))
+	}
+	arg := call.Argument(0)
+	seed, err := arg.ToInteger()
+	if !arg.IsNumber() || err != nil || seed < 1 {
+		panic(call.Otto.MakeTypeError("faker.seed: seed must be a positive integer"))
+	}
+
+	exec.faker = gofakeit.New(uint64(seed))
+	return otto.UndefinedValue()
+}
+
+// address returns a generated postal address
+func (b *FakerBinding) address(call otto.FunctionCall) otto.Value {
+	addr := b.faker(call.Otto).Address()
+	obj, err := call.Otto.Object(`({})`)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "faker.address: "+err.Error()))
+	}
+	_ = obj.Set("street", addr.Street)
+	_ = obj.Set("city", addr.City)
+	_ = obj.Set("state", addr.State)
+	_ = obj.Set("zip", addr.Zip)
+	_ = obj.Set("country", addr.Country)
+	_ = obj.Set("latitude", addr.Latitude)
+	_ = obj.Set("longitude", addr.Longitude)
+	return obj.Value()
+}
+
+// number returns an integer between min and max, inclusive (default: 0 to 100)
+func (b *FakerBinding) number(call otto.FunctionCall) otto.Value {
+	lo, hi := fakerRange(call, "faker.number", 0, 100)
+	value, _ := call.Otto.ToValue(b.faker(call.Otto).Number(int(lo), int(hi)))
+	return value
+}
+
+// float returns a number between min and max (default: 0 to 1)
+func (b *FakerBinding) float(call otto.FunctionCall) otto.Value {
+	lo, hi := fakerRange(call, "faker.float", 0, 1)
+	value, _ := call.Otto.ToValue(b.faker(call.Otto).Float64Range(lo, hi))
+	return value
+}
+
+// date returns an ISO 8601 timestamp between two dates given as ISO 8601 strings or epoch milliseconds (default:
+// the last year)
+func (b *FakerBinding) date(call otto.FunctionCall) otto.Value {
+	now := time.Now().UTC()
+	from := fakerTime(call, call.Argument(0), now.AddDate(-1, 0, 0))
+	to := fakerTime(call, call.Argument(1), now)
+	if to.Before(from) {
+		panic(call.Otto.MakeRangeError("faker.date: to is before from"))
+	}
+
+	value, _ := call.Otto.ToValue(b.faker(call.Otto).DateRange(from, to).UTC().Format("2006-01-02T15:04:05.000Z"))
+	return value
+}
+
+// pick returns a random element of an array
+func (b *FakerBinding) pick(call otto.FunctionCall) otto.Value {
+	arg := call.Argument(0)
+	if !arg.IsObject() || arg.Class() != "Array" {
+		panic(call.Otto.MakeTypeError("faker.pick: items must be an array"))
+	}
+	lengthValue, _ := arg.Object().Get("length")
+	length, _ := lengthValue.ToInteger()
+	if length == 0 {
+		return otto.UndefinedValue()
+	}
+
+	item, _ := arg.Object().Get(strconv.Itoa(b.faker(call.Otto).Number(0, int(length)-1)))
+	return item
+}
+
+// fakerRange returns the min and max arguments, or their defaults when omitted
+func fakerRange(call otto.FunctionCall, fn string, lo, hi float64) (float64, float64) {
+	for i, bound := range []*float64{&lo, &hi} {
+		arg := call.Argument(i)
+		if !arg.IsDefined() {
+			continue
+		}
+		value, err := arg.ToFloat()
+		if !arg.IsNumber() || err != nil {
+			panic(call.Otto.MakeTypeError(fn + ": min and max must be numbers"))
+		}
+		*bound = value
+	}
+	if hi < lo {
+		panic(call.Otto.MakeRangeError(fn + ": max is lower than min"))
+	}
+	return lo, hi
+}
+
+// fakerTime converts an ISO 8601 string or epoch milliseconds argument, def when it is omitted
+func fakerTime(call otto.FunctionCall, arg otto.Value, def time.Time) time.Time {
+	switch {
+	case !arg.IsDefined():
+		return def
+	case arg.IsNumber():
+		ms, _ := arg.ToInteger()
+		return time.UnixMilli(ms).UTC()
+	case arg.IsString():
+		t, err := time.Parse(time.RFC3339Nano, arg.String())
+		if err != nil {
+			panic(call.Otto.MakeTypeError("faker.date: " + arg.String() + " is not an ISO 8601 timestamp"))
+		}
+		return t
+	}
+	panic(call.Otto.MakeTypeError("faker.date: dates must be ISO 8601 strings or epoch milliseconds"))
+}
@@ -0,0 +1,267 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// Errors for requests (and redirects) refused by the fetch configuration
+var (
+	errHostNotAllowed   = errors.New("host is not in fetch.allowed_hosts")
+	errSchemeNotAllowed = errors.New("scheme is not in fetch.allowed_schemes")
+	errRedirectDenied   = errors.New("redirects to other hosts are not followed (fetch.redirects: same_host)")
+	errResponseTooLarge = errors.New("response exceeds fetch.max_response_bytes")
+)
+
+// FetchBinding performs HTTP requests for scripts through the plugin's pooled client
+// Calls are synchronous: the script continues once the response body has been read
+type FetchBinding struct {
+	plugin *Plugin
+}
+
+// newFetchBinding creates a new fetch binding
+func newFetchBinding(plugin *Plugin) *FetchBinding {
+	return &FetchBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the fetch function into the VM
+func (f *FetchBinding) inject(vm *otto.Otto) error {
+	// fetch(url, {method, headers, body, timeoutMs, client}) - returns the response
+	return vm.Set("fetch", f.fetch)
+}
+
+// fetch sends a request and returns {status, statusText, ok, url, headers, text(), json()}
+// Throws a TypeError for invalid arguments and an Error when the request fails or the host is not allowed
+func (f *FetchBinding) fetch(call otto.FunctionCall) otto.Value {
+	pending := f.prepare(call)
+	pending.run(f.plugin.executionFor(call.Otto).ctx)
+	return pending.value(call.Otto)
+}
+
+// fetchCall is a request prepared by fetch, sent by run
+type fetchCall struct {
+	binding *FetchBinding
+	client  *http.Client
+	req     *http.Request
+	allowed []string
+	timeout time.Duration
+	stream  bool // the body is read by the script (fetch(url, {stream: true}))
+
+	// Outcome of run
+	resp   *http.Response
+	body   string
+	cancel context.CancelFunc // ends a streamed response
+	err    error
+}
+
+// prepareBuffered prepares a request whose body is read before run returns, as parallel() requires
+func (f *FetchBinding) prepareBuffered(call otto.FunctionCall) *fetchCall {
+	pending := f.prepare(call)
+	if pending.stream {
+		panic(call.Otto.MakeTypeError("fetch: streamed responses cannot be fetched in parallel"))
+	}
+	return pending
+}
+
+// prepare validates the arguments of fetch and builds the request
+func (f *FetchBinding) prepare(call otto.FunctionCall) *fetchCall {
+	cfg := f.plugin.cfg.Fetch
+
+	target, err := url.Parse(call.Argument(0).String())
+	if err != nil || !target.IsAbs() || target.Host == "" {
+		panic(call.Otto.MakeTypeError("fetch: url must be an absolute URL"))
+	}
+	if !slices.Contains(cfg.AllowedSchemes, target.Scheme) {
+		panic(call.Otto.MakeCustomError("Error", "fetch: "+target.Scheme+": "+errSchemeNotAllowed.Error()))
+	}
+
+	exec := f.plugin.executionFor(call.Otto)
+	allowed := f.plugin.allowedHosts(exec)
+	if !hostAllowed(strings.ToLower(target.Hostname()), allowed) {
+		panic(call.Otto.MakeCustomError("Error", "fetch: "+target.Hostname()+": "+errHostNotAllowed.Error()))
+	}
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", "fetch: no execution is running"))
+	}
+	if exec.mock {
+		panic(call.Otto.MakeCustomError("Error", "fetch: not available in mock mode"))
+	}
+
+	opts := f.options(call, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	client, ok := f.plugin.fetchClients.get(opts.client)
+	if !ok {
+		panic(call.Otto.MakeTypeError("fetch: unknown client profile " + opts.client))
+	}
+
+	req, err := http.NewRequest(opts.method, target.String(), opts.body)
+	if err != nil {
+		panic(call.Otto.MakeTypeError("fetch: " + err.Error()))
+	}
+	for name, value := range opts.headers {
+		req.Header.Set(name, value)
+	}
+
+	return &fetchCall{binding: f, client: client, req: req, allowed: allowed, timeout: opts.timeout, stream: opts.stream}
+}
+
+// run sends the request under ctx and reads the response body unless it is streamed; it does not use the VM
+func (c *fetchCall) run(ctx context.Context) {
+	// Requests end with the execution, so a timed-out script does not keep waiting on the network
+	// The timeout of a streamed response also bounds reading its body
+	ctx, cancel := context.WithTimeout(withAllowedHosts(ctx, c.allowed), c.timeout)
+
+	resp, err := c.client.Do(c.req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		c.binding.plugin.log.Debug("fetch failed", zap.String("host", c.req.URL.Hostname()), zap.Error(err))
+		c.err = err
+		return
+	}
+	if c.stream {
+		c.resp, c.cancel = resp, cancel
+		return
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	// Read one byte more than allowed to tell a body of exactly the limit from a larger one
+	maxBytes := c.binding.plugin.cfg.Fetch.MaxResponseBytes
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		c.err = fmt.Errorf("failed to read response: %w", err)
+		return
+	}
+	if len(data) > maxBytes {
+		c.err = errResponseTooLarge
+		return
+	}
+	c.resp, c.body = resp, string(data)
+}
+
+// value returns the response object, or throws the error of run
+func (c *fetchCall) value(vm *otto.Otto) otto.Value {
+	if c.err != nil {
+		panic(vm.MakeCustomError("Error", "fetch: "+c.err.Error()))
+	}
+	if c.stream {
+		return c.binding.streamResponse(vm, c.resp, newFetchStream(c.resp, c.cancel, c.binding.plugin.cfg.Fetch))
+	}
+	return c.binding.response(vm, c.resp, c.body)
+}
+
+// fetchOptions are the options a script passes as the second argument of fetch
+type fetchOptions struct {
+	method  string
+	headers map[string]string
+	body    io.Reader
+	timeout time.Duration
+	client  string // client profile, empty for the default client
+	stream  bool
+}
+
+// options reads the optional second argument of fetch
+func (f *FetchBinding) options(call otto.FunctionCall, timeout time.Duration) fetchOptions {
+	opts := fetchOptions{method: http.MethodGet, timeout: timeout}
+	arg := call.Argument(1)
+	if !arg.IsObject() {
+		return opts
+	}
+	obj := arg.Object()
+
+	if v, _ := obj.Get("method"); v.IsString() {
+		opts.method = strings.ToUpper(v.String())
+	}
+	if v, _ := obj.Get("headers"); v.IsObject() {
+		headerObj := v.Object()
+		opts.headers = make(map[string]string)
+		for _, name := range headerObj.Keys() {
+			value, _ := headerObj.Get(name)
+			opts.headers[name] = value.String()
+		}
+	}
+	if v, _ := obj.Get("body"); v.IsDefined() && !v.IsNull() {
+		opts.body = strings.NewReader(v.String())
+	}
+	if v, _ := obj.Get("timeoutMs"); v.IsNumber() {
+		if ms, err := v.ToInteger(); err == nil && ms > 0 {
+			opts.timeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v, _ := obj.Get("client"); v.IsString() {
+		opts.client = v.String()
+	}
+	if v, _ := obj.Get("stream"); v.IsBoolean() {
+		opts.stream, _ = v.ToBoolean()
+	}
+	return opts
+}
+
+// response converts an HTTP response to the object returned by fetch
+func (f *FetchBinding) response(vm *otto.Otto, resp *http.Response, body string) otto.Value {
+	obj := f.responseObject(vm, resp)
+	_ = obj.Set("text", func(call otto.FunctionCall) otto.Value {
+		value, _ := call.Otto.ToValue(body)
+		return value
+	})
+	_ = obj.Set("json", func(call otto.FunctionCall) otto.Value {
+		value, err := call.Otto.Call("JSON.parse", nil, body)
+		if err != nil {
+			panic(call.Otto.MakeSyntaxError("fetch: response is not valid JSON"))
+		}
+		return value
+	})
+	// Binary bodies such as documents and images do not survive the conversion to a script string
+	_ = obj.Set("base64", func(call otto.FunctionCall) otto.Value {
+		value, _ := call.Otto.ToValue(base64.StdEncoding.EncodeToString([]byte(body)))
+		return value
+	})
+
+	return obj.Value()
+}
+
+// streamResponse converts a response fetched with {stream: true}: its body is read through the body object
+func (f *FetchBinding) streamResponse(vm *otto.Otto, resp *http.Response, stream *fetchStream) otto.Value {
+	exec := f.plugin.executionFor(vm)
+	if exec == nil {
+		stream.close()
+		panic(vm.MakeCustomError("Error", "fetch: no execution is running"))
+	}
+
+	obj := f.responseObject(vm, resp)
+	_ = obj.Set("body", f.streamBody(vm, exec, stream))
+	return obj.Value()
+}
+
+// responseObject returns the status, URL and headers of a response as an object
+func (f *FetchBinding) responseObject(vm *otto.Otto, resp *http.Response) *otto.Object {
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "fetch: "+err.Error()))
+	}
+
+	// Multiple values of a header are joined with ", " as in the Fetch API
+	headers := make(map[string]interface{}, len(resp.Header))
+	for name, values := range resp.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+
+	_ = obj.Set("status", resp.StatusCode)
+	_ = obj.Set("statusText", http.StatusText(resp.StatusCode))
+	_ = obj.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
+	_ = obj.Set("url", resp.Request.URL.String())
+	_ = obj.Set("headers", headers)
+	return obj
+}
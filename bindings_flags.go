@@ -0,0 +1,81 @@
+package jsmachine
+
+import (
+	"github.com/robertkrimen/otto"
+)
+
+// FlagsBinding exposes feature flags to JavaScript
+type FlagsBinding struct {
+	plugin *Plugin
+}
+
+// newFlagsBinding creates a new flags binding
+func newFlagsBinding(plugin *Plugin) *FlagsBinding {
+	return &FlagsBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the flags object into the VM
+func (f *FlagsBinding) inject(vm *otto.Otto) error {
+	flagsObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// flags.isEnabled(name, context)
+	if err := flagsObj.Set("isEnabled", f.isEnabled); err != nil {
+		return err
+	}
+
+	// flags.getValue(name, defaultValue, context)
+	if err := flagsObj.Set("getValue", f.getValue); err != nil {
+		return err
+	}
+
+	return vm.Set("flags", flagsObj)
+}
+
+// isEnabled reports whether a flag evaluates to true; unknown flags are disabled
+func (f *FlagsBinding) isEnabled(call otto.FunctionCall) otto.Value {
+	value, ok := f.evaluate(call.Argument(0).String(), call.Argument(1))
+	if ok && value == true {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
+
+// getValue returns a flag's value, or defaultValue (undefined if omitted) for unknown flags
+func (f *FlagsBinding) getValue(call otto.FunctionCall) otto.Value {
+	value, ok := f.evaluate(call.Argument(0).String(), call.Argument(2))
+	if !ok {
+		return call.Argument(1)
+	}
+
+	result, err := call.Otto.ToValue(value)
+	if err != nil {
+		return call.Argument(1)
+	}
+	return result
+}
+
+// evaluate resolves a flag for the evaluation context passed from JavaScript
+func (f *FlagsBinding) evaluate(name string, evalCtx otto.Value) (interface{}, bool) {
+	if f.plugin.flags == nil {
+		return nil, false
+	}
+
+	def, ok := f.plugin.flags.get(name)
+	if !ok {
+		return nil, false
+	}
+
+	var attrs map[string]interface{}
+	if evalCtx.IsObject() {
+		if exported, err := evalCtx.Export(); err == nil {
+			attrs, _ = exported.(map[string]interface{})
+		}
+	}
+
+	return def.evaluate(attrs), true
+}
@@ -0,0 +1,229 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// GraphQLBinding sends GraphQL operations to configured endpoints and normalizes their responses
+type GraphQLBinding struct {
+	plugin *Plugin
+}
+
+// newGraphQLBinding creates a new graphql binding
+func newGraphQLBinding(plugin *Plugin) *GraphQLBinding {
+	return &GraphQLBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the graphql object into the VM
+func (g *GraphQLBinding) inject(vm *otto.Otto) error {
+	graphqlObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// graphql.query(endpoint, query, variables, {operationName, headers, timeoutMs}) - returns {data, errors, extensions}
+	if err := graphqlObj.Set("query", g.query); err != nil {
+		return err
+	}
+
+	// graphql.batch(endpoint, [{query, variables, operationName}], {headers, timeoutMs}) - returns an array of results
+	if err := graphqlObj.Set("batch", g.batch); err != nil {
+		return err
+	}
+
+	return vm.Set("graphql", graphqlObj)
+}
+
+// query sends one operation
+// GraphQL errors are returned in errors; throws a TypeError for unknown endpoints and an Error when the request fails
+func (g *GraphQLBinding) query(call otto.FunctionCall) otto.Value {
+	pending := g.prepareQuery(call)
+	pending.run(g.plugin.executionFor(call.Otto).ctx)
+	return pending.value(call.Otto)
+}
+
+// batch sends several operations, in one request when the endpoint has batching enabled
+func (g *GraphQLBinding) batch(call otto.FunctionCall) otto.Value {
+	pending := g.prepareBatch(call)
+	pending.run(g.plugin.executionFor(call.Otto).ctx)
+	return pending.value(call.Otto)
+}
+
+// graphqlCall holds operations prepared by graphql.query or graphql.batch, sent by run
+type graphqlCall struct {
+	binding    *GraphQLBinding
+	fn         string
+	name       string
+	gql        *graphqlClient
+	operations []*graphqlOperation
+	timeout    time.Duration
+	single     bool // graphql.query returns one result rather than a list
+
+	// Outcome of run
+	results []*graphqlResult
+	err     error
+}
+
+// prepareQuery validates the arguments of graphql.query
+func (g *GraphQLBinding) prepareQuery(call otto.FunctionCall) *graphqlCall {
+	if query := call.Argument(1); !query.IsString() || query.String() == "" {
+		panic(call.Otto.MakeTypeError("graphql.query: query is required"))
+	}
+	operation := &graphqlOperation{
+		Query:     call.Argument(1).String(),
+		Variables: g.variables(call.Otto, "graphql.query", call.Argument(2)),
+	}
+	if v := call.Argument(3); v.IsObject() {
+		if name, _ := v.Object().Get("operationName"); name.IsString() {
+			operation.OperationName = name.String()
+		}
+	}
+
+	pending := g.prepare(call, "graphql.query", call.Argument(3), []*graphqlOperation{operation})
+	pending.single = true
+	return pending
+}
+
+// prepareBatch validates the arguments of graphql.batch
+func (g *GraphQLBinding) prepareBatch(call otto.FunctionCall) *graphqlCall {
+	arg := call.Argument(1)
+	if !arg.IsObject() || arg.Class() != "Array" {
+		panic(call.Otto.MakeTypeError("graphql.batch: operations must be an array"))
+	}
+	obj := arg.Object()
+	lengthValue, _ := obj.Get("length")
+	length, _ := lengthValue.ToInteger()
+	if length == 0 {
+		panic(call.Otto.MakeTypeError("graphql.batch: operations cannot be empty"))
+	}
+
+	operations := make([]*graphqlOperation, 0, length)
+	for i := range length {
+		item, _ := obj.Get(strconv.FormatInt(i, 10))
+		if !item.IsObject() {
+			panic(call.Otto.MakeTypeError("graphql.batch: every operation must be an object"))
+		}
+		itemObj := item.Object()
+		query, _ := itemObj.Get("query")
+		if !query.IsString() || query.String() == "" {
+			panic(call.Otto.MakeTypeError("graphql.batch: every operation needs a query"))
+		}
+		variables, _ := itemObj.Get("variables")
+		operation := &graphqlOperation{
+			Query:     query.String(),
+			Variables: g.variables(call.Otto, "graphql.batch", variables),
+		}
+		if name, _ := itemObj.Get("operationName"); name.IsString() {
+			operation.OperationName = name.String()
+		}
+		operations = append(operations, operation)
+	}
+
+	return g.prepare(call, "graphql.batch", call.Argument(2), operations)
+}
+
+// prepare addresses operations to the endpoint named by the first argument
+func (g *GraphQLBinding) prepare(call otto.FunctionCall, fn string, options otto.Value, operations []*graphqlOperation) *graphqlCall {
+	name := call.Argument(0).String()
+	var endpoint *GraphQLEndpointConfig
+	if cfg := g.plugin.cfg.GraphQL; cfg != nil {
+		endpoint = cfg.Endpoints[name]
+	}
+	if endpoint == nil {
+		panic(call.Otto.MakeTypeError(fn + ": unknown endpoint " + name))
+	}
+	exec := g.plugin.executionFor(call.Otto)
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", fn+": no execution is running"))
+	}
+	if exec.mock {
+		panic(call.Otto.MakeCustomError("Error", fn+": not available in mock mode"))
+	}
+
+	fetchCfg := g.plugin.cfg.Fetch
+	client, _ := g.plugin.fetchClients.get(endpoint.Client) // validated with the configuration
+	gql := &graphqlClient{
+		endpoint: endpoint,
+		client:   client,
+		maxBytes: fetchCfg.MaxResponseBytes,
+		headers:  make(map[string]string),
+	}
+
+	timeout := time.Duration(fetchCfg.TimeoutMs) * time.Millisecond
+	if options.IsObject() {
+		obj := options.Object()
+		if v, _ := obj.Get("headers"); v.IsObject() {
+			headerObj := v.Object()
+			for _, header := range headerObj.Keys() {
+				value, _ := headerObj.Get(header)
+				gql.headers[header] = value.String()
+			}
+		}
+		if v, _ := obj.Get("timeoutMs"); v.IsNumber() {
+			if ms, err := v.ToInteger(); err == nil && ms > 0 {
+				timeout = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	return &graphqlCall{binding: g, fn: fn, name: name, gql: gql, operations: operations, timeout: timeout}
+}
+
+// run sends the operations under ctx; it does not use the VM
+func (c *graphqlCall) run(ctx context.Context) {
+	// Requests end with the execution, so a timed-out script does not keep waiting on the network
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	c.results, c.err = c.gql.execute(ctx, c.operations)
+	if c.err != nil {
+		c.binding.plugin.log.Debug("graphql request failed", zap.String("endpoint", c.name), zap.Error(c.err))
+	}
+}
+
+// value returns the results as objects, or throws the error of run
+func (c *graphqlCall) value(vm *otto.Otto) otto.Value {
+	if c.err != nil {
+		panic(vm.MakeCustomError("Error", c.fn+": "+c.name+": "+c.err.Error()))
+	}
+	if c.single {
+		return c.binding.toValue(vm, c.fn, c.results[0])
+	}
+	return c.binding.toValue(vm, c.fn, c.results)
+}
+
+// variables serializes the variables of an operation, which must be an object when given
+func (g *GraphQLBinding) variables(vm *otto.Otto, fn string, value otto.Value) json.RawMessage {
+	if !value.IsDefined() || value.IsNull() {
+		return nil
+	}
+	if !value.IsObject() || value.Class() == "Array" {
+		panic(vm.MakeTypeError(fn + ": variables must be an object"))
+	}
+	encoded, err := vm.Call("JSON.stringify", nil, value)
+	if err != nil {
+		panic(vm.MakeTypeError(fn + ": variables cannot be serialized to JSON"))
+	}
+	return json.RawMessage(encoded.String())
+}
+
+// toValue converts results into script values
+func (g *GraphQLBinding) toValue(vm *otto.Otto, fn string, results any) otto.Value {
+	data, err := json.Marshal(results)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", fn+": "+err.Error()))
+	}
+	value, err := vm.Call("JSON.parse", nil, string(data))
+	if err != nil {
+		panic(vm.MakeCustomError("Error", fn+": "+err.Error()))
+	}
+	return value
+}
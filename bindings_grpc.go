@@ -0,0 +1,193 @@
+package jsmachine
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCBinding calls unary methods of configured gRPC servers, converting between JSON and protobuf in Go
+type GRPCBinding struct {
+	plugin *Plugin
+}
+
+// newGRPCBinding creates a new grpc binding
+func newGRPCBinding(plugin *Plugin) *GRPCBinding {
+	return &GRPCBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the grpc object into the VM
+func (g *GRPCBinding) inject(vm *otto.Otto) error {
+	grpcObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// grpc.call(target, method, request, {metadata, timeoutMs}) - returns the response message
+	if err := grpcObj.Set("call", g.call); err != nil {
+		return err
+	}
+
+	return vm.Set("grpc", grpcObj)
+}
+
+// call invokes a unary method given as "package.Service/Method"; the request is an object or a JSON string
+// Throws a TypeError for unknown targets, methods and invalid requests, and an Error with the numeric status
+// code in its code property (and its name, e.g. NotFound, in status) when the call fails
+func (g *GRPCBinding) call(call otto.FunctionCall) otto.Value {
+	pending := g.prepare(call)
+	pending.run(g.plugin.executionFor(call.Otto).ctx)
+	return pending.value(call.Otto)
+}
+
+// grpcCall is a unary call prepared by grpc.call, invoked by run
+type grpcCall struct {
+	binding    *GRPCBinding
+	target     *grpcTarget
+	targetName string
+	fullMethod string
+	md         metadata.MD
+	timeout    time.Duration
+	req        *dynamicpb.Message
+	resp       *dynamicpb.Message
+
+	// Outcome of run
+	err error
+}
+
+// prepare resolves the method and encodes the request of grpc.call
+func (g *GRPCBinding) prepare(call otto.FunctionCall) *grpcCall {
+	targetName := call.Argument(0).String()
+	methodName := call.Argument(1).String()
+
+	var target *grpcTarget
+	if g.plugin.grpcTargets != nil {
+		target, _ = g.plugin.grpcTargets.get(targetName)
+	}
+	if target == nil {
+		panic(call.Otto.MakeTypeError("grpc.call: unknown target " + targetName))
+	}
+
+	exec := g.plugin.executionFor(call.Otto)
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", "grpc.call: no execution is running"))
+	}
+	if exec.mock {
+		panic(call.Otto.MakeCustomError("Error", "grpc.call: not available in mock mode"))
+	}
+
+	timeout, md := g.options(call.Argument(3), time.Duration(target.cfg.TimeoutMs)*time.Millisecond)
+
+	// Method descriptors may be fetched from the server's reflection service
+	ctx, cancel := context.WithTimeout(exec.ctx, timeout)
+	defer cancel()
+
+	method, err := target.method(ctx, methodName)
+	if err != nil {
+		panic(call.Otto.MakeTypeError("grpc.call: " + targetName + ": " + err.Error()))
+	}
+
+	request := call.Argument(2)
+	requestJSON := "{}"
+	switch {
+	case request.IsString():
+		requestJSON = request.String()
+	case request.IsDefined() && !request.IsNull():
+		encoded, err := call.Otto.Call("JSON.stringify", nil, request)
+		if err != nil {
+			panic(call.Otto.MakeTypeError("grpc.call: request cannot be serialized to JSON"))
+		}
+		requestJSON = encoded.String()
+	}
+
+	req := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal([]byte(requestJSON), req); err != nil {
+		panic(call.Otto.MakeTypeError("grpc.call: invalid " + string(method.Input().FullName()) + ": " + err.Error()))
+	}
+
+	return &grpcCall{
+		binding:    g,
+		target:     target,
+		targetName: targetName,
+		fullMethod: "/" + string(method.Parent().FullName()) + "/" + string(method.Name()),
+		md:         md,
+		timeout:    timeout,
+		req:        req,
+		resp:       dynamicpb.NewMessage(method.Output()),
+	}
+}
+
+// run invokes the method under ctx; it does not use the VM
+func (c *grpcCall) run(ctx context.Context) {
+	// Calls end with the execution, so a timed-out script does not keep waiting on the server
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	c.err = c.target.conn.Invoke(metadata.NewOutgoingContext(ctx, c.md), c.fullMethod, c.req, c.resp)
+	c.binding.plugin.grpcCalls.WithLabelValues(c.targetName, c.fullMethod, status.Code(c.err).String()).Inc()
+	if c.err != nil {
+		c.binding.plugin.log.Debug("grpc call failed",
+			zap.String("target", c.targetName), zap.String("method", c.fullMethod), zap.Error(c.err))
+	}
+}
+
+// value returns the response as an object, or throws the status of run
+func (c *grpcCall) value(vm *otto.Otto) otto.Value {
+	if c.err != nil {
+		st := status.Convert(c.err)
+		c.binding.throw(vm, st.Code(), "grpc.call: "+c.fullMethod+": "+st.Code().String()+": "+st.Message())
+	}
+
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(c.resp)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "grpc.call: failed to encode response: "+err.Error()))
+	}
+	result, err := vm.Call("JSON.parse", nil, string(data))
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "grpc.call: failed to decode response: "+err.Error()))
+	}
+	return result
+}
+
+// options applies the optional fourth argument of call, returning the deadline and outgoing metadata
+func (g *GRPCBinding) options(arg otto.Value, timeout time.Duration) (time.Duration, metadata.MD) {
+	md := metadata.MD{}
+	if !arg.IsObject() {
+		return timeout, md
+	}
+	obj := arg.Object()
+
+	if v, _ := obj.Get("metadata"); v.IsObject() {
+		mdObj := v.Object()
+		for _, key := range mdObj.Keys() {
+			value, _ := mdObj.Get(key)
+			md.Append(strings.ToLower(key), value.String())
+		}
+	}
+	if v, _ := obj.Get("timeoutMs"); v.IsNumber() {
+		if ms, err := v.ToInteger(); err == nil && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return timeout, md
+}
+
+// throw throws an Error carrying the gRPC status code, so scripts can tell NotFound from Unavailable
+func (g *GRPCBinding) throw(vm *otto.Otto, code codes.Code, message string) {
+	errValue := vm.MakeCustomError("Error", message)
+	if obj := errValue.Object(); obj != nil {
+		_ = obj.Set("code", int(code))
+		_ = obj.Set("status", code.String())
+	}
+	panic(errValue)
+}
@@ -0,0 +1,54 @@
+package jsmachine
+
+import (
+	"github.com/robertkrimen/otto"
+)
+
+// I18nBinding exposes translation catalogs to JavaScript
+type I18nBinding struct {
+	plugin *Plugin
+}
+
+// newI18nBinding creates a new i18n binding
+func newI18nBinding(plugin *Plugin) *I18nBinding {
+	return &I18nBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the i18n object into the VM
+func (i *I18nBinding) inject(vm *otto.Otto) error {
+	i18nObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// i18n.t(key, params, locale)
+	if err := i18nObj.Set("t", i.translate); err != nil {
+		return err
+	}
+
+	return vm.Set("i18n", i18nObj)
+}
+
+// translate returns the message for key, or the key itself when it is unknown or i18n is disabled
+func (i *I18nBinding) translate(call otto.FunctionCall) otto.Value {
+	key := call.Argument(0).String()
+	if i.plugin.i18n == nil {
+		return stringValue(key)
+	}
+
+	var params map[string]interface{}
+	if arg := call.Argument(1); arg.IsObject() {
+		if exported, err := arg.Export(); err == nil {
+			params, _ = exported.(map[string]interface{})
+		}
+	}
+
+	locale := ""
+	if arg := call.Argument(2); arg.IsString() {
+		locale = arg.String()
+	}
+
+	return stringValue(i.plugin.i18n.translate(key, params, locale))
+}
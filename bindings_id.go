@@ -0,0 +1,167 @@
+package jsmachine
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// Layout of Snowflake IDs
+const (
+	defaultSnowflakeEpochMs = 1288834974657
+	snowflakeNodeBits       = 10
+	snowflakeSequenceBits   = 12
+	maxSnowflakeNode        = 1<<snowflakeNodeBits - 1
+	maxSnowflakeSequence    = 1<<snowflakeSequenceBits - 1
+)
+
+// ksuidEpoch is the Unix time KSUID timestamps count from
+const ksuidEpoch = 1400000000
+
+// Alphabets of ULIDs (Crockford's base32) and KSUIDs (base62)
+const (
+	ulidAlphabet  = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	ksuidAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// IDBinding generates sortable unique IDs in the formats of the Go services scripts share records with
+// Generators are shared by all VMs of the node, so IDs generated within one millisecond stay ordered
+type IDBinding struct {
+	plugin *Plugin
+
+	mu sync.Mutex
+
+	// Last ULID: its millisecond and random part, incremented for IDs of the same millisecond
+	ulidMs     int64
+	ulidRandom [10]byte
+
+	// Last Snowflake: its millisecond since the epoch and sequence number
+	snowflakeMs  int64
+	snowflakeSeq int64
+}
+
+// newIDBinding creates a new id binding
+func newIDBinding(plugin *Plugin) *IDBinding {
+	return &IDBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the id object into the VM
+func (b *IDBinding) inject(vm *otto.Otto) error {
+	idObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// id.ulid() - returns a 26-character ULID
+	if err := idObj.Set("ulid", func(call otto.FunctionCall) otto.Value {
+		value, _ := call.Otto.ToValue(b.ulid(time.Now()))
+		return value
+	}); err != nil {
+		return err
+	}
+
+	// id.ksuid() - returns a 27-character KSUID
+	if err := idObj.Set("ksuid", func(call otto.FunctionCall) otto.Value {
+		value, _ := call.Otto.ToValue(newKSUID(time.Now()))
+		return value
+	}); err != nil {
+		return err
+	}
+
+	// id.snowflake() - returns a Snowflake ID of this node as a decimal string
+	if err := idObj.Set("snowflake", b.snowflake); err != nil {
+		return err
+	}
+
+	return vm.Set("id", idObj)
+}
+
+// ulid returns a ULID: 48 bits of Unix milliseconds and 80 random bits, encoded in Crockford's base32
+// IDs of the same millisecond increment the random part of the previous one, so they sort in generation order
+func (b *IDBinding) ulid(now time.Time) string {
+	b.mu.Lock()
+	ms := now.UnixMilli()
+	if ms <= b.ulidMs {
+		ms = b.ulidMs
+		for i := len(b.ulidRandom) - 1; i >= 0; i-- {
+			b.ulidRandom[i]++
+			if b.ulidRandom[i] != 0 {
+				break
+			}
+		}
+	} else {
+		b.ulidMs = ms
+		_, _ = rand.Read(b.ulidRandom[:])
+	}
+	var data [16]byte
+	binary.BigEndian.PutUint64(data[:8], uint64(ms)<<16)
+	copy(data[6:], b.ulidRandom[:])
+	b.mu.Unlock()
+
+	// 128 bits in 26 characters of 5 bits, the first holding the 3 leading bits
+	n := new(big.Int).SetBytes(data[:])
+	mask := big.NewInt(31)
+	out := make([]byte, 26)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = ulidAlphabet[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+	return string(out)
+}
+
+// newKSUID returns a KSUID: 32 bits of seconds since the KSUID epoch and 128 random bits, encoded in base62
+func newKSUID(now time.Time) string {
+	var data [20]byte
+	binary.BigEndian.PutUint32(data[:4], uint32(now.Unix()-ksuidEpoch))
+	_, _ = rand.Read(data[4:])
+
+	n := new(big.Int).SetBytes(data[:])
+	base := big.NewInt(int64(len(ksuidAlphabet)))
+	digit := new(big.Int)
+	out := make([]byte, 27)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		out[i] = ksuidAlphabet[digit.Int64()]
+	}
+	return string(out)
+}
+
+// snowflake returns the next Snowflake ID of this node
+// Throws an Error when js.snowflake is not configured
+func (b *IDBinding) snowflake(call otto.FunctionCall) otto.Value {
+	cfg := b.plugin.cfg.Snowflake
+	if cfg == nil {
+		panic(call.Otto.MakeCustomError("Error", "id.snowflake: js.snowflake is not configured"))
+	}
+
+	value, _ := call.Otto.ToValue(strconv.FormatInt(b.nextSnowflake(cfg), 10))
+	return value
+}
+
+// nextSnowflake returns the next ID; IDs never repeat: a clock moving backwards keeps the last millisecond, and once
+// the sequence of a millisecond is exhausted the next millisecond is used early rather than waiting with the lock held
+func (b *IDBinding) nextSnowflake(cfg *SnowflakeConfig) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ms := time.Now().UnixMilli() - cfg.EpochMs
+	if ms <= b.snowflakeMs {
+		ms = b.snowflakeMs
+		b.snowflakeSeq = (b.snowflakeSeq + 1) & maxSnowflakeSequence
+		if b.snowflakeSeq == 0 {
+			ms++
+		}
+	} else {
+		b.snowflakeSeq = 0
+	}
+	b.snowflakeMs = ms
+
+	return ms<<(snowflakeNodeBits+snowflakeSequenceBits) | int64(cfg.NodeID)<<snowflakeSequenceBits | b.snowflakeSeq
+}
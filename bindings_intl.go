@@ -0,0 +1,243 @@
+package jsmachine
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/robertkrimen/otto"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// defaultLocale is used when no locale is given or it cannot be parsed
+var defaultLocale = language.AmericanEnglish
+
+// IntlBinding provides locale-aware number and currency formatting to JavaScript
+type IntlBinding struct{}
+
+// newIntlBinding creates a new intl binding
+func newIntlBinding() *IntlBinding {
+	return &IntlBinding{}
+}
+
+// inject injects the intl object into the VM
+func (i *IntlBinding) inject(vm *otto.Otto) error {
+	intlObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// intl.formatNumber(value, locale, options)
+	if err := intlObj.Set("formatNumber", i.formatNumber); err != nil {
+		return err
+	}
+
+	// intl.formatCurrency(value, currency, locale, options)
+	if err := intlObj.Set("formatCurrency", i.formatCurrency); err != nil {
+		return err
+	}
+
+	return vm.Set("intl", intlObj)
+}
+
+// formatNumber formats a number for a locale
+// Supported options mirror Intl.NumberFormat: style (decimal, percent),
+// minimumFractionDigits, maximumFractionDigits and useGrouping
+func (i *IntlBinding) formatNumber(call otto.FunctionCall) otto.Value {
+	value, ok := numberArgument(call.Argument(0))
+	if !ok {
+		return otto.UndefinedValue()
+	}
+
+	printer := message.NewPrinter(localeArgument(call.Argument(1)))
+	options := call.Argument(2)
+
+	var opts []number.Option
+	if digits, ok := intOption(options, "minimumFractionDigits"); ok {
+		opts = append(opts, number.MinFractionDigits(digits))
+	}
+	if digits, ok := intOption(options, "maximumFractionDigits"); ok {
+		opts = append(opts, number.MaxFractionDigits(digits))
+	}
+	if grouping, ok := boolOption(options, "useGrouping"); ok && !grouping {
+		opts = append(opts, number.NoSeparator())
+	}
+
+	var formatted string
+	if style, _ := stringOption(options, "style"); style == "percent" {
+		formatted = printer.Sprint(number.Percent(value, opts...))
+	} else {
+		formatted = printer.Sprint(number.Decimal(value, opts...))
+	}
+
+	return stringValue(formatted)
+}
+
+// currencyLayout places the currency symbol relative to the amount, after the CLDR standard currency pattern of a
+// locale
+type currencyLayout struct {
+	// Symbol follows the amount ("1.234,50 €") instead of preceding it ("€1,234.50")
+	suffix bool
+	// Symbol and amount are separated by a no-break space ("€ 1.234,50")
+	space bool
+}
+
+// currencyLayouts holds the layouts differing from the English one (prefix, no space), by language or
+// language-region
+var currencyLayouts = map[string]currencyLayout{
+	"bg": {suffix: true, space: true}, "cs": {suffix: true, space: true}, "da": {suffix: true, space: true},
+	"de": {suffix: true, space: true}, "el": {suffix: true, space: true}, "es": {suffix: true, space: true},
+	"fi": {suffix: true, space: true}, "fr": {suffix: true, space: true}, "hr": {suffix: true, space: true},
+	"hu": {suffix: true, space: true}, "it": {suffix: true, space: true}, "lt": {suffix: true, space: true},
+	"lv": {suffix: true, space: true}, "nb": {suffix: true, space: true}, "no": {suffix: true, space: true},
+	"pl": {suffix: true, space: true}, "pt": {space: true}, "pt-PT": {suffix: true, space: true},
+	"ro": {suffix: true, space: true}, "ru": {suffix: true, space: true}, "sk": {suffix: true, space: true},
+	"sl": {suffix: true, space: true}, "sv": {suffix: true, space: true}, "uk": {suffix: true, space: true},
+	"nl": {space: true}, "de-AT": {space: true}, "de-CH": {space: true}, "de-LI": {space: true},
+	"it-CH": {space: true}, "fr-CH": {suffix: true, space: true},
+}
+
+// currencyLayoutFor returns the layout of a locale, preferring its language-region entry
+func currencyLayoutFor(tag language.Tag) currencyLayout {
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+	if layout, ok := currencyLayouts[base.String()+"-"+region.String()]; ok {
+		return layout
+	}
+	return currencyLayouts[base.String()]
+}
+
+// formatCurrency formats an amount in an ISO 4217 currency for a locale
+// The currency's standard number of decimals is used; options.currencyDisplay selects
+// symbol (default), narrowSymbol or code
+// The symbol is placed before or after the amount as the locale does, separated by a no-break space where the locale
+// has one or where a code or alphabetic symbol precedes the amount ("CHF 1,234.50")
+func (i *IntlBinding) formatCurrency(call otto.FunctionCall) otto.Value {
+	value, ok := numberArgument(call.Argument(0))
+	if !ok {
+		return otto.UndefinedValue()
+	}
+
+	unit, err := currency.ParseISO(call.Argument(1).String())
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+
+	locale := localeArgument(call.Argument(2))
+	printer := message.NewPrinter(locale)
+	amount := unit.Amount(math.Abs(value))
+
+	// x/text always formats "<symbol> <amount>"; split the two to lay them out for the locale
+	formatter := currency.Symbol
+	switch display, _ := stringOption(call.Argument(3), "currencyDisplay"); display {
+	case "code":
+		formatter = currency.ISO
+	case "narrowSymbol":
+		formatter = currency.NarrowSymbol
+	}
+	symbol := printer.Sprint(formatter(unit))
+	digits := strings.TrimPrefix(printer.Sprint(formatter(amount)), symbol+" ")
+
+	layout := currencyLayoutFor(locale)
+	separator := ""
+	if layout.space || (!layout.suffix && endsWithLetter(symbol)) {
+		separator = "\u00a0"
+	}
+
+	formatted := symbol + separator + digits
+	if layout.suffix {
+		formatted = digits + separator + symbol
+	}
+	if value < 0 {
+		formatted = "-" + formatted
+	}
+
+	return stringValue(formatted)
+}
+
+// endsWithLetter reports whether s ends with a letter, as currency codes and symbols like "CHF" do
+func endsWithLetter(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsLetter(r)
+}
+
+// numberArgument returns a finite number argument
+func numberArgument(arg otto.Value) (float64, bool) {
+	if !arg.IsNumber() {
+		return 0, false
+	}
+	value, err := arg.ToFloat()
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+// localeArgument parses a BCP 47 locale, falling back to defaultLocale
+func localeArgument(arg otto.Value) language.Tag {
+	if !arg.IsString() {
+		return defaultLocale
+	}
+	tag, err := language.Parse(arg.String())
+	if err != nil {
+		return defaultLocale
+	}
+	return tag
+}
+
+// option returns a property of an options object
+func option(options otto.Value, name string) (otto.Value, bool) {
+	if !options.IsObject() {
+		return otto.UndefinedValue(), false
+	}
+	value, err := options.Object().Get(name)
+	if err != nil || value.IsUndefined() {
+		return otto.UndefinedValue(), false
+	}
+	return value, true
+}
+
+// intOption returns a non-negative integer option
+func intOption(options otto.Value, name string) (int, bool) {
+	value, ok := option(options, name)
+	if !ok {
+		return 0, false
+	}
+	n, err := value.ToInteger()
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// boolOption returns a boolean option
+func boolOption(options otto.Value, name string) (bool, bool) {
+	value, ok := option(options, name)
+	if !ok {
+		return false, false
+	}
+	b, err := value.ToBoolean()
+	return b, err == nil
+}
+
+// stringOption returns a string option
+func stringOption(options otto.Value, name string) (string, bool) {
+	value, ok := option(options, name)
+	if !ok {
+		return "", false
+	}
+	return value.String(), true
+}
+
+// stringValue converts a Go string to a JavaScript value
+func stringValue(s string) otto.Value {
+	value, err := otto.ToValue(s)
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	return value
+}
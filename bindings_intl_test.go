@@ -0,0 +1,42 @@
+package jsmachine
+
+import (
+	"testing"
+
+	"github.com/robertkrimen/otto"
+)
+
+func TestIntlFormatCurrency(t *testing.T) {
+	vm := otto.New()
+	if err := newIntlBinding().inject(vm); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call string
+		want string
+	}{
+		{"en-US symbol first", `intl.formatCurrency(1234.5, "USD", "en-US")`, "$1,234.50"},
+		{"en-US code spaced", `intl.formatCurrency(1234.5, "CHF", "en-US")`, "CHF\u00a01,234.50"},
+		{"en-US negative", `intl.formatCurrency(-1234.5, "EUR", "en-US")`, "-€1,234.50"},
+		{"fr-FR symbol last", `intl.formatCurrency(1234.5, "EUR", "fr-FR")`, "1\u00a0234,50\u00a0€"},
+		{"de-DE symbol last", `intl.formatCurrency(1234.5, "EUR", "de-DE")`, "1.234,50\u00a0€"},
+		{"de-DE negative", `intl.formatCurrency(-1234.5, "EUR", "de-DE")`, "-1.234,50\u00a0€"},
+		{"de-DE code", `intl.formatCurrency(1234.5, "EUR", "de-DE", {currencyDisplay: "code"})`, "1.234,50\u00a0EUR"},
+		{"de-CH symbol first", `intl.formatCurrency(1234.5, "CHF", "de-CH")`, "CHF\u00a01’234.50"},
+		{"ja-JP no decimals", `intl.formatCurrency(1234.5, "JPY", "ja-JP")`, "￥1,235"},
+		{"ja-JP foreign currency", `intl.formatCurrency(1234.5, "USD", "ja-JP")`, "$1,234.50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := vm.Run(tt.call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := value.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/robertkrimen/otto"
+)
+
+// jsonPatchCopyLimit bounds the bytes copy operations of one patch may add, so a short patch cannot copy a document
+// into itself until the node runs out of memory
+const jsonPatchCopyLimit = 10 << 20
+
+// Formats of jsondiff.diff
+const (
+	jsonDiffPatch = "patch"
+	jsonDiffMerge = "merge"
+)
+
+// jsonPatchOp is one operation of an RFC 6902 JSON Patch
+type jsonPatchOp struct {
+	Op    string
+	Path  string
+	Value any
+}
+
+// MarshalJSON writes the value of add and replace operations, even when it is null, and none for remove
+func (op jsonPatchOp) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(map[string]string{"op": op.Op, "path": op.Path})
+	}
+	return json.Marshal(map[string]any{"op": op.Op, "path": op.Path, "value": op.Value})
+}
+
+// JSONPatchBinding computes and applies JSON Patch (RFC 6902) and JSON Merge Patch (RFC 7386) documents in Go, so
+// audit and sync scripts produce standard change sets without walking documents key by key
+type JSONPatchBinding struct{}
+
+// newJSONPatchBinding creates a new JSON patch binding
+func newJSONPatchBinding() *JSONPatchBinding {
+	return &JSONPatchBinding{}
+}
+
+// injectDiff injects the jsondiff object into the VM
+func (j *JSONPatchBinding) injectDiff(vm *otto.Otto) error {
+	jsondiffObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// jsondiff.diff(a, b, {format}) - returns the patch turning a into b
+	if err := jsondiffObj.Set("diff", j.diff); err != nil {
+		return err
+	}
+
+	return vm.Set("jsondiff", jsondiffObj)
+}
+
+// injectPatch injects the jsonpatch object into the VM
+func (j *JSONPatchBinding) injectPatch(vm *otto.Otto) error {
+	jsonpatchObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// jsonpatch.apply(doc, patch) - returns the patched document
+	if err := jsonpatchObj.Set("apply", j.apply); err != nil {
+		return err
+	}
+
+	return vm.Set("jsonpatch", jsonpatchObj)
+}
+
+// diff returns the JSON Patch operations turning the first document into the second, or with {format: "merge"}
+// their JSON Merge Patch
+func (j *JSONPatchBinding) diff(call otto.FunctionCall) otto.Value {
+	a := jsonArgument(call.Otto, call.Argument(0), "jsondiff.diff", "a")
+	b := jsonArgument(call.Otto, call.Argument(1), "jsondiff.diff", "b")
+
+	format := jsonDiffPatch
+	if options := call.Argument(2); options.IsObject() {
+		if v, _ := options.Object().Get("format"); v.IsDefined() {
+			format = v.String()
+		}
+	}
+
+	switch format {
+	case jsonDiffPatch:
+		ops := []jsonPatchOp{}
+		diffJSONPatch("", a, b, &ops)
+		return queryResult(call.Otto, "jsondiff.diff", ops)
+	case jsonDiffMerge:
+		original, _ := json.Marshal(a)
+		modified, _ := json.Marshal(b)
+		patch, err := jsonpatch.CreateMergePatch(original, modified)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("Error", "jsondiff.diff: "+err.Error()))
+		}
+		return queryResult(call.Otto, "jsondiff.diff", json.RawMessage(patch))
+	default:
+		panic(call.Otto.MakeTypeError("jsondiff.diff: format must be " + jsonDiffPatch + " or " + jsonDiffMerge))
+	}
+}
+
+// apply applies a patch to a document: an array is a JSON Patch, an object a JSON Merge Patch
+// Throws a TypeError for documents or patches that are not JSON and an Error when an operation fails, including a
+// test operation whose value differs
+func (j *JSONPatchBinding) apply(call otto.FunctionCall) otto.Value {
+	doc, _ := json.Marshal(jsonArgument(call.Otto, call.Argument(0), "jsonpatch.apply", "doc"))
+	patch := jsonArgument(call.Otto, call.Argument(1), "jsonpatch.apply", "patch")
+	patchData, _ := json.Marshal(patch)
+
+	var (
+		result []byte
+		err    error
+	)
+	switch patch.(type) {
+	case []any:
+		decoded, decodeErr := jsonpatch.DecodePatch(patchData)
+		if decodeErr != nil {
+			panic(call.Otto.MakeTypeError("jsonpatch.apply: " + decodeErr.Error()))
+		}
+		options := jsonpatch.NewApplyOptions()
+		options.SupportNegativeIndices = false
+		options.AccumulatedCopySizeLimit = jsonPatchCopyLimit
+		result, err = decoded.ApplyWithOptions(doc, options)
+	case map[string]any:
+		result, err = jsonpatch.MergePatch(doc, patchData)
+	default:
+		panic(call.Otto.MakeTypeError("jsonpatch.apply: patch must be an array of operations or a merge patch object"))
+	}
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			panic(call.Otto.MakeCustomError("Error", "jsonpatch.apply: test operation failed"))
+		}
+		panic(call.Otto.MakeCustomError("Error", "jsonpatch.apply: "+err.Error()))
+	}
+	return queryResult(call.Otto, "jsonpatch.apply", json.RawMessage(result))
+}
+
+// diffJSONPatch appends the operations turning a into b at path: object members are compared in key order, array
+// elements by index, and values of different types are replaced
+func diffJSONPatch(path string, a, b any, ops *[]jsonPatchOp) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for key := range a {
+				keys = append(keys, key)
+			}
+			for key := range b {
+				if _, ok := a[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+
+			for _, key := range keys {
+				member := path + "/" + escapeJSONPointer(key)
+				av, inA := a[key]
+				bv, inB := b[key]
+				switch {
+				case !inB:
+					*ops = append(*ops, jsonPatchOp{Op: "remove", Path: member})
+				case !inA:
+					*ops = append(*ops, jsonPatchOp{Op: "add", Path: member, Value: bv})
+				default:
+					diffJSONPatch(member, av, bv, ops)
+				}
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			common := min(len(a), len(b))
+			for i := range common {
+				diffJSONPatch(path+"/"+strconv.Itoa(i), a[i], b[i], ops)
+			}
+			// Removed from the end, so the indexes of the remaining elements stay valid
+			for i := len(a) - 1; i >= common; i-- {
+				*ops = append(*ops, jsonPatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+			}
+			for i := common; i < len(b); i++ {
+				*ops = append(*ops, jsonPatchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, jsonPatchOp{Op: "replace", Path: path, Value: b})
+	}
+}
+
+// escapeJSONPointer escapes a reference token of a JSON Pointer (RFC 6901)
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,208 @@
+package jsmachine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// defaultLockTTL is used when lock.acquire is called without a TTL
+const defaultLockTTL = 30 * time.Second
+
+// distributedLocker is implemented by the RR lock plugin and coordinates locks across nodes
+type distributedLocker interface {
+	// Lock acquires resource for id, returning false if it is held by someone else
+	Lock(resource, id string, ttl time.Duration) (bool, error)
+	// Release frees resource if it is held by id
+	Release(resource, id string) (bool, error)
+}
+
+// localLocker is an in-process distributedLocker used when no lock plugin is available
+type localLocker struct {
+	mu    sync.Mutex
+	locks map[string]localLock
+}
+
+// localLock is a held lock and its expiry
+type localLock struct {
+	owner   string
+	expires time.Time
+}
+
+// newLocalLocker creates an empty lock table
+func newLocalLocker() *localLocker {
+	return &localLocker{
+		locks: make(map[string]localLock),
+	}
+}
+
+// Lock implements distributedLocker
+func (l *localLocker) Lock(resource, id string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if held, ok := l.locks[resource]; ok && held.owner != id && now.Before(held.expires) {
+		return false, nil
+	}
+
+	l.locks[resource] = localLock{owner: id, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release implements distributedLocker
+func (l *localLocker) Release(resource, id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	held, ok := l.locks[resource]
+	if !ok || held.owner != id {
+		return false, nil
+	}
+
+	delete(l.locks, resource)
+	return true, nil
+}
+
+// heldLocks are the locks acquired by one execution
+type heldLocks struct {
+	owner string
+	names map[string]struct{}
+}
+
+// LockBinding lets scripts coordinate through named locks
+// Locks are owned by the execution that acquired them and released when it completes
+type LockBinding struct {
+	plugin *Plugin
+	local  *localLocker
+
+	mu   sync.Mutex
+	held map[*execution]*heldLocks
+}
+
+// newLockBinding creates a new lock binding
+func newLockBinding(plugin *Plugin) *LockBinding {
+	return &LockBinding{
+		plugin: plugin,
+		local:  newLocalLocker(),
+		held:   make(map[*execution]*heldLocks),
+	}
+}
+
+// inject injects the lock object into the VM
+func (l *LockBinding) inject(vm *otto.Otto) error {
+	lockObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// lock.acquire(name, ttlMs) - returns true if the lock was acquired
+	if err := lockObj.Set("acquire", l.acquire); err != nil {
+		return err
+	}
+
+	// lock.release(name) - returns true if the lock was held and released
+	if err := lockObj.Set("release", l.release); err != nil {
+		return err
+	}
+
+	return vm.Set("lock", lockObj)
+}
+
+// locker returns the lock plugin when collected, the in-process table otherwise
+func (l *LockBinding) locker() distributedLocker {
+	if l.plugin.lockPlugin != nil {
+		return l.plugin.lockPlugin
+	}
+	return l.local
+}
+
+// acquire tries to take the named lock without waiting
+func (l *LockBinding) acquire(call otto.FunctionCall) otto.Value {
+	name := call.Argument(0).String()
+	exec := l.plugin.executionFor(call.Otto)
+	if name == "" || exec == nil {
+		return otto.FalseValue()
+	}
+
+	ttl := defaultLockTTL
+	if arg := call.Argument(1); arg.IsNumber() {
+		if ms, err := arg.ToInteger(); err == nil && ms > 0 {
+			ttl = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	l.mu.Lock()
+	held, ok := l.held[exec]
+	if !ok {
+		held = &heldLocks{owner: newRecordID(), names: make(map[string]struct{})}
+		l.held[exec] = held
+	}
+	l.mu.Unlock()
+
+	acquired, err := l.locker().Lock(name, held.owner, ttl)
+	if err != nil {
+		l.plugin.log.Warn("failed to acquire lock", zap.String("lock", name), zap.Error(err))
+		return otto.FalseValue()
+	}
+	if !acquired {
+		return otto.FalseValue()
+	}
+
+	l.mu.Lock()
+	held.names[name] = struct{}{}
+	l.mu.Unlock()
+
+	return otto.TrueValue()
+}
+
+// release frees a lock held by the current execution
+func (l *LockBinding) release(call otto.FunctionCall) otto.Value {
+	name := call.Argument(0).String()
+	exec := l.plugin.executionFor(call.Otto)
+	if exec == nil {
+		return otto.FalseValue()
+	}
+
+	l.mu.Lock()
+	held, ok := l.held[exec]
+	if ok {
+		_, ok = held.names[name]
+		delete(held.names, name)
+	}
+	l.mu.Unlock()
+
+	if !ok {
+		return otto.FalseValue()
+	}
+
+	released, err := l.locker().Release(name, held.owner)
+	if err != nil {
+		l.plugin.log.Warn("failed to release lock", zap.String("lock", name), zap.Error(err))
+		return otto.FalseValue()
+	}
+	if !released {
+		return otto.FalseValue()
+	}
+	return otto.TrueValue()
+}
+
+// releaseHeld frees every lock still held by exec; called when the execution completes
+func (l *LockBinding) releaseHeld(exec *execution) {
+	l.mu.Lock()
+	held, ok := l.held[exec]
+	delete(l.held, exec)
+	l.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	for name := range held.names {
+		if _, err := l.locker().Release(name, held.owner); err != nil {
+			l.plugin.log.Warn("failed to release lock", zap.String("lock", name), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// Outcomes counted by js_mail_messages_total
+const (
+	mailSent        = "sent"
+	mailFailed      = "failed"
+	mailRateLimited = "rate_limited"
+)
+
+// mailRateLimitKey is the ratelimit key shared by every mail.send call
+const mailRateLimitKey = "js:mail"
+
+// MailBinding sends email for scripts through the configured driver
+// Calls are synchronous: mail.send returns once the server accepted the message
+type MailBinding struct {
+	plugin *Plugin
+}
+
+// newMailBinding creates a new mail binding
+func newMailBinding(plugin *Plugin) *MailBinding {
+	return &MailBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the mail object into the VM
+func (m *MailBinding) inject(vm *otto.Otto) error {
+	mailObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// mail.send({to, cc, bcc, replyTo, subject, text, html, template, data}) - returns the Message-ID
+	if err := mailObj.Set("send", m.send); err != nil {
+		return err
+	}
+
+	return vm.Set("mail", mailObj)
+}
+
+// send composes and sends a message, returning its Message-ID (null in mock mode)
+// Throws a TypeError for an invalid message and an Error when sending fails or the rate limit is reached
+func (m *MailBinding) send(call otto.FunctionCall) otto.Value {
+	cfg := m.plugin.cfg.Mail
+	if cfg == nil {
+		panic(call.Otto.MakeCustomError("Error", "mail.send: js.mail is not configured"))
+	}
+
+	msg := m.message(call, cfg)
+	if m.plugin.mocked(call.Otto) {
+		return otto.NullValue()
+	}
+
+	if cfg.MaxPerMinute > 0 {
+		allowed, err := m.plugin.rateLimiter.allow(mailRateLimitKey, cfg.MaxPerMinute, time.Minute)
+		if err != nil {
+			m.plugin.log.Warn("mail rate limiter failed", zap.Error(err))
+		}
+		if !allowed {
+			m.plugin.mailMessages.WithLabelValues(mailRateLimited).Inc()
+			panic(call.Otto.MakeCustomError("Error", fmt.Sprintf("mail.send: rate limit of %d messages per minute reached", cfg.MaxPerMinute)))
+		}
+	}
+
+	exec := m.plugin.executionFor(call.Otto)
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", "mail.send: no execution is running"))
+	}
+	ctx, cancel := context.WithTimeout(exec.ctx, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	id := newMessageID(msg.from)
+	if err := m.plugin.mailer.send(ctx, msg, id, composeMail(msg, id, time.Now())); err != nil {
+		m.plugin.mailMessages.WithLabelValues(mailFailed).Inc()
+		m.plugin.log.Warn("mail not sent", zap.String("message_id", id), zap.Error(err))
+		panic(call.Otto.MakeCustomError("Error", "mail.send: "+err.Error()))
+	}
+	m.plugin.mailMessages.WithLabelValues(mailSent).Inc()
+
+	return stringValue(id)
+}
+
+// message reads and validates the message object, rendering its template
+func (m *MailBinding) message(call otto.FunctionCall, cfg *MailConfig) *mailMessage {
+	arg := call.Argument(0)
+	if !arg.IsObject() {
+		panic(call.Otto.MakeTypeError("mail.send: message must be an object"))
+	}
+	obj := arg.Object()
+
+	from, _ := netmail.ParseAddress(cfg.From) // validated with the configuration
+	msg := &mailMessage{
+		from:    from,
+		to:      m.addresses(call, obj, "to"),
+		cc:      m.addresses(call, obj, "cc"),
+		bcc:     m.addresses(call, obj, "bcc"),
+		subject: m.string(obj, "subject"),
+		text:    m.string(obj, "text"),
+		html:    m.string(obj, "html"),
+	}
+	if replyTo := m.addresses(call, obj, "replyTo"); len(replyTo) > 0 {
+		msg.replyTo = replyTo[0]
+	}
+
+	if name := m.string(obj, "template"); name != "" {
+		tpl, ok := m.plugin.mailTemplates[name]
+		if !ok {
+			panic(call.Otto.MakeTypeError("mail.send: unknown template " + name))
+		}
+		var data interface{}
+		if v, _ := obj.Get("data"); v.IsDefined() {
+			data, _ = v.Export()
+		}
+		if err := tpl.render(msg, data); err != nil {
+			panic(call.Otto.MakeCustomError("TemplateError", "mail.send: "+err.Error()))
+		}
+	}
+
+	switch recipients := len(msg.recipients()); {
+	case len(msg.to) == 0:
+		panic(call.Otto.MakeTypeError("mail.send: to is required"))
+	case recipients > cfg.MaxRecipients:
+		panic(call.Otto.MakeTypeError(fmt.Sprintf("mail.send: %d recipients exceed mail.max_recipients (%d)", recipients, cfg.MaxRecipients)))
+	case msg.subject == "":
+		panic(call.Otto.MakeTypeError("mail.send: subject is required"))
+	case strings.ContainsAny(msg.subject, "\r\n"):
+		panic(call.Otto.MakeTypeError("mail.send: subject must be a single line"))
+	case msg.text == "" && msg.html == "":
+		panic(call.Otto.MakeTypeError("mail.send: text or html is required"))
+	}
+	return msg
+}
+
+// addresses reads a field holding one address or an array of addresses
+func (m *MailBinding) addresses(call otto.FunctionCall, obj *otto.Object, field string) []*netmail.Address {
+	v, _ := obj.Get(field)
+	if !v.IsDefined() || v.IsNull() {
+		return nil
+	}
+
+	var raw []string
+	if v.Class() == "Array" {
+		exported, _ := v.Export()
+		items, _ := exported.([]interface{})
+		for _, item := range items {
+			raw = append(raw, fmt.Sprint(item))
+		}
+		if strs, ok := exported.([]string); ok {
+			raw = strs
+		}
+	} else {
+		raw = []string{v.String()}
+	}
+
+	addrs := make([]*netmail.Address, 0, len(raw))
+	for _, s := range raw {
+		// ParseAddress rejects line breaks, so addresses cannot inject headers
+		addr, err := netmail.ParseAddress(s)
+		if err != nil {
+			panic(call.Otto.MakeTypeError(fmt.Sprintf("mail.send: invalid %s address %q", field, s)))
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// string reads an optional string field
+func (m *MailBinding) string(obj *otto.Object, field string) string {
+	v, _ := obj.Get(field)
+	if !v.IsDefined() || v.IsNull() {
+		return ""
+	}
+	return v.String()
+}
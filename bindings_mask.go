@@ -0,0 +1,150 @@
+package jsmachine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/robertkrimen/otto"
+)
+
+// Strategies of mask rules
+const (
+	maskHash     = "hash"
+	maskTruncate = "truncate"
+	maskNull     = "null"
+)
+
+// maskRule is a compiled mask rule
+type maskRule struct {
+	path     jp.Expr
+	strategy string
+	keep     int
+	fromEnd  bool
+}
+
+// MaskBinding applies the data masking policies of the configuration in Go, so PII scrubbing scripts share one
+// central policy instead of each deleting fields by hand
+type MaskBinding struct {
+	key      []byte
+	policies map[string][]maskRule
+}
+
+// newMaskBinding creates a new mask binding with the policies of js.mask
+func newMaskBinding(plugin *Plugin) *MaskBinding {
+	m := &MaskBinding{
+		policies: make(map[string][]maskRule),
+	}
+	cfg := plugin.cfg.Mask
+	if cfg == nil {
+		return m
+	}
+
+	m.key = []byte(cfg.HashKey)
+	for name, rules := range cfg.Policies {
+		compiled := make([]maskRule, 0, len(rules))
+		for _, rule := range rules {
+			// Validated with the configuration
+			path, _ := compileMaskPath(rule.Path)
+			compiled = append(compiled, maskRule{path: path, strategy: rule.Strategy, keep: rule.Keep, fromEnd: rule.FromEnd})
+		}
+		m.policies[name] = compiled
+	}
+	return m
+}
+
+// compileMaskPath parses a JSONPath expression and checks it selects values that can be replaced
+func compileMaskPath(source string) (jp.Expr, error) {
+	path, err := jp.ParseString(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) < 2 {
+		return nil, errors.New("the path must select values below the root")
+	}
+	if _, err := path.Modify(map[string]any{}, func(element any) (any, bool) { return element, false }); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// inject injects the mask object into the VM
+func (m *MaskBinding) inject(vm *otto.Otto) error {
+	maskObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// mask.apply(doc, policy) - returns a copy of doc masked by a js.mask policy
+	if err := maskObj.Set("apply", m.apply); err != nil {
+		return err
+	}
+
+	return vm.Set("mask", maskObj)
+}
+
+// apply returns a copy of a document with the rules of a js.mask policy applied in order; doc is a value or a JSON
+// string, and paths that match nothing are skipped
+// Throws a TypeError for unknown policies and documents that are not JSON
+func (m *MaskBinding) apply(call otto.FunctionCall) otto.Value {
+	name := call.Argument(1).String()
+	rules, ok := m.policies[name]
+	if !ok {
+		panic(call.Otto.MakeTypeError("mask.apply: unknown policy " + name))
+	}
+
+	doc := jsonArgument(call.Otto, call.Argument(0), "mask.apply", "doc")
+	for _, rule := range rules {
+		masked, err := rule.path.Modify(doc, func(element any) (any, bool) {
+			return m.mask(rule, element), true
+		})
+		if err != nil {
+			panic(call.Otto.MakeCustomError("Error", "mask.apply: "+err.Error()))
+		}
+		doc = masked
+	}
+	return queryResult(call.Otto, "mask.apply", doc)
+}
+
+// mask returns the masked form of one value; null stays null with every strategy
+func (m *MaskBinding) mask(rule maskRule, value any) any {
+	if value == nil || rule.strategy == maskNull {
+		return nil
+	}
+
+	switch rule.strategy {
+	case maskHash:
+		// Strings are hashed as they are, other values as their JSON encoding
+		text, ok := value.(string)
+		if !ok {
+			data, _ := json.Marshal(value)
+			text = string(data)
+		}
+		mac := hmac.New(sha256.New, m.key)
+		mac.Write([]byte(text))
+		return hex.EncodeToString(mac.Sum(nil))
+	case maskTruncate:
+		var runes []rune
+		switch v := value.(type) {
+		case string:
+			runes = []rune(v)
+		case float64:
+			runes = []rune(strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			// Objects, arrays and booleans have no characters to keep
+			return nil
+		}
+		if len(runes) <= rule.keep {
+			return string(runes)
+		}
+		if rule.fromEnd {
+			return string(runes[len(runes)-rule.keep:])
+		}
+		return string(runes[:rule.keep])
+	}
+	return nil
+}
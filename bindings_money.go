@@ -0,0 +1,99 @@
+package jsmachine
+
+import (
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/shopspring/decimal"
+)
+
+// MoneyBinding converts amounts between currencies with the exchange rates of js.money, so pricing scripts share one
+// conversion instead of each fetching and applying rates in floating point
+type MoneyBinding struct {
+	plugin *Plugin
+}
+
+// newMoneyBinding creates a new money binding
+func newMoneyBinding(plugin *Plugin) *MoneyBinding {
+	return &MoneyBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the money object into the VM
+func (m *MoneyBinding) inject(vm *otto.Otto) error {
+	moneyObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// money.convert(amount, from, to) - returns the converted amount as a decimal string
+	if err := moneyObj.Set("convert", m.convert); err != nil {
+		return err
+	}
+
+	// money.rate(from, to) - returns how much of to one unit of from buys, as a decimal string
+	if err := moneyObj.Set("rate", m.rate); err != nil {
+		return err
+	}
+
+	return vm.Set("money", moneyObj)
+}
+
+// convert converts an amount, given as a string or number, without rounding it: the result keeps up to 16 decimal
+// places for decimal.round to round to the currency's precision
+// Throws a TypeError for invalid amounts and currency codes and an Error when a rate is not available
+func (m *MoneyBinding) convert(call otto.FunctionCall) otto.Value {
+	amount := decimalArgument(call, 0)
+	from := m.currency(call, "money.convert", 1)
+	to := m.currency(call, "money.convert", 2)
+
+	rates := m.rates(call, "money.convert")
+	fromRate, err := rates.get(from)
+	if err == nil {
+		var toRate decimal.Decimal
+		if toRate, err = rates.get(to); err == nil {
+			return stringValue(amount.Mul(toRate).DivRound(fromRate, defaultDivisionPlaces).String())
+		}
+	}
+	panic(call.Otto.MakeCustomError("Error", "money.convert: "+err.Error()))
+}
+
+// rate returns the exchange rate between two currencies, derived through the base currency
+// Throws a TypeError for invalid currency codes and an Error when a rate is not available
+func (m *MoneyBinding) rate(call otto.FunctionCall) otto.Value {
+	from := m.currency(call, "money.rate", 0)
+	to := m.currency(call, "money.rate", 1)
+
+	rate, err := m.rates(call, "money.rate").rate(from, to)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "money.rate: "+err.Error()))
+	}
+	return stringValue(rate.String())
+}
+
+// currency reads a currency code argument; lowercase codes are accepted
+func (m *MoneyBinding) currency(call otto.FunctionCall, fn string, index int) string {
+	code := strings.ToUpper(call.Argument(index).String())
+	if !call.Argument(index).IsString() || !currencyCode.MatchString(code) {
+		panic(call.Otto.MakeTypeError(fn + ": invalid currency code " + call.Argument(index).String()))
+	}
+	return code
+}
+
+// rates returns the current exchange rates, fetching them when the http source has none cached
+func (m *MoneyBinding) rates(call otto.FunctionCall, fn string) *exchangeRates {
+	source := m.plugin.rates
+	if source == nil {
+		panic(call.Otto.MakeCustomError("Error", fn+": js.money is not configured"))
+	}
+	if _, remote := source.(*httpRates); remote && m.plugin.mocked(call.Otto) {
+		panic(call.Otto.MakeCustomError("Error", fn+": not available in mock mode"))
+	}
+
+	rates, err := source.rates()
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", fn+": "+err.Error()))
+	}
+	return rates
+}
@@ -0,0 +1,111 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// Outcomes counted by js_mqtt_messages_total
+const (
+	mqttPublished = "published"
+	mqttFailed    = "failed"
+)
+
+// MQTTBinding publishes messages to the broker configured in js.mqtt
+type MQTTBinding struct {
+	plugin *Plugin
+}
+
+// newMQTTBinding creates a new mqtt binding
+func newMQTTBinding(plugin *Plugin) *MQTTBinding {
+	return &MQTTBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the mqtt object into the VM
+func (m *MQTTBinding) inject(vm *otto.Otto) error {
+	mqttObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// mqtt.publish(topic, payload, qos, {retain}) - returns true once the broker accepted the message
+	if err := mqttObj.Set("publish", m.publish); err != nil {
+		return err
+	}
+
+	return vm.Set("mqtt", mqttObj)
+}
+
+// publish sends a message with QoS 0, 1 or 2 (default: 0) and returns true (null in mock mode)
+// Objects are sent as JSON, other values as strings; throws when the topic is not allowed or publishing fails
+func (m *MQTTBinding) publish(call otto.FunctionCall) otto.Value {
+	client := m.plugin.mqtt
+	if client == nil {
+		panic(call.Otto.MakeCustomError("Error", "mqtt.publish: js.mqtt is not configured"))
+	}
+	cfg := m.plugin.cfg.MQTT
+
+	topic := call.Argument(0).String()
+	if topic == "" || len(topic) > mqttMaxString || strings.ContainsAny(topic, "+#\x00") {
+		panic(call.Otto.MakeTypeError("mqtt.publish: topic must be a non-empty topic name without wildcards"))
+	}
+	if !mqttTopicAllowed(topic, cfg.AllowedTopics) {
+		panic(call.Otto.MakeCustomError("Error", "mqtt.publish: "+topic+": topic is not in mqtt.allowed_topics"))
+	}
+
+	var payload []byte
+	switch v := call.Argument(1); {
+	case v.IsObject():
+		encoded, err := call.Otto.Call("JSON.stringify", nil, v)
+		if err != nil {
+			panic(call.Otto.MakeTypeError("mqtt.publish: payload cannot be serialized to JSON"))
+		}
+		payload = []byte(encoded.String())
+	case v.IsDefined() && !v.IsNull():
+		payload = []byte(v.String())
+	}
+	if len(payload) > cfg.MaxPayloadBytes {
+		panic(call.Otto.MakeTypeError(fmt.Sprintf("mqtt.publish: payload exceeds mqtt.max_payload_bytes (%d)", cfg.MaxPayloadBytes)))
+	}
+
+	var qos int64
+	if v := call.Argument(2); v.IsDefined() && !v.IsNull() {
+		qos, _ = v.ToInteger()
+		if qos < 0 || qos > 2 {
+			panic(call.Otto.MakeTypeError("mqtt.publish: qos must be 0, 1 or 2"))
+		}
+	}
+	retain := false
+	if v := call.Argument(3); v.IsObject() {
+		if r, _ := v.Object().Get("retain"); r.IsBoolean() {
+			retain, _ = r.ToBoolean()
+		}
+	}
+
+	if m.plugin.mocked(call.Otto) {
+		return otto.NullValue()
+	}
+
+	parent := context.Background()
+	if exec := m.plugin.executionFor(call.Otto); exec != nil {
+		parent = exec.ctx
+	}
+	ctx, cancel := context.WithTimeout(parent, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	if err := client.publish(ctx, topic, payload, byte(qos), retain); err != nil {
+		m.plugin.mqttMessages.WithLabelValues(mqttFailed).Inc()
+		m.plugin.log.Warn("mqtt publish failed", zap.String("topic", topic), zap.Error(err))
+		panic(call.Otto.MakeCustomError("Error", "mqtt.publish: "+topic+": "+err.Error()))
+	}
+	m.plugin.mqttMessages.WithLabelValues(mqttPublished).Inc()
+
+	return otto.TrueValue()
+}
@@ -0,0 +1,138 @@
+package jsmachine
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// NetBinding provides DNS resolution and IP utilities to JavaScript
+type NetBinding struct {
+	plugin   *Plugin
+	resolver *net.Resolver
+	policy   addressPolicy
+}
+
+// newNetBinding creates a new net binding
+func newNetBinding(plugin *Plugin) *NetBinding {
+	allowed, _ := parsePrefixes("net.allowed_cidrs", plugin.cfg.Net.AllowedCIDRs) // validated with the configuration
+	return &NetBinding{
+		plugin:   plugin,
+		resolver: net.DefaultResolver,
+		policy:   addressPolicy{allowed: allowed},
+	}
+}
+
+// inject injects the net object into the VM
+func (n *NetBinding) inject(vm *otto.Otto) error {
+	netObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// net.lookup(host) - returns resolved IP addresses or null
+	if err := netObj.Set("lookup", n.lookup); err != nil {
+		return err
+	}
+
+	// net.isPrivateIP(ip)
+	if err := netObj.Set("isPrivateIP", n.isPrivateIP); err != nil {
+		return err
+	}
+
+	// net.cidrContains(cidr, ip)
+	if err := netObj.Set("cidrContains", n.cidrContains); err != nil {
+		return err
+	}
+
+	return vm.Set("net", netObj)
+}
+
+// lookup resolves host to its IP addresses
+// Returns null when the host is not in js.net.allowed_hosts or resolution fails
+// Addresses in denied ranges (private, loopback, link-local, ...) are left out unless js.net.allowed_cidrs lists them
+func (n *NetBinding) lookup(call otto.FunctionCall) otto.Value {
+	host := strings.ToLower(strings.TrimSuffix(call.Argument(0).String(), "."))
+	cfg := n.plugin.cfg.Net
+
+	if !hostAllowed(host, cfg.AllowedHosts) {
+		n.plugin.log.Warn("DNS lookup of host not in net.allowed_hosts", zap.String("host", host))
+		return otto.NullValue()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.LookupTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	resolved, err := n.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		n.plugin.log.Debug("DNS lookup failed", zap.String("host", host), zap.Error(err))
+		return otto.NullValue()
+	}
+
+	addrs := make([]string, 0, len(resolved))
+	for _, addr := range resolved {
+		if n.policy.permits(addr) {
+			addrs = append(addrs, addr.Unmap().String())
+		}
+	}
+	if len(addrs) == 0 {
+		n.plugin.log.Debug("DNS lookup returned denied addresses only", zap.String("host", host))
+		return otto.NullValue()
+	}
+
+	result, err := call.Otto.ToValue(addrs)
+	if err != nil {
+		return otto.NullValue()
+	}
+	return result
+}
+
+// isPrivateIP reports whether ip is loopback, private (RFC 1918 / RFC 4193), link-local or unspecified
+func (n *NetBinding) isPrivateIP(call otto.FunctionCall) otto.Value {
+	addr, err := netip.ParseAddr(call.Argument(0).String())
+	if err != nil {
+		return otto.FalseValue()
+	}
+
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
+
+// cidrContains reports whether ip belongs to the cidr network
+func (n *NetBinding) cidrContains(call otto.FunctionCall) otto.Value {
+	prefix, err := netip.ParsePrefix(call.Argument(0).String())
+	if err != nil {
+		return otto.FalseValue()
+	}
+	addr, err := netip.ParseAddr(call.Argument(1).String())
+	if err != nil {
+		return otto.FalseValue()
+	}
+
+	if prefix.Contains(addr.Unmap()) {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
+
+// hostAllowed matches host against allowlist patterns ("example.com", "*.example.com", "*")
+func hostAllowed(host string, allowed []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, pattern := range allowed {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
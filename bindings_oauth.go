@@ -0,0 +1,74 @@
+package jsmachine
+
+import (
+	"context"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// OAuthBinding hands out OAuth2 client-credentials tokens of the configured providers
+// Tokens are cached for all executions of the node and refreshed shortly before they expire
+type OAuthBinding struct {
+	plugin *Plugin
+	tokens *oauthTokens
+}
+
+// newOAuthBinding creates a new oauth binding
+func newOAuthBinding(plugin *Plugin) *OAuthBinding {
+	return &OAuthBinding{
+		plugin: plugin,
+		tokens: newOAuthTokens(),
+	}
+}
+
+// inject injects the oauth object into the VM
+func (o *OAuthBinding) inject(vm *otto.Otto) error {
+	oauthObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// oauth.token(provider) - returns an access token
+	if err := oauthObj.Set("token", o.token); err != nil {
+		return err
+	}
+
+	return vm.Set("oauth", oauthObj)
+}
+
+// token returns a valid access token of a js.oauth.providers provider
+// Throws a TypeError for unknown providers and an Error when no token can be obtained
+func (o *OAuthBinding) token(call otto.FunctionCall) otto.Value {
+	name := call.Argument(0).String()
+	var provider *OAuthProviderConfig
+	if cfg := o.plugin.cfg.OAuth; cfg != nil {
+		provider = cfg.Providers[name]
+	}
+	if provider == nil {
+		panic(call.Otto.MakeTypeError("oauth.token: unknown provider " + name))
+	}
+	if o.plugin.mocked(call.Otto) {
+		panic(call.Otto.MakeCustomError("Error", "oauth.token: not available in mock mode"))
+	}
+
+	client, _ := o.plugin.fetchClients.get(provider.Client) // validated with the configuration
+	margin := time.Duration(o.plugin.cfg.OAuth.RefreshMarginMs) * time.Millisecond
+
+	token, err := o.tokens.get(name, margin, func() (*oauthToken, error) {
+		// The token is shared, so its request is not bound to the execution that happens to trigger it
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.plugin.cfg.Fetch.TimeoutMs)*time.Millisecond)
+		defer cancel()
+		return requestOAuthToken(ctx, client, provider)
+	})
+	if err != nil {
+		if token == "" {
+			panic(call.Otto.MakeCustomError("Error", "oauth.token: "+name+": "+err.Error()))
+		}
+		o.plugin.log.Warn("OAuth token refresh failed, using the current token", zap.String("provider", name), zap.Error(err))
+	}
+
+	result, _ := call.Otto.ToValue(token)
+	return result
+}
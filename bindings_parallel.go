@@ -0,0 +1,227 @@
+package jsmachine
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// maxParallelCalls bounds the calls one parallel() invocation starts
+const maxParallelCalls = 32
+
+// settleScript calls a function and describes its outcome like parallel() describes binding calls
+const settleScript = `(function (fn) {
+	try { return {ok: true, value: fn()}; } catch (e) { return {ok: false, error: e}; }
+})`
+
+// pendingCall is a binding call prepared on the VM goroutine whose I/O can run on another goroutine
+type pendingCall interface {
+	// run performs the I/O under ctx; it must not use the VM
+	run(ctx context.Context)
+
+	// value converts the outcome on the VM goroutine, throwing like the binding itself
+	value(vm *otto.Otto) otto.Value
+}
+
+// ParallelBinding runs I/O binding calls concurrently: otto has no async I/O, so without it every fetch or gRPC
+// call of a script waits for the previous one
+type ParallelBinding struct {
+	plugin *Plugin
+}
+
+// newParallelBinding creates a new parallel binding
+func newParallelBinding(plugin *Plugin) *ParallelBinding {
+	return &ParallelBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the parallel function into the VM
+func (b *ParallelBinding) inject(vm *otto.Otto) error {
+	// parallel([descriptor or function, ...], {timeoutMs}) - returns [{ok, value, error}, ...]
+	return vm.Set("parallel", b.parallel)
+}
+
+// preparers returns the binding calls a descriptor may name, keyed by the function scripts call
+func (b *ParallelBinding) preparers() map[string]func(call otto.FunctionCall) pendingCall {
+	bindings := b.plugin.bindings
+	return map[string]func(call otto.FunctionCall) pendingCall{
+		"fetch":         func(call otto.FunctionCall) pendingCall { return bindings.fetch.prepareBuffered(call) },
+		"grpc.call":     func(call otto.FunctionCall) pendingCall { return bindings.grpc.prepare(call) },
+		"graphql.query": func(call otto.FunctionCall) pendingCall { return bindings.graphql.prepareQuery(call) },
+		"graphql.batch": func(call otto.FunctionCall) pendingCall { return bindings.graphql.prepareBatch(call) },
+	}
+}
+
+// parallel starts the binding calls described by the first argument on their own goroutines, calls the functions
+// it holds on the VM meanwhile, and returns the outcome of each entry in order once all of them completed
+// A descriptor is an object with one key naming the binding function and the array of its arguments as value,
+// e.g. {fetch: [url, options]}; calls still running when timeoutMs elapses are cancelled and fail
+func (b *ParallelBinding) parallel(call otto.FunctionCall) otto.Value {
+	exec := b.plugin.executionFor(call.Otto)
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", "parallel: no execution is running"))
+	}
+
+	arg := call.Argument(0)
+	if !arg.IsObject() || arg.Class() != "Array" {
+		panic(call.Otto.MakeTypeError("parallel: calls must be an array"))
+	}
+	list := arg.Object()
+	lengthValue, _ := list.Get("length")
+	length, _ := lengthValue.ToInteger()
+	if length > maxParallelCalls {
+		panic(call.Otto.MakeTypeError("parallel: at most " + strconv.Itoa(maxParallelCalls) + " calls are allowed"))
+	}
+
+	timeout := time.Duration(0)
+	if options := call.Argument(1); options.IsObject() {
+		if v, _ := options.Object().Get("timeoutMs"); v.IsDefined() {
+			ms, err := v.ToInteger()
+			if !v.IsNumber() || err != nil || ms < 1 {
+				panic(call.Otto.MakeTypeError("parallel: timeoutMs must be a positive number"))
+			}
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	// Calls in flight are cancelled when the VM is interrupted while a function runs
+	ctx, cancel := context.WithCancel(exec.ctx)
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Prepare every descriptor before starting any I/O, so invalid arguments are reported consistently
+	items := make([]otto.Value, length)
+	pending := make([]pendingCall, length)
+	results := make([]otto.Value, length)
+	for i := range length {
+		items[i], _ = list.Get(strconv.FormatInt(i, 10))
+		if items[i].IsFunction() {
+			continue
+		}
+		results[i] = b.settle(call.Otto, func() otto.Value {
+			pending[i] = b.prepare(call.Otto, exec, items[i])
+			return otto.UndefinedValue()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range pending {
+		if p == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.run(ctx)
+		}()
+	}
+
+	// Functions run on the VM goroutine while the binding calls are in flight
+	if slices.ContainsFunc(items, otto.Value.IsFunction) {
+		settle, err := call.Otto.Run(settleScript)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("Error", "parallel: "+err.Error()))
+		}
+		for i, item := range items {
+			if !item.IsFunction() {
+				continue
+			}
+			if results[i], err = settle.Call(otto.UndefinedValue(), item); err != nil {
+				panic(call.Otto.MakeCustomError("Error", "parallel: "+err.Error()))
+			}
+		}
+	}
+
+	wg.Wait()
+	for i, p := range pending {
+		if p != nil {
+			results[i] = b.settle(call.Otto, func() otto.Value { return p.value(call.Otto) })
+		}
+	}
+
+	array, err := call.Otto.Object(`[]`)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "parallel: "+err.Error()))
+	}
+	for _, result := range results {
+		if _, err := array.Call("push", result); err != nil {
+			panic(call.Otto.MakeCustomError("Error", "parallel: "+err.Error()))
+		}
+	}
+	return array.Value()
+}
+
+// prepare validates a descriptor and prepares the binding call it names
+func (b *ParallelBinding) prepare(vm *otto.Otto, exec *execution, item otto.Value) pendingCall {
+	if !item.IsObject() {
+		panic(vm.MakeTypeError("parallel: every call must be a function or a descriptor object"))
+	}
+	keys := item.Object().Keys()
+	if len(keys) != 1 {
+		panic(vm.MakeTypeError("parallel: a descriptor names exactly one binding function"))
+	}
+	name := keys[0]
+
+	prepare, ok := b.preparers()[name]
+	if !ok {
+		panic(vm.MakeTypeError("parallel: " + name + " cannot be called in parallel"))
+	}
+	if binding, _, _ := strings.Cut(name, "."); !exec.pool.hasBinding(binding) {
+		panic(vm.MakeTypeError("parallel: " + binding + " is not available"))
+	}
+
+	args, _ := item.Object().Get(name)
+	if !args.IsObject() || args.Class() != "Array" {
+		panic(vm.MakeTypeError("parallel: the arguments of " + name + " must be an array"))
+	}
+	argsObj := args.Object()
+	lengthValue, _ := argsObj.Get("length")
+	length, _ := lengthValue.ToInteger()
+	argumentList := make([]otto.Value, length)
+	for i := range length {
+		argumentList[i], _ = argsObj.Get(strconv.FormatInt(i, 10))
+	}
+
+	return prepare(otto.FunctionCall{Otto: vm, This: otto.UndefinedValue(), ArgumentList: argumentList})
+}
+
+// settle runs fn, returning {ok: true, value} or {ok: false, error} with the error it threw
+func (b *ParallelBinding) settle(vm *otto.Otto, fn func() otto.Value) (result otto.Value) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		thrown, ok := r.(otto.Value)
+		if !ok {
+			panic(r)
+		}
+		result = b.outcome(vm, false, thrown)
+	}()
+
+	return b.outcome(vm, true, fn())
+}
+
+// outcome builds the result object of one call
+func (b *ParallelBinding) outcome(vm *otto.Otto, ok bool, value otto.Value) otto.Value {
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "parallel: "+err.Error()))
+	}
+	_ = obj.Set("ok", ok)
+	if ok {
+		_ = obj.Set("value", value)
+	} else {
+		_ = obj.Set("error", value)
+	}
+	return obj.Value()
+}
@@ -0,0 +1,206 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/robertkrimen/otto"
+)
+
+// pdfMaxBytes is the largest document pdf.extractText accepts, and the ceiling of its maxBytes option
+const pdfMaxBytes = 32 << 20
+
+// pdfPage is the text of one page
+type pdfPage struct {
+	number int
+	text   string
+}
+
+// PDFBinding extracts text from PDF documents in Go, so ingestion scripts index documents without a conversion
+// service
+type PDFBinding struct {
+	plugin *Plugin
+}
+
+// newPDFBinding creates a new pdf binding
+func newPDFBinding(plugin *Plugin) *PDFBinding {
+	return &PDFBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the pdf object into the VM
+func (b *PDFBinding) inject(vm *otto.Otto) error {
+	pdfObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// pdf.extractText(base64, {pages, maxBytes}) - returns {pageCount, pages: [{page, text}], text}
+	if err := pdfObj.Set("extractText", b.extractText); err != nil {
+		return err
+	}
+
+	return vm.Set("pdf", pdfObj)
+}
+
+// extractText extracts the text of a base64-encoded PDF document, page by page
+// Throws a TypeError for invalid arguments, a RangeError for documents above maxBytes and an Error for documents
+// that cannot be read, including encrypted ones
+func (b *PDFBinding) extractText(call otto.FunctionCall) otto.Value {
+	if !call.Argument(0).IsString() {
+		panic(call.Otto.MakeTypeError("pdf.extractText: document must be a base64 string"))
+	}
+	encoded := call.Argument(0).String()
+
+	maxBytes := pdfMaxBytes
+	var ranges [][2]int
+	if options := call.Argument(1); options.IsObject() {
+		if v, _ := options.Object().Get("maxBytes"); v.IsDefined() {
+			n, err := v.ToInteger()
+			if !v.IsNumber() || err != nil || n < 1 || n > pdfMaxBytes {
+				panic(call.Otto.MakeTypeError("pdf.extractText: maxBytes must be between 1 and " + strconv.Itoa(pdfMaxBytes)))
+			}
+			maxBytes = int(n)
+		}
+		if v, _ := options.Object().Get("pages"); v.IsDefined() {
+			var err error
+			if ranges, err = parsePageRanges(v.String()); err != nil {
+				panic(call.Otto.MakeTypeError("pdf.extractText: " + err.Error()))
+			}
+		}
+	}
+
+	// Checked before decoding, so oversized documents are never held twice
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxBytes+2 {
+		panic(call.Otto.MakeRangeError("pdf.extractText: document exceeds " + strconv.Itoa(maxBytes) + " bytes"))
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		panic(call.Otto.MakeTypeError("pdf.extractText: document is not valid base64"))
+	}
+	if len(data) > maxBytes {
+		panic(call.Otto.MakeRangeError("pdf.extractText: document exceeds " + strconv.Itoa(maxBytes) + " bytes"))
+	}
+
+	ctx := context.Background()
+	if exec := b.plugin.executionFor(call.Otto); exec != nil {
+		ctx = exec.ctx
+	}
+	count, pages, err := extractPDFText(ctx, data, ranges)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "pdf.extractText: "+err.Error()))
+	}
+	return b.result(call.Otto, count, pages)
+}
+
+// result builds the object returned by pdf.extractText
+func (b *PDFBinding) result(vm *otto.Otto, count int, pages []pdfPage) otto.Value {
+	obj, err := vm.Object(`({pages: []})`)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "pdf.extractText: "+err.Error()))
+	}
+	list, _ := obj.Get("pages")
+
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		pageObj, err := vm.Object(`({})`)
+		if err != nil {
+			panic(vm.MakeCustomError("Error", "pdf.extractText: "+err.Error()))
+		}
+		_ = pageObj.Set("page", page.number)
+		_ = pageObj.Set("text", page.text)
+		if _, err := list.Object().Call("push", pageObj); err != nil {
+			panic(vm.MakeCustomError("Error", "pdf.extractText: "+err.Error()))
+		}
+		texts[i] = page.text
+	}
+
+	_ = obj.Set("pageCount", count)
+	_ = obj.Set("text", strings.Join(texts, "\n\n"))
+	return obj.Value()
+}
+
+// extractPDFText returns the page count of a document and the text of the pages within ranges (all pages when
+// empty); ctx is checked between pages
+func extractPDFText(ctx context.Context, data []byte, ranges [][2]int) (count int, pages []pdfPage, err error) {
+	// The parser panics on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed document: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		if strings.Contains(err.Error(), "encrypted") {
+			return 0, nil, errors.New("encrypted documents are not supported")
+		}
+		return 0, nil, err
+	}
+
+	count = reader.NumPage()
+	for number := 1; number <= count; number++ {
+		if !pageSelected(ranges, number) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+
+		page := reader.Page(number)
+		if page.V.IsNull() {
+			continue
+		}
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return 0, nil, fmt.Errorf("page %d: %w", number, err)
+		}
+		pages = append(pages, pdfPage{number: number, text: text})
+	}
+	return count, pages, nil
+}
+
+// parsePageRanges parses a list of 1-based pages and ranges such as "1-3,7,10-"; an open range ends at the last page
+func parsePageRanges(spec string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil || from < 1 {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		to := from
+		if isRange {
+			if last = strings.TrimSpace(last); last == "" {
+				to = math.MaxInt
+			} else if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid page range %q", part)
+			}
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+// pageSelected reports whether a page is within ranges, or ranges is empty
+func pageSelected(ranges [][2]int, number int) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, r := range ranges {
+		if number >= r[0] && number <= r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package jsmachine
+
+import (
+	"github.com/robertkrimen/otto"
+)
+
+// ProgressBinding lets scripts report progress checkpoints to stream subscribers
+type ProgressBinding struct {
+	plugin *Plugin
+}
+
+// newProgressBinding creates a new progress binding
+func newProgressBinding(plugin *Plugin) *ProgressBinding {
+	return &ProgressBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the progress object into the VM
+func (p *ProgressBinding) inject(vm *otto.Otto) error {
+	progressObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// progress.report(data)
+	if err := progressObj.Set("report", p.report); err != nil {
+		return err
+	}
+
+	return vm.Set("progress", progressObj)
+}
+
+// report publishes a progress checkpoint for the running execution
+// It is a no-op when nobody is subscribed to the execution's request ID
+func (p *ProgressBinding) report(call otto.FunctionCall) otto.Value {
+	exec := p.plugin.executionFor(call.Otto)
+	if exec == nil || !p.plugin.streams.hasSubscribers(exec.requestID) {
+		return otto.UndefinedValue()
+	}
+
+	data, err := call.Argument(0).Export()
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+
+	p.plugin.streams.publish(streamEvent{
+		Type:      streamEventProgress,
+		RequestID: exec.requestID,
+		Data:      data,
+	})
+
+	return otto.UndefinedValue()
+}
@@ -0,0 +1,195 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// promMaxPoints is the number of points per series Prometheus accepts in a range query
+const promMaxPoints = 11000
+
+// PromBinding queries the Prometheus server configured in js.prometheus, so scripts can act on live metric values
+type PromBinding struct {
+	plugin *Plugin
+}
+
+// newPromBinding creates a new prom binding
+func newPromBinding(plugin *Plugin) *PromBinding {
+	return &PromBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the prom object into the VM
+func (b *PromBinding) inject(vm *otto.Otto) error {
+	promObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// prom.query(expr, {time}) - returns an array of {metric, value, timestamp}
+	if err := promObj.Set("query", b.query); err != nil {
+		return err
+	}
+
+	// prom.queryRange(expr, range, {end, step}) - returns an array of {metric, values: [{timestamp, value}]}
+	if err := promObj.Set("queryRange", b.queryRange); err != nil {
+		return err
+	}
+
+	return vm.Set("prom", promObj)
+}
+
+// query evaluates an instant query, at the current time unless the time option is given
+// Range vector selectors return series with values like queryRange
+func (b *PromBinding) query(call otto.FunctionCall) otto.Value {
+	expr := b.expr(call, "prom.query")
+	ts := time.Now()
+	if opts := call.Argument(1); opts.IsObject() {
+		if v, _ := opts.Object().Get("time"); v.IsDefined() {
+			ts = b.timeValue(call.Otto, "prom.query", "time", v)
+		}
+	}
+
+	client, ctx, cancel := b.client(call, "prom.query")
+	defer cancel()
+
+	result, err := client.query(ctx, expr, ts)
+	if err != nil {
+		b.plugin.log.Debug("prometheus query failed", zap.String("query", expr), zap.Error(err))
+		panic(call.Otto.MakeCustomError("Error", "prom.query: "+err.Error()))
+	}
+	return b.toValue(call.Otto, result, result.resultType == "matrix")
+}
+
+// queryRange evaluates a range query over the range ending now (or at end), with about 100 points per series
+// unless step is given; range and step are milliseconds or durations such as "1h" and "30s"
+func (b *PromBinding) queryRange(call otto.FunctionCall) otto.Value {
+	expr := b.expr(call, "prom.queryRange")
+	length := b.duration(call.Otto, "prom.queryRange", "range", call.Argument(1))
+
+	end := time.Now()
+	step := max(length/100, time.Second)
+	if opts := call.Argument(2); opts.IsObject() {
+		obj := opts.Object()
+		if v, _ := obj.Get("end"); v.IsDefined() {
+			end = b.timeValue(call.Otto, "prom.queryRange", "end", v)
+		}
+		if v, _ := obj.Get("step"); v.IsDefined() {
+			step = b.duration(call.Otto, "prom.queryRange", "step", v)
+		}
+	}
+	if length/step >= promMaxPoints {
+		panic(call.Otto.MakeTypeError(fmt.Sprintf("prom.queryRange: range / step exceeds %d points", promMaxPoints)))
+	}
+
+	client, ctx, cancel := b.client(call, "prom.queryRange")
+	defer cancel()
+
+	result, err := client.queryRange(ctx, expr, end.Add(-length), end, step)
+	if err != nil {
+		b.plugin.log.Debug("prometheus range query failed", zap.String("query", expr), zap.Error(err))
+		panic(call.Otto.MakeCustomError("Error", "prom.queryRange: "+err.Error()))
+	}
+	return b.toValue(call.Otto, result, true)
+}
+
+// expr returns the PromQL expression given as the first argument
+func (b *PromBinding) expr(call otto.FunctionCall, fn string) string {
+	if b.plugin.cfg.Prometheus == nil {
+		panic(call.Otto.MakeCustomError("Error", fn+": js.prometheus is not configured"))
+	}
+	expr := call.Argument(0)
+	if !expr.IsString() || expr.String() == "" {
+		panic(call.Otto.MakeTypeError(fn + ": expr must be a PromQL expression"))
+	}
+	return expr.String()
+}
+
+// client returns a client for the running execution, with a context that ends with the execution
+func (b *PromBinding) client(call otto.FunctionCall, fn string) (*promClient, context.Context, context.CancelFunc) {
+	exec := b.plugin.executionFor(call.Otto)
+	if exec == nil {
+		panic(call.Otto.MakeCustomError("Error", fn+": no execution is running"))
+	}
+	if exec.mock {
+		panic(call.Otto.MakeCustomError("Error", fn+": not available in mock mode"))
+	}
+
+	cfg := b.plugin.cfg.Prometheus
+	httpClient, _ := b.plugin.fetchClients.get(cfg.Client) // validated with the configuration
+	client := &promClient{cfg: cfg, client: httpClient, maxBytes: b.plugin.cfg.Fetch.MaxResponseBytes}
+
+	ctx, cancel := context.WithTimeout(exec.ctx, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	return client, ctx, cancel
+}
+
+// duration reads a positive duration given in milliseconds or as a Go duration string
+func (b *PromBinding) duration(vm *otto.Otto, fn, name string, v otto.Value) time.Duration {
+	var d time.Duration
+	switch {
+	case v.IsNumber():
+		ms, _ := v.ToInteger()
+		d = time.Duration(ms) * time.Millisecond
+	case v.IsString():
+		d, _ = time.ParseDuration(v.String())
+	}
+	if d <= 0 {
+		panic(vm.MakeTypeError(fmt.Sprintf("%s: %s must be a positive number of milliseconds or a duration such as \"1h\"", fn, name)))
+	}
+	return d
+}
+
+// timeValue reads a time given as a Date or as milliseconds since the epoch
+func (b *PromBinding) timeValue(vm *otto.Otto, fn, name string, v otto.Value) time.Time {
+	if v.Class() == "Date" {
+		v, _ = v.Object().Call("getTime")
+	}
+	if !v.IsNumber() {
+		panic(vm.MakeTypeError(fn + ": " + name + " must be a Date or milliseconds since the epoch"))
+	}
+	ms, _ := v.ToInteger()
+	return time.UnixMilli(ms)
+}
+
+// toValue converts series into script objects; matrices get a values array, other results a single value
+// Values are numbers, including NaN and Infinity, and timestamps are milliseconds since the epoch
+func (b *PromBinding) toValue(vm *otto.Otto, result *promResult, matrix bool) otto.Value {
+	items := make([]interface{}, 0, len(result.series))
+	for _, series := range result.series {
+		metric := make(map[string]interface{}, len(series.metric))
+		for name, value := range series.metric {
+			metric[name] = value
+		}
+		item := map[string]interface{}{"metric": metric}
+
+		if matrix {
+			values := make([]interface{}, 0, len(series.samples))
+			for _, sample := range series.samples {
+				values = append(values, map[string]interface{}{
+					"timestamp": sample.timestamp.UnixMilli(),
+					"value":     sample.value,
+				})
+			}
+			item["values"] = values
+		} else if len(series.samples) > 0 {
+			item["timestamp"] = series.samples[0].timestamp.UnixMilli()
+			item["value"] = series.samples[0].value
+		}
+		items = append(items, item)
+	}
+
+	if len(result.warnings) > 0 {
+		b.plugin.log.Debug("prometheus query returned warnings", zap.Strings("warnings", result.warnings))
+	}
+
+	value, err := vm.ToValue(items)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "prom: "+err.Error()))
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+package jsmachine
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Sizes of generated QR code images, in pixels
+const (
+	defaultQRCodeSize = 256
+	maxQRCodeSize     = 2048
+)
+
+// qrcodeLevels are the error recovery levels a script may request, by name
+var qrcodeLevels = map[string]qrcode.RecoveryLevel{
+	"low":     qrcode.Low,
+	"medium":  qrcode.Medium,
+	"high":    qrcode.High,
+	"highest": qrcode.Highest,
+}
+
+// QRCodeBinding renders QR codes as PNG images in Go, for ticket and label scripts
+type QRCodeBinding struct{}
+
+// newQRCodeBinding creates a new qrcode binding
+func newQRCodeBinding() *QRCodeBinding {
+	return &QRCodeBinding{}
+}
+
+// inject injects the qrcode object into the VM
+func (q *QRCodeBinding) inject(vm *otto.Otto) error {
+	qrcodeObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// qrcode.generate(data, size, {level, border}) - returns a base64-encoded PNG image
+	if err := qrcodeObj.Set("generate", q.generate); err != nil {
+		return err
+	}
+
+	return vm.Set("qrcode", qrcodeObj)
+}
+
+// generate encodes data in a QR code and returns the square PNG image of size pixels, base64-encoded
+// Throws a TypeError for invalid arguments and a RangeError for data too long for a QR code
+func (q *QRCodeBinding) generate(call otto.FunctionCall) otto.Value {
+	if !call.Argument(0).IsString() {
+		panic(call.Otto.MakeTypeError("qrcode.generate: data must be a string"))
+	}
+	data := call.Argument(0).String()
+	if data == "" {
+		panic(call.Otto.MakeTypeError("qrcode.generate: data is empty"))
+	}
+
+	size := defaultQRCodeSize
+	if arg := call.Argument(1); arg.IsDefined() {
+		n, err := arg.ToInteger()
+		if !arg.IsNumber() || err != nil || n < 1 || n > maxQRCodeSize {
+			panic(call.Otto.MakeTypeError("qrcode.generate: size must be between 1 and " + strconv.Itoa(maxQRCodeSize)))
+		}
+		size = int(n)
+	}
+
+	level := qrcode.Medium
+	border := true
+	if options := call.Argument(2); options.IsObject() {
+		if v, _ := options.Object().Get("level"); v.IsDefined() {
+			var ok bool
+			if level, ok = qrcodeLevels[v.String()]; !ok {
+				panic(call.Otto.MakeTypeError("qrcode.generate: level must be low, medium, high or highest"))
+			}
+		}
+		if v, _ := options.Object().Get("border"); v.IsBoolean() {
+			border, _ = v.ToBoolean()
+		}
+	}
+
+	code, err := qrcode.New(data, level)
+	if err != nil {
+		panic(call.Otto.MakeRangeError("qrcode.generate: " + err.Error()))
+	}
+	code.DisableBorder = !border
+
+	png, err := code.PNG(size)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "qrcode.generate: "+err.Error()))
+	}
+	value, _ := call.Otto.ToValue(base64.StdEncoding.EncodeToString(png))
+	return value
+}
@@ -0,0 +1,176 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/jmespath/go-jmespath"
+	"github.com/ohler55/ojg/jp"
+	"github.com/robertkrimen/otto"
+)
+
+// queryCacheSize bounds the number of compiled expressions kept for reuse, per language
+const queryCacheSize = 256
+
+// QueryBinding evaluates JSONPath and JMESPath expressions in Go, so transform scripts extract nested fields with
+// one declarative expression instead of chains of defensive property accesses
+type QueryBinding struct {
+	mu       sync.Mutex
+	jsonpath map[string]jp.Expr
+	jmespath map[string]*jmespath.JMESPath
+}
+
+// newQueryBinding creates a new query binding
+func newQueryBinding() *QueryBinding {
+	return &QueryBinding{
+		jsonpath: make(map[string]jp.Expr),
+		jmespath: make(map[string]*jmespath.JMESPath),
+	}
+}
+
+// injectJSONPath injects the jsonpath object into the VM
+func (q *QueryBinding) injectJSONPath(vm *otto.Otto) error {
+	jsonpathObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// jsonpath.query(doc, expr) - returns the array of matching values
+	if err := jsonpathObj.Set("query", q.query); err != nil {
+		return err
+	}
+
+	return vm.Set("jsonpath", jsonpathObj)
+}
+
+// injectJMESPath injects the jmespath object into the VM
+func (q *QueryBinding) injectJMESPath(vm *otto.Otto) error {
+	jmespathObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// jmespath.search(doc, expr) - returns the result of the expression, null when nothing matches
+	if err := jmespathObj.Set("search", q.search); err != nil {
+		return err
+	}
+
+	return vm.Set("jmespath", jmespathObj)
+}
+
+// query returns every value of doc matched by a JSONPath expression; array elements are visited in order, object
+// members in key order
+// Throws a SyntaxError for invalid expressions and a TypeError for documents that are not JSON
+func (q *QueryBinding) query(call otto.FunctionCall) otto.Value {
+	doc := queryDocument(call, "jsonpath.query")
+	expr, err := q.compileJSONPath(call.Argument(1).String())
+	if err != nil {
+		panic(call.Otto.MakeSyntaxError("jsonpath.query: " + err.Error()))
+	}
+
+	matches := expr.Get(doc)
+	if matches == nil {
+		matches = []any{}
+	}
+	return queryResult(call.Otto, "jsonpath.query", matches)
+}
+
+// search evaluates a JMESPath expression against doc
+// Throws a SyntaxError for invalid expressions, a TypeError for documents that are not JSON and an Error when the
+// evaluation fails, e.g. for a function called with arguments of the wrong type
+func (q *QueryBinding) search(call otto.FunctionCall) otto.Value {
+	doc := queryDocument(call, "jmespath.search")
+	expr, err := q.compileJMESPath(call.Argument(1).String())
+	if err != nil {
+		panic(call.Otto.MakeSyntaxError("jmespath.search: " + strings.TrimPrefix(err.Error(), "SyntaxError: ")))
+	}
+
+	result, err := expr.Search(doc)
+	if err != nil {
+		// Type errors quote the offending value, which may be the whole document
+		message := err.Error()
+		if strings.HasPrefix(message, "Invalid type for:") {
+			message = "a function argument has the wrong type"
+		}
+		panic(call.Otto.MakeCustomError("Error", "jmespath.search: "+message))
+	}
+	return queryResult(call.Otto, "jmespath.search", result)
+}
+
+// compileJSONPath returns the parsed JSONPath expression, reusing previously parsed expressions
+func (q *QueryBinding) compileJSONPath(source string) (jp.Expr, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if expr, ok := q.jsonpath[source]; ok {
+		return expr, nil
+	}
+	expr, err := jp.ParseString(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scripts building expressions from data could grow the cache without bound
+	if len(q.jsonpath) >= queryCacheSize {
+		q.jsonpath = make(map[string]jp.Expr)
+	}
+	q.jsonpath[source] = expr
+	return expr, nil
+}
+
+// compileJMESPath returns the compiled JMESPath expression, reusing previously compiled expressions
+func (q *QueryBinding) compileJMESPath(source string) (*jmespath.JMESPath, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if expr, ok := q.jmespath[source]; ok {
+		return expr, nil
+	}
+	expr, err := jmespath.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(q.jmespath) >= queryCacheSize {
+		q.jmespath = make(map[string]*jmespath.JMESPath)
+	}
+	q.jmespath[source] = expr
+	return expr, nil
+}
+
+// queryDocument converts the first argument, a value or a JSON string, to generic Go data
+func queryDocument(call otto.FunctionCall, fn string) any {
+	return jsonArgument(call.Otto, call.Argument(0), fn, "doc")
+}
+
+// jsonArgument converts an argument, a value or a JSON string, to generic Go data; name identifies it in errors
+func jsonArgument(vm *otto.Otto, arg otto.Value, fn, name string) any {
+	if !arg.IsString() {
+		encoded, err := vm.Call("JSON.stringify", nil, arg)
+		if err != nil || !encoded.IsString() {
+			panic(vm.MakeTypeError(fn + ": " + name + " cannot be serialized to JSON"))
+		}
+		arg = encoded
+	}
+	text := arg.String()
+
+	var doc any
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		panic(vm.MakeTypeError(fn + ": " + name + " is not valid JSON: " + err.Error()))
+	}
+	return doc
+}
+
+// queryResult converts a result to a script value
+func queryResult(vm *otto.Otto, fn string, result any) otto.Value {
+	data, err := json.Marshal(result)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", fn+": "+err.Error()))
+	}
+	value, err := vm.Call("JSON.parse", nil, string(data))
+	if err != nil {
+		panic(vm.MakeCustomError("Error", fn+": "+err.Error()))
+	}
+	return value
+}
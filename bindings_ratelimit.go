@@ -0,0 +1,67 @@
+package jsmachine
+
+import (
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// RateLimitBinding lets scripts throttle the actions they perform, such as outbound webhook calls
+type RateLimitBinding struct {
+	plugin *Plugin
+}
+
+// newRateLimitBinding creates a new ratelimit binding
+func newRateLimitBinding(plugin *Plugin) *RateLimitBinding {
+	return &RateLimitBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the ratelimit object into the VM
+func (r *RateLimitBinding) inject(vm *otto.Otto) error {
+	rateLimitObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// ratelimit.allow(key, limit, windowMs) - returns true if the action is within the limit
+	if err := rateLimitObj.Set("allow", r.allow); err != nil {
+		return err
+	}
+
+	return vm.Set("ratelimit", rateLimitObj)
+}
+
+// allow counts an action under key and reports whether fewer than limit actions happened in the last windowMs
+// Throws a TypeError for a missing key or non-positive limit/windowMs; limiter failures deny the action
+// In mock mode nothing is counted and every action is allowed
+func (r *RateLimitBinding) allow(call otto.FunctionCall) otto.Value {
+	key := call.Argument(0).String()
+	if !call.Argument(0).IsString() || key == "" {
+		panic(call.Otto.MakeTypeError("ratelimit.allow: key must be a non-empty string"))
+	}
+	limit, err := call.Argument(1).ToInteger()
+	if err != nil || limit < 1 {
+		panic(call.Otto.MakeTypeError("ratelimit.allow: limit must be a positive number"))
+	}
+	windowMs, err := call.Argument(2).ToInteger()
+	if err != nil || windowMs < 1 {
+		panic(call.Otto.MakeTypeError("ratelimit.allow: windowMs must be a positive number"))
+	}
+
+	if r.plugin.mocked(call.Otto) {
+		return otto.TrueValue()
+	}
+
+	allowed, err := r.plugin.rateLimiter.allow(key, int(limit), time.Duration(windowMs)*time.Millisecond)
+	if err != nil {
+		r.plugin.log.Warn("rate limiter failed, denying the action", zap.String("key", key), zap.Error(err))
+		return otto.FalseValue()
+	}
+	if allowed {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
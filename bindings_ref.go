@@ -0,0 +1,120 @@
+package jsmachine
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// Reference datasets, as of October 2026: ISO 3166-1 countries with their currency and calling code, the ISO 4217
+// currencies in use with their minor units, and the time zones of the IANA tz database's zone.tab
+var (
+	//go:embed refdata/countries.json
+	refCountriesJSON string
+
+	//go:embed refdata/currencies.json
+	refCurrenciesJSON string
+
+	//go:embed refdata/timezones.json
+	refTimezonesJSON string
+)
+
+// refTimezone is a zone of the timezones dataset
+type refTimezone struct {
+	Name    string `json:"name"`
+	Country string `json:"country"`
+	Comment string `json:"comment"`
+}
+
+// refTimezones parses the timezones dataset once; offsets are added per call since they change with DST
+var refTimezones = sync.OnceValue(func() []refTimezone {
+	var zones []refTimezone
+	if err := json.Unmarshal([]byte(refTimezonesJSON), &zones); err != nil {
+		panic(fmt.Sprintf("refdata/timezones.json: %v", err))
+	}
+	return zones
+})
+
+// RefBinding exposes reference datasets embedded in the plugin, so scripts stop carrying their own copies of
+// country, currency and time zone tables
+type RefBinding struct{}
+
+// newRefBinding creates a new ref binding
+func newRefBinding() *RefBinding {
+	return &RefBinding{}
+}
+
+// inject injects the ref object into the VM
+func (r *RefBinding) inject(vm *otto.Otto) error {
+	refObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// ref.countries() - returns an array of {code, alpha3, numeric, name, currency, callingCode}
+	if err := refObj.Set("countries", func(call otto.FunctionCall) otto.Value {
+		return r.parse(call.Otto, "ref.countries", refCountriesJSON)
+	}); err != nil {
+		return err
+	}
+
+	// ref.currencies() - returns an array of {code, numeric, name, digits}
+	if err := refObj.Set("currencies", func(call otto.FunctionCall) otto.Value {
+		return r.parse(call.Otto, "ref.currencies", refCurrenciesJSON)
+	}); err != nil {
+		return err
+	}
+
+	// ref.timezones(country) - returns an array of {name, country, comment, offset}, of one country when given
+	if err := refObj.Set("timezones", r.timezones); err != nil {
+		return err
+	}
+
+	return vm.Set("ref", refObj)
+}
+
+// parse returns a fresh copy of a dataset, so scripts may modify what they get
+func (r *RefBinding) parse(vm *otto.Otto, fn, data string) otto.Value {
+	value, err := vm.Call("JSON.parse", nil, data)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", fn+": "+err.Error()))
+	}
+	return value
+}
+
+// timezones returns the time zones with their current UTC offset, e.g. "+02:00"; the offset is null for zones the
+// host's time zone database does not know
+// Throws a TypeError when country is not an ISO 3166-1 alpha-2 code
+func (r *RefBinding) timezones(call otto.FunctionCall) otto.Value {
+	var country string
+	if arg := call.Argument(0); arg.IsDefined() && !arg.IsNull() {
+		country = strings.ToUpper(arg.String())
+		if len(country) != 2 || strings.Trim(country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			panic(call.Otto.MakeTypeError("ref.timezones: country must be an ISO 3166-1 alpha-2 code"))
+		}
+	}
+
+	type zone struct {
+		refTimezone
+		Offset *string `json:"offset"`
+	}
+	now := time.Now()
+	zones := []zone{}
+	for _, tz := range refTimezones() {
+		if country != "" && tz.Country != country {
+			continue
+		}
+		z := zone{refTimezone: tz}
+		if loc, err := time.LoadLocation(tz.Name); err == nil {
+			offset := now.In(loc).Format("-07:00")
+			z.Offset = &offset
+		}
+		zones = append(zones, z)
+	}
+	return queryResult(call.Otto, "ref.timezones", zones)
+}
@@ -0,0 +1,115 @@
+package jsmachine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// RequireBinding exposes shared libraries to JavaScript as require("lib/<name>")
+type RequireBinding struct {
+	plugin *Plugin
+}
+
+// newRequireBinding creates a new require binding
+func newRequireBinding(plugin *Plugin) *RequireBinding {
+	return &RequireBinding{
+		plugin: plugin,
+	}
+}
+
+// vmModules holds the evaluated libraries of one VM
+type vmModules struct {
+	plugin    *Plugin
+	set       *librarySet
+	libraries map[string]*library
+	exports   map[string]otto.Value
+	loading   map[string]bool
+}
+
+// inject defines require and evaluates every library in the VM, so executions only pay for a lookup
+func (r *RequireBinding) inject(vm *otto.Otto) error {
+	set := r.plugin.libraries.Load()
+	modules := &vmModules{
+		plugin:    r.plugin,
+		set:       set,
+		libraries: set.libraries,
+		exports:   make(map[string]otto.Value, len(set.libraries)),
+		loading:   make(map[string]bool),
+	}
+
+	// require("lib/<name>")
+	if err := vm.Set("require", modules.require); err != nil {
+		return err
+	}
+
+	for _, name := range libraryNames(modules.libraries) {
+		if _, err := modules.load(vm, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// require returns the exports of a library; unknown modules and circular requires throw
+func (m *vmModules) require(call otto.FunctionCall) otto.Value {
+	id := call.Argument(0).String()
+	name, ok := strings.CutPrefix(id, libraryPrefix)
+	if !ok {
+		panic(call.Otto.MakeCustomError("Error", "cannot find module '"+id+"' (only "+libraryPrefix+"<name> modules are available)"))
+	}
+
+	// After a module cache flush, libraries are evaluated again from the new set on first use
+	if set := m.plugin.libraries.Load(); set != m.set && len(m.loading) == 0 {
+		m.set = set
+		m.libraries = set.libraries
+		clear(m.exports)
+	}
+
+	exports, err := m.load(call.Otto, name)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", err.Error()))
+	}
+	return exports
+}
+
+// load evaluates a library once per VM and caches its exports
+func (m *vmModules) load(vm *otto.Otto, name string) (otto.Value, error) {
+	if exports, ok := m.exports[name]; ok {
+		return exports, nil
+	}
+
+	lib, ok := m.libraries[name]
+	if !ok {
+		return otto.UndefinedValue(), fmt.Errorf("cannot find module '%s%s'", libraryPrefix, name)
+	}
+	if m.loading[name] {
+		return otto.UndefinedValue(), fmt.Errorf("circular require of '%s%s'", libraryPrefix, name)
+	}
+	m.loading[name] = true
+	defer delete(m.loading, name)
+
+	factory, err := vm.Run(lib.script)
+	if err != nil {
+		return otto.UndefinedValue(), fmt.Errorf("library %s: %w", name, err)
+	}
+
+	module, err := vm.Object(`({exports: {}})`)
+	if err != nil {
+		return otto.UndefinedValue(), err
+	}
+	exports, err := module.Get("exports")
+	if err != nil {
+		return otto.UndefinedValue(), err
+	}
+	if _, err := factory.Call(otto.UndefinedValue(), module, exports); err != nil {
+		return otto.UndefinedValue(), fmt.Errorf("library %s: %w", name, err)
+	}
+
+	if exports, err = module.Get("exports"); err != nil {
+		return otto.UndefinedValue(), err
+	}
+	m.exports[name] = exports
+	return exports, nil
+}
@@ -0,0 +1,162 @@
+package jsmachine
+
+import (
+	"strconv"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/robertkrimen/otto"
+)
+
+// SemverBinding parses and compares semantic versions in Go, so release scripts stop comparing version strings
+// character by character
+type SemverBinding struct{}
+
+// newSemverBinding creates a new semver binding
+func newSemverBinding() *SemverBinding {
+	return &SemverBinding{}
+}
+
+// inject injects the semver object into the VM
+func (s *SemverBinding) inject(vm *otto.Otto) error {
+	semverObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// semver.parse(version) - returns {version, major, minor, patch, prerelease, build}, null when invalid
+	if err := semverObj.Set("parse", s.parse); err != nil {
+		return err
+	}
+
+	// semver.valid(version) - returns whether version is a semantic version
+	if err := semverObj.Set("valid", func(call otto.FunctionCall) otto.Value {
+		_, err := semver.NewVersion(call.Argument(0).String())
+		value, _ := call.Otto.ToValue(err == nil)
+		return value
+	}); err != nil {
+		return err
+	}
+
+	// semver.compare(a, b) - returns -1, 0 or 1
+	if err := semverObj.Set("compare", s.compare); err != nil {
+		return err
+	}
+
+	// semver.satisfies(version, range) - returns whether version is within range, e.g. "^1.2" or ">=1.0, <2.0"
+	if err := semverObj.Set("satisfies", s.satisfies); err != nil {
+		return err
+	}
+
+	// semver.maxSatisfying(versions, range) - returns the highest version within range, null when none is
+	if err := semverObj.Set("maxSatisfying", s.maxSatisfying); err != nil {
+		return err
+	}
+
+	// semver.inc(version, part) - returns the next major, minor or patch version
+	if err := semverObj.Set("inc", s.inc); err != nil {
+		return err
+	}
+
+	return vm.Set("semver", semverObj)
+}
+
+// parse returns the parts of a version; a leading v and missing minor or patch numbers are accepted
+func (s *SemverBinding) parse(call otto.FunctionCall) otto.Value {
+	v, err := semver.NewVersion(call.Argument(0).String())
+	if err != nil {
+		return otto.NullValue()
+	}
+
+	obj, err := call.Otto.Object(`({})`)
+	if err != nil {
+		panic(call.Otto.MakeCustomError("Error", "semver.parse: "+err.Error()))
+	}
+	_ = obj.Set("version", v.String())
+	_ = obj.Set("major", v.Major())
+	_ = obj.Set("minor", v.Minor())
+	_ = obj.Set("patch", v.Patch())
+	_ = obj.Set("prerelease", v.Prerelease())
+	_ = obj.Set("build", v.Metadata())
+	return obj.Value()
+}
+
+// compare orders two versions by semantic version precedence; build metadata is ignored
+// Throws a TypeError for invalid versions
+func (s *SemverBinding) compare(call otto.FunctionCall) otto.Value {
+	a := s.version(call, "semver.compare", call.Argument(0))
+	b := s.version(call, "semver.compare", call.Argument(1))
+	value, _ := call.Otto.ToValue(a.Compare(b))
+	return value
+}
+
+// satisfies reports whether a version is within a range; pre-releases only satisfy ranges naming a pre-release
+// Throws a TypeError for invalid versions and ranges
+func (s *SemverBinding) satisfies(call otto.FunctionCall) otto.Value {
+	v := s.version(call, "semver.satisfies", call.Argument(0))
+	c := s.constraint(call, "semver.satisfies", call.Argument(1))
+	value, _ := call.Otto.ToValue(c.Check(v))
+	return value
+}
+
+// maxSatisfying returns the highest version of an array within a range, as it appears in the array
+// Throws a TypeError for invalid versions and ranges
+func (s *SemverBinding) maxSatisfying(call otto.FunctionCall) otto.Value {
+	arg := call.Argument(0)
+	if !arg.IsObject() || arg.Class() != "Array" {
+		panic(call.Otto.MakeTypeError("semver.maxSatisfying: versions must be an array"))
+	}
+	c := s.constraint(call, "semver.maxSatisfying", call.Argument(1))
+
+	list := arg.Object()
+	lengthValue, _ := list.Get("length")
+	length, _ := lengthValue.ToInteger()
+
+	var best *semver.Version
+	result := otto.NullValue()
+	for i := range length {
+		item, _ := list.Get(strconv.FormatInt(i, 10))
+		v := s.version(call, "semver.maxSatisfying", item)
+		if c.Check(v) && (best == nil || v.GreaterThan(best)) {
+			best, result = v, item
+		}
+	}
+	return result
+}
+
+// inc returns the version following another: part is major, minor or patch
+// Throws a TypeError for invalid versions and parts
+func (s *SemverBinding) inc(call otto.FunctionCall) otto.Value {
+	v := s.version(call, "semver.inc", call.Argument(0))
+
+	var next semver.Version
+	switch part := call.Argument(1).String(); part {
+	case "major":
+		next = v.IncMajor()
+	case "minor":
+		next = v.IncMinor()
+	case "patch":
+		next = v.IncPatch()
+	default:
+		panic(call.Otto.MakeTypeError("semver.inc: part must be major, minor or patch, got " + part))
+	}
+	value, _ := call.Otto.ToValue(next.String())
+	return value
+}
+
+// version parses a version argument
+func (s *SemverBinding) version(call otto.FunctionCall, fn string, arg otto.Value) *semver.Version {
+	v, err := semver.NewVersion(arg.String())
+	if err != nil {
+		panic(call.Otto.MakeTypeError(fn + ": invalid version " + strconv.Quote(arg.String())))
+	}
+	return v
+}
+
+// constraint parses a range argument
+func (s *SemverBinding) constraint(call otto.FunctionCall, fn string, arg otto.Value) *semver.Constraints {
+	c, err := semver.NewConstraint(arg.String())
+	if err != nil {
+		panic(call.Otto.MakeTypeError(fn + ": invalid range " + strconv.Quote(arg.String()) + ": " + err.Error()))
+	}
+	return c
+}
@@ -0,0 +1,199 @@
+package jsmachine
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// SigningBinding signs outbound requests with credentials and keys from the configuration, so secrets never appear
+// in script source and the hashing runs in Go
+type SigningBinding struct {
+	plugin *Plugin
+}
+
+// newSigningBinding creates a new signing binding
+func newSigningBinding(plugin *Plugin) *SigningBinding {
+	return &SigningBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the signing object into the VM
+func (s *SigningBinding) inject(vm *otto.Otto) error {
+	signingObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// signing.sigv4(request, profile) - returns the request with AWS Signature Version 4 headers
+	if err := signingObj.Set("sigv4", s.sigv4); err != nil {
+		return err
+	}
+
+	// signing.hmac(key, data, options) - returns the signature of data
+	if err := signingObj.Set("hmac", s.hmac); err != nil {
+		return err
+	}
+
+	// signing.hmacRequest(request, key, options) - returns the request with a signature header
+	if err := signingObj.Set("hmacRequest", s.hmacRequest); err != nil {
+		return err
+	}
+
+	return vm.Set("signing", signingObj)
+}
+
+// sigv4 signs a {method, url, headers, body} request with the AWS credentials of a js.signing.aws profile
+// The result can be passed to fetch as is: fetch(signed.url, signed)
+func (s *SigningBinding) sigv4(call otto.FunctionCall) otto.Value {
+	req := s.request(call, call.Argument(0))
+
+	name := call.Argument(1).String()
+	var creds *AWSCredentials
+	if cfg := s.plugin.cfg.Signing; cfg != nil {
+		creds = cfg.AWS[name]
+	}
+	if creds == nil {
+		panic(call.Otto.MakeTypeError("signing.sigv4: unknown credentials profile " + name))
+	}
+
+	sigv4(req, creds, time.Now())
+	return s.value(call.Otto, req)
+}
+
+// hmac returns the HMAC of data with a js.signing.hmac_keys key
+// Options: algorithm (sha1, sha256, sha512; default sha256), encoding (hex, base64; default hex)
+func (s *SigningBinding) hmac(call otto.FunctionCall) otto.Value {
+	key := s.key(call, call.Argument(0).String())
+	opts := s.hmacOptions(call, call.Argument(2))
+
+	signature := s.sign(call, key, call.Argument(1).String(), opts)
+	result, _ := call.Otto.ToValue(signature)
+	return result
+}
+
+// hmacRequest signs the body of a {method, url, headers, body} request with a js.signing.hmac_keys key and sets the
+// signature header; with timestampHeader set, "<unix seconds>.<body>" is signed and the timestamp sent along
+// Options: header (default X-Signature), prefix (e.g. "sha256="), timestampHeader, algorithm, encoding
+func (s *SigningBinding) hmacRequest(call otto.FunctionCall) otto.Value {
+	req := s.request(call, call.Argument(0))
+	key := s.key(call, call.Argument(1).String())
+	opts := s.hmacOptions(call, call.Argument(2))
+
+	data := req.body
+	if opts.timestampHeader != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.headers[opts.timestampHeader] = timestamp
+		data = timestamp + "." + data
+	}
+	req.headers[opts.header] = opts.prefix + s.sign(call, key, data, opts)
+	return s.value(call.Otto, req)
+}
+
+// hmacOptions are the options of the HMAC helpers
+type hmacOptions struct {
+	algorithm       string
+	encoding        string
+	header          string
+	prefix          string
+	timestampHeader string
+}
+
+// hmacOptions reads the options argument of the HMAC helpers
+func (s *SigningBinding) hmacOptions(call otto.FunctionCall, arg otto.Value) hmacOptions {
+	opts := hmacOptions{algorithm: "sha256", encoding: "hex", header: "X-Signature"}
+	if !arg.IsObject() {
+		return opts
+	}
+	obj := arg.Object()
+
+	for name, field := range map[string]*string{
+		"algorithm":       &opts.algorithm,
+		"encoding":        &opts.encoding,
+		"header":          &opts.header,
+		"prefix":          &opts.prefix,
+		"timestampHeader": &opts.timestampHeader,
+	} {
+		if v, _ := obj.Get(name); v.IsString() {
+			*field = v.String()
+		}
+	}
+	return opts
+}
+
+// sign computes and encodes an HMAC, throwing a TypeError for unsupported options
+func (s *SigningBinding) sign(call otto.FunctionCall, key []byte, data string, opts hmacOptions) string {
+	h, ok := hmacHashes[opts.algorithm]
+	if !ok {
+		panic(call.Otto.MakeTypeError("signing: unsupported algorithm " + opts.algorithm + " (sha1, sha256, sha512)"))
+	}
+	signature, err := encodeDigest(hmacSum(h, key, data), opts.encoding)
+	if err != nil {
+		panic(call.Otto.MakeTypeError("signing: " + err.Error()))
+	}
+	return signature
+}
+
+// key returns a configured HMAC key, throwing a TypeError for unknown names
+func (s *SigningBinding) key(call otto.FunctionCall, name string) []byte {
+	if cfg := s.plugin.cfg.Signing; cfg != nil {
+		if key, ok := cfg.HMACKeys[name]; ok {
+			return []byte(key)
+		}
+	}
+	panic(call.Otto.MakeTypeError("signing: unknown key " + name))
+}
+
+// request reads a {method, url, headers, body} object
+func (s *SigningBinding) request(call otto.FunctionCall, arg otto.Value) *signedRequest {
+	if !arg.IsObject() {
+		panic(call.Otto.MakeTypeError("signing: request must be an object with a url"))
+	}
+	obj := arg.Object()
+
+	raw, _ := obj.Get("url")
+	target, err := url.Parse(raw.String())
+	if !raw.IsString() || err != nil || !target.IsAbs() || target.Host == "" {
+		panic(call.Otto.MakeTypeError("signing: request url must be an absolute URL"))
+	}
+
+	req := &signedRequest{method: http.MethodGet, url: target, headers: make(map[string]string)}
+	if v, _ := obj.Get("method"); v.IsString() {
+		req.method = strings.ToUpper(v.String())
+	}
+	if v, _ := obj.Get("headers"); v.IsObject() {
+		headerObj := v.Object()
+		for _, name := range headerObj.Keys() {
+			value, _ := headerObj.Get(name)
+			req.headers[name] = value.String()
+		}
+	}
+	if v, _ := obj.Get("body"); v.IsDefined() && !v.IsNull() {
+		req.body = v.String()
+	}
+	return req
+}
+
+// value converts a signed request back to the object fetch accepts as options
+func (s *SigningBinding) value(vm *otto.Otto, req *signedRequest) otto.Value {
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "signing: "+err.Error()))
+	}
+
+	headers := make(map[string]interface{}, len(req.headers))
+	for name, value := range req.headers {
+		headers[name] = value
+	}
+
+	_ = obj.Set("method", req.method)
+	_ = obj.Set("url", req.url.String())
+	_ = obj.Set("headers", headers)
+	_ = obj.Set("body", req.body)
+	return obj.Value()
+}
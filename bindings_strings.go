@@ -0,0 +1,216 @@
+package jsmachine
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/robertkrimen/otto"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations covers letters that do not decompose into an ASCII base letter plus diacritics
+var transliterations = map[rune]string{
+	'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Ø': "O", 'ø': "o", 'Œ': "OE", 'œ': "oe",
+	'Ł': "L", 'ł': "l", 'Đ': "D", 'đ': "d", 'Þ': "Th", 'þ': "th", 'Ð': "D", 'ð': "d", 'ı': "i",
+
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ё': "Yo", 'Ж': "Zh", 'З': "Z", 'И': "I",
+	'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O", 'П': "P", 'Р': "R", 'С': "S", 'Т': "T",
+	'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch", 'Ъ': "", 'Ы': "Y", 'Ь': "",
+	'Э': "E", 'Ю': "Yu", 'Я': "Ya",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// StringsBinding provides string similarity and normalization helpers to JavaScript
+type StringsBinding struct{}
+
+// newStringsBinding creates a new strings binding
+func newStringsBinding() *StringsBinding {
+	return &StringsBinding{}
+}
+
+// inject injects the strings object into the VM
+func (s *StringsBinding) inject(vm *otto.Otto) error {
+	stringsObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// strings.similarity(a, b, algorithm)
+	if err := stringsObj.Set("similarity", s.similarity); err != nil {
+		return err
+	}
+
+	// strings.slugify(text, separator)
+	if err := stringsObj.Set("slugify", s.slugify); err != nil {
+		return err
+	}
+
+	// strings.transliterate(text)
+	if err := stringsObj.Set("transliterate", s.transliterate); err != nil {
+		return err
+	}
+
+	return vm.Set("strings", stringsObj)
+}
+
+// similarity returns a score between 0 (different) and 1 (identical)
+// algorithm is levenshtein (default) or jaro_winkler
+func (s *StringsBinding) similarity(call otto.FunctionCall) otto.Value {
+	a, b := call.Argument(0).String(), call.Argument(1).String()
+
+	algorithm := "levenshtein"
+	if arg := call.Argument(2); !arg.IsUndefined() {
+		algorithm = arg.String()
+	}
+
+	var score float64
+	switch algorithm {
+	case "levenshtein":
+		score = levenshteinSimilarity([]rune(a), []rune(b))
+	case "jaro_winkler":
+		score = jaroWinkler([]rune(a), []rune(b))
+	default:
+		panic(call.Otto.MakeRangeError(fmt.Sprintf("strings.similarity: unknown algorithm %q", algorithm)))
+	}
+
+	result, _ := otto.ToValue(score)
+	return result
+}
+
+// slugify converts text to a lowercase ASCII slug, e.g. "Crème Brûlée!" -> "creme-brulee"
+func (s *StringsBinding) slugify(call otto.FunctionCall) otto.Value {
+	separator := "-"
+	if arg := call.Argument(1); !arg.IsUndefined() {
+		separator = arg.String()
+	}
+
+	var sb strings.Builder
+	pending := false
+	for _, r := range transliterate(call.Argument(0).String()) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if pending && sb.Len() > 0 {
+				sb.WriteString(separator)
+			}
+			pending = false
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		pending = true
+	}
+
+	return stringValue(sb.String())
+}
+
+// transliterate converts text to its closest ASCII representation, e.g. "Straße" -> "Strasse"
+func (s *StringsBinding) transliterate(call otto.FunctionCall) otto.Value {
+	return stringValue(transliterate(call.Argument(0).String()))
+}
+
+// transliterate strips diacritics and replaces letters from the transliteration table
+// Characters without an ASCII equivalent are kept as they are
+func transliterate(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if replacement, ok := transliterations[r]; ok {
+			sb.WriteString(replacement)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), sb.String())
+	if err != nil {
+		return sb.String()
+	}
+	return stripped
+}
+
+// levenshteinSimilarity is 1 - edit distance / length of the longer string
+func levenshteinSimilarity(a, b []rune) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(b)])/float64(longest)
+}
+
+// jaroWinkler computes the Jaro-Winkler similarity with the standard prefix scale of 0.1
+func jaroWinkler(a, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	window := max(len(a), len(b))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo, hi := max(0, i-window), min(len(b), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
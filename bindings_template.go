@@ -0,0 +1,138 @@
+package jsmachine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/robertkrimen/otto"
+)
+
+// templateCacheSize bounds the number of parsed templates kept for reuse
+const templateCacheSize = 256
+
+// TemplateBinding exposes Go text/template rendering and the sprig-style helpers to JavaScript
+type TemplateBinding struct {
+	mu    sync.Mutex
+	cache map[string]*template.Template
+}
+
+// newTemplateBinding creates a new template binding
+func newTemplateBinding() *TemplateBinding {
+	return &TemplateBinding{
+		cache: make(map[string]*template.Template),
+	}
+}
+
+// inject injects the template and helpers objects into the VM
+func (t *TemplateBinding) inject(vm *otto.Otto) error {
+	templateObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// template.render(source, data)
+	if err := templateObj.Set("render", t.render); err != nil {
+		return err
+	}
+
+	return vm.Set("template", templateObj)
+}
+
+// injectHelpers injects the helpers object (the template helper functions) into the VM
+func (t *TemplateBinding) injectHelpers(vm *otto.Otto) error {
+	helpersObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// helpers.trim(s), helpers.default(fallback, value), ...
+	for _, name := range helperNames() {
+		if err := helpersObj.Set(name, jsHelper(name, helperFuncs[name])); err != nil {
+			return err
+		}
+	}
+
+	return vm.Set("helpers", helpersObj)
+}
+
+// render executes a Go text/template with data; parse and execution errors throw
+func (t *TemplateBinding) render(call otto.FunctionCall) otto.Value {
+	tpl, err := t.parse(call.Argument(0).String())
+	if err != nil {
+		panic(call.Otto.MakeSyntaxError(err.Error()))
+	}
+
+	var data interface{}
+	if arg := call.Argument(1); !arg.IsUndefined() {
+		if data, err = arg.Export(); err != nil {
+			panic(call.Otto.MakeTypeError(fmt.Sprintf("template.render: %v", err)))
+		}
+	}
+
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, data); err != nil {
+		panic(call.Otto.MakeCustomError("TemplateError", err.Error()))
+	}
+
+	return stringValue(sb.String())
+}
+
+// parse returns the parsed template for source, reusing previously parsed templates
+func (t *TemplateBinding) parse(source string) (*template.Template, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tpl, ok := t.cache[source]; ok {
+		return tpl, nil
+	}
+
+	tpl, err := template.New("script").Funcs(helperFuncMap()).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scripts rendering generated sources could grow the cache without bound
+	if len(t.cache) >= templateCacheSize {
+		t.cache = make(map[string]*template.Template)
+	}
+	t.cache[source] = tpl
+
+	return tpl, nil
+}
+
+// invalidate drops all parsed templates
+func (t *TemplateBinding) invalidate() {
+	t.mu.Lock()
+	clear(t.cache)
+	t.mu.Unlock()
+}
+
+// jsHelper adapts a helper to a JavaScript function; helper errors throw
+func jsHelper(name string, fn helperFunc) func(otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		args := make([]interface{}, len(call.ArgumentList))
+		for i, arg := range call.ArgumentList {
+			if arg.IsUndefined() || arg.IsNull() {
+				continue
+			}
+			exported, err := arg.Export()
+			if err != nil {
+				panic(call.Otto.MakeTypeError(fmt.Sprintf("helpers.%s: %v", name, err)))
+			}
+			args[i] = exported
+		}
+
+		result, err := fn(args...)
+		if err != nil {
+			panic(call.Otto.MakeTypeError(fmt.Sprintf("helpers.%s: %v", name, err)))
+		}
+
+		value, err := call.Otto.ToValue(result)
+		if err != nil {
+			return otto.UndefinedValue()
+		}
+		return value
+	}
+}
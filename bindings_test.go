@@ -0,0 +1,71 @@
+package jsmachine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bindingTypes reports typeof for a listed, an unlisted and an always-present global
+const bindingTypes = `[typeof intl, typeof fetch, typeof helpers].join(",")`
+
+func TestBindingsSelectiveInjection(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "probe.js"), []byte(bindingTypes), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		req  *ExecuteRequest
+		want string
+	}{
+		{
+			name: "trusted pool",
+			cfg:  &Config{PoolSize: 1, Bindings: []string{"intl", "helpers"}},
+			req:  &ExecuteRequest{Code: bindingTypes},
+			want: "object,undefined,object",
+		},
+		{
+			name: "trusted pool by script",
+			cfg: &Config{
+				PoolSize:  1,
+				Bindings:  []string{"intl", "fetch", "helpers"},
+				Scripts:   &ScriptsConfig{Dir: dir},
+				Untrusted: &UntrustedPoolConfig{PoolSize: 1, Bindings: []string{"helpers"}},
+			},
+			req:  &ExecuteRequest{Script: "probe"},
+			want: "object,function,object",
+		},
+		{
+			name: "untrusted pool",
+			cfg: &Config{
+				PoolSize:  1,
+				Bindings:  []string{"intl", "fetch", "helpers"},
+				Untrusted: &UntrustedPoolConfig{PoolSize: 1, Bindings: []string{"intl", "helpers"}},
+			},
+			req:  &ExecuteRequest{Code: bindingTypes},
+			want: "object,undefined,object",
+		},
+		{
+			name: "untrusted pool without listed binding",
+			cfg: &Config{
+				PoolSize:  1,
+				Untrusted: &UntrustedPoolConfig{PoolSize: 1, Bindings: []string{"helpers"}},
+			},
+			req:  &ExecuteRequest{Code: bindingTypes},
+			want: "undefined,undefined,object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := serveTestPlugin(t, tt.cfg)
+			resp := execute(t, p, tt.req)
+			if resp.Result != tt.want {
+				t.Errorf("got %v, want %v", resp.Result, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package jsmachine
+
+import (
+	"strings"
+
+	"github.com/mssola/useragent"
+	"github.com/robertkrimen/otto"
+)
+
+// UABinding parses User-Agent headers in Go, which is far faster than JavaScript parsers under otto
+type UABinding struct{}
+
+// newUABinding creates a new user-agent binding
+func newUABinding() *UABinding {
+	return &UABinding{}
+}
+
+// inject injects the ua object into the VM
+func (u *UABinding) inject(vm *otto.Otto) error {
+	uaObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// ua.parse(userAgent)
+	if err := uaObj.Set("parse", u.parse); err != nil {
+		return err
+	}
+
+	return vm.Set("ua", uaObj)
+}
+
+// parse returns browser, engine, OS and device details of a User-Agent string
+func (u *UABinding) parse(call otto.FunctionCall) otto.Value {
+	header := call.Argument(0).String()
+	ua := useragent.New(header)
+
+	browser, browserVersion := ua.Browser()
+	engine, engineVersion := ua.Engine()
+	os := ua.OSInfo()
+
+	result, err := call.Otto.ToValue(map[string]interface{}{
+		"browser": map[string]interface{}{"name": browser, "version": browserVersion},
+		"engine":  map[string]interface{}{"name": engine, "version": engineVersion},
+		"os":      map[string]interface{}{"name": os.Name, "version": os.Version, "platform": ua.Platform()},
+		"device":  map[string]interface{}{"type": deviceType(ua, header), "model": ua.Model()},
+		"bot":     ua.Bot(),
+		"mobile":  ua.Mobile(),
+	})
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	return result
+}
+
+// deviceType classifies the device as bot, tablet, mobile, desktop or unknown
+func deviceType(ua *useragent.UserAgent, header string) string {
+	switch {
+	case strings.TrimSpace(header) == "":
+		return "unknown"
+	case ua.Bot():
+		return "bot"
+	case strings.Contains(header, "iPad") || strings.Contains(header, "Tablet") ||
+		(strings.Contains(header, "Android") && !strings.Contains(header, "Mobile")):
+		return "tablet"
+	case ua.Mobile():
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
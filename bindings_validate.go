@@ -0,0 +1,328 @@
+package jsmachine
+
+import (
+	"errors"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+	"github.com/robertkrimen/otto"
+)
+
+// Reasons a value fails validation
+const (
+	invalidFormat   = "format"
+	invalidLength   = "length"
+	invalidChecksum = "checksum"
+	invalidCountry  = "country"
+)
+
+// ibanLengths is the IBAN length of each country of the IBAN registry (ISO 13616)
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22, "BH": 22, "BI": 27,
+	"BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22, "DJ": 27, "DK": 18, "DO": 28,
+	"EE": 20, "EG": 29, "ES": 24, "FI": 18, "FK": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23,
+	"GL": 18, "GR": 27, "GT": 28, "HN": 28, "HR": 21, "HU": 28, "IE": 22, "IL": 23, "IQ": 23, "IS": 26,
+	"IT": 27, "JO": 30, "KW": 30, "KZ": 20, "LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21,
+	"LY": 25, "MC": 27, "MD": 24, "ME": 22, "MK": 19, "MN": 20, "MR": 27, "MT": 31, "MU": 30, "NI": 28,
+	"NL": 18, "NO": 15, "OM": 23, "PK": 24, "PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22,
+	"RU": 33, "SA": 24, "SC": 31, "SD": 18, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "SO": 23, "ST": 25,
+	"SV": 28, "TL": 23, "TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20, "YE": 30,
+}
+
+// vatFormats is the format of the VAT number of each supported country, without its prefix: EU member states (as
+// listed by VIES, Greece as EL and Northern Ireland as XI), the United Kingdom, Switzerland and Norway
+var vatFormats = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U\d{8}$`),
+	"BE": regexp.MustCompile(`^[01]\d{9}$`),
+	"BG": regexp.MustCompile(`^\d{9,10}$`),
+	"CY": regexp.MustCompile(`^\d{8}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^\d{8,10}$`),
+	"DE": regexp.MustCompile(`^\d{9}$`),
+	"DK": regexp.MustCompile(`^\d{8}$`),
+	"EE": regexp.MustCompile(`^\d{9}$`),
+	"EL": regexp.MustCompile(`^\d{9}$`),
+	"ES": regexp.MustCompile(`^[A-Z0-9]\d{7}[A-Z0-9]$`),
+	"FI": regexp.MustCompile(`^\d{8}$`),
+	"FR": regexp.MustCompile(`^[A-HJ-NP-Z0-9]{2}\d{9}$`),
+	"HR": regexp.MustCompile(`^\d{11}$`),
+	"HU": regexp.MustCompile(`^\d{8}$`),
+	"IE": regexp.MustCompile(`^(\d{7}[A-W][A-I]?|\d[A-Z+*]\d{5}[A-W])$`),
+	"IT": regexp.MustCompile(`^\d{11}$`),
+	"LT": regexp.MustCompile(`^(\d{9}|\d{12})$`),
+	"LU": regexp.MustCompile(`^\d{8}$`),
+	"LV": regexp.MustCompile(`^\d{11}$`),
+	"MT": regexp.MustCompile(`^\d{8}$`),
+	"NL": regexp.MustCompile(`^\d{9}B\d{2}$`),
+	"PL": regexp.MustCompile(`^\d{10}$`),
+	"PT": regexp.MustCompile(`^\d{9}$`),
+	"RO": regexp.MustCompile(`^[1-9]\d{1,9}$`),
+	"SE": regexp.MustCompile(`^\d{10}01$`),
+	"SI": regexp.MustCompile(`^\d{8}$`),
+	"SK": regexp.MustCompile(`^\d{10}$`),
+	"XI": regexp.MustCompile(`^(\d{9}|\d{12}|GD\d{3}|HA\d{3})$`),
+	"GB": regexp.MustCompile(`^(\d{9}|\d{12}|GD\d{3}|HA\d{3})$`),
+	"CH": regexp.MustCompile(`^E\d{9}(MWST|TVA|IVA)?$`),
+	"NO": regexp.MustCompile(`^\d{9}(MVA)?$`),
+}
+
+// vatPrefixes maps the ISO country codes whose VAT numbers use another prefix
+var vatPrefixes = map[string]string{"GR": "EL"}
+
+// phoneTypes names the phone number types scripts see
+var phoneTypes = map[phonenumbers.PhoneNumberType]string{
+	phonenumbers.FIXED_LINE:           "fixed_line",
+	phonenumbers.MOBILE:               "mobile",
+	phonenumbers.FIXED_LINE_OR_MOBILE: "fixed_line_or_mobile",
+	phonenumbers.TOLL_FREE:            "toll_free",
+	phonenumbers.PREMIUM_RATE:         "premium_rate",
+	phonenumbers.SHARED_COST:          "shared_cost",
+	phonenumbers.VOIP:                 "voip",
+	phonenumbers.PERSONAL_NUMBER:      "personal_number",
+	phonenumbers.PAGER:                "pager",
+	phonenumbers.UAN:                  "uan",
+	phonenumbers.VOICEMAIL:            "voicemail",
+}
+
+// validation is the outcome of one validator
+type validation struct {
+	valid      bool
+	normalized string
+	reason     string
+
+	// Extra properties of valid values, e.g. the country of a phone number
+	extra map[string]string
+}
+
+// ValidateBinding checks common form fields in Go, so form-processing scripts do not ship validation libraries
+type ValidateBinding struct{}
+
+// newValidateBinding creates a new validate binding
+func newValidateBinding() *ValidateBinding {
+	return &ValidateBinding{}
+}
+
+// inject injects the validate object into the VM
+func (v *ValidateBinding) inject(vm *otto.Otto) error {
+	validateObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// validate.email(value) - returns {valid, normalized, reason}
+	if err := validateObj.Set("email", func(call otto.FunctionCall) otto.Value {
+		return v.result(call.Otto, validateEmail(call.Argument(0).String()))
+	}); err != nil {
+		return err
+	}
+
+	// validate.iban(value, country) - returns {valid, normalized, reason, country}
+	if err := validateObj.Set("iban", func(call otto.FunctionCall) otto.Value {
+		return v.result(call.Otto, validateIBAN(call.Argument(0).String(), v.country(call, "validate.iban")))
+	}); err != nil {
+		return err
+	}
+
+	// validate.phone(value, country) - returns {valid, normalized, reason, country, type}
+	if err := validateObj.Set("phone", func(call otto.FunctionCall) otto.Value {
+		return v.result(call.Otto, validatePhone(call.Argument(0).String(), v.country(call, "validate.phone")))
+	}); err != nil {
+		return err
+	}
+
+	// validate.vat(value, country) - returns {valid, normalized, reason, country}
+	if err := validateObj.Set("vat", func(call otto.FunctionCall) otto.Value {
+		return v.result(call.Otto, validateVAT(call.Argument(0).String(), v.country(call, "validate.vat")))
+	}); err != nil {
+		return err
+	}
+
+	return vm.Set("validate", validateObj)
+}
+
+// country returns the optional ISO 3166-1 alpha-2 country argument, upper-cased
+func (v *ValidateBinding) country(call otto.FunctionCall, fn string) string {
+	arg := call.Argument(1)
+	if !arg.IsDefined() || arg.IsNull() {
+		return ""
+	}
+	country := strings.ToUpper(arg.String())
+	if len(country) != 2 || strings.Trim(country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		panic(call.Otto.MakeTypeError(fn + ": country must be an ISO 3166-1 alpha-2 code"))
+	}
+	return country
+}
+
+// result converts an outcome to {valid, normalized, reason, ...}: normalized is null and reason set when invalid
+func (v *ValidateBinding) result(vm *otto.Otto, r validation) otto.Value {
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "validate: "+err.Error()))
+	}
+	_ = obj.Set("valid", r.valid)
+	if r.valid {
+		_ = obj.Set("normalized", r.normalized)
+		for name, value := range r.extra {
+			_ = obj.Set(name, value)
+		}
+	} else {
+		_ = obj.Set("normalized", otto.NullValue())
+		_ = obj.Set("reason", r.reason)
+	}
+	return obj.Value()
+}
+
+// validateEmail checks a bare address (no display name) with a domain of at least two labels; the domain is
+// lower-cased
+func validateEmail(value string) validation {
+	value = strings.TrimSpace(value)
+	if len(value) > 254 {
+		return validation{reason: invalidLength}
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		return validation{reason: invalidFormat}
+	}
+
+	local, domain, _ := strings.Cut(addr.Address, "@")
+	if len(local) > 64 {
+		return validation{reason: invalidLength}
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return validation{reason: invalidFormat}
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return validation{reason: invalidFormat}
+		}
+		for _, c := range label {
+			if c != '-' && !isAlphanumeric(c) && c < 0x80 {
+				return validation{reason: invalidFormat}
+			}
+		}
+	}
+	return validation{valid: true, normalized: local + "@" + strings.ToLower(domain)}
+}
+
+// validateIBAN checks the length of an IBAN for its country and its ISO 7064 mod 97-10 check digits; spaces are
+// ignored and letters upper-cased
+func validateIBAN(value, country string) validation {
+	iban := compactCode(value, " ")
+	if len(iban) < 5 {
+		return validation{reason: invalidFormat}
+	}
+	for _, c := range iban {
+		if !isAlphanumeric(c) {
+			return validation{reason: invalidFormat}
+		}
+	}
+
+	code := iban[:2]
+	if country != "" && code != country {
+		return validation{reason: invalidCountry}
+	}
+	length, ok := ibanLengths[code]
+	if !ok {
+		return validation{reason: invalidCountry}
+	}
+	if len(iban) != length {
+		return validation{reason: invalidLength}
+	}
+
+	// The country code and check digits move to the end, letters count as 10 to 35
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' {
+			digits.WriteString(strconv.Itoa(int(c - 'A' + 10)))
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	if new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return validation{reason: invalidChecksum}
+	}
+	return validation{valid: true, normalized: iban, extra: map[string]string{"country": code}}
+}
+
+// validatePhone checks a phone number against the numbering plan of its country; numbers without a leading + are
+// read as national numbers of country
+func validatePhone(value, country string) validation {
+	number, err := phonenumbers.Parse(value, country)
+	if errors.Is(err, phonenumbers.ErrInvalidCountryCode) {
+		return validation{reason: invalidCountry}
+	}
+	if err != nil {
+		return validation{reason: invalidFormat}
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		return validation{reason: invalidFormat}
+	}
+
+	region := phonenumbers.GetRegionCodeForNumber(number)
+	if country != "" && region != country {
+		return validation{reason: invalidCountry}
+	}
+
+	kind, ok := phoneTypes[phonenumbers.GetNumberType(number)]
+	if !ok {
+		kind = "unknown"
+	}
+	return validation{
+		valid:      true,
+		normalized: phonenumbers.Format(number, phonenumbers.E164),
+		extra:      map[string]string{"country": region, "type": kind},
+	}
+}
+
+// validateVAT checks the format of a VAT identification number for its country; the country prefix may be omitted
+// when country is given, and spaces, dots and dashes are ignored
+func validateVAT(value, country string) validation {
+	vat := compactCode(value, " .-")
+	if len(vat) < 3 {
+		return validation{reason: invalidFormat}
+	}
+
+	prefix, number := country, vat
+	if p, ok := vatPrefixes[country]; ok {
+		prefix = p
+	}
+	switch {
+	case prefix == "":
+		prefix, number = vat[:2], vat[2:]
+	case strings.HasPrefix(vat, prefix) || strings.HasPrefix(vat, country):
+		number = vat[2:]
+	}
+
+	format, ok := vatFormats[prefix]
+	if !ok {
+		return validation{reason: invalidCountry}
+	}
+	// A complete number of another country, e.g. DE123456789 checked for FR
+	if other, ok := vatFormats[vat[:2]]; ok && number == vat && other.MatchString(vat[2:]) {
+		return validation{reason: invalidCountry}
+	}
+	if !format.MatchString(number) {
+		return validation{reason: invalidFormat}
+	}
+	return validation{valid: true, normalized: prefix + number, extra: map[string]string{"country": prefix}}
+}
+
+// compactCode upper-cases a code and removes the separator characters
+func compactCode(value, separators string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(separators, r) {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(value)))
+}
+
+// isAlphanumeric reports whether c is an ASCII letter or digit
+func isAlphanumeric(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
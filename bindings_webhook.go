@@ -0,0 +1,127 @@
+package jsmachine
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// WebhookBinding queues webhooks for background delivery with retries
+type WebhookBinding struct {
+	plugin *Plugin
+}
+
+// newWebhookBinding creates a new webhook binding
+func newWebhookBinding(plugin *Plugin) *WebhookBinding {
+	return &WebhookBinding{
+		plugin: plugin,
+	}
+}
+
+// inject injects the webhook object into the VM
+func (w *WebhookBinding) inject(vm *otto.Otto) error {
+	webhookObj, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	// webhook.dispatch(url, payload, {method, headers, client, maxAttempts}) - returns the delivery ID
+	if err := webhookObj.Set("dispatch", w.dispatch); err != nil {
+		return err
+	}
+
+	return vm.Set("webhook", webhookObj)
+}
+
+// dispatch queues a delivery and returns its ID without waiting for it (null in mock mode)
+// Objects are sent as JSON, strings as they are; throws when the URL is not allowed or the queue is full
+func (w *WebhookBinding) dispatch(call otto.FunctionCall) otto.Value {
+	dispatcher := w.plugin.webhooks
+	if dispatcher == nil {
+		panic(call.Otto.MakeCustomError("Error", "webhook.dispatch: js.webhooks is not configured"))
+	}
+	fetchCfg := w.plugin.cfg.Fetch
+
+	// Deliveries go through the fetch clients, so they are subject to the same host, scheme and address checks
+	target, err := url.Parse(call.Argument(0).String())
+	if err != nil || !target.IsAbs() || target.Host == "" {
+		panic(call.Otto.MakeTypeError("webhook.dispatch: url must be an absolute URL"))
+	}
+	if !slices.Contains(fetchCfg.AllowedSchemes, target.Scheme) {
+		panic(call.Otto.MakeCustomError("Error", "webhook.dispatch: "+target.Scheme+": "+errSchemeNotAllowed.Error()))
+	}
+	if !hostAllowed(strings.ToLower(target.Hostname()), w.plugin.allowedHosts(w.plugin.executionFor(call.Otto))) {
+		panic(call.Otto.MakeCustomError("Error", "webhook.dispatch: "+target.Hostname()+": "+errHostNotAllowed.Error()))
+	}
+
+	delivery := &webhookDelivery{
+		id:          newRecordID(),
+		method:      http.MethodPost,
+		url:         target.String(),
+		headers:     make(map[string]string),
+		maxAttempts: w.plugin.cfg.Webhooks.MaxAttempts,
+	}
+
+	payload := call.Argument(1)
+	switch {
+	case payload.IsString():
+		delivery.body = []byte(payload.String())
+	case payload.IsDefined() && !payload.IsNull():
+		body, err := call.Otto.Call("JSON.stringify", nil, payload)
+		if err != nil {
+			panic(call.Otto.MakeTypeError("webhook.dispatch: payload cannot be serialized to JSON"))
+		}
+		delivery.body = []byte(body.String())
+		delivery.headers["Content-Type"] = "application/json"
+	}
+
+	clientName := w.options(call.Argument(2), delivery)
+	client, ok := w.plugin.fetchClients.get(clientName)
+	if !ok {
+		panic(call.Otto.MakeTypeError("webhook.dispatch: unknown client profile " + clientName))
+	}
+	delivery.client = client
+
+	if w.plugin.mocked(call.Otto) {
+		return otto.NullValue()
+	}
+	if !dispatcher.enqueue(delivery) {
+		w.plugin.log.Warn("webhook queue is full", zap.String("url", delivery.url))
+		panic(call.Otto.MakeCustomError("Error", "webhook.dispatch: queue is full"))
+	}
+
+	return stringValue(delivery.id)
+}
+
+// options applies the optional third argument of dispatch, returning the client profile
+func (w *WebhookBinding) options(arg otto.Value, delivery *webhookDelivery) string {
+	if !arg.IsObject() {
+		return ""
+	}
+	obj := arg.Object()
+
+	if v, _ := obj.Get("method"); v.IsString() {
+		delivery.method = strings.ToUpper(v.String())
+	}
+	if v, _ := obj.Get("headers"); v.IsObject() {
+		headerObj := v.Object()
+		for _, name := range headerObj.Keys() {
+			value, _ := headerObj.Get(name)
+			delivery.headers[name] = value.String()
+		}
+	}
+	// Attempts can be lowered per delivery, never raised beyond the configuration
+	if v, _ := obj.Get("maxAttempts"); v.IsNumber() {
+		if n, err := v.ToInteger(); err == nil && n > 0 && int(n) < delivery.maxAttempts {
+			delivery.maxAttempts = int(n)
+		}
+	}
+	if v, _ := obj.Get("client"); v.IsString() {
+		return v.String()
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package jsmachine
+
+import (
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// Cache kinds accepted by FlushCaches
+const (
+	cachePrograms   = "programs"
+	cacheModules    = "modules"
+	cacheCollectors = "collectors"
+	cacheTemplates  = "templates"
+	cacheFlags      = "flags"
+	cacheValues     = "cache"
+	cacheOAuth      = "oauth_tokens"
+	cacheDiscovery  = "discovery"
+	cacheGRPC       = "grpc_descriptors"
+)
+
+// cacheKinds lists the flushable caches in flush order
+var cacheKinds = []string{cachePrograms, cacheModules, cacheCollectors, cacheTemplates, cacheFlags, cacheValues, cacheOAuth, cacheDiscovery, cacheGRPC}
+
+// FlushCachesRequest selects the cache to flush
+type FlushCachesRequest struct {
+	// programs, modules, collectors, templates, flags, cache, oauth_tokens, discovery or grpc_descriptors (empty = all)
+	Kind string `json:"kind,omitempty"`
+}
+
+// FlushCachesResponse lists the caches that were flushed
+type FlushCachesResponse struct {
+	// Flushed caches; caches of features that are not configured are skipped
+	Flushed []string `json:"flushed"`
+}
+
+// FlushCaches drops cached state so it is rebuilt from its source, e.g. to pick up changed shared libraries without a restart
+func (r *rpc) FlushCaches(req *FlushCachesRequest, resp *FlushCachesResponse) error {
+	kinds := cacheKinds
+	if req.Kind != "" {
+		if !slices.Contains(cacheKinds, req.Kind) {
+			return fmt.Errorf("unknown cache kind %q (expected one of %v)", req.Kind, cacheKinds)
+		}
+		kinds = []string{req.Kind}
+	}
+
+	resp.Flushed = make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		flushed, err := r.plugin.flushCache(kind)
+		if err != nil {
+			return fmt.Errorf("failed to flush %s: %w", kind, err)
+		}
+		if flushed {
+			resp.Flushed = append(resp.Flushed, kind)
+		}
+	}
+
+	r.log.Info("caches flushed", zap.Strings("flushed", resp.Flushed))
+	return nil
+}
+
+// flushCache flushes one cache, reporting false when the feature owning it is not configured
+func (p *Plugin) flushCache(kind string) (bool, error) {
+	switch kind {
+	case cachePrograms:
+		// Re-reading the registry queues new and changed scripts for the background compiler and returns without
+		// waiting for it; ad-hoc code is compiled on its next run
+		if p.programs != nil {
+			p.programs.clear()
+		}
+		if p.scripts == nil {
+			return p.programs != nil, nil
+		}
+		return true, p.scripts.load()
+
+	case cacheModules:
+		// VMs evaluate the new libraries on their next require
+		libraries, err := loadLibraries(p.cfg.Libraries)
+		if err != nil {
+			return false, err
+		}
+		p.libraries.Store(libraries)
+		return true, nil
+
+	case cacheCollectors:
+		p.bindings.metrics.invalidate()
+		return true, nil
+
+	case cacheTemplates:
+		p.bindings.template.invalidate()
+		return true, nil
+
+	case cacheFlags:
+		if p.flags == nil {
+			return false, nil
+		}
+		p.flags.invalidate()
+		return true, nil
+
+	case cacheValues:
+		// Entries of a kv storage may be shared with other nodes and applications; they expire by their TTL
+		if p.cfg.Cache.Storage != "" {
+			return false, nil
+		}
+		p.bindings.cache.memory.clear()
+		return true, nil
+
+	case cacheOAuth:
+		// Tokens are requested again on the next oauth.token call, e.g. after the provider revoked them
+		if p.cfg.OAuth == nil {
+			return false, nil
+		}
+		p.bindings.oauth.tokens.clear()
+		return true, nil
+
+	case cacheDiscovery:
+		// Services are resolved again on the next discovery.resolve call
+		if p.discovery == nil {
+			return false, nil
+		}
+		p.discovery.invalidate()
+		return true, nil
+
+	case cacheGRPC:
+		// Methods are described again by server reflection on their next grpc.call, e.g. after a deployment
+		if p.grpcTargets == nil {
+			return false, nil
+		}
+		p.grpcTargets.invalidate()
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown cache kind %q", kind)
+}
@@ -0,0 +1,195 @@
+package jsmachine
+
+import "runtime/debug"
+
+const (
+	// engineName identifies the JavaScript engine backing the VM pool
+	engineName = "otto"
+
+	// Module paths used to resolve versions from the build info
+	engineModule = "github.com/robertkrimen/otto"
+	pluginModule = "github.com/roadrunner-plugins/js-machine"
+)
+
+// CapabilitiesRequest is empty; the argument is required by net/rpc
+type CapabilitiesRequest struct{}
+
+// CapabilitiesResponse describes the engine and the server's configuration
+type CapabilitiesResponse struct {
+	Engine        string `json:"engine"`
+	EngineVersion string `json:"engine_version"`
+	PluginVersion string `json:"plugin_version"`
+
+	// Global objects injected into the main pool's VMs
+	Bindings []string `json:"bindings"`
+
+	// Global holding the versioned binding namespaces, and the API versions every binding is available in: a
+	// binding at version vN is available as rr.vN.<name>, the unversioned global is an alias of v1
+	BindingNamespace string              `json:"binding_namespace"`
+	BindingVersions  map[string][]string `json:"binding_versions"`
+
+	Limits CapabilityLimits `json:"limits"`
+
+	// Limits and bindings of the pool running ad-hoc code (nil when all code shares one pool)
+	Untrusted *UntrustedCapabilities `json:"untrusted,omitempty"`
+
+	// ExecuteRequest fields honoured by this server
+	RequestFeatures []string `json:"request_features"`
+
+	// Optional subsystems that are enabled in the configuration
+	Features []string `json:"features"`
+}
+
+// CapabilityLimits reports the configured execution limits
+type CapabilityLimits struct {
+	PoolSize         int `json:"pool_size"`
+	DefaultTimeoutMs int `json:"default_timeout_ms"`
+	MaxMemoryMB      int `json:"max_memory_mb"`
+
+	// Ceiling for requested timeouts (0 = none) and whether larger ones are clamped or rejected
+	MaxTimeoutMs  int    `json:"max_timeout_ms"`
+	TimeoutPolicy string `json:"timeout_policy"`
+
+	// Maximum accepted code size in bytes (0 = unlimited)
+	MaxCodeSizeBytes int `json:"max_code_size_bytes"`
+
+	// Maximum attempts a request may ask for in its retry policy
+	MaxRetryAttempts int `json:"max_retry_attempts"`
+}
+
+// UntrustedCapabilities describes the pool that runs ad-hoc code
+type UntrustedCapabilities struct {
+	PoolSize         int      `json:"pool_size"`
+	DefaultTimeoutMs int      `json:"default_timeout_ms"`
+	MaxTimeoutMs     int      `json:"max_timeout_ms"`
+	Bindings         []string `json:"bindings"`
+}
+
+// Capabilities lets clients discover what the server supports instead of guessing
+func (r *rpc) Capabilities(_ *CapabilitiesRequest, resp *CapabilitiesResponse) error {
+	cfg := r.plugin.cfg
+	pools := r.plugin.pools()
+
+	resp.Engine = engineName
+	resp.EngineVersion = moduleVersion(engineModule)
+	resp.PluginVersion = moduleVersion(pluginModule)
+	resp.Bindings = pools[0].bindings
+	resp.BindingNamespace = bindingNamespace
+	resp.BindingVersions = capabilityVersions()
+
+	resp.Limits = CapabilityLimits{
+		PoolSize:         pools[0].size,
+		DefaultTimeoutMs: int(pools[0].defaultTimeout.Milliseconds()),
+		MaxMemoryMB:      cfg.MaxMemoryMB,
+		MaxTimeoutMs:     int(pools[0].maxTimeout.Milliseconds()),
+		MaxCodeSizeBytes: cfg.MaxCodeSize,
+		TimeoutPolicy:    cfg.TimeoutPolicy,
+		MaxRetryAttempts: maxRetryAttempts,
+	}
+
+	if len(pools) > 1 {
+		u := pools[1]
+		resp.Untrusted = &UntrustedCapabilities{
+			PoolSize:         u.size,
+			DefaultTimeoutMs: int(u.defaultTimeout.Milliseconds()),
+			MaxTimeoutMs:     int(u.maxTimeout.Milliseconds()),
+			Bindings:         u.bindings,
+		}
+	}
+
+	resp.RequestFeatures = []string{"timeout_ms", "request_id", "caller", "tag", "traceparent", "profile", "retry"}
+
+	resp.Features = []string{"lint", "benchmark", "map_reduce", "flush_caches"}
+	if cfg.GRPC != nil {
+		resp.Features = append(resp.Features, "grpc")
+	}
+	if cfg.WebSocket != nil {
+		resp.Features = append(resp.Features, "websocket")
+	}
+	if cfg.OTLP != nil {
+		resp.Features = append(resp.Features, "otlp")
+	}
+	if cfg.Sentry != nil {
+		resp.Features = append(resp.Features, "sentry")
+	}
+	if cfg.OnFailureWebhook != nil {
+		resp.Features = append(resp.Features, "on_failure_webhook")
+	}
+	if cfg.DeadLetter != nil {
+		resp.Features = append(resp.Features, "dead_letter")
+	}
+	if cfg.Profiling != nil {
+		resp.Features = append(resp.Features, "profiling")
+	}
+	if cfg.Tracing != nil {
+		resp.Features = append(resp.Features, "tracing")
+	}
+	if cfg.Flags != nil {
+		resp.Features = append(resp.Features, "flags")
+	}
+	if cfg.I18n != nil {
+		resp.Features = append(resp.Features, "i18n")
+	}
+	if cfg.OAuth != nil {
+		resp.Features = append(resp.Features, "oauth")
+	}
+	if cfg.Mail != nil {
+		resp.Features = append(resp.Features, "mail")
+	}
+	if cfg.Webhooks != nil {
+		resp.Features = append(resp.Features, "webhooks")
+	}
+	if cfg.Discovery != nil {
+		resp.Features = append(resp.Features, "discovery")
+	}
+	if cfg.GRPCClient != nil {
+		resp.Features = append(resp.Features, "grpc_client")
+	}
+	if cfg.GraphQL != nil {
+		resp.Features = append(resp.Features, "graphql")
+	}
+	if cfg.MQTT != nil {
+		resp.Features = append(resp.Features, "mqtt")
+	}
+	if cfg.Prometheus != nil {
+		resp.Features = append(resp.Features, "prometheus")
+	}
+	if cfg.Schedules != nil {
+		resp.Features = append(resp.Features, "schedules")
+	}
+	if cfg.Costs != nil {
+		resp.Features = append(resp.Features, "costs")
+	}
+	if cfg.Scripts != nil {
+		resp.Features = append(resp.Features, "scripts")
+	}
+	if resp.Untrusted != nil {
+		resp.Features = append(resp.Features, "untrusted_pool")
+	}
+	if cfg.Audit != nil {
+		resp.Features = append(resp.Features, "audit", "replay")
+	}
+
+	return nil
+}
+
+// moduleVersion returns the version of a module compiled into the binary
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
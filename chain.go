@@ -0,0 +1,90 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+// chainInput is the ctx.input of a follow-up script: the outcome of the execution that triggered it
+type chainInput struct {
+	// Script that triggered the follow-up and the request ID of its execution
+	Script    string `json:"script"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Result and records of a successful execution (on_success)
+	Result  interface{}       `json:"result,omitempty"`
+	Records []json.RawMessage `json:"records,omitempty"`
+
+	// Error, error code and status of a failed execution (on_failure)
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Status    string `json:"status,omitempty"`
+
+	// Number of scripts run in the chain before the follow-up
+	Depth int `json:"depth"`
+}
+
+// chain runs the on_success or on_failure follow-up of a registered script in the background, with the outcome of
+// the execution as ctx.input
+// Follow-ups run like any execution by script name, so they can trigger follow-ups of their own up to
+// js.scripts.max_chain_depth; Stop waits for the follow-ups that already started
+func (p *Plugin) chain(req *ExecuteRequest, resp *ExecuteResponse, status string) {
+	if req.Script == "" || p.scripts == nil {
+		return
+	}
+	script, err := p.scripts.get(req.Script)
+	if err != nil {
+		return
+	}
+
+	input := chainInput{Script: req.Script, RequestID: req.RequestID, Depth: req.chainDepth + 1}
+	next := script.info.OnSuccess
+	if resp.Error != "" {
+		next = script.info.OnFailure
+		input.Error, input.ErrorCode, input.Status = resp.Error, resp.ErrorCode, status
+	} else {
+		input.Result, input.Records = resp.Result, resp.Records
+	}
+	if next == "" {
+		return
+	}
+
+	log := p.log.With(zap.String("script", req.Script), zap.String("next", next), zap.String("request_id", req.RequestID))
+	if input.Depth > p.cfg.Scripts.MaxChainDepth {
+		log.Warn("script chain stopped at js.scripts.max_chain_depth", zap.Int("depth", req.chainDepth))
+		return
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		log.Error("failed to encode the input of the follow-up script", zap.Error(err))
+		return
+	}
+	if err := p.lifecycle.begin(); err != nil {
+		log.Warn("follow-up script not run, the plugin is stopping")
+		return
+	}
+
+	go func() {
+		defer p.lifecycle.done()
+
+		followUp := &ExecuteResponse{}
+		err := (&rpc{plugin: p, log: p.log}).handleExecute(context.Background(), &ExecuteRequest{
+			Script:     next,
+			RequestID:  stepRequestID(req.RequestID, next),
+			Caller:     req.Caller,
+			Tag:        req.Tag,
+			input:      string(data),
+			chainDepth: input.Depth,
+		}, followUp)
+		switch {
+		case err != nil:
+			log.Warn("follow-up script failed", zap.Error(err))
+		case followUp.Error != "":
+			log.Warn("follow-up script failed", zap.String("error", p.redactor.string(followUp.Error)))
+		default:
+			log.Debug("follow-up script completed", zap.Int64("duration_ms", followUp.DurationMs))
+		}
+	}()
+}
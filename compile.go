@@ -0,0 +1,57 @@
+package jsmachine
+
+import (
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	// Program sources for js_programs_total
+	programCached   = "cached"
+	programCompiled = "compiled"
+)
+
+// compileProgram parses code once so it can be run on any VM
+// Returns nil when the code does not compile, leaving the error to be reported by the execution
+func compileProgram(code string) *otto.Script {
+	program, err := otto.New().Compile("", code)
+	if err != nil {
+		return nil
+	}
+	return program
+}
+
+// compile returns the execution's or the program cache's program or parses script, recording the compile time
+func (p *Plugin) compile(vm *otto.Otto, exec *execution, script string) (*otto.Script, error) {
+	program := exec.program
+	if program == nil && p.programs != nil {
+		program = p.programs.get(script)
+	}
+	if program != nil {
+		p.programsTotal.WithLabelValues(programCached).Inc()
+		return program, nil
+	}
+
+	start := time.Now()
+	program, err := vm.Compile("", script)
+	p.compileDuration.Observe(time.Since(start).Seconds())
+	p.programsTotal.WithLabelValues(programCompiled).Inc()
+
+	if err == nil && p.programs != nil {
+		p.programs.put(script, program)
+	}
+	return program, err
+}
+
+// observeRun records the time a program ran, excluding compilation
+func (p *Plugin) observeRun(exec *execution, duration time.Duration, failed bool) {
+	status := "success"
+	switch {
+	case exec.isAborted():
+		status = "timeout"
+	case failed:
+		status = "error"
+	}
+	p.runDuration.WithLabelValues(status, exec.pool.name).Observe(duration.Seconds())
+}
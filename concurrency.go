@@ -0,0 +1,161 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// concurrencyLimiter bounds concurrent work per key: executions of registered scripts, or the executions and
+// outbound calls sharing a concurrency group
+// Keys without a configured limit are not bounded
+type concurrencyLimiter struct {
+	slots map[string]chan struct{}
+
+	wait    *prometheus.HistogramVec
+	waiting *prometheus.GaugeVec
+}
+
+// newConcurrencyLimiter creates a semaphore per key in limits
+func newConcurrencyLimiter(limits map[string]int, wait *prometheus.HistogramVec, waiting *prometheus.GaugeVec) *concurrencyLimiter {
+	slots := make(map[string]chan struct{}, len(limits))
+	for key, limit := range limits {
+		slots[key] = make(chan struct{}, limit)
+	}
+
+	return &concurrencyLimiter{
+		slots:   slots,
+		wait:    wait,
+		waiting: waiting,
+	}
+}
+
+// acquire waits for a slot of key; the returned func releases it
+// Waiting ends early when ctx is done or the plugin stops
+func (l *concurrencyLimiter) acquire(ctx context.Context, stop <-chan struct{}, key string) (func(), error) {
+	slot, ok := l.slots[key]
+	if !ok {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	l.waiting.WithLabelValues(key).Inc()
+	defer func() {
+		l.waiting.WithLabelValues(key).Dec()
+		l.wait.WithLabelValues(key).Observe(time.Since(start).Seconds())
+	}()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a %s concurrency slot: %w", key, ctx.Err())
+	case <-stop:
+		return nil, errNotReady
+	}
+}
+
+// heldGroupsKey is the context key of the concurrency groups an execution holds
+type heldGroupsKey struct{}
+
+// acquireGroups waits for a slot of every group, in name order so executions sharing groups cannot deadlock
+// The returned context marks the groups as held: calls made with it do not wait for them again, which would
+// deadlock a group of one
+func (l *concurrencyLimiter) acquireGroups(ctx context.Context, stop <-chan struct{}, groups []string) (context.Context, func(), error) {
+	groups = slices.Sorted(slices.Values(groups))
+	releases := make([]func(), 0, len(groups))
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+
+	for _, group := range groups {
+		r, err := l.acquire(ctx, stop, group)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		releases = append(releases, r)
+	}
+	held := append(slices.Clone(heldGroups(ctx)), groups...)
+	return context.WithValue(ctx, heldGroupsKey{}, held), release, nil
+}
+
+// acquireCall waits for a slot of group for one outbound call, unless the calling execution holds the group
+func (l *concurrencyLimiter) acquireCall(ctx context.Context, group string) (func(), error) {
+	if slices.Contains(heldGroups(ctx), group) {
+		return func() {}, nil
+	}
+	return l.acquire(ctx, nil, group)
+}
+
+// heldGroups returns the groups held by the execution ctx belongs to
+func heldGroups(ctx context.Context) []string {
+	groups, _ := ctx.Value(heldGroupsKey{}).([]string)
+	return groups
+}
+
+// groupTransport holds a slot of a concurrency group for every request, until its response body is closed
+type groupTransport struct {
+	next    http.RoundTripper
+	limiter *concurrencyLimiter
+	group   string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *groupTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquireCall(req.Context(), t.group)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody releases a concurrency slot when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close implements io.Closer
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// groupInterceptor holds a slot of a concurrency group for every unary gRPC call
+func groupInterceptor(limiter *concurrencyLimiter, group string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		release, err := limiter.acquireCall(ctx, group)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// scriptGroups returns the concurrency groups executions of a registered script hold
+func (p *Plugin) scriptGroups(script string) []string {
+	if script == "" || p.cfg.Scripts == nil {
+		return nil
+	}
+	return p.cfg.Scripts.Groups[script]
+}
@@ -1,6 +1,13 @@
 package jsmachine
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"slices"
+	"time"
+)
 
 // Config holds plugin configuration
 type Config struct {
@@ -8,10 +15,1063 @@ type Config struct {
 	PoolSize       int `mapstructure:"pool_size"`
 	MaxMemoryMB    int `mapstructure:"max_memory_mb"`
 	DefaultTimeout int `mapstructure:"default_timeout_ms"`
+
+	// Build VMs when executions need them instead of all of them at startup (disabled when nil)
+	LazyPool *LazyPoolConfig `mapstructure:"lazy_pool"`
+
+	// Ceiling for requested timeouts (default: 0, no ceiling) and what happens to requests above it: clamp or reject
+	MaxTimeout    int    `mapstructure:"max_timeout_ms"`
+	TimeoutPolicy string `mapstructure:"timeout_policy"`
+
+	// Maximum size of submitted code in bytes (default: 0, unlimited); registered scripts are exempt
+	MaxCodeSize int `mapstructure:"max_code_size_bytes"`
+
+	// Reject submitted code with invalid UTF-8 instead of replacing the invalid sequences
+	StrictEncoding bool `mapstructure:"strict_encoding"`
+
+	// Time scripts get to react to ctx.aborted()/ctx.onAbort before being interrupted (default: 0, interrupt at once)
+	AbortGraceMs int `mapstructure:"abort_grace_ms"`
+
+	// Maximum emit(record) calls per execution (default: 10000)
+	MaxRecords int `mapstructure:"max_records"`
+
+	// Global objects injected into the main pool's VMs (default: all bindings)
+	Bindings []string `mapstructure:"bindings"`
+
+	// Histogram bucket overrides for the plugin's own metrics
+	HistogramBuckets *HistogramBucketsConfig `mapstructure:"histogram_buckets"`
+
+	// Execution duration thresholds counted by js_executions_over_threshold_total (default: none)
+	SLOThresholds []int `mapstructure:"slo_thresholds_ms"`
+
+	// Tags requests may carry to segment executions in logs, traces and metrics (default: none, tags are rejected)
+	Tags []string `mapstructure:"tags"`
+
+	// Slots per named concurrency group, shared by every pool and entry point; scripts (scripts.groups), fetch
+	// client profiles and gRPC targets (concurrency_group) hold slots of the groups they are in (default: none)
+	ConcurrencyGroups map[string]int `mapstructure:"concurrency_groups"`
+
+	// gRPC endpoint (disabled when nil)
+	GRPC *GRPCConfig `mapstructure:"grpc"`
+
+	// WebSocket event streaming via the HTTP plugin middleware (disabled when nil)
+	WebSocket *WebSocketConfig `mapstructure:"websocket"`
+
+	// OTLP export of script logs (disabled when nil)
+	OTLP *OTLPConfig `mapstructure:"otlp"`
+
+	// Sentry reporting of script failures (disabled when nil)
+	Sentry *SentryConfig `mapstructure:"sentry"`
+
+	// Webhook notified about failed executions (disabled when nil)
+	OnFailureWebhook *FailureWebhookConfig `mapstructure:"on_failure_webhook"`
+
+	// Default retry policy (no retries unless configured)
+	Retry *RetryPolicy `mapstructure:"retry"`
+
+	// Storage for executions that exhausted retries (disabled when nil)
+	DeadLetter *DeadLetterConfig `mapstructure:"dead_letter"`
+
+	// Audit log of completed executions (disabled when nil)
+	Audit *AuditConfig `mapstructure:"audit"`
+
+	// Constants injected into every VM as read-only globals
+	Globals map[string]interface{} `mapstructure:"globals"`
+
+	// Freeze the standard built-ins (Object, Array, JSON, ...) and their prototypes as well as the bindings, so
+	// scripts cannot patch them for later executions on the same VM
+	FreezeBuiltins bool `mapstructure:"freeze_builtins"`
+
+	// Feature flag provider for the flags binding (disabled when nil)
+	Flags *FlagsConfig `mapstructure:"flags"`
+
+	// DNS lookups of the net binding
+	Net *NetConfig `mapstructure:"net"`
+
+	// Translation catalogs for the i18n binding (disabled when nil)
+	I18n *I18nConfig `mapstructure:"i18n"`
+
+	// Limits of the in-process cache binding
+	Cache *CacheConfig `mapstructure:"cache"`
+
+	// Backend of the ratelimit binding
+	RateLimit *RateLimitConfig `mapstructure:"ratelimit"`
+
+	// Hosts and connection pool of the fetch binding
+	Fetch *FetchConfig `mapstructure:"fetch"`
+
+	// Scrubbing of script data before it is logged, audited or reported (disabled when nil)
+	Redaction *RedactionConfig `mapstructure:"redaction"`
+
+	// Credentials and keys of the signing binding (disabled when nil)
+	Signing *SigningConfig `mapstructure:"signing"`
+
+	// Data masking policies of the mask binding (disabled when nil)
+	Mask *MaskConfig `mapstructure:"mask"`
+
+	// Identity providers of the oauth binding (disabled when nil)
+	OAuth *OAuthConfig `mapstructure:"oauth"`
+
+	// Driver and limits of the mail binding (disabled when nil)
+	Mail *MailConfig `mapstructure:"mail"`
+
+	// Background delivery of webhook.dispatch (disabled when nil)
+	Webhooks *WebhookDispatchConfig `mapstructure:"webhooks"`
+
+	// Services the discovery binding resolves (disabled when nil)
+	Discovery *DiscoveryConfig `mapstructure:"discovery"`
+
+	// gRPC servers scripts call with grpc.call (disabled when nil)
+	GRPCClient *GRPCClientConfig `mapstructure:"grpc_client"`
+
+	// GraphQL endpoints of the graphql binding (disabled when nil)
+	GraphQL *GraphQLConfig `mapstructure:"graphql"`
+
+	// MQTT broker of the mqtt binding (disabled when nil)
+	MQTT *MQTTConfig `mapstructure:"mqtt"`
+
+	// Prometheus server the prom binding queries (disabled when nil)
+	Prometheus *PrometheusConfig `mapstructure:"prometheus"`
+
+	// Node identity of id.snowflake (disabled when nil)
+	Snowflake *SnowflakeConfig `mapstructure:"snowflake"`
+
+	// Exchange rates of money.convert (disabled when nil)
+	Money *MoneyConfig `mapstructure:"money"`
+
+	// Registered scripts run on cron schedules (disabled when nil)
+	Schedules *SchedulesConfig `mapstructure:"schedules"`
+
+	// Execution cost accounting per caller, with optional daily budgets (disabled when nil)
+	Costs *CostsConfig `mapstructure:"costs"`
+
+	// Shared library scripts available through require("lib/<name>") (name -> path)
+	Libraries map[string]string `mapstructure:"libraries"`
+
+	// Registry of named scripts loaded from a directory (disabled when nil)
+	Scripts *ScriptsConfig `mapstructure:"scripts"`
+
+	// Separate pool for ad-hoc code, leaving the main pool to registered scripts (disabled when nil)
+	Untrusted *UntrustedPoolConfig `mapstructure:"untrusted"`
+
+	// Registered scripts run once in mock mode on every new VM of the main pool before it takes executions, so
+	// the caches they fill are warm (default: none)
+	Prewarm []string `mapstructure:"prewarm"`
+
+	// Store keeping large results for requests with accept_result_ref to page through (disabled when nil)
+	Results *ResultStoreConfig `mapstructure:"results"`
+
+	// Cache of compiled ad-hoc code, optionally kept across restarts (disabled when nil)
+	ProgramCache *ProgramCacheConfig `mapstructure:"program_cache"`
+
+	// Run one execution per dedupe_key across nodes, sharing its result through a KV storage (disabled when nil)
+	Dedupe *DedupeConfig `mapstructure:"dedupe"`
+
+	// Hot-spot sampling for requests with profile set (disabled when nil)
+	Profiling *ProfilingConfig `mapstructure:"profiling"`
+
+	// Binding-level timing of recent executions for the Flamegraph RPC (disabled when nil)
+	Tracing *TracingConfig `mapstructure:"tracing"`
+
+	// Static checks applied by the Lint RPC
+	Lint *LintConfig `mapstructure:"lint"`
+}
+
+// Policies for requested timeouts above max_timeout_ms
+const (
+	timeoutPolicyClamp  = "clamp"
+	timeoutPolicyReject = "reject"
+)
+
+// GRPCConfig configures the gRPC endpoint mirroring the RPC API
+type GRPCConfig struct {
+	// Address to listen on, e.g. 127.0.0.1:9002; when empty, the service is registered with the RR gRPC plugin
+	Listen string `mapstructure:"listen"`
+}
+
+// WebSocketConfig configures execution event streaming
+type WebSocketConfig struct {
+	// HTTP path handled by the middleware (default: /js/ws)
+	Path string `mapstructure:"path"`
+}
+
+// OTLPConfig configures forwarding of script logs to an OTLP/HTTP collector
+type OTLPConfig struct {
+	// Collector base URL, e.g. http://otel-collector:4318 (logs are posted to /v1/logs)
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Extra HTTP headers (e.g. authentication)
+	Headers map[string]string `mapstructure:"headers"`
+
+	// service.name resource attribute (default: roadrunner)
+	ServiceName string `mapstructure:"service_name"`
+
+	// Maximum records per export request (default: 512)
+	BatchSize int `mapstructure:"batch_size"`
+
+	// Flush interval in milliseconds (default: 1000)
+	FlushIntervalMs int `mapstructure:"flush_interval_ms"`
+
+	// Export request timeout in milliseconds (default: 5000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// SentryConfig configures reporting of failed executions to Sentry
+type SentryConfig struct {
+	DSN         string `mapstructure:"dsn"`
+	Environment string `mapstructure:"environment"`
+
+	// Release tag of failures of ad-hoc code; registered scripts report name@version (their source hash)
+	Release string `mapstructure:"release"`
+
+	// Fraction of failures reported, 0..1 (default: 1)
+	SampleRate float64 `mapstructure:"sample_rate"`
+
+	// Attach the (redacted) script source to events
+	AttachCode bool `mapstructure:"attach_code"`
+
+	// Regular expressions whose matches are replaced before sending
+	Redact []string `mapstructure:"redact"`
+}
+
+// FailureWebhookConfig configures POST notifications for failed executions
+type FailureWebhookConfig struct {
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Statuses that trigger a notification (default: error, timeout)
+	Statuses []string `mapstructure:"statuses"`
+
+	// Registered scripts whose failures notify (default: all scripts and ad-hoc code)
+	Scripts []string `mapstructure:"scripts"`
+
+	// Delivery attempts including the first one (default: 3)
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// Initial retry backoff in milliseconds, doubled after every attempt (default: 500)
+	BackoffMs int `mapstructure:"backoff_ms"`
+
+	// Request timeout in milliseconds (default: 5000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Include the script source (first 2KB) in the payload
+	IncludeCode bool `mapstructure:"include_code"`
+}
+
+// DeadLetterConfig configures the dead-letter store
+type DeadLetterConfig struct {
+	// Storage driver: file or kv (default: file)
+	Driver string `mapstructure:"driver"`
+
+	// Directory for the file driver
+	Path string `mapstructure:"path"`
+
+	// Name of the kv plugin storage for the kv driver (the key of its section under kv)
+	Storage string `mapstructure:"storage"`
+
+	// Prefix of the keys of the kv driver (default: "js:dead_letter:")
+	Prefix string `mapstructure:"prefix"`
+}
+
+// AuditConfig configures the audit log
+type AuditConfig struct {
+	// Number of recent records kept in memory for replay (default: 1000)
+	Capacity int `mapstructure:"capacity"`
+
+	// Optional JSON lines file every record is appended to
+	Path string `mapstructure:"path"`
+}
+
+// FlagsConfig configures the feature flag provider
+type FlagsConfig struct {
+	// Provider: file, env or http (default: file)
+	Provider string `mapstructure:"provider"`
+
+	// JSON file with flag definitions (file provider)
+	Path string `mapstructure:"path"`
+
+	// Environment variable prefix (env provider, default: JS_FLAG_)
+	Prefix string `mapstructure:"prefix"`
+
+	// URL returning flag definitions as JSON (http provider)
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+
+	// How long flags are cached before reloading (default: 30000)
+	CacheTTLMs int `mapstructure:"cache_ttl_ms"`
+
+	// HTTP request timeout (default: 5000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// NetConfig configures the net binding
+type NetConfig struct {
+	// Hosts net.lookup may resolve; supports "*.example.com" and "*" (default: none)
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+
+	// DNS resolution timeout (default: 2000)
+	LookupTimeoutMs int `mapstructure:"lookup_timeout_ms"`
+
+	// Private, loopback and link-local ranges net.lookup may return (default: none)
+	AllowedCIDRs []string `mapstructure:"allowed_cidrs"`
+}
+
+// CacheConfig bounds the in-process cache binding
+type CacheConfig struct {
+	// Entries kept before the least recently used ones are evicted (default: 10000)
+	MaxEntries int `mapstructure:"max_entries"`
+
+	// Largest JSON-encoded value cache.set accepts (default: 65536)
+	MaxValueBytes int `mapstructure:"max_value_bytes"`
+
+	// TTL of entries set without ttlMs (default: 60000)
+	DefaultTTLMs int `mapstructure:"default_ttl_ms"`
+
+	// Key namespacing: script prefixes keys with the name of the running script, shared leaves them as passed
+	// (default: script); the cache_prefix of a script's environment overlay takes precedence
+	Namespace string `mapstructure:"namespace"`
+
+	// Name of a kv plugin storage keeping the values instead of process memory (the key of its section under kv)
+	Storage string `mapstructure:"storage"`
+
+	// Prefix of the keys in the kv storage, before the namespace (default: "js:cache:")
+	Prefix string `mapstructure:"prefix"`
+}
+
+// RedactionConfig lists what is scrubbed from script data written to logs, audit records and error reports
+type RedactionConfig struct {
+	// Object keys whose values are replaced as a whole, case-insensitive (e.g. password, card_number)
+	Fields []string `mapstructure:"fields"`
+
+	// Regular expressions whose matches are replaced in strings and code
+	Patterns []string `mapstructure:"patterns"`
+}
+
+// FetchConfig restricts and sizes the HTTP client behind the fetch binding
+type FetchConfig struct {
+	// Hosts fetch may request; supports "*.example.com" and "*" (default: none)
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+
+	// Private, loopback and link-local ranges fetch may connect to anyway (default: none)
+	AllowedCIDRs []string `mapstructure:"allowed_cidrs"`
+
+	// URL schemes fetch accepts: http, https (default: both)
+	AllowedSchemes []string `mapstructure:"allowed_schemes"`
+
+	// Redirect policy: follow, same_host or none (default: follow)
+	Redirects string `mapstructure:"redirects"`
+
+	// Request timeout when the script sets none, also bounds dialing (default: 10000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Largest response body read (default: 1048576), also the longest line readLine returns from a streamed body
+	MaxResponseBytes int `mapstructure:"max_response_bytes"`
+
+	// Largest body of a response fetched with {stream: true} (default: 104857600)
+	MaxStreamBytes int `mapstructure:"max_stream_bytes"`
+
+	// Largest chunk body.read() returns from a streamed body, at least 64 (default: 65536)
+	StreamChunkBytes int `mapstructure:"stream_chunk_bytes"`
+
+	// Redirects followed before the redirect response is returned (default: 5)
+	MaxRedirects int `mapstructure:"max_redirects"`
+
+	// Connections per host, including those in use; requests beyond it wait for one (default: 16)
+	MaxConnsPerHost int `mapstructure:"max_conns_per_host"`
+
+	// Idle connections kept for reuse, in total and per host (default: 64 and 8)
+	MaxIdleConns        int `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"`
+
+	// Time an idle connection is kept open (default: 90000)
+	IdleConnTimeoutMs int `mapstructure:"idle_conn_timeout_ms"`
+
+	// Named client profiles scripts select with fetch(url, {client: name})
+	Clients map[string]*FetchClientConfig `mapstructure:"clients"`
+}
+
+// FetchClientConfig is an HTTP client profile with its own TLS identity, trust and proxy
+// Profiles share the limits of js.fetch but keep separate connection pools
+type FetchClientConfig struct {
+	// Client certificate and key presented for mTLS (PEM)
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+
+	// CA bundle trusted instead of the system roots (PEM)
+	CAFile string `mapstructure:"ca_file"`
+
+	// HTTP(S) proxy requests are sent through, e.g. http://proxy.internal:3128
+	Proxy string `mapstructure:"proxy"`
+
+	// Ranges this profile may connect to in addition to js.fetch.allowed_cidrs
+	AllowedCIDRs []string `mapstructure:"allowed_cidrs"`
+
+	// Concurrency group every request holds a slot of until its response is read (default: none)
+	ConcurrencyGroup string `mapstructure:"concurrency_group"`
+}
+
+// SigningConfig holds the secrets scripts sign requests with, referenced by name
+type SigningConfig struct {
+	// AWS credentials for signing.sigv4 (profile name -> credentials)
+	AWS map[string]*AWSCredentials `mapstructure:"aws"`
+
+	// Keys for signing.hmac and signing.hmacRequest (name -> key)
+	HMACKeys map[string]string `mapstructure:"hmac_keys"`
+}
+
+// MaskConfig holds the data masking policies scripts apply by name, so PII scrubbing follows one central policy
+type MaskConfig struct {
+	// Key of the HMAC-SHA256 behind the hash strategy, so hashes of guessable values such as emails cannot be
+	// reversed by hashing candidates (required when a rule hashes)
+	HashKey string `mapstructure:"hash_key"`
+
+	// Rules of each policy (name -> rules), applied in order
+	Policies map[string][]*MaskRule `mapstructure:"policies"`
+}
+
+// MaskRule masks the values one JSONPath expression matches
+type MaskRule struct {
+	// JSONPath of the masked values, e.g. $.customer.email or $..card_number
+	Path string `mapstructure:"path"`
+
+	// Strategy: hash, truncate or null
+	Strategy string `mapstructure:"strategy"`
+
+	// Characters truncate keeps (default: 4)
+	Keep int `mapstructure:"keep"`
+
+	// Keep the last characters instead of the first, e.g. of card numbers
+	FromEnd bool `mapstructure:"from_end"`
+}
+
+// AWSCredentials sign requests to one AWS service in one region
+type AWSCredentials struct {
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+
+	// Set for temporary credentials
+	SessionToken string `mapstructure:"session_token"`
+
+	// e.g. eu-west-1 and execute-api
+	Region  string `mapstructure:"region"`
+	Service string `mapstructure:"service"`
+}
+
+// OAuthConfig lists the identity providers oauth.token obtains client-credentials tokens from
+type OAuthConfig struct {
+	// Providers by name
+	Providers map[string]*OAuthProviderConfig `mapstructure:"providers"`
+
+	// Tokens expiring within this margin are refreshed before use (default: 60000)
+	RefreshMarginMs int `mapstructure:"refresh_margin_ms"`
+}
+
+// OAuthProviderConfig is a client registered with an identity provider
+type OAuthProviderConfig struct {
+	// Token endpoint, e.g. https://auth.example.com/oauth2/token
+	TokenURL string `mapstructure:"token_url"`
+
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+
+	// How the client authenticates: basic (Authorization header) or post (form body) (default: basic)
+	AuthMethod string `mapstructure:"auth_method"`
+
+	// Scopes requested, and the audience for providers that require one
+	Scopes   []string `mapstructure:"scopes"`
+	Audience string   `mapstructure:"audience"`
+
+	// fetch client profile used for the token endpoint (default: the default fetch client)
+	Client string `mapstructure:"client"`
+}
+
+// MailConfig selects how mail.send delivers messages
+type MailConfig struct {
+	// smtp, or log to only log messages during development (default: smtp)
+	Driver string `mapstructure:"driver"`
+
+	// Sender of every message, e.g. "Shop <noreply@example.com>"
+	From string `mapstructure:"from"`
+
+	// Server of the smtp driver
+	SMTP *SMTPConfig `mapstructure:"smtp"`
+
+	// Time to deliver a message to the server (default: 10000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Messages per minute across all executions, 0 for no limit (default: 60)
+	MaxPerMinute int `mapstructure:"max_per_minute"`
+
+	// Recipients per message, including cc and bcc (default: 50)
+	MaxRecipients int `mapstructure:"max_recipients"`
+
+	// Directory of <name>.subject.tmpl, <name>.text.tmpl and <name>.html.tmpl templates (optional)
+	TemplatesDir string `mapstructure:"templates_dir"`
+}
+
+// SMTPConfig is the SMTP server of the mail binding
+type SMTPConfig struct {
+	Host string `mapstructure:"host"`
+
+	// default: 587
+	Port int `mapstructure:"port"`
+
+	// PLAIN authentication when set
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// starttls, tls (implicit, usually port 465) or none (default: starttls)
+	Security string `mapstructure:"security"`
+}
+
+// WebhookDispatchConfig sizes the dispatcher behind webhook.dispatch
+type WebhookDispatchConfig struct {
+	// Deliveries waiting for a worker; dispatch throws beyond it (default: 1024)
+	QueueSize int `mapstructure:"queue_size"`
+
+	// Deliveries sent concurrently (default: 4)
+	Workers int `mapstructure:"workers"`
+
+	// Attempts per delivery including the first one (default: 5)
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// Initial retry backoff, doubled after every attempt up to max_backoff_ms (default: 1000 and 60000)
+	BackoffMs    int `mapstructure:"backoff_ms"`
+	MaxBackoffMs int `mapstructure:"max_backoff_ms"`
+
+	// Timeout of one attempt (default: 10000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// DiscoveryConfig maps logical service names to their provider
+type DiscoveryConfig struct {
+	// Services by logical name
+	Services map[string]*ServiceConfig `mapstructure:"services"`
+
+	// Consul agent used by services with the consul provider
+	Consul *ConsulConfig `mapstructure:"consul"`
+
+	// Time resolved endpoints are reused (default: 10000)
+	CacheTTLMs int `mapstructure:"cache_ttl_ms"`
+
+	// Time a resolution may take (default: 2000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// ServiceConfig tells discovery where the endpoints of a service come from
+type ServiceConfig struct {
+	// static, dns or consul (default: static)
+	Provider string `mapstructure:"provider"`
+
+	// "host:port" endpoints of the static provider
+	Endpoints []string `mapstructure:"endpoints"`
+
+	// SRV record of the dns provider, e.g. _http._tcp.orders.internal
+	SRV string `mapstructure:"srv"`
+
+	// Consul service name (default: the logical name) and optional tag filter of the consul provider
+	Name string `mapstructure:"name"`
+	Tag  string `mapstructure:"tag"`
+}
+
+// ConsulConfig is the Consul agent of the discovery binding
+type ConsulConfig struct {
+	// HTTP API address (default: http://127.0.0.1:8500)
+	Addr string `mapstructure:"addr"`
+
+	// ACL token and datacenter (default: the agent's)
+	Token      string `mapstructure:"token"`
+	Datacenter string `mapstructure:"datacenter"`
+
+	// Request timeout (default: 2000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// GRPCClientConfig lists the gRPC servers of the grpc binding
+type GRPCClientConfig struct {
+	// Targets by name
+	Targets map[string]*GRPCTargetConfig `mapstructure:"targets"`
+}
+
+// GRPCTargetConfig is a gRPC server and where the descriptors of its methods come from
+type GRPCTargetConfig struct {
+	// Server address, e.g. orders.internal:9000 or dns:///orders.internal:9000
+	Addr string `mapstructure:"addr"`
+
+	// FileDescriptorSet produced by protoc --descriptor_set_out (--include_imports recommended)
+	DescriptorSet string `mapstructure:"descriptor_set"`
+
+	// Fetch descriptors from the server reflection API instead of a descriptor set
+	Reflection bool `mapstructure:"reflection"`
+
+	// TLS settings (plaintext when nil)
+	TLS *GRPCTLSConfig `mapstructure:"tls"`
+
+	// Default deadline of a call (default: 5000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Concurrency group every call holds a slot of (default: none)
+	ConcurrencyGroup string `mapstructure:"concurrency_group"`
+}
+
+// GRPCTLSConfig is the TLS configuration of a gRPC target
+type GRPCTLSConfig struct {
+	// CA bundle verifying the server (default: system roots) and name expected in its certificate
+	CAFile     string `mapstructure:"ca_file"`
+	ServerName string `mapstructure:"server_name"`
+
+	// Client certificate for mutual TLS
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// GraphQLConfig lists the endpoints of the graphql binding
+type GraphQLConfig struct {
+	// Endpoints by name
+	Endpoints map[string]*GraphQLEndpointConfig `mapstructure:"endpoints"`
+}
+
+// GraphQLEndpointConfig is a GraphQL server and the protocol extensions it supports
+type GraphQLEndpointConfig struct {
+	// Endpoint URL, e.g. https://api.example.com/graphql
+	URL string `mapstructure:"url"`
+
+	// Headers sent with every request, e.g. an API key
+	Headers map[string]string `mapstructure:"headers"`
+
+	// fetch client profile used for the endpoint (default: the default fetch client)
+	Client string `mapstructure:"client"`
+
+	// Send graphql.batch operations as one array request, at most max_batch_size per request (default: false and 10)
+	Batching     bool `mapstructure:"batching"`
+	MaxBatchSize int  `mapstructure:"max_batch_size"`
+
+	// Send sha256 hashes of queries first, as Automatic Persisted Queries servers expect (default: false)
+	PersistedQueries bool `mapstructure:"persisted_queries"`
+}
+
+// MQTTConfig is the broker mqtt.publish sends messages to
+type MQTTConfig struct {
+	// Broker address: tcp://host:1883, or ssl://host:8883 (mqtts:// also accepted) for TLS
+	Broker string `mapstructure:"broker"`
+
+	// Client identifier (default: roadrunner-js- followed by a random suffix)
+	ClientID string `mapstructure:"client_id"`
+
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// CA bundle verifying TLS brokers (default: system roots)
+	CAFile string `mapstructure:"ca_file"`
+
+	// Keepalive interval negotiated with the broker in seconds, 0 to disable (default: 30)
+	KeepAliveS int `mapstructure:"keepalive_s"`
+
+	// Timeout of connecting and of every packet exchange (default: 5000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Topic filters scripts may publish to, with the + and # wildcards (default: all topics)
+	AllowedTopics []string `mapstructure:"allowed_topics"`
+
+	// Largest payload accepted from scripts (default: 262144)
+	MaxPayloadBytes int `mapstructure:"max_payload_bytes"`
+}
+
+// PrometheusConfig is the server prom.query and prom.queryRange query
+type PrometheusConfig struct {
+	// Base URL of the HTTP API, e.g. http://prometheus:9090 (Thanos, Mimir and VictoriaMetrics URLs work too)
+	URL string `mapstructure:"url"`
+
+	// Headers sent with every query, e.g. Authorization or X-Scope-OrgID
+	Headers map[string]string `mapstructure:"headers"`
+
+	// fetch client profile used for the server (default: the default fetch client)
+	Client string `mapstructure:"client"`
+
+	// Query timeout (default: 10000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// SnowflakeConfig identifies this node in the Snowflake IDs it generates; it must match the layout of the services
+// sharing the IDs: 41 bits of milliseconds since the epoch, 10 bits of node ID and a 12-bit sequence
+type SnowflakeConfig struct {
+	// ID of this node, unique among everything generating IDs of the same epoch (0-1023)
+	NodeID int `mapstructure:"node_id"`
+
+	// Epoch of the timestamps, in Unix milliseconds (default: 1288834974657, the Twitter epoch)
+	EpochMs int64 `mapstructure:"epoch_ms"`
+}
+
+// MoneyConfig selects where money.convert gets its exchange rates
+type MoneyConfig struct {
+	// file (rates read once at startup) or http (rates fetched from a provider and cached) (default: file)
+	Source string `mapstructure:"source"`
+
+	// Currency the rates are quoted against: each rate is the amount of a currency one unit of base buys
+	// (default: USD)
+	Base string `mapstructure:"base"`
+
+	// JSON file of the file source, an object of rates by currency code, e.g. {"EUR": 0.92, "GBP": "0.79"}
+	File string `mapstructure:"file"`
+
+	// Provider of the http source
+	HTTP *MoneyHTTPConfig `mapstructure:"http"`
+}
+
+// MoneyHTTPConfig configures the rate provider of the http money source
+type MoneyHTTPConfig struct {
+	// URL returning the rates as JSON, e.g. https://api.frankfurter.app/latest?from=USD
+	URL string `mapstructure:"url"`
+
+	// Dot-separated path of the rates object in the response (default: rates)
+	RatesPath string `mapstructure:"rates_path"`
+
+	// Headers sent with every request, e.g. an API key
+	Headers map[string]string `mapstructure:"headers"`
+
+	// fetch client profile used for the provider (default: the default fetch client)
+	Client string `mapstructure:"client"`
+
+	// How long fetched rates are used before they are fetched again (default: 3600000)
+	TTLMs int `mapstructure:"ttl_ms"`
+
+	// Request timeout (default: 10000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// SchedulesConfig configures scripts run on cron schedules
+type SchedulesConfig struct {
+	// Schedules by name; the name labels logs, metrics and the recorded last run
+	Jobs map[string]*ScheduleConfig `mapstructure:"jobs"`
+
+	// IANA time zone cron expressions are evaluated in (default: Local)
+	Timezone string `mapstructure:"timezone"`
+
+	// Where the last run of every schedule is recorded, so restarts catch up instead of skipping or repeating runs
+	State *ScheduleStateConfig `mapstructure:"state"`
+}
+
+// ScheduleConfig is a registered script and when it runs
+type ScheduleConfig struct {
+	// Registered script name (requires js.scripts)
+	Script string `mapstructure:"script"`
+
+	// Five-field cron expression or descriptor, e.g. "*/15 * * * *" or "@daily"
+	Cron string `mapstructure:"cron"`
+
+	// Runs missed while RoadRunner was down: skip, once (a single run) or all (every run, oldest first) (default: skip)
+	CatchUp string `mapstructure:"catch_up"`
+
+	// Most missed runs made by the all policy; older ones are skipped (default: 100)
+	MaxCatchUp int `mapstructure:"max_catch_up"`
+
+	// Execution timeout (default: js.default_timeout_ms)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Execution tag, one of js.tags (default: none)
+	Tag string `mapstructure:"tag"`
+
+	// IANA time zone of the expression, windows and blackouts (default: schedules.timezone)
+	Timezone string `mapstructure:"timezone"`
+
+	// Times runs are allowed in; slots outside every window are skipped (default: none, runs are always allowed)
+	Windows []*ScheduleWindowConfig `mapstructure:"windows"`
+
+	// Times runs are not allowed in, e.g. maintenance or holidays; they take precedence over windows
+	Blackouts []*ScheduleBlackoutConfig `mapstructure:"blackouts"`
+}
+
+// ScheduleWindowConfig is a recurring daily time range
+type ScheduleWindowConfig struct {
+	// Days of the week, as names, numbers or ranges such as mon-fri (default: every day)
+	Days []string `mapstructure:"days"`
+
+	// Start and end as HH:MM; the end is excluded, and an end before the start continues on the next day
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// ScheduleBlackoutConfig is a recurring daily range (days, from, to) or a one-off period (start, end)
+type ScheduleBlackoutConfig struct {
+	ScheduleWindowConfig `mapstructure:",squash"`
+
+	// Start and end of a one-off period, as "2006-01-02 15:04" in the schedule's time zone or RFC 3339
+	Start string `mapstructure:"start"`
+	End   string `mapstructure:"end"`
+}
+
+// CostsConfig configures execution cost accounting per caller
+type CostsConfig struct {
+	// Daily budgets by caller; callers without one get default_budget
+	Budgets map[string]*CostBudgetConfig `mapstructure:"budgets"`
+
+	// Budget of callers not listed in budgets, including requests without a caller (default: unlimited)
+	DefaultBudget *CostBudgetConfig `mapstructure:"default_budget"`
+
+	// IANA time zone whose midnight starts a new budget day (default: UTC)
+	Timezone string `mapstructure:"timezone"`
+
+	// Callers tracked individually; further callers are accounted together as "(other)" (default: 1000)
+	MaxCallers int `mapstructure:"max_callers"`
+}
+
+// CostBudgetConfig caps what a caller may use per day; zero fields are unlimited
+// Executions are rejected once a cap is reached, an execution in progress is not interrupted
+type CostBudgetConfig struct {
+	Executions int64 `mapstructure:"executions"`
+	CPUMs      int64 `mapstructure:"cpu_ms"`
+	WallMs     int64 `mapstructure:"wall_ms"`
+	BindingMs  int64 `mapstructure:"binding_ms"`
+}
+
+// ScheduleStateConfig selects where last runs are recorded
+type ScheduleStateConfig struct {
+	// memory (nothing is caught up after a restart), file or kv (shared by instances, each slot runs once)
+	// (default: file)
+	Driver string `mapstructure:"driver"`
+
+	// State file of the file driver (default: js-schedules.json)
+	Path string `mapstructure:"path"`
+
+	// Name of the kv plugin storage for the kv driver (the key of its section under kv)
+	Storage string `mapstructure:"storage"`
+
+	// Prefix of the keys of the kv driver (default: "js:schedules:")
+	Prefix string `mapstructure:"prefix"`
+}
+
+// RateLimitConfig selects where ratelimit.allow keeps its counters
+type RateLimitConfig struct {
+	// memory (limits apply per RoadRunner instance) or redis (limits are shared) (default: memory)
+	Driver string `mapstructure:"driver"`
+
+	// Keys the memory driver tracks at once; new keys are refused beyond it (default: 100000)
+	MaxKeys int `mapstructure:"max_keys"`
+
+	// Redis server used by the redis driver
+	Redis *RateLimitRedisConfig `mapstructure:"redis"`
+}
+
+// RateLimitRedisConfig configures the Redis connection of the ratelimit binding
+type RateLimitRedisConfig struct {
+	// Server address (default: 127.0.0.1:6379)
+	Addr string `mapstructure:"addr"`
+
+	// AUTH password (default: none)
+	Password string `mapstructure:"password"`
+
+	// Database number (default: 0)
+	DB int `mapstructure:"db"`
+
+	// Prefix of the counter keys (default: "js:ratelimit:")
+	Prefix string `mapstructure:"prefix"`
+
+	// Dial and command timeout (default: 1000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Maximum connections to the server (default: 4)
+	PoolSize int `mapstructure:"pool_size"`
+}
+
+// I18nConfig configures translation catalogs
+type I18nConfig struct {
+	// Directory containing one JSON catalog per locale (en.json, de-AT.json)
+	Dir string `mapstructure:"dir"`
+
+	// Locale used when a message is missing in the requested one (default: en)
+	DefaultLocale string `mapstructure:"default_locale"`
+
+	// How often the directory is checked for changed catalogs (default: 5000)
+	ReloadIntervalMs int `mapstructure:"reload_interval_ms"`
+}
+
+// ScriptsConfig configures the script registry
+type ScriptsConfig struct {
+	// Directory containing name.js files and optional name.manifest.json files
+	Dir string `mapstructure:"dir"`
+
+	// Maximum concurrent executions per script name (unlisted scripts are only bound by the pool)
+	MaxConcurrency map[string]int `mapstructure:"max_concurrency"`
+
+	// Concurrency groups executions of a script hold a slot of while they run (script name -> group names)
+	Groups map[string][]string `mapstructure:"groups"`
+
+	// Environment overlays merged over the pool defaults for executions of a script (script name -> overlay)
+	Environments map[string]*ScriptEnvironmentConfig `mapstructure:"environments"`
+
+	// Jobs pipelines the output of a script is pushed to (script name -> sink)
+	Sinks map[string]*ScriptSinkConfig `mapstructure:"sinks"`
+
+	// Retry policies of scripts, replacing js.retry for requests without their own policy (script name -> policy)
+	Retry map[string]*RetryPolicy `mapstructure:"retry"`
+
+	// Maximum number of follow-up scripts run in a row through on_success/on_failure (default: 10)
+	MaxChainDepth int `mapstructure:"max_chain_depth"`
+
+	// Self-tests run on startup (disabled when nil)
+	SelfTest *SelfTestConfig `mapstructure:"self_test"`
+}
+
+// ScriptSinkConfig pushes what successful executions of a script produce to a Jobs pipeline (requires the jobs plugin)
+type ScriptSinkConfig struct {
+	// Jobs pipeline the jobs are pushed to
+	Pipeline string `mapstructure:"pipeline"`
+
+	// result (one job with the execution result) or items (one job per emit(record) call) (default: result)
+	Mode string `mapstructure:"mode"`
+
+	// Job name consumers dispatch on (default: the script name)
+	Job string `mapstructure:"job"`
+
+	// Priority of the jobs (default: 10)
+	Priority int64 `mapstructure:"priority"`
+
+	// Delay before the jobs can be consumed in seconds (default: 0)
+	Delay int64 `mapstructure:"delay"`
+
+	// Headers added to the jobs
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Maximum emit(record) calls per execution, replacing js.max_records for the script (default: 10000)
+	MaxItems int `mapstructure:"max_items"`
+}
+
+// ScriptEnvironmentConfig is the environment overlay of a registered script
+type ScriptEnvironmentConfig struct {
+	// Read-only globals defined for the script's executions in addition to js.globals
+	Globals map[string]interface{} `mapstructure:"globals"`
+
+	// Hosts fetch and webhook.dispatch may request, replacing fetch.allowed_hosts (default: fetch.allowed_hosts)
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+
+	// Prefix added to the keys the script reads and writes with the cache binding (default: none, shared keys)
+	CachePrefix string `mapstructure:"cache_prefix"`
+}
+
+// SelfTestConfig configures the startup self-test of registered scripts
+type SelfTestConfig struct {
+	// Global function a script declares as its self-test (default: __test__)
+	Function string `mapstructure:"function"`
+
+	// Timeout per script in milliseconds (default: 5000)
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// UntrustedPoolConfig configures the VM pool that runs ad-hoc code
+type UntrustedPoolConfig struct {
+	// Number of VMs (default: 2)
+	PoolSize int `mapstructure:"pool_size"`
+
+	// Timeout when a request does not set one (default: 5000)
+	DefaultTimeout int `mapstructure:"default_timeout_ms"`
+
+	// Ceiling for requested timeouts (default: default_timeout_ms)
+	MaxTimeout int `mapstructure:"max_timeout_ms"`
+
+	// Global objects available to ad-hoc code (default: bindings without side effects outside the execution)
+	Bindings []string `mapstructure:"bindings"`
+}
+
+// untrustedBindings are the default bindings of the untrusted pool
+var untrustedBindings = []string{"log", "progress", "ua", "intl", "decimal", "strings", "ndjson", "jsonpath", "jmespath", "jsondiff", "jsonpatch", "faker", "qrcode", "validate", "id", "semver", "ref", "i18n", "template", "helpers", "require", "ctx", "control", "emit"}
+
+// ProfilingConfig configures hot-spot sampling of executions
+type ProfilingConfig struct {
+	// Sampling interval in microseconds (default: 1000)
+	IntervalUs int `mapstructure:"interval_us"`
+
+	// Number of hot spots returned (default: 10)
+	Top int `mapstructure:"top"`
+}
+
+// HistogramBucketsConfig sets the upper bounds of histogram buckets, in increasing order
+type HistogramBucketsConfig struct {
+	// js_execution_duration_seconds and js_run_duration_seconds
+	ExecutionDuration []float64 `mapstructure:"execution_duration_seconds"`
+
+	// js_code_size_bytes
+	CodeSize []float64 `mapstructure:"code_size_bytes"`
+}
+
+// Default histogram buckets
+var (
+	defaultDurationBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+	defaultCodeSizeBuckets = []float64{100, 500, 1000, 5000, 10000, 50000, 100000, 500000}
+)
+
+// TracingConfig configures the execution tracing ring
+type TracingConfig struct {
+	// Number of recent executions kept (default: 1000)
+	Capacity int `mapstructure:"capacity"`
+}
+
+// LazyPoolConfig configures on-demand VM construction, which applies to the untrusted pool as well
+type LazyPoolConfig struct {
+	// VMs built at startup; the others are built by executions finding no idle VM, up to the pool size (default: 0)
+	Initial int `mapstructure:"initial"`
+}
+
+// ResultStoreConfig configures the store of results returned by handle
+type ResultStoreConfig struct {
+	// Results whose JSON encoding is larger are stored (default: 1048576)
+	ThresholdBytes int `mapstructure:"threshold_bytes"`
+
+	// Time a result is kept unless released (default: 300000)
+	TTLMs int `mapstructure:"ttl_ms"`
+
+	// Total size of the stored results; the results closest to expiry are dropped to make room (default: 268435456)
+	MaxBytes int64 `mapstructure:"max_bytes"`
+}
+
+// DedupeConfig configures the deduplication of executions carrying a dedupe_key
+// The claim on a key is a lock of the RR lock plugin when available (process-local otherwise), results are kept in a
+// storage of the RR kv plugin
+type DedupeConfig struct {
+	// Name of the kv plugin storage holding the shared results (the key of its section under kv)
+	Storage string `mapstructure:"storage"`
+
+	// Prefix of the lock and storage keys (default: "js:dedupe:")
+	Prefix string `mapstructure:"prefix"`
+
+	// Time an execution holds the claim on its key; waiting requests take over after it (default: default_timeout_ms)
+	LockTTLMs int `mapstructure:"lock_ttl_ms"`
+
+	// Time the result of an execution is shared (default: 60000)
+	ResultTTLMs int `mapstructure:"result_ttl_ms"`
+
+	// Interval waiting requests check for the result, jittered by +/-50% (default: 50)
+	PollIntervalMs int `mapstructure:"poll_interval_ms"`
+}
+
+// ProgramCacheConfig configures the cache of compiled ad-hoc code
+type ProgramCacheConfig struct {
+	// Number of programs kept, the least recently used are evicted (default: 1000)
+	MaxEntries int `mapstructure:"max_entries"`
+
+	// File the cached code is written to on shutdown and compiled from at startup (default: none, not kept)
+	Path string `mapstructure:"path"`
+}
+
+// LintConfig configures the rule set of the Lint RPC
+type LintConfig struct {
+	// Enabled rules (default: all)
+	Rules []string `mapstructure:"rules"`
+
+	// Globals scripts must not reference (default: eval)
+	DisallowedGlobals []string `mapstructure:"disallowed_globals"`
+
+	// Maximum cyclomatic complexity per function (default: 15)
+	MaxComplexity int `mapstructure:"max_complexity"`
 }
 
 // InitDefaults sets default configuration values
 func (c *Config) InitDefaults() {
+	c.initDefaults(PluginName)
+}
+
+// initDefaults sets default configuration values for the plugin instance of the given name, which prefixes the
+// default WebSocket path, schedule state file and Redis keys so instances do not share them
+func (c *Config) initDefaults(instance string) {
 	if c.PoolSize == 0 {
 		c.PoolSize = 4
 	}
@@ -21,6 +1081,470 @@ func (c *Config) InitDefaults() {
 	if c.DefaultTimeout == 0 {
 		c.DefaultTimeout = 30000
 	}
+	if c.TimeoutPolicy == "" {
+		c.TimeoutPolicy = timeoutPolicyClamp
+	}
+	if c.MaxRecords == 0 {
+		c.MaxRecords = 10000
+	}
+	if c.Bindings == nil {
+		c.Bindings = bindingNames
+	}
+	if c.WebSocket != nil && c.WebSocket.Path == "" {
+		c.WebSocket.Path = "/" + instance + "/ws"
+	}
+	if c.Retry == nil {
+		c.Retry = &RetryPolicy{}
+	}
+	if c.Retry.MaxAttempts == 0 {
+		c.Retry.MaxAttempts = 1
+	}
+	if len(c.Retry.RetryOn) == 0 {
+		c.Retry.RetryOn = []string{"error"}
+	}
+	if c.Profiling != nil {
+		if c.Profiling.IntervalUs == 0 {
+			c.Profiling.IntervalUs = 1000
+		}
+		if c.Profiling.Top == 0 {
+			c.Profiling.Top = 10
+		}
+	}
+	if c.HistogramBuckets == nil {
+		c.HistogramBuckets = &HistogramBucketsConfig{}
+	}
+	if len(c.HistogramBuckets.ExecutionDuration) == 0 {
+		c.HistogramBuckets.ExecutionDuration = defaultDurationBuckets
+	}
+	if len(c.HistogramBuckets.CodeSize) == 0 {
+		c.HistogramBuckets.CodeSize = defaultCodeSizeBuckets
+	}
+	if r := c.Results; r != nil {
+		if r.ThresholdBytes == 0 {
+			r.ThresholdBytes = 1 << 20
+		}
+		if r.TTLMs == 0 {
+			r.TTLMs = 300000
+		}
+		if r.MaxBytes == 0 {
+			r.MaxBytes = 256 << 20
+		}
+	}
+	if c.ProgramCache != nil && c.ProgramCache.MaxEntries == 0 {
+		c.ProgramCache.MaxEntries = 1000
+	}
+	if d := c.Dedupe; d != nil {
+		if d.Prefix == "" {
+			d.Prefix = instance + ":dedupe:"
+		}
+		if d.LockTTLMs == 0 {
+			d.LockTTLMs = c.DefaultTimeout
+		}
+		if d.ResultTTLMs == 0 {
+			d.ResultTTLMs = 60000
+		}
+		if d.PollIntervalMs == 0 {
+			d.PollIntervalMs = 50
+		}
+	}
+	if c.Tracing != nil && c.Tracing.Capacity == 0 {
+		c.Tracing.Capacity = 1000
+	}
+	if c.Lint == nil {
+		c.Lint = &LintConfig{}
+	}
+	if c.Lint.Rules == nil {
+		c.Lint.Rules = lintRules
+	}
+	if c.Lint.DisallowedGlobals == nil {
+		c.Lint.DisallowedGlobals = []string{"eval"}
+	}
+	if c.Lint.MaxComplexity == 0 {
+		c.Lint.MaxComplexity = 15
+	}
+	if c.Net == nil {
+		c.Net = &NetConfig{}
+	}
+	if c.Net.LookupTimeoutMs == 0 {
+		c.Net.LookupTimeoutMs = 2000
+	}
+	if c.Cache == nil {
+		c.Cache = &CacheConfig{}
+	}
+	if c.Cache.MaxEntries == 0 {
+		c.Cache.MaxEntries = 10000
+	}
+	if c.Cache.MaxValueBytes == 0 {
+		c.Cache.MaxValueBytes = 65536
+	}
+	if c.Cache.DefaultTTLMs == 0 {
+		c.Cache.DefaultTTLMs = 60000
+	}
+	if c.Cache.Namespace == "" {
+		c.Cache.Namespace = cacheNamespaceScript
+	}
+	if c.Cache.Prefix == "" {
+		c.Cache.Prefix = instance + ":cache:"
+	}
+	if c.Fetch == nil {
+		c.Fetch = &FetchConfig{}
+	}
+	if c.Fetch.TimeoutMs == 0 {
+		c.Fetch.TimeoutMs = 10000
+	}
+	if c.Fetch.MaxResponseBytes == 0 {
+		c.Fetch.MaxResponseBytes = 1 << 20
+	}
+	if c.Fetch.MaxStreamBytes == 0 {
+		c.Fetch.MaxStreamBytes = 100 << 20
+	}
+	if c.Fetch.StreamChunkBytes == 0 {
+		c.Fetch.StreamChunkBytes = 64 << 10
+	}
+	if c.Fetch.AllowedSchemes == nil {
+		c.Fetch.AllowedSchemes = []string{"http", "https"}
+	}
+	if c.Fetch.Redirects == "" {
+		c.Fetch.Redirects = redirectFollow
+	}
+	if c.Fetch.MaxRedirects == 0 {
+		c.Fetch.MaxRedirects = 5
+	}
+	if c.Fetch.MaxConnsPerHost == 0 {
+		c.Fetch.MaxConnsPerHost = 16
+	}
+	if c.Fetch.MaxIdleConns == 0 {
+		c.Fetch.MaxIdleConns = 64
+	}
+	if c.Fetch.MaxIdleConnsPerHost == 0 {
+		c.Fetch.MaxIdleConnsPerHost = 8
+	}
+	if c.Fetch.IdleConnTimeoutMs == 0 {
+		c.Fetch.IdleConnTimeoutMs = 90000
+	}
+	if c.RateLimit == nil {
+		c.RateLimit = &RateLimitConfig{}
+	}
+	if c.RateLimit.Driver == "" {
+		c.RateLimit.Driver = rateLimitMemory
+	}
+	if c.RateLimit.MaxKeys == 0 {
+		c.RateLimit.MaxKeys = 100000
+	}
+	if c.RateLimit.Driver == rateLimitRedis {
+		if c.RateLimit.Redis == nil {
+			c.RateLimit.Redis = &RateLimitRedisConfig{}
+		}
+		r := c.RateLimit.Redis
+		if r.Addr == "" {
+			r.Addr = "127.0.0.1:6379"
+		}
+		if r.Prefix == "" {
+			r.Prefix = instance + ":ratelimit:"
+		}
+		if r.TimeoutMs == 0 {
+			r.TimeoutMs = 1000
+		}
+		if r.PoolSize == 0 {
+			r.PoolSize = 4
+		}
+	}
+	if u := c.Untrusted; u != nil {
+		if u.PoolSize == 0 {
+			u.PoolSize = 2
+		}
+		if u.DefaultTimeout == 0 {
+			u.DefaultTimeout = 5000
+		}
+		if u.MaxTimeout == 0 {
+			u.MaxTimeout = u.DefaultTimeout
+		}
+		if u.Bindings == nil {
+			u.Bindings = untrustedBindings
+		}
+	}
+	if c.Scripts != nil {
+		if c.Scripts.MaxChainDepth == 0 {
+			c.Scripts.MaxChainDepth = 10
+		}
+		for script, sink := range c.Scripts.Sinks {
+			if sink == nil {
+				continue
+			}
+			if sink.Mode == "" {
+				sink.Mode = sinkResult
+			}
+			if sink.Job == "" {
+				sink.Job = script
+			}
+			if sink.Priority == 0 {
+				sink.Priority = 10
+			}
+			if sink.MaxItems == 0 {
+				sink.MaxItems = 10000
+			}
+		}
+		for _, policy := range c.Scripts.Retry {
+			if policy == nil {
+				continue
+			}
+			if policy.MaxAttempts == 0 {
+				policy.MaxAttempts = 1
+			}
+			if len(policy.RetryOn) == 0 {
+				policy.RetryOn = c.Retry.RetryOn
+			}
+		}
+	}
+	if c.Scripts != nil && c.Scripts.SelfTest != nil {
+		if c.Scripts.SelfTest.Function == "" {
+			c.Scripts.SelfTest.Function = "__test__"
+		}
+		if c.Scripts.SelfTest.TimeoutMs == 0 {
+			c.Scripts.SelfTest.TimeoutMs = 5000
+		}
+	}
+	if c.I18n != nil {
+		if c.I18n.DefaultLocale == "" {
+			c.I18n.DefaultLocale = "en"
+		}
+		if c.I18n.ReloadIntervalMs == 0 {
+			c.I18n.ReloadIntervalMs = 5000
+		}
+	}
+	if d := c.Discovery; d != nil {
+		if d.CacheTTLMs == 0 {
+			d.CacheTTLMs = 10000
+		}
+		if d.TimeoutMs == 0 {
+			d.TimeoutMs = 2000
+		}
+		for _, svc := range d.Services {
+			if svc != nil && svc.Provider == "" {
+				svc.Provider = discoveryStatic
+			}
+		}
+		if d.Consul != nil {
+			if d.Consul.Addr == "" {
+				d.Consul.Addr = "http://127.0.0.1:8500"
+			}
+			if d.Consul.TimeoutMs == 0 {
+				d.Consul.TimeoutMs = 2000
+			}
+		}
+	}
+	if p := c.Prometheus; p != nil && p.TimeoutMs == 0 {
+		p.TimeoutMs = 10000
+	}
+	if co := c.Costs; co != nil {
+		if co.Timezone == "" {
+			co.Timezone = "UTC"
+		}
+		if co.MaxCallers == 0 {
+			co.MaxCallers = 1000
+		}
+	}
+	if s := c.Schedules; s != nil {
+		if s.Timezone == "" {
+			s.Timezone = "Local"
+		}
+		for _, job := range s.Jobs {
+			if job == nil {
+				continue
+			}
+			if job.CatchUp == "" {
+				job.CatchUp = catchUpSkip
+			}
+			if job.MaxCatchUp == 0 {
+				job.MaxCatchUp = 100
+			}
+			if job.TimeoutMs == 0 {
+				job.TimeoutMs = c.DefaultTimeout
+			}
+			if job.Timezone == "" {
+				job.Timezone = s.Timezone
+			}
+		}
+		if s.State == nil {
+			s.State = &ScheduleStateConfig{}
+		}
+		if s.State.Driver == "" {
+			s.State.Driver = scheduleStateFile
+		}
+		if s.State.Path == "" {
+			s.State.Path = instance + "-schedules.json"
+		}
+		if s.State.Prefix == "" {
+			s.State.Prefix = instance + ":schedules:"
+		}
+	}
+	if m := c.MQTT; m != nil {
+		if m.ClientID == "" {
+			// The random part of a record ID keeps instances apart; brokers disconnect a client whose ID is reused
+			id := newRecordID()
+			m.ClientID = "roadrunner-js-" + id[len(id)-8:]
+		}
+		if m.KeepAliveS == 0 {
+			m.KeepAliveS = 30
+		}
+		if m.TimeoutMs == 0 {
+			m.TimeoutMs = 5000
+		}
+		if m.MaxPayloadBytes == 0 {
+			m.MaxPayloadBytes = 256 << 10
+		}
+	}
+	if g := c.GraphQL; g != nil {
+		for _, endpoint := range g.Endpoints {
+			if endpoint != nil && endpoint.MaxBatchSize == 0 {
+				endpoint.MaxBatchSize = 10
+			}
+		}
+	}
+	if g := c.GRPCClient; g != nil {
+		for _, target := range g.Targets {
+			if target != nil && target.TimeoutMs == 0 {
+				target.TimeoutMs = 5000
+			}
+		}
+	}
+	if w := c.Webhooks; w != nil {
+		if w.QueueSize == 0 {
+			w.QueueSize = 1024
+		}
+		if w.Workers == 0 {
+			w.Workers = 4
+		}
+		if w.MaxAttempts == 0 {
+			w.MaxAttempts = 5
+		}
+		if w.BackoffMs == 0 {
+			w.BackoffMs = 1000
+		}
+		if w.MaxBackoffMs == 0 {
+			w.MaxBackoffMs = 60000
+		}
+		if w.TimeoutMs == 0 {
+			w.TimeoutMs = 10000
+		}
+	}
+	if s := c.Snowflake; s != nil && s.EpochMs == 0 {
+		s.EpochMs = defaultSnowflakeEpochMs
+	}
+	if m := c.Money; m != nil {
+		if m.Source == "" {
+			m.Source = moneyFile
+		}
+		if m.Base == "" {
+			m.Base = "USD"
+		}
+		if h := m.HTTP; h != nil {
+			if h.RatesPath == "" {
+				h.RatesPath = "rates"
+			}
+			if h.TTLMs == 0 {
+				h.TTLMs = 3600000
+			}
+			if h.TimeoutMs == 0 {
+				h.TimeoutMs = 10000
+			}
+		}
+	}
+	if m := c.Mask; m != nil {
+		for _, rules := range m.Policies {
+			for _, rule := range rules {
+				if rule != nil && rule.Strategy == maskTruncate && rule.Keep == 0 {
+					rule.Keep = 4
+				}
+			}
+		}
+	}
+	if m := c.Mail; m != nil {
+		if m.Driver == "" {
+			m.Driver = mailSMTP
+		}
+		if m.TimeoutMs == 0 {
+			m.TimeoutMs = 10000
+		}
+		if m.MaxPerMinute == 0 {
+			m.MaxPerMinute = 60
+		}
+		if m.MaxRecipients == 0 {
+			m.MaxRecipients = 50
+		}
+		if m.SMTP != nil {
+			if m.SMTP.Port == 0 {
+				m.SMTP.Port = 587
+			}
+			if m.SMTP.Security == "" {
+				m.SMTP.Security = smtpStartTLS
+			}
+		}
+	}
+	if o := c.OAuth; o != nil {
+		if o.RefreshMarginMs == 0 {
+			o.RefreshMarginMs = 60000
+		}
+		for _, provider := range o.Providers {
+			if provider != nil && provider.AuthMethod == "" {
+				provider.AuthMethod = oauthAuthBasic
+			}
+		}
+	}
+	if f := c.Flags; f != nil {
+		if f.Provider == "" {
+			f.Provider = "file"
+		}
+		if f.Prefix == "" {
+			f.Prefix = "JS_FLAG_"
+		}
+		if f.CacheTTLMs == 0 {
+			f.CacheTTLMs = 30000
+		}
+		if f.TimeoutMs == 0 {
+			f.TimeoutMs = 5000
+		}
+	}
+	if c.Audit != nil && c.Audit.Capacity == 0 {
+		c.Audit.Capacity = 1000
+	}
+	if c.DeadLetter != nil && c.DeadLetter.Driver == "" {
+		c.DeadLetter.Driver = "file"
+	}
+	if c.DeadLetter != nil && c.DeadLetter.Prefix == "" {
+		c.DeadLetter.Prefix = instance + ":dead_letter:"
+	}
+	if w := c.OnFailureWebhook; w != nil {
+		if len(w.Statuses) == 0 {
+			w.Statuses = []string{"error", "timeout"}
+		}
+		if w.MaxAttempts == 0 {
+			w.MaxAttempts = 3
+		}
+		if w.BackoffMs == 0 {
+			w.BackoffMs = 500
+		}
+		if w.TimeoutMs == 0 {
+			w.TimeoutMs = 5000
+		}
+	}
+	if c.Sentry != nil && c.Sentry.SampleRate == 0 {
+		c.Sentry.SampleRate = 1
+	}
+	if c.OTLP != nil {
+		if c.OTLP.ServiceName == "" {
+			c.OTLP.ServiceName = "roadrunner"
+		}
+		if c.OTLP.BatchSize == 0 {
+			c.OTLP.BatchSize = 512
+		}
+		if c.OTLP.FlushIntervalMs == 0 {
+			c.OTLP.FlushIntervalMs = 1000
+		}
+		if c.OTLP.TimeoutMs == 0 {
+			c.OTLP.TimeoutMs = 5000
+		}
+	}
 }
 
 // Validate ensures the configuration is valid
@@ -28,14 +1552,761 @@ func (c *Config) Validate() error {
 	if c.PoolSize < 1 {
 		return fmt.Errorf("pool_size must be at least 1, got %d", c.PoolSize)
 	}
+	if l := c.LazyPool; l != nil && (l.Initial < 0 || l.Initial > c.PoolSize) {
+		return fmt.Errorf("lazy_pool.initial must be between 0 and pool_size (%d), got %d", c.PoolSize, l.Initial)
+	}
 	if c.PoolSize > 100 {
 		return fmt.Errorf("pool_size cannot exceed 100, got %d", c.PoolSize)
 	}
 	if c.DefaultTimeout < 100 {
 		return fmt.Errorf("default_timeout_ms must be at least 100ms, got %d", c.DefaultTimeout)
 	}
+	if c.MaxTimeout != 0 && c.MaxTimeout < c.DefaultTimeout {
+		return fmt.Errorf("max_timeout_ms cannot be lower than default_timeout_ms, got %d", c.MaxTimeout)
+	}
+	if c.TimeoutPolicy != timeoutPolicyClamp && c.TimeoutPolicy != timeoutPolicyReject {
+		return fmt.Errorf("timeout_policy must be clamp or reject, got %q", c.TimeoutPolicy)
+	}
+	if c.MaxCodeSize < 0 {
+		return fmt.Errorf("max_code_size_bytes cannot be negative, got %d", c.MaxCodeSize)
+	}
+	if c.AbortGraceMs < 0 {
+		return fmt.Errorf("abort_grace_ms cannot be negative, got %d", c.AbortGraceMs)
+	}
+	if c.MaxRecords < 1 {
+		return fmt.Errorf("max_records must be at least 1, got %d", c.MaxRecords)
+	}
 	if c.MaxMemoryMB < 64 {
 		return fmt.Errorf("max_memory_mb must be at least 64MB, got %d", c.MaxMemoryMB)
 	}
+	if err := c.Retry.validate(); err != nil {
+		return fmt.Errorf("retry: %w", err)
+	}
+	for _, rule := range c.Lint.Rules {
+		if !slices.Contains(lintRules, rule) {
+			return fmt.Errorf("lint.rules: unknown rule %q", rule)
+		}
+	}
+	if c.Profiling != nil {
+		if c.Profiling.IntervalUs < 100 {
+			return fmt.Errorf("profiling.interval_us must be at least 100, got %d", c.Profiling.IntervalUs)
+		}
+		if c.Profiling.Top < 1 {
+			return fmt.Errorf("profiling.top must be at least 1, got %d", c.Profiling.Top)
+		}
+	}
+	if err := validateBuckets("histogram_buckets.execution_duration_seconds", c.HistogramBuckets.ExecutionDuration); err != nil {
+		return err
+	}
+	if err := validateBuckets("histogram_buckets.code_size_bytes", c.HistogramBuckets.CodeSize); err != nil {
+		return err
+	}
+	for i, threshold := range c.SLOThresholds {
+		if threshold < 1 || (i > 0 && threshold <= c.SLOThresholds[i-1]) {
+			return fmt.Errorf("slo_thresholds_ms must be positive and strictly increasing, got %v", c.SLOThresholds)
+		}
+	}
+	for i, tag := range c.Tags {
+		if tag == "" || len(tag) > maxLabelLength {
+			return fmt.Errorf("tags: tag %q must be non-empty and at most %d bytes", tag, maxLabelLength)
+		}
+		if slices.Contains(c.Tags[:i], tag) {
+			return fmt.Errorf("tags: duplicate tag %q", tag)
+		}
+	}
+	if err := c.validateConcurrencyGroups(); err != nil {
+		return err
+	}
+	if err := c.validateEnvironments(); err != nil {
+		return err
+	}
+	if r := c.Results; r != nil {
+		if r.ThresholdBytes < 1 || r.TTLMs < 1000 {
+			return fmt.Errorf("results.threshold_bytes must be positive and results.ttl_ms at least 1000")
+		}
+		if r.MaxBytes < int64(r.ThresholdBytes) {
+			return fmt.Errorf("results.max_bytes must be at least results.threshold_bytes (%d), got %d", r.ThresholdBytes, r.MaxBytes)
+		}
+	}
+	if c.ProgramCache != nil && c.ProgramCache.MaxEntries < 1 {
+		return fmt.Errorf("program_cache.max_entries must be at least 1, got %d", c.ProgramCache.MaxEntries)
+	}
+	if d := c.Dedupe; d != nil {
+		switch {
+		case d.Storage == "":
+			return fmt.Errorf("dedupe.storage is required")
+		case d.LockTTLMs < 1000 || d.ResultTTLMs < 1000:
+			return fmt.Errorf("dedupe.lock_ttl_ms and dedupe.result_ttl_ms must be at least 1000")
+		case d.PollIntervalMs < 1:
+			return fmt.Errorf("dedupe.poll_interval_ms must be positive, got %d", d.PollIntervalMs)
+		}
+	}
+	if c.Tracing != nil && c.Tracing.Capacity < 1 {
+		return fmt.Errorf("tracing.capacity must be at least 1, got %d", c.Tracing.Capacity)
+	}
+	if c.Lint.MaxComplexity < 1 {
+		return fmt.Errorf("lint.max_complexity must be at least 1, got %d", c.Lint.MaxComplexity)
+	}
+	if err := validateGlobals(c.Globals); err != nil {
+		return err
+	}
+	if err := validateLibraries(c.Libraries); err != nil {
+		return err
+	}
+	if f := c.Flags; f != nil {
+		switch {
+		case f.Provider == "file" && f.Path == "":
+			return fmt.Errorf("flags.path is required for the file provider")
+		case f.Provider == "http" && f.URL == "":
+			return fmt.Errorf("flags.url is required for the http provider")
+		case f.Provider != "file" && f.Provider != "env" && f.Provider != "http":
+			return fmt.Errorf("flags.provider must be file, env or http, got %q", f.Provider)
+		case f.CacheTTLMs < 0 || f.TimeoutMs < 1:
+			return fmt.Errorf("flags.cache_ttl_ms cannot be negative and flags.timeout_ms must be positive")
+		}
+	}
+	if c.Net.LookupTimeoutMs < 1 {
+		return fmt.Errorf("net.lookup_timeout_ms must be positive, got %d", c.Net.LookupTimeoutMs)
+	}
+	if c.Cache.MaxEntries < 1 || c.Cache.MaxValueBytes < 1 || c.Cache.DefaultTTLMs < 1 {
+		return fmt.Errorf("cache.max_entries, cache.max_value_bytes and cache.default_ttl_ms must be positive")
+	}
+	if n := c.Cache.Namespace; n != cacheNamespaceScript && n != cacheNamespaceShared {
+		return fmt.Errorf("cache.namespace must be %q or %q, got %q", cacheNamespaceScript, cacheNamespaceShared, n)
+	}
+	if f := c.Fetch; f.TimeoutMs < 1 || f.MaxResponseBytes < 1 || f.MaxRedirects < 0 || f.MaxConnsPerHost < 1 ||
+		f.MaxIdleConns < 1 || f.MaxIdleConnsPerHost < 1 || f.IdleConnTimeoutMs < 1 {
+		return fmt.Errorf("fetch: timeout, size, connection and idle limits must be positive")
+	}
+	if c.Fetch.MaxStreamBytes < 1 || c.Fetch.StreamChunkBytes < minStreamChunkBytes {
+		return fmt.Errorf("fetch.max_stream_bytes must be positive and fetch.stream_chunk_bytes at least %d", minStreamChunkBytes)
+	}
+	for _, scheme := range c.Fetch.AllowedSchemes {
+		if scheme != "http" && scheme != "https" {
+			return fmt.Errorf("fetch.allowed_schemes: unsupported scheme %q (http, https)", scheme)
+		}
+	}
+	switch c.Fetch.Redirects {
+	case redirectFollow, redirectSameHost, redirectNone:
+	default:
+		return fmt.Errorf("fetch.redirects must be %q, %q or %q, got %q", redirectFollow, redirectSameHost, redirectNone, c.Fetch.Redirects)
+	}
+	if _, err := parsePrefixes("fetch.allowed_cidrs", c.Fetch.AllowedCIDRs); err != nil {
+		return err
+	}
+	for name, client := range c.Fetch.Clients {
+		if err := validateFetchClient(name, client); err != nil {
+			return err
+		}
+	}
+	if _, err := parsePrefixes("net.allowed_cidrs", c.Net.AllowedCIDRs); err != nil {
+		return err
+	}
+	if d := c.Discovery; d != nil {
+		if err := d.validate(); err != nil {
+			return err
+		}
+	}
+	if g := c.GRPCClient; g != nil {
+		for name, target := range g.Targets {
+			if err := validateGRPCTarget(name, target); err != nil {
+				return err
+			}
+		}
+	}
+	if g := c.GraphQL; g != nil {
+		for name, endpoint := range g.Endpoints {
+			if err := c.validateGraphQLEndpoint(name, endpoint); err != nil {
+				return err
+			}
+		}
+	}
+	if m := c.MQTT; m != nil {
+		if err := m.validate(); err != nil {
+			return err
+		}
+	}
+	if p := c.Prometheus; p != nil {
+		promURL, err := url.Parse(p.URL)
+		if err != nil || (promURL.Scheme != "http" && promURL.Scheme != "https") || promURL.Host == "" {
+			return fmt.Errorf("prometheus.url: must be an http or https URL, got %q", p.URL)
+		}
+		if p.TimeoutMs < 1 {
+			return fmt.Errorf("prometheus.timeout_ms must be positive, got %d", p.TimeoutMs)
+		}
+		if _, ok := c.Fetch.Clients[p.Client]; p.Client != "" && !ok {
+			return fmt.Errorf("prometheus.client: unknown fetch client profile %q", p.Client)
+		}
+	}
+	if co := c.Costs; co != nil {
+		if _, err := time.LoadLocation(co.Timezone); err != nil {
+			return fmt.Errorf("costs.timezone: %w", err)
+		}
+		if co.MaxCallers < 1 {
+			return fmt.Errorf("costs.max_callers must be positive, got %d", co.MaxCallers)
+		}
+		budgets := map[string]*CostBudgetConfig{"default_budget": co.DefaultBudget}
+		for caller, budget := range co.Budgets {
+			budgets["budgets."+caller] = budget
+		}
+		for key, b := range budgets {
+			if b != nil && (b.Executions < 0 || b.CPUMs < 0 || b.WallMs < 0 || b.BindingMs < 0) {
+				return fmt.Errorf("costs.%s: limits cannot be negative", key)
+			}
+		}
+	}
+	if s := c.Schedules; s != nil {
+		if err := c.validateSchedules(s); err != nil {
+			return err
+		}
+	}
+	if len(c.Prewarm) > 0 && c.Scripts == nil {
+		return fmt.Errorf("prewarm requires js.scripts, prewarming runs registered scripts")
+	}
+	if w := c.Webhooks; w != nil {
+		if w.QueueSize < 1 || w.Workers < 1 || w.MaxAttempts < 1 || w.BackoffMs < 1 || w.TimeoutMs < 1 {
+			return fmt.Errorf("webhooks: queue_size, workers, max_attempts, backoff_ms and timeout_ms must be positive")
+		}
+		if w.MaxBackoffMs < w.BackoffMs {
+			return fmt.Errorf("webhooks.max_backoff_ms cannot be lower than webhooks.backoff_ms")
+		}
+	}
+	if m := c.Mail; m != nil {
+		if err := m.validate(); err != nil {
+			return err
+		}
+	}
+	if o := c.OAuth; o != nil {
+		if o.RefreshMarginMs < 0 {
+			return fmt.Errorf("oauth.refresh_margin_ms cannot be negative, got %d", o.RefreshMarginMs)
+		}
+		for name, provider := range o.Providers {
+			if err := c.validateOAuthProvider(name, provider); err != nil {
+				return err
+			}
+		}
+	}
+	if s := c.Signing; s != nil {
+		for name, creds := range s.AWS {
+			if creds == nil || creds.AccessKeyID == "" || creds.SecretAccessKey == "" || creds.Region == "" || creds.Service == "" {
+				return fmt.Errorf("signing.aws.%s: access_key_id, secret_access_key, region and service are required", name)
+			}
+		}
+		for name, key := range s.HMACKeys {
+			if key == "" {
+				return fmt.Errorf("signing.hmac_keys.%s: key is empty", name)
+			}
+		}
+	}
+	if s := c.Snowflake; s != nil {
+		if s.NodeID < 0 || s.NodeID > maxSnowflakeNode {
+			return fmt.Errorf("snowflake.node_id must be between 0 and %d, got %d", maxSnowflakeNode, s.NodeID)
+		}
+		if s.EpochMs < 0 || s.EpochMs > time.Now().UnixMilli() {
+			return fmt.Errorf("snowflake.epoch_ms must be a past Unix time in milliseconds, got %d", s.EpochMs)
+		}
+	}
+	if m := c.Mask; m != nil {
+		if err := m.validate(); err != nil {
+			return err
+		}
+	}
+	if m := c.Money; m != nil {
+		if err := c.validateMoney(m); err != nil {
+			return err
+		}
+	}
+	switch c.RateLimit.Driver {
+	case rateLimitMemory:
+		if c.RateLimit.MaxKeys < 1 {
+			return fmt.Errorf("ratelimit.max_keys must be positive, got %d", c.RateLimit.MaxKeys)
+		}
+	case rateLimitRedis:
+		if r := c.RateLimit.Redis; r.TimeoutMs < 1 || r.PoolSize < 1 || r.DB < 0 {
+			return fmt.Errorf("ratelimit.redis.timeout_ms and ratelimit.redis.pool_size must be positive and ratelimit.redis.db not negative")
+		}
+	default:
+		return fmt.Errorf("ratelimit.driver must be %q or %q, got %q", rateLimitMemory, rateLimitRedis, c.RateLimit.Driver)
+	}
+	if c.I18n != nil {
+		if c.I18n.Dir == "" {
+			return fmt.Errorf("i18n.dir is required when i18n is configured")
+		}
+		if c.I18n.ReloadIntervalMs < 100 {
+			return fmt.Errorf("i18n.reload_interval_ms must be at least 100ms, got %d", c.I18n.ReloadIntervalMs)
+		}
+	}
+	if c.Scripts != nil {
+		if c.Scripts.Dir == "" {
+			return fmt.Errorf("scripts.dir is required when scripts is configured")
+		}
+		for name, limit := range c.Scripts.MaxConcurrency {
+			if limit < 1 {
+				return fmt.Errorf("scripts.max_concurrency.%s must be at least 1, got %d", name, limit)
+			}
+		}
+		if c.Scripts.MaxChainDepth < 1 {
+			return fmt.Errorf("scripts.max_chain_depth must be at least 1, got %d", c.Scripts.MaxChainDepth)
+		}
+		for script, sink := range c.Scripts.Sinks {
+			key := "scripts.sinks." + script
+			switch {
+			case sink == nil || sink.Pipeline == "":
+				return fmt.Errorf("%s.pipeline is required", key)
+			case sink.Mode != sinkResult && sink.Mode != sinkItems:
+				return fmt.Errorf("%s.mode must be %q or %q, got %q", key, sinkResult, sinkItems, sink.Mode)
+			case sink.Priority < 1 || sink.Delay < 0 || sink.MaxItems < 1:
+				return fmt.Errorf("%s: priority and max_items must be positive and delay not negative", key)
+			}
+		}
+		for script, policy := range c.Scripts.Retry {
+			if policy == nil {
+				return fmt.Errorf("scripts.retry.%s: policy is empty", script)
+			}
+			if err := policy.validate(); err != nil {
+				return fmt.Errorf("scripts.retry.%s: %w", script, err)
+			}
+		}
+		if t := c.Scripts.SelfTest; t != nil {
+			if !globalNamePattern.MatchString(t.Function) {
+				return fmt.Errorf("scripts.self_test.function: %q is not a valid identifier", t.Function)
+			}
+			if t.TimeoutMs < 1 {
+				return fmt.Errorf("scripts.self_test.timeout_ms must be positive, got %d", t.TimeoutMs)
+			}
+		}
+	}
+	if u := c.Untrusted; u != nil {
+		if u.PoolSize < 1 || u.PoolSize > 100 {
+			return fmt.Errorf("untrusted.pool_size must be between 1 and 100, got %d", u.PoolSize)
+		}
+		if u.DefaultTimeout < 100 {
+			return fmt.Errorf("untrusted.default_timeout_ms must be at least 100ms, got %d", u.DefaultTimeout)
+		}
+		if u.MaxTimeout < u.DefaultTimeout {
+			return fmt.Errorf("untrusted.max_timeout_ms cannot be lower than untrusted.default_timeout_ms")
+		}
+		if err := validateBindings("untrusted.bindings", u.Bindings); err != nil {
+			return err
+		}
+	}
+	if err := validateBindings("bindings", c.Bindings); err != nil {
+		return err
+	}
+	if c.Audit != nil && c.Audit.Capacity < 1 {
+		return fmt.Errorf("audit.capacity must be at least 1, got %d", c.Audit.Capacity)
+	}
+	if c.DeadLetter != nil && c.DeadLetter.Driver == "file" && c.DeadLetter.Path == "" {
+		return fmt.Errorf("dead_letter.path is required for the file driver")
+	}
+	if c.DeadLetter != nil && c.DeadLetter.Driver == "kv" && c.DeadLetter.Storage == "" {
+		return fmt.Errorf("dead_letter.storage is required for the kv driver")
+	}
+	if w := c.OnFailureWebhook; w != nil {
+		if w.URL == "" {
+			return fmt.Errorf("on_failure_webhook.url is required when on_failure_webhook is configured")
+		}
+		if w.MaxAttempts < 1 || w.BackoffMs < 1 || w.TimeoutMs < 1 {
+			return fmt.Errorf("on_failure_webhook.max_attempts, backoff_ms and timeout_ms must be positive")
+		}
+		for _, status := range w.Statuses {
+			if status != "error" && status != "timeout" {
+				return fmt.Errorf("on_failure_webhook.statuses: unknown status %q", status)
+			}
+		}
+		if len(w.Scripts) > 0 && c.Scripts == nil {
+			return fmt.Errorf("on_failure_webhook.scripts requires js.scripts, it filters registered scripts")
+		}
+	}
+	if c.Sentry != nil {
+		if c.Sentry.DSN == "" {
+			return fmt.Errorf("sentry.dsn is required when sentry is configured")
+		}
+		if c.Sentry.SampleRate < 0 || c.Sentry.SampleRate > 1 {
+			return fmt.Errorf("sentry.sample_rate must be between 0 and 1, got %v", c.Sentry.SampleRate)
+		}
+	}
+	if c.OTLP != nil {
+		if c.OTLP.Endpoint == "" {
+			return fmt.Errorf("otlp.endpoint is required when otlp is configured")
+		}
+		if c.OTLP.BatchSize < 1 || c.OTLP.FlushIntervalMs < 1 || c.OTLP.TimeoutMs < 1 {
+			return fmt.Errorf("otlp.batch_size, otlp.flush_interval_ms and otlp.timeout_ms must be positive")
+		}
+	}
+	return nil
+}
+
+// validateBindings checks that a binding list only names known bindings, each once
+func validateBindings(key string, names []string) error {
+	for i, name := range names {
+		if !slices.Contains(bindingNames, name) {
+			return fmt.Errorf("%s: unknown binding %q", key, name)
+		}
+		if slices.Contains(names[:i], name) {
+			return fmt.Errorf("%s: duplicate binding %q", key, name)
+		}
+	}
+	return nil
+}
+
+// validateBuckets checks that bucket upper bounds are positive and strictly increasing
+func validateBuckets(key string, buckets []float64) error {
+	for i, bound := range buckets {
+		if bound <= 0 {
+			return fmt.Errorf("%s: bucket bounds must be positive, got %v", key, bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return fmt.Errorf("%s: bucket bounds must be strictly increasing, got %v after %v", key, bound, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// validateFetchClient checks a fetch client profile; certificate files are loaded when the plugin initializes
+func validateFetchClient(name string, client *FetchClientConfig) error {
+	key := "fetch.clients." + name
+	if client == nil {
+		return fmt.Errorf("%s: profile is empty", key)
+	}
+	if (client.CertFile == "") != (client.KeyFile == "") {
+		return fmt.Errorf("%s: cert_file and key_file must be set together", key)
+	}
+	if client.Proxy != "" {
+		proxy, err := url.Parse(client.Proxy)
+		if err != nil || (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Host == "" {
+			return fmt.Errorf("%s.proxy: must be an http or https URL, got %q", key, client.Proxy)
+		}
+	}
+	_, err := parsePrefixes(key+".allowed_cidrs", client.AllowedCIDRs)
+	return err
+}
+
+// validateOAuthProvider checks an identity provider of the oauth binding
+func (c *Config) validateOAuthProvider(name string, provider *OAuthProviderConfig) error {
+	key := "oauth.providers." + name
+	if provider == nil {
+		return fmt.Errorf("%s: provider is empty", key)
+	}
+	tokenURL, err := url.Parse(provider.TokenURL)
+	if err != nil || (tokenURL.Scheme != "http" && tokenURL.Scheme != "https") || tokenURL.Host == "" {
+		return fmt.Errorf("%s.token_url: must be an http or https URL, got %q", key, provider.TokenURL)
+	}
+	if provider.ClientID == "" || provider.ClientSecret == "" {
+		return fmt.Errorf("%s: client_id and client_secret are required", key)
+	}
+	if provider.AuthMethod != oauthAuthBasic && provider.AuthMethod != oauthAuthPost {
+		return fmt.Errorf("%s.auth_method must be %q or %q, got %q", key, oauthAuthBasic, oauthAuthPost, provider.AuthMethod)
+	}
+	if _, ok := c.Fetch.Clients[provider.Client]; provider.Client != "" && !ok {
+		return fmt.Errorf("%s.client: unknown fetch client profile %q", key, provider.Client)
+	}
+	return nil
+}
+
+// validateMoney checks the money configuration
+func (c *Config) validateMoney(m *MoneyConfig) error {
+	if !currencyCode.MatchString(m.Base) {
+		return fmt.Errorf("money.base must be a three-letter currency code, got %q", m.Base)
+	}
+	switch m.Source {
+	case moneyFile:
+		if m.File == "" {
+			return fmt.Errorf("money.file is required by the file source")
+		}
+	case moneyHTTP:
+		h := m.HTTP
+		if h == nil {
+			return fmt.Errorf("money.http is required by the http source")
+		}
+		providerURL, err := url.Parse(h.URL)
+		if err != nil || (providerURL.Scheme != "http" && providerURL.Scheme != "https") || providerURL.Host == "" {
+			return fmt.Errorf("money.http.url: must be an http or https URL, got %q", h.URL)
+		}
+		if h.TTLMs < 1000 {
+			return fmt.Errorf("money.http.ttl_ms must be at least 1000ms, got %d", h.TTLMs)
+		}
+		if h.TimeoutMs < 1 {
+			return fmt.Errorf("money.http.timeout_ms must be positive, got %d", h.TimeoutMs)
+		}
+		if _, ok := c.Fetch.Clients[h.Client]; h.Client != "" && !ok {
+			return fmt.Errorf("money.http.client: unknown fetch client profile %q", h.Client)
+		}
+	default:
+		return fmt.Errorf("money.source must be %q or %q, got %q", moneyFile, moneyHTTP, m.Source)
+	}
+	return nil
+}
+
+// validate checks the mask configuration
+func (m *MaskConfig) validate() error {
+	for name, rules := range m.Policies {
+		if len(rules) == 0 {
+			return fmt.Errorf("mask.policies.%s: at least one rule is required", name)
+		}
+		for i, rule := range rules {
+			if rule == nil || rule.Path == "" {
+				return fmt.Errorf("mask.policies.%s[%d]: path is required", name, i)
+			}
+			if _, err := compileMaskPath(rule.Path); err != nil {
+				return fmt.Errorf("mask.policies.%s[%d]: invalid path %q: %w", name, i, rule.Path, err)
+			}
+			switch rule.Strategy {
+			case maskHash:
+				if m.HashKey == "" {
+					return fmt.Errorf("mask.policies.%s[%d]: mask.hash_key is required by the hash strategy", name, i)
+				}
+			case maskTruncate:
+				if rule.Keep < 1 {
+					return fmt.Errorf("mask.policies.%s[%d]: keep must be positive, got %d", name, i, rule.Keep)
+				}
+			case maskNull:
+			default:
+				return fmt.Errorf("mask.policies.%s[%d]: strategy must be %q, %q or %q, got %q", name, i, maskHash, maskTruncate, maskNull, rule.Strategy)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the mail configuration
+func (m *MailConfig) validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("mail.from: %w", err)
+	}
+	if m.TimeoutMs < 1 || m.MaxPerMinute < 0 || m.MaxRecipients < 1 {
+		return fmt.Errorf("mail: timeout_ms and max_recipients must be positive, max_per_minute cannot be negative")
+	}
+
+	switch m.Driver {
+	case mailLog:
+		return nil
+	case mailSMTP:
+	default:
+		return fmt.Errorf("mail.driver must be %q or %q, got %q", mailSMTP, mailLog, m.Driver)
+	}
+
+	if m.SMTP == nil || m.SMTP.Host == "" {
+		return fmt.Errorf("mail.smtp.host is required by the smtp driver")
+	}
+	switch m.SMTP.Security {
+	case smtpStartTLS, smtpTLS, smtpNone:
+	default:
+		return fmt.Errorf("mail.smtp.security must be %q, %q or %q, got %q", smtpStartTLS, smtpTLS, smtpNone, m.SMTP.Security)
+	}
+	if m.SMTP.Port < 1 || m.SMTP.Port > 65535 {
+		return fmt.Errorf("mail.smtp.port must be between 1 and 65535, got %d", m.SMTP.Port)
+	}
+	return nil
+}
+
+// validate checks the discovery configuration
+func (d *DiscoveryConfig) validate() error {
+	if d.CacheTTLMs < 0 || d.TimeoutMs < 1 {
+		return fmt.Errorf("discovery: timeout_ms must be positive and cache_ttl_ms cannot be negative")
+	}
+	for name, svc := range d.Services {
+		key := "discovery.services." + name
+		if svc == nil {
+			return fmt.Errorf("%s: service is empty", key)
+		}
+		switch svc.Provider {
+		case discoveryStatic:
+			if len(svc.Endpoints) == 0 {
+				return fmt.Errorf("%s: the static provider requires endpoints", key)
+			}
+			for _, endpoint := range svc.Endpoints {
+				if _, _, err := net.SplitHostPort(endpoint); err != nil {
+					return fmt.Errorf("%s.endpoints: %w", key, err)
+				}
+			}
+		case discoveryDNS:
+			if svc.SRV == "" {
+				return fmt.Errorf("%s: the dns provider requires srv", key)
+			}
+		case discoveryConsul:
+			if d.Consul == nil {
+				return fmt.Errorf("%s: the consul provider requires discovery.consul", key)
+			}
+		default:
+			return fmt.Errorf("%s.provider must be %q, %q or %q, got %q", key, discoveryStatic, discoveryDNS, discoveryConsul, svc.Provider)
+		}
+	}
+	return nil
+}
+
+// validateGRPCTarget checks a grpc_client target
+func validateGRPCTarget(name string, target *GRPCTargetConfig) error {
+	key := "grpc_client.targets." + name
+	if target == nil || target.Addr == "" {
+		return fmt.Errorf("%s.addr is required", key)
+	}
+	if (target.DescriptorSet == "") == !target.Reflection {
+		return fmt.Errorf("%s: exactly one of descriptor_set and reflection must be set", key)
+	}
+	if target.TimeoutMs < 1 {
+		return fmt.Errorf("%s.timeout_ms must be positive", key)
+	}
+	if tls := target.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+		return fmt.Errorf("%s.tls: cert_file and key_file must be set together", key)
+	}
+	return nil
+}
+
+// validateGraphQLEndpoint checks an endpoint of the graphql binding
+func (c *Config) validateGraphQLEndpoint(name string, endpoint *GraphQLEndpointConfig) error {
+	key := "graphql.endpoints." + name
+	if endpoint == nil {
+		return fmt.Errorf("%s: endpoint is empty", key)
+	}
+	endpointURL, err := url.Parse(endpoint.URL)
+	if err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Host == "" {
+		return fmt.Errorf("%s.url: must be an http or https URL, got %q", key, endpoint.URL)
+	}
+	if endpoint.MaxBatchSize < 1 {
+		return fmt.Errorf("%s.max_batch_size must be positive, got %d", key, endpoint.MaxBatchSize)
+	}
+	if _, ok := c.Fetch.Clients[endpoint.Client]; endpoint.Client != "" && !ok {
+		return fmt.Errorf("%s.client: unknown fetch client profile %q", key, endpoint.Client)
+	}
+	return nil
+}
+
+// validate checks the mqtt configuration
+func (m *MQTTConfig) validate() error {
+	broker, err := url.Parse(m.Broker)
+	if err != nil || broker.Host == "" || broker.Port() == "" ||
+		!slices.Contains([]string{"tcp", "mqtt", "ssl", "mqtts"}, broker.Scheme) {
+		return fmt.Errorf("mqtt.broker: must be tcp://host:port or ssl://host:port, got %q", m.Broker)
+	}
+	if m.KeepAliveS < 0 || m.KeepAliveS > 65535 {
+		return fmt.Errorf("mqtt.keepalive_s must be between 0 and 65535, got %d", m.KeepAliveS)
+	}
+	if m.TimeoutMs < 1 || m.MaxPayloadBytes < 1 {
+		return fmt.Errorf("mqtt: timeout_ms and max_payload_bytes must be positive")
+	}
+	if m.MaxPayloadBytes > mqttMaxRemainingLength-mqttMaxString-5 {
+		return fmt.Errorf("mqtt.max_payload_bytes must not exceed %d, got %d", mqttMaxRemainingLength-mqttMaxString-5, m.MaxPayloadBytes)
+	}
+	for _, field := range []struct{ key, value string }{{"client_id", m.ClientID}, {"username", m.Username}, {"password", m.Password}} {
+		if len(field.value) > mqttMaxString {
+			return fmt.Errorf("mqtt.%s must not exceed %d bytes", field.key, mqttMaxString)
+		}
+	}
+	for _, filter := range m.AllowedTopics {
+		if filter == "" {
+			return fmt.Errorf("mqtt.allowed_topics: empty topic filter")
+		}
+	}
+	return nil
+}
+
+// validateSchedules checks the schedules configuration; scripts are checked against the registry at Init
+func (c *Config) validateSchedules(s *SchedulesConfig) error {
+	if c.Scripts == nil {
+		return fmt.Errorf("schedules requires js.scripts, schedules run registered scripts")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("schedules.timezone: %w", err)
+	}
+	for name, job := range s.Jobs {
+		key := "schedules.jobs." + name
+		if job == nil || job.Script == "" {
+			return fmt.Errorf("%s.script is required", key)
+		}
+		if _, err := newScheduledJob(name, job); err != nil {
+			return err
+		}
+		if !slices.Contains([]string{catchUpSkip, catchUpOnce, catchUpAll}, job.CatchUp) {
+			return fmt.Errorf("%s.catch_up must be %q, %q or %q, got %q", key, catchUpSkip, catchUpOnce, catchUpAll, job.CatchUp)
+		}
+		if job.MaxCatchUp < 1 || job.TimeoutMs < 1 {
+			return fmt.Errorf("%s: max_catch_up and timeout_ms must be positive", key)
+		}
+		if job.Tag != "" && !slices.Contains(c.Tags, job.Tag) {
+			return fmt.Errorf("%s.tag: %q is not one of js.tags", key, job.Tag)
+		}
+	}
+	switch s.State.Driver {
+	case scheduleStateMemory, scheduleStateFile:
+	case scheduleStateKV:
+		if s.State.Storage == "" {
+			return fmt.Errorf("schedules.state.storage is required for the kv driver")
+		}
+	default:
+		return fmt.Errorf("schedules.state.driver must be %q, %q or %q, got %q",
+			scheduleStateMemory, scheduleStateFile, scheduleStateKV, s.State.Driver)
+	}
+	return nil
+}
+
+// validateConcurrencyGroups checks group limits and that scripts, fetch client profiles and gRPC targets only
+// reference configured groups
+func (c *Config) validateConcurrencyGroups() error {
+	for group, limit := range c.ConcurrencyGroups {
+		if group == "" || len(group) > maxLabelLength {
+			return fmt.Errorf("concurrency_groups: group name %q must be non-empty and at most %d bytes", group, maxLabelLength)
+		}
+		if limit < 1 {
+			return fmt.Errorf("concurrency_groups.%s must be at least 1, got %d", group, limit)
+		}
+	}
+
+	known := func(key, group string) error {
+		if _, ok := c.ConcurrencyGroups[group]; !ok {
+			return fmt.Errorf("%s: unknown concurrency group %q", key, group)
+		}
+		return nil
+	}
+	if c.Scripts != nil {
+		for script, groups := range c.Scripts.Groups {
+			for _, group := range groups {
+				if err := known("scripts.groups."+script, group); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name, profile := range c.Fetch.Clients {
+		if profile != nil && profile.ConcurrencyGroup != "" {
+			if err := known("fetch.clients."+name+".concurrency_group", profile.ConcurrencyGroup); err != nil {
+				return err
+			}
+		}
+	}
+	if c.GRPCClient != nil {
+		for name, target := range c.GRPCClient.Targets {
+			if target != nil && target.ConcurrencyGroup != "" {
+				if err := known("grpc_client.targets."+name+".concurrency_group", target.ConcurrencyGroup); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateEnvironments checks the environment overlays of scripts: overlay globals must be injectable and must not
+// shadow js.globals, which are defined for every script
+func (c *Config) validateEnvironments() error {
+	if c.Scripts == nil {
+		return nil
+	}
+	for script, env := range c.Scripts.Environments {
+		if env == nil {
+			continue
+		}
+		if err := validateGlobals(env.Globals); err != nil {
+			return fmt.Errorf("scripts.environments.%s.%w", script, err)
+		}
+		for name := range env.Globals {
+			if _, ok := c.Globals[name]; ok {
+				return fmt.Errorf("scripts.environments.%s.globals: %q is already defined in globals", script, name)
+			}
+		}
+		if len(env.CachePrefix) > maxLabelLength {
+			return fmt.Errorf("scripts.environments.%s.cache_prefix must not exceed %d bytes", script, maxLabelLength)
+		}
+	}
 	return nil
 }
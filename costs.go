@@ -0,0 +1,258 @@
+package jsmachine
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	// errCodeBudgetExceeded marks requests rejected because their caller used up a daily budget
+	errCodeBudgetExceeded = "budget_exceeded"
+
+	// Accounts of requests without a caller, and of callers beyond costs.max_callers
+	costAnonymous = "(anonymous)"
+	costOther     = "(other)"
+)
+
+// Kinds of cost exported by js_caller_cost_seconds_total
+const (
+	costCPU     = "cpu"
+	costWall    = "wall"
+	costBinding = "binding"
+)
+
+var errBudgetExceeded = errors.New("daily execution budget of the caller is exhausted")
+
+// CallerCost is the usage of a caller over a period, or a daily budget (zero fields are unlimited)
+type CallerCost struct {
+	Executions int64 `json:"executions"`
+	CPUMs      int64 `json:"cpu_ms"`
+	WallMs     int64 `json:"wall_ms"`
+	BindingMs  int64 `json:"binding_ms"`
+}
+
+// exceeds reports whether usage reached any limit of budget
+func (usage CallerCost) exceeds(budget *CostBudgetConfig) bool {
+	if budget == nil {
+		return false
+	}
+	return (budget.Executions > 0 && usage.Executions >= budget.Executions) ||
+		(budget.CPUMs > 0 && usage.CPUMs >= budget.CPUMs) ||
+		(budget.WallMs > 0 && usage.WallMs >= budget.WallMs) ||
+		(budget.BindingMs > 0 && usage.BindingMs >= budget.BindingMs)
+}
+
+// callerAccount is the usage of one caller
+type callerAccount struct {
+	total    CallerCost
+	today    CallerCost
+	rejected int64 // today
+
+	// Sub-millisecond remainders, so short executions still add up
+	cpu, wall, binding time.Duration
+}
+
+// costLedger accounts execution costs per caller and enforces daily budgets
+type costLedger struct {
+	cfg *CostsConfig
+	loc *time.Location
+
+	costs      *prometheus.CounterVec
+	rejections *prometheus.CounterVec
+
+	mu       sync.Mutex
+	day      string
+	accounts map[string]*callerAccount
+}
+
+// newCostLedger creates an empty ledger (the time zone is validated with the configuration)
+func newCostLedger(cfg *CostsConfig, costs, rejections *prometheus.CounterVec) *costLedger {
+	loc, _ := time.LoadLocation(cfg.Timezone)
+	return &costLedger{
+		cfg:        cfg,
+		loc:        loc,
+		costs:      costs,
+		rejections: rejections,
+		accounts:   make(map[string]*callerAccount),
+	}
+}
+
+// rollover starts a new budget day when the date changed (l.mu held)
+func (l *costLedger) rollover() {
+	if day := time.Now().In(l.loc).Format(time.DateOnly); day != l.day {
+		l.day = day
+		for _, acc := range l.accounts {
+			acc.today, acc.rejected = CallerCost{}, 0
+		}
+	}
+}
+
+// account returns the account of caller for the current day (l.mu held)
+// Callers beyond max_callers share one account, bounding memory and metric cardinality
+func (l *costLedger) account(caller string) (string, *callerAccount) {
+	l.rollover()
+
+	if caller == "" {
+		caller = costAnonymous
+	}
+	acc, ok := l.accounts[caller]
+	if !ok && len(l.accounts) >= l.cfg.MaxCallers {
+		caller = costOther
+		acc, ok = l.accounts[caller]
+	}
+	if !ok {
+		acc = &callerAccount{}
+		l.accounts[caller] = acc
+	}
+	return caller, acc
+}
+
+// budget returns the daily budget of a caller
+func (l *costLedger) budget(caller string) *CostBudgetConfig {
+	if budget, ok := l.cfg.Budgets[caller]; ok {
+		return budget
+	}
+	return l.cfg.DefaultBudget
+}
+
+// admit checks the caller's budget before an execution, counting a rejection when it is used up
+func (l *costLedger) admit(caller string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	budget := l.budget(caller)
+	name, acc := l.account(caller)
+	if !acc.today.exceeds(budget) {
+		return nil
+	}
+	acc.rejected++
+	l.rejections.WithLabelValues(name).Inc()
+	return errBudgetExceeded
+}
+
+// record adds an execution: its wall time, the time its own code ran on the VM (charged as CPU time) and the time
+// it spent in binding calls, taken from its binding trace
+// VM time stands in for CPU time: measuring the CPU time of the thread running a script requires locking the
+// goroutine to it, which slows executions down considerably when goroutines outnumber the CPUs
+func (l *costLedger) record(caller string, wall time.Duration, trace *executionTrace) {
+	cpu := trace.spent[""]
+	binding := bindingTime(trace)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	name, acc := l.account(caller)
+	acc.total.Executions++
+	acc.today.Executions++
+	l.add(acc, &acc.cpu, cpu, func(c *CallerCost) *int64 { return &c.CPUMs })
+	l.add(acc, &acc.wall, wall, func(c *CallerCost) *int64 { return &c.WallMs })
+	l.add(acc, &acc.binding, binding, func(c *CallerCost) *int64 { return &c.BindingMs })
+	l.costs.WithLabelValues(name, costCPU).Add(cpu.Seconds())
+	l.costs.WithLabelValues(name, costWall).Add(wall.Seconds())
+	l.costs.WithLabelValues(name, costBinding).Add(binding.Seconds())
+}
+
+// add credits whole milliseconds of d to the total and today's usage, keeping the remainder (l.mu held)
+func (l *costLedger) add(acc *callerAccount, remainder *time.Duration, d time.Duration, field func(*CallerCost) *int64) {
+	*remainder += d
+	ms := remainder.Milliseconds()
+	*remainder -= time.Duration(ms) * time.Millisecond
+	*field(&acc.total) += ms
+	*field(&acc.today) += ms
+}
+
+// CallerStats is the usage and budget of a caller
+type CallerStats struct {
+	Caller string `json:"caller"`
+
+	// Usage since the plugin started and since midnight in costs.timezone
+	Total CallerCost `json:"total"`
+	Today CallerCost `json:"today"`
+
+	// Executions rejected today because the budget was used up
+	Rejected int64 `json:"rejected"`
+
+	// Daily budget (nil when unlimited)
+	Budget *CallerCost `json:"budget,omitempty"`
+}
+
+// StatsRequest selects the callers to report
+type StatsRequest struct {
+	// Caller to report, "(anonymous)" for requests without one (empty = all callers)
+	Caller string `json:"caller,omitempty"`
+}
+
+// StatsResponse contains the usage of callers
+type StatsResponse struct {
+	// Current budget day in costs.timezone, e.g. 2026-10-17
+	Day string `json:"day"`
+
+	// Callers sorted by name
+	Callers []*CallerStats `json:"callers"`
+}
+
+// stats reports the accounts matching caller (all when empty)
+func (l *costLedger) stats(caller string) *StatsResponse {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Roll the day over before reporting today's usage
+	l.rollover()
+
+	resp := &StatsResponse{Day: l.day, Callers: make([]*CallerStats, 0, len(l.accounts))}
+	for name, acc := range l.accounts {
+		if caller != "" && name != caller {
+			continue
+		}
+		stats := &CallerStats{Caller: name, Total: acc.total, Today: acc.today, Rejected: acc.rejected}
+		if budget := l.budget(name); budget != nil {
+			stats.Budget = &CallerCost{
+				Executions: budget.Executions,
+				CPUMs:      budget.CPUMs,
+				WallMs:     budget.WallMs,
+				BindingMs:  budget.BindingMs,
+			}
+		}
+		resp.Callers = append(resp.Callers, stats)
+	}
+	sort.Slice(resp.Callers, func(i, j int) bool { return resp.Callers[i].Caller < resp.Callers[j].Caller })
+	return resp
+}
+
+// Stats reports execution costs and budgets per caller
+func (r *rpc) Stats(req *StatsRequest, resp *StatsResponse) error {
+	if r.plugin.costs == nil {
+		return errors.New("cost accounting is not configured")
+	}
+	*resp = *r.plugin.costs.stats(req.Caller)
+	return nil
+}
+
+// rejectBudget encodes a request whose caller used up a daily budget in resp
+func (r *rpc) rejectBudget(req *ExecuteRequest, resp *ExecuteResponse, err error) {
+	resp.Error = err.Error()
+	resp.ErrorCode = errCodeBudgetExceeded
+	resp.RequestID = req.RequestID
+
+	r.plugin.executionsTotal.WithLabelValues(errCodeBudgetExceeded, r.plugin.poolFor(req.Script).name, req.Tag).Inc()
+	r.log.Debug("JavaScript execution request over budget",
+		zap.String("request_id", req.RequestID),
+		zap.String("caller", req.Caller),
+	)
+}
+
+// bindingTime returns the time a trace spent in binding calls
+func bindingTime(trace *executionTrace) time.Duration {
+	var spent time.Duration
+	for path, d := range trace.spent {
+		if path != "" {
+			spent += d
+		}
+	}
+	return spent
+}
@@ -0,0 +1,479 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/api/v4/plugins/v1/kv"
+	"go.uber.org/zap"
+)
+
+// DeadLetterEntry is a failed execution that exhausted its retries
+type DeadLetterEntry struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	RequestID string    `json:"request_id,omitempty"`
+	Script    string    `json:"script,omitempty"`
+	Code      string    `json:"code"`
+	TimeoutMs int       `json:"timeout_ms,omitempty"`
+
+	// Retry policy of the original request, reused on re-drive
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	Status   string `json:"status"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// deadLetterStore persists dead-lettered executions
+type deadLetterStore interface {
+	// put stores a new entry
+	put(entry *DeadLetterEntry) error
+	// list returns up to limit entries, oldest first (limit <= 0 returns all)
+	list(limit int) ([]*DeadLetterEntry, error)
+	// get returns a single entry
+	get(id string) (*DeadLetterEntry, error)
+	// remove deletes entries by ID, ignoring unknown IDs
+	remove(ids ...string) (int, error)
+}
+
+// errDeadLetterNotFound is returned when an entry does not exist
+var errDeadLetterNotFound = errors.New("dead-letter entry not found")
+
+// newDeadLetterStore creates the store for the configured driver
+func (p *Plugin) newDeadLetterStore(cfg *DeadLetterConfig) (deadLetterStore, error) {
+	switch cfg.Driver {
+	case "file":
+		return newFileDeadLetterStore(cfg.Path)
+	case "kv":
+		storage, err := p.kvStorage(cfg.Storage)
+		if err != nil {
+			return nil, fmt.Errorf("dead-letter storage: %w", err)
+		}
+		return &kvDeadLetterStore{storage: storage, prefix: cfg.Prefix, locker: p.bindings.lock.locker()}, nil
+	default:
+		return nil, fmt.Errorf("unknown dead-letter driver %q", cfg.Driver)
+	}
+}
+
+// fileDeadLetterStore keeps one JSON file per entry in a directory
+type fileDeadLetterStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// newFileDeadLetterStore creates the directory if needed
+func newFileDeadLetterStore(dir string) (*fileDeadLetterStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	return &fileDeadLetterStore{dir: dir}, nil
+}
+
+func (s *fileDeadLetterStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *fileDeadLetterStore) put(entry *DeadLetterEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Write to a temp file first so readers never see partial entries
+	tmp := s.path(entry.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(entry.ID))
+}
+
+func (s *fileDeadLetterStore) list(limit int) ([]*DeadLetterEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(ids)
+
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	entries := make([]*DeadLetterEntry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *fileDeadLetterStore) get(id string) (*DeadLetterEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(id)
+}
+
+// read loads an entry; the caller holds the lock
+func (s *fileDeadLetterStore) read(id string) (*DeadLetterEntry, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, errDeadLetterNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &DeadLetterEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("corrupted dead-letter entry %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+func (s *fileDeadLetterStore) remove(ids ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, id := range ids {
+		if id == "" || strings.ContainsAny(id, `/\.`) {
+			continue
+		}
+		err := os.Remove(s.path(id))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// kvDeadLetterStore keeps entries in a storage of the kv plugin, shared by the nodes using it
+// KV storages cannot list their keys, so the IDs are kept in an index entry, updated under a lock
+type kvDeadLetterStore struct {
+	mu      sync.Mutex
+	storage kv.Storage
+	prefix  string
+	locker  distributedLocker
+}
+
+// kvIndexLockWait bounds the wait for the index lock held by another node
+const kvIndexLockWait = 5 * time.Second
+
+func (s *kvDeadLetterStore) key(id string) string {
+	return s.prefix + "entry:" + id
+}
+
+func (s *kvDeadLetterStore) put(entry *DeadLetterEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.Set(&kvItem{key: s.key(entry.ID), value: data}); err != nil {
+		return err
+	}
+
+	return s.updateIndex(func(ids []string) []string {
+		return append(ids, entry.ID)
+	})
+}
+
+func (s *kvDeadLetterStore) list(limit int) ([]*DeadLetterEntry, error) {
+	ids, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	if len(ids) == 0 {
+		return []*DeadLetterEntry{}, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+	values, err := s.storage.MGet(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*DeadLetterEntry, 0, len(ids))
+	for _, id := range ids {
+		data, ok := values[s.key(id)]
+		if !ok {
+			// Removed by another node since the index was read
+			continue
+		}
+		entry := &DeadLetterEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, fmt.Errorf("corrupted dead-letter entry %s: %w", id, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *kvDeadLetterStore) get(id string) (*DeadLetterEntry, error) {
+	if id == "" {
+		return nil, errDeadLetterNotFound
+	}
+
+	data, err := s.storage.Get(s.key(id))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errDeadLetterNotFound
+	}
+
+	entry := &DeadLetterEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("corrupted dead-letter entry %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+func (s *kvDeadLetterStore) remove(ids ...string) (int, error) {
+	removed := make([]string, 0, len(ids))
+	err := s.updateIndex(func(index []string) []string {
+		kept := index[:0]
+		for _, id := range index {
+			if slices.Contains(ids, id) {
+				removed = append(removed, s.key(id))
+			} else {
+				kept = append(kept, id)
+			}
+		}
+		return kept
+	})
+	if err != nil || len(removed) == 0 {
+		return 0, err
+	}
+	if err := s.storage.Delete(removed...); err != nil {
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// index returns the IDs of the stored entries, oldest first
+func (s *kvDeadLetterStore) index() ([]string, error) {
+	data, err := s.storage.Get(s.prefix + "index")
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("corrupted dead-letter index: %w", err)
+	}
+	return ids, nil
+}
+
+// updateIndex rewrites the index with update, holding the index lock so concurrent updates from other nodes are not
+// lost
+func (s *kvDeadLetterStore) updateIndex(update func(ids []string) []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lockKey, owner := s.prefix+"index:lock", newRecordID()
+	deadline := time.Now().Add(kvIndexLockWait)
+	for {
+		acquired, err := s.locker.Lock(lockKey, owner, kvIndexLockWait)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("dead-letter index is locked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() { _, _ = s.locker.Release(lockKey, owner) }()
+
+	ids, err := s.index()
+	if err != nil {
+		return err
+	}
+	// IDs start with their creation time, so sorting keeps the oldest first
+	ids = update(ids)
+	sort.Strings(ids)
+
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return s.storage.Set(&kvItem{key: s.prefix + "index", value: data})
+}
+
+// deadLetter stores a failed execution that exhausted its retries
+func (p *Plugin) deadLetter(req *ExecuteRequest, resp *ExecuteResponse, status string) {
+	entry := &DeadLetterEntry{
+		ID:        newRecordID(),
+		CreatedAt: time.Now(),
+		RequestID: req.RequestID,
+		Script:    req.Script,
+		Code:      req.Code,
+		TimeoutMs: req.TimeoutMs,
+		Retry:     req.Retry,
+		Status:    status,
+		Error:     resp.Error,
+		Attempts:  resp.Attempts,
+	}
+
+	if err := p.deadLetters.put(entry); err != nil {
+		p.log.Error("failed to store dead-letter entry",
+			zap.String("request_id", req.RequestID),
+			zap.Error(err))
+		return
+	}
+
+	p.log.Warn("execution dead-lettered",
+		zap.String("id", entry.ID),
+		zap.String("request_id", req.RequestID),
+		zap.Int("attempts", resp.Attempts))
+}
+
+// DeadLetterListRequest lists dead-letter entries
+type DeadLetterListRequest struct {
+	// Maximum number of entries, oldest first (0 = all)
+	Limit int `json:"limit"`
+}
+
+// DeadLetterListResponse contains dead-letter entries
+type DeadLetterListResponse struct {
+	Entries []*DeadLetterEntry `json:"entries"`
+}
+
+// DeadLetterRedriveRequest re-executes dead-letter entries
+type DeadLetterRedriveRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// DeadLetterRedriveResponse contains the execution result per entry ID
+// Entries that succeed are removed from the store, failing ones are kept
+type DeadLetterRedriveResponse struct {
+	Results map[string]*ExecuteResponse `json:"results"`
+}
+
+// DeadLetterPurgeRequest deletes dead-letter entries
+type DeadLetterPurgeRequest struct {
+	IDs []string `json:"ids"`
+
+	// Delete every entry (IDs are ignored)
+	All bool `json:"all"`
+}
+
+// DeadLetterPurgeResponse reports how many entries were deleted
+type DeadLetterPurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// DeadLetterList returns stored dead-letter entries
+func (r *rpc) DeadLetterList(req *DeadLetterListRequest, resp *DeadLetterListResponse) error {
+	if r.plugin.deadLetters == nil {
+		return fmt.Errorf("dead-letter store is not configured")
+	}
+
+	entries, err := r.plugin.deadLetters.list(req.Limit)
+	if err != nil {
+		return err
+	}
+	resp.Entries = entries
+	return nil
+}
+
+// DeadLetterRedrive re-executes entries and removes those that succeed
+func (r *rpc) DeadLetterRedrive(req *DeadLetterRedriveRequest, resp *DeadLetterRedriveResponse) error {
+	if r.plugin.deadLetters == nil {
+		return fmt.Errorf("dead-letter store is not configured")
+	}
+
+	resp.Results = make(map[string]*ExecuteResponse, len(req.IDs))
+	for _, id := range req.IDs {
+		entry, err := r.plugin.deadLetters.get(id)
+		if err != nil {
+			resp.Results[id] = &ExecuteResponse{Error: err.Error()}
+			continue
+		}
+
+		execResp := &ExecuteResponse{}
+		execReq := &ExecuteRequest{
+			Code:      entry.Code,
+			Script:    entry.Script,
+			TimeoutMs: entry.TimeoutMs,
+			RequestID: entry.RequestID,
+			Retry:     entry.Retry,
+			redrive:   true,
+		}
+		if err := r.handleExecute(context.Background(), execReq, execResp); err != nil {
+			execResp.Error = err.Error()
+		}
+		resp.Results[id] = execResp
+
+		if execResp.Error == "" {
+			if _, err := r.plugin.deadLetters.remove(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeadLetterPurge deletes entries
+func (r *rpc) DeadLetterPurge(req *DeadLetterPurgeRequest, resp *DeadLetterPurgeResponse) error {
+	if r.plugin.deadLetters == nil {
+		return fmt.Errorf("dead-letter store is not configured")
+	}
+
+	ids := req.IDs
+	if req.All {
+		entries, err := r.plugin.deadLetters.list(0)
+		if err != nil {
+			return err
+		}
+		ids = make([]string, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+	}
+
+	purged, err := r.plugin.deadLetters.remove(ids...)
+	resp.Purged = purged
+	return err
+}
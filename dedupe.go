@@ -0,0 +1,184 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/api/v4/plugins/v1/kv"
+	"go.uber.org/zap"
+)
+
+// Outcomes counted by js_dedupe_requests_total
+const (
+	dedupeExecuted = "executed"
+	dedupeShared   = "shared"
+)
+
+// kvItem is an entry written to a KV storage
+type kvItem struct {
+	key     string
+	value   []byte
+	timeout string
+}
+
+// Key implements kv.Item
+func (i *kvItem) Key() string { return i.key }
+
+// Value implements kv.Item
+func (i *kvItem) Value() []byte { return i.value }
+
+// Timeout implements kv.Item (RFC 3339)
+func (i *kvItem) Timeout() string { return i.timeout }
+
+// kvStorage builds the storage configured as kv.<name> with the KV driver it names
+func (p *Plugin) kvStorage(name string) (kv.Storage, error) {
+	key := "kv." + name
+	if !p.configurer.Has(key) {
+		return nil, fmt.Errorf("kv storage %q is not configured", name)
+	}
+
+	var section struct {
+		Driver string `mapstructure:"driver"`
+	}
+	if err := p.configurer.UnmarshalKey(key, &section); err != nil {
+		return nil, err
+	}
+	driver, ok := p.kvDrivers[section.Driver]
+	if !ok {
+		return nil, fmt.Errorf("kv driver %q of storage %q is not available", section.Driver, name)
+	}
+	return driver.KvFromConfig(key)
+}
+
+// sharedResult is what an execution stores for the requests carrying the same dedupe key
+type sharedResult struct {
+	Result  json.RawMessage   `json:"result"`
+	Records []json.RawMessage `json:"records,omitempty"`
+}
+
+// deduper lets one execution per dedupe key run across RoadRunner nodes: the first request claims the key with a
+// lock, the others wait for the result it stores in a KV storage
+// The claim expires after lock_ttl_ms, so a node that died while running does not block its key for longer
+type deduper struct {
+	cfg      *DedupeConfig
+	storage  kv.Storage
+	locker   distributedLocker
+	requests *prometheus.CounterVec
+	log      *zap.Logger
+}
+
+// dedupeClaim is the claim of a request that runs the execution for its key
+type dedupeClaim struct {
+	d     *deduper
+	key   string
+	owner string
+}
+
+// claim returns the claim on the request's key, or nil after filling resp with the shared result (done is then
+// true) or when deduplication failed and the request runs on its own
+// Requests wait while another one holds the claim, checking for its result every poll_interval_ms (jittered so
+// waiters do not poll in lockstep); a claim released without a result is taken over by one of them
+func (d *deduper) claim(ctx context.Context, lc *lifecycle, req *ExecuteRequest, resp *ExecuteResponse) (claim *dedupeClaim, done bool) {
+	key := d.cfg.Prefix + req.DedupeKey
+	owner := newRecordID()
+	start := time.Now()
+	poll := time.Duration(d.cfg.PollIntervalMs) * time.Millisecond
+
+	for {
+		if d.shared(key, req, resp, start) {
+			return nil, true
+		}
+
+		acquired, err := d.locker.Lock(key, owner, time.Duration(d.cfg.LockTTLMs)*time.Millisecond)
+		if err != nil {
+			d.log.Warn("failed to claim dedupe key, executing without deduplication", zap.String("key", req.DedupeKey), zap.Error(err))
+			return nil, false
+		}
+		if acquired {
+			// The previous holder may have stored its result between the check and the claim
+			if d.shared(key, req, resp, start) {
+				d.release(key, owner)
+				return nil, true
+			}
+			return &dedupeClaim{d: d, key: key, owner: owner}, false
+		}
+
+		select {
+		case <-ctx.Done():
+			resp.Error = ctx.Err().Error()
+			resp.RequestID = req.RequestID
+			return nil, true
+		case <-time.After(poll/2 + rand.N(poll)):
+		}
+		if !lc.serving() {
+			resp.Error = errNotReady.Error()
+			resp.ErrorCode = errCodeNotReady
+			resp.RequestID = req.RequestID
+			return nil, true
+		}
+	}
+}
+
+// shared fills resp with the result stored for key, reporting whether there was one
+func (d *deduper) shared(key string, req *ExecuteRequest, resp *ExecuteResponse, start time.Time) bool {
+	data, err := d.storage.Get(key)
+	if err != nil {
+		d.log.Warn("failed to read shared result", zap.String("key", req.DedupeKey), zap.Error(err))
+		return false
+	}
+	if data == nil {
+		return false
+	}
+
+	var stored sharedResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		d.log.Warn("invalid shared result", zap.String("key", req.DedupeKey), zap.Error(err))
+		return false
+	}
+	if err := json.Unmarshal(stored.Result, &resp.Result); err != nil {
+		return false
+	}
+	resp.Records = stored.Records
+	resp.Deduplicated = true
+	resp.RequestID = req.RequestID
+	resp.DurationMs = time.Since(start).Milliseconds()
+
+	d.requests.WithLabelValues(dedupeShared).Inc()
+	return true
+}
+
+// finish stores the result of a successful execution for result_ttl_ms and releases the claim; failed executions
+// store nothing, so a waiting request takes over
+func (c *dedupeClaim) finish(resp *ExecuteResponse) {
+	d := c.d
+	defer d.release(c.key, c.owner)
+
+	d.requests.WithLabelValues(dedupeExecuted).Inc()
+	if resp.Error != "" {
+		return
+	}
+
+	result, err := json.Marshal(resp.Result)
+	if err == nil {
+		var data []byte
+		data, err = json.Marshal(sharedResult{Result: result, Records: resp.Records})
+		if err == nil {
+			expires := time.Now().Add(time.Duration(d.cfg.ResultTTLMs) * time.Millisecond)
+			err = d.storage.Set(&kvItem{key: c.key, value: data, timeout: expires.Format(time.RFC3339)})
+		}
+	}
+	if err != nil {
+		d.log.Warn("failed to store shared result", zap.String("key", c.key), zap.Error(err))
+	}
+}
+
+// release frees the claim on key
+func (d *deduper) release(key, owner string) {
+	if _, err := d.locker.Release(key, owner); err != nil {
+		d.log.Warn("failed to release dedupe key", zap.String("key", key), zap.Error(err))
+	}
+}
@@ -0,0 +1,102 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ValueDiff is a single difference between two JSON-compatible values
+type ValueDiff struct {
+	// JSON-pointer-like path of the difference ("" = root)
+	Path string `json:"path"`
+
+	// Value on each side (absent when the key exists on one side only)
+	Original interface{} `json:"original,omitempty"`
+	Replayed interface{} `json:"replayed,omitempty"`
+}
+
+// diffValues compares two values after normalizing them through JSON
+func diffValues(original, replayed interface{}) ([]ValueDiff, error) {
+	a, err := normalizeJSON(original)
+	if err != nil {
+		return nil, fmt.Errorf("original: %w", err)
+	}
+	b, err := normalizeJSON(replayed)
+	if err != nil {
+		return nil, fmt.Errorf("replayed: %w", err)
+	}
+
+	var diffs []ValueDiff
+	collectDiffs("", a, b, &diffs)
+	return diffs, nil
+}
+
+// normalizeJSON converts a Go value to the generic form produced by encoding/json
+// so values exported by otto and values decoded from storage compare equal
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// collectDiffs walks both values and appends differences
+func collectDiffs(path string, a, b interface{}, diffs *[]ValueDiff) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, seen := av[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			collectDiffs(path+"/"+k, av[k], bv[k], diffs)
+		}
+		return
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		n := len(av)
+		if len(bv) > n {
+			n = len(bv)
+		}
+		for i := 0; i < n; i++ {
+			var x, y interface{}
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			collectDiffs(fmt.Sprintf("%s/%d", path, i), x, y, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, ValueDiff{Path: path, Original: a, Replayed: b})
+	}
+}
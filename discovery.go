@@ -0,0 +1,173 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service discovery providers
+const (
+	discoveryStatic = "static"
+	discoveryDNS    = "dns"
+	discoveryConsul = "consul"
+)
+
+// discoveryEntry is the cached endpoint list of a service
+type discoveryEntry struct {
+	endpoints []string
+	expires   time.Time
+}
+
+// serviceDiscovery resolves logical service names to "host:port" endpoints and caches the results
+type serviceDiscovery struct {
+	cfg      *DiscoveryConfig
+	resolver *net.Resolver
+	client   *http.Client
+
+	mu      sync.Mutex
+	entries map[string]*discoveryEntry
+}
+
+// newServiceDiscovery creates a resolver for the configured services
+func newServiceDiscovery(cfg *DiscoveryConfig) *serviceDiscovery {
+	d := &serviceDiscovery{
+		cfg:      cfg,
+		resolver: net.DefaultResolver,
+		entries:  make(map[string]*discoveryEntry),
+	}
+	if cfg.Consul != nil {
+		d.client = &http.Client{Timeout: time.Duration(cfg.Consul.TimeoutMs) * time.Millisecond}
+	}
+	return d
+}
+
+// resolve returns the endpoints of a configured service
+// When a refresh fails, the last known endpoints are returned along with the error
+func (d *serviceDiscovery) resolve(ctx context.Context, name string) ([]string, error) {
+	svc := d.cfg.Services[name]
+
+	d.mu.Lock()
+	cached := d.entries[name]
+	d.mu.Unlock()
+	if cached != nil && time.Now().Before(cached.expires) {
+		return cached.endpoints, nil
+	}
+
+	endpoints, err := d.lookup(ctx, name, svc)
+	if err != nil {
+		if cached != nil {
+			return cached.endpoints, err
+		}
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.entries[name] = &discoveryEntry{
+		endpoints: endpoints,
+		expires:   time.Now().Add(time.Duration(d.cfg.CacheTTLMs) * time.Millisecond),
+	}
+	d.mu.Unlock()
+	return endpoints, nil
+}
+
+// lookup asks the service's provider for its endpoints
+func (d *serviceDiscovery) lookup(ctx context.Context, name string, svc *ServiceConfig) ([]string, error) {
+	switch svc.Provider {
+	case discoveryDNS:
+		return d.lookupSRV(ctx, svc.SRV)
+	case discoveryConsul:
+		consulName := svc.Name
+		if consulName == "" {
+			consulName = name
+		}
+		return d.lookupConsul(ctx, consulName, svc.Tag)
+	}
+	return svc.Endpoints, nil
+}
+
+// lookupSRV resolves a SRV record; targets come ordered by priority and randomized by weight
+func (d *serviceDiscovery) lookupSRV(ctx context.Context, record string) ([]string, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", record)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]string, 0, len(records))
+	for _, srv := range records {
+		endpoints = append(endpoints, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+	}
+	return endpoints, nil
+}
+
+// consulServiceEntry is the part of a Consul health API entry discovery uses
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// lookupConsul returns the instances of a service passing their Consul health checks
+func (d *serviceDiscovery) lookupConsul(ctx context.Context, name, tag string) ([]string, error) {
+	consul := d.cfg.Consul
+	query := url.Values{"passing": {"true"}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	if consul.Datacenter != "" {
+		query.Set("dc", consul.Datacenter)
+	}
+
+	endpoint := strings.TrimSuffix(consul.Addr, "/") + "/v1/health/service/" + url.PathEscape(name) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if consul.Token != "" {
+		req.Header.Set("X-Consul-Token", consul.Token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul responded with %s", resp.Status)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid consul response: %w", err)
+	}
+
+	endpoints := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Services registered without an address use the address of their node
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	return endpoints, nil
+}
+
+// invalidate drops every cached endpoint list
+func (d *serviceDiscovery) invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	clear(d.entries)
+}
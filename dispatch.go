@@ -0,0 +1,170 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Outcomes counted by js_webhook_deliveries_total
+const (
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+	deliveryDropped   = "dropped"
+)
+
+// webhookDelivery is a request queued by webhook.dispatch
+type webhookDelivery struct {
+	id          string
+	method      string
+	url         string
+	headers     map[string]string
+	body        []byte
+	client      *http.Client
+	maxAttempts int
+}
+
+// webhookDispatcher delivers queued webhooks in the background, retrying with exponential backoff
+// Deliveries are at least once: receivers can deduplicate with the X-Webhook-Delivery header
+type webhookDispatcher struct {
+	cfg        *WebhookDispatchConfig
+	log        *zap.Logger
+	deliveries *prometheus.CounterVec
+
+	queue chan *webhookDelivery
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newWebhookDispatcher creates a dispatcher; workers start with run
+func newWebhookDispatcher(cfg *WebhookDispatchConfig, log *zap.Logger, deliveries *prometheus.CounterVec) *webhookDispatcher {
+	return &webhookDispatcher{
+		cfg:        cfg,
+		log:        log,
+		deliveries: deliveries,
+		queue:      make(chan *webhookDelivery, cfg.QueueSize),
+		quit:       make(chan struct{}),
+	}
+}
+
+// enqueue queues a delivery, reporting false when the queue is full
+func (d *webhookDispatcher) enqueue(delivery *webhookDelivery) bool {
+	select {
+	case d.queue <- delivery:
+		return true
+	default:
+		return false
+	}
+}
+
+// run starts the workers
+func (d *webhookDispatcher) run() {
+	for range d.cfg.Workers {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case delivery := <-d.queue:
+					d.deliver(delivery)
+				case <-d.quit:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// stop stops the workers, waiting for in-flight attempts (bounded by ctx)
+// Deliveries still queued or waiting for a retry are dropped
+func (d *webhookDispatcher) stop(ctx context.Context) {
+	close(d.quit)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	if dropped := len(d.queue); dropped > 0 {
+		d.deliveries.WithLabelValues(deliveryDropped).Add(float64(dropped))
+		d.log.Warn("webhook deliveries dropped on shutdown", zap.Int("deliveries", dropped))
+	}
+}
+
+// deliver sends a delivery until it succeeds, fails permanently or runs out of attempts
+func (d *webhookDispatcher) deliver(delivery *webhookDelivery) {
+	backoff := time.Duration(d.cfg.BackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(d.cfg.MaxBackoffMs) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(delivery)
+		if err == nil {
+			d.deliveries.WithLabelValues(deliveryDelivered).Inc()
+			return
+		}
+
+		if !retry || attempt >= delivery.maxAttempts {
+			d.deliveries.WithLabelValues(deliveryFailed).Inc()
+			d.log.Warn("webhook delivery failed",
+				zap.String("delivery_id", delivery.id),
+				zap.String("url", delivery.url),
+				zap.Int("attempts", attempt),
+				zap.Error(err))
+			return
+		}
+
+		// Full jitter keeps retries of many deliveries to the same failing receiver apart
+		wait := rand.N(backoff) + time.Millisecond
+		select {
+		case <-time.After(wait):
+			backoff = min(backoff*2, maxBackoff)
+		case <-d.quit:
+			d.deliveries.WithLabelValues(deliveryDropped).Inc()
+			return
+		}
+	}
+}
+
+// send makes one attempt, reporting whether a failure is worth retrying
+// Network errors, 408, 429 and 5xx responses are retried; other responses above 299 are final
+func (d *webhookDispatcher) send(delivery *webhookDelivery) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.cfg.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, delivery.method, delivery.url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range delivery.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("X-Webhook-Delivery", delivery.id)
+
+	resp, err := delivery.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return false, fmt.Errorf("receiver responded with %s", resp.Status)
+}
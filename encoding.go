@@ -0,0 +1,25 @@
+package jsmachine
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// byteOrderMark is the UTF-8 encoded BOM prepended by some (mostly Windows) editors
+const byteOrderMark = "\ufeff"
+
+// normalizeCode strips a leading BOM and converts CRLF/CR line endings to LF
+// Unless keepInvalid is set, invalid UTF-8 sequences are replaced with U+FFFD
+func normalizeCode(code string, keepInvalid bool) string {
+	code = strings.TrimPrefix(code, byteOrderMark)
+
+	if strings.Contains(code, "\r") {
+		code = strings.ReplaceAll(code, "\r\n", "\n")
+		code = strings.ReplaceAll(code, "\r", "\n")
+	}
+
+	if !keepInvalid && !utf8.ValidString(code) {
+		code = strings.ToValidUTF8(code, string(utf8.RuneError))
+	}
+	return code
+}
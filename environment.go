@@ -0,0 +1,143 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/robertkrimen/otto"
+)
+
+// overlayScript defines the overlay globals as non-configurable accessors resolved against the running execution, so
+// one VM serves every script; values are deep-frozen like js.globals
+const overlayScript = `(function (names, lookup) {
+	var global = Function("return this")();
+	function freeze(v) { if (v !== null && typeof v === "object") { for (var k in v) { freeze(v[k]); } Object.freeze(v); } return v; }
+	function getter(name) { return function () { return lookup(name, freeze); }; }
+	for (var i = 0; i < names.length; i++) {
+		Object.defineProperty(global, names[i], {get: getter(names[i])});
+	}
+})`
+
+// allowedHostsKey is the context key of the hosts a fetch request and its redirects may reach
+type allowedHostsKey struct{}
+
+// scriptEnvironment returns the environment overlay of a registered script (nil when it has none)
+func (p *Plugin) scriptEnvironment(script string) *ScriptEnvironmentConfig {
+	if script == "" || p.cfg.Scripts == nil {
+		return nil
+	}
+	return p.cfg.Scripts.Environments[script]
+}
+
+// overlayGlobals returns the overlay globals of every script (JSON-encoded) and their names in order
+func (p *Plugin) overlayGlobals() (map[string]map[string]string, []string) {
+	if p.cfg.Scripts == nil {
+		return nil, nil
+	}
+
+	values := make(map[string]map[string]string, len(p.cfg.Scripts.Environments))
+	var names []string
+	for script, env := range p.cfg.Scripts.Environments {
+		if env == nil || len(env.Globals) == 0 {
+			continue
+		}
+		values[script] = make(map[string]string, len(env.Globals))
+		for name, value := range env.Globals {
+			// Validated to be JSON-serializable
+			data, _ := json.Marshal(value)
+			values[script][name] = string(data)
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return values, names
+}
+
+// injectOverlays defines the overlay globals of all scripts in vm; they are undefined for executions of other code
+func (p *Plugin) injectOverlays(vm *otto.Otto) error {
+	if len(p.overlayNames) == 0 {
+		return nil
+	}
+
+	fn, err := vm.Run(overlayScript)
+	if err != nil {
+		return err
+	}
+	_, err = fn.Call(otto.UndefinedValue(), p.overlayNames, p.overlayLookup)
+	return err
+}
+
+// overlayLookup returns an overlay global of the running execution, parsed once per execution
+func (p *Plugin) overlayLookup(call otto.FunctionCall) otto.Value {
+	exec := p.executionFor(call.Otto)
+	if exec == nil {
+		return otto.UndefinedValue()
+	}
+	name := call.Argument(0).String()
+	if value, ok := exec.overlay[name]; ok {
+		return value
+	}
+
+	data, ok := p.overlayValues[exec.script][name]
+	if !ok {
+		return otto.UndefinedValue()
+	}
+	value, err := call.Otto.Call("JSON.parse", nil, data)
+	if err != nil {
+		return otto.UndefinedValue()
+	}
+	if value, err = call.Argument(1).Call(otto.UndefinedValue(), value); err != nil {
+		return otto.UndefinedValue()
+	}
+
+	if exec.overlay == nil {
+		exec.overlay = make(map[string]otto.Value)
+	}
+	exec.overlay[name] = value
+	return value
+}
+
+// allowedHosts returns the hosts fetch may reach for an execution: its script's overlay or fetch.allowed_hosts
+func (p *Plugin) allowedHosts(exec *execution) []string {
+	if exec != nil {
+		if env := p.scriptEnvironment(exec.script); env != nil && env.AllowedHosts != nil {
+			return env.AllowedHosts
+		}
+	}
+	return p.cfg.Fetch.AllowedHosts
+}
+
+// withAllowedHosts marks the hosts a request's redirects may reach
+func withAllowedHosts(ctx context.Context, hosts []string) context.Context {
+	return context.WithValue(ctx, allowedHostsKey{}, hosts)
+}
+
+// requestAllowedHosts returns the hosts marked on a request's context, or def
+func requestAllowedHosts(ctx context.Context, def []string) []string {
+	if hosts, ok := ctx.Value(allowedHostsKey{}).([]string); ok {
+		return hosts
+	}
+	return def
+}
+
+// cacheKey namespaces a cache key for the running execution: with the cache prefix of its script's overlay, or
+// per js.cache.namespace with the script name (ad-hoc code shares one namespace)
+func (p *Plugin) cacheKey(vm *otto.Otto, key string) string {
+	exec := p.executionFor(vm)
+	if exec != nil {
+		if env := p.scriptEnvironment(exec.script); env != nil && env.CachePrefix != "" {
+			return env.CachePrefix + key
+		}
+	}
+
+	if p.cfg.Cache.Namespace != cacheNamespaceScript {
+		return key
+	}
+	if exec == nil || exec.script == "" {
+		return cacheAdHocPrefix + key
+	}
+	return cacheScriptPrefix + exec.script + ":" + key
+}
@@ -0,0 +1,151 @@
+package jsmachine
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/robertkrimen/otto"
+)
+
+// execution carries per-run state that bindings need while a script runs on a VM
+type execution struct {
+	// Request ID supplied by the caller (may be empty)
+	requestID string
+
+	// W3C trace context for log correlation (hex encoded, may be empty)
+	traceID string
+	spanID  string
+
+	// Name and version (source hash) of the registered script being run (empty for ad-hoc code)
+	script  string
+	version string
+
+	// Caller identity supplied with the request (may be empty)
+	caller string
+
+	// Allowlisted purpose supplied with the request (may be empty)
+	tag string
+
+	// Program compiled when the script was registered (nil = compile the code before running it)
+	program *otto.Script
+
+	// JSON document exposed to the script as ctx.input (empty = undefined), parsed on first access
+	input      string
+	inputValue *otto.Value
+
+	// Overlay globals of the script read so far (only touched on the VM's goroutine)
+	overlay map[string]otto.Value
+
+	// Attempt number, starting at 1
+	attempt int
+
+	// Time the execution is interrupted, set once a VM is acquired
+	deadline time.Time
+
+	// Done when the execution times out or is cancelled, bounds blocking binding calls (set once a VM is acquired)
+	ctx context.Context
+
+	// Pool the execution runs in, selected by trust level
+	pool *vmPool
+
+	// Backpressure: executions queued for a VM when this one arrived (0 if a VM was idle) and time spent queued
+	queueDepth int
+	waited     time.Duration
+
+	// Mock mode: bindings with side effects (logging, metrics) are disabled
+	mock bool
+
+	// Set when the execution timed out or was cancelled (read by ctx.aborted() while the script still runs)
+	aborted     atomic.Bool
+	abortReason error
+
+	// Functions registered with ctx.onAbort (only touched on the VM's goroutine)
+	abortHandlers []otto.Value
+
+	// Bodies of responses fetched with {stream: true}, closed when the script stops running
+	streams []*fetchStream
+
+	// Output records added with emit(record), JSON-encoded
+	records []json.RawMessage
+
+	// Generator of the faker binding, created on first use
+	faker *gofakeit.Faker
+
+	// Hot-spot sampling (nil unless the request asked for a profile)
+	profiler *profiler
+
+	// Binding call timing (nil unless tracing is enabled)
+	tracer *tracer
+
+	// The result was returned after the execution was aborted
+	partial bool
+
+	// Final status (success, error, timeout), set when execution completes
+	status string
+}
+
+// scriptHash returns a short stable identifier for a script's source
+func scriptHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newRecordID returns a unique, time-ordered ID for stored records
+func newRecordID() string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return fmt.Sprintf("%016x-%s", time.Now().UnixNano(), hex.EncodeToString(suffix[:]))
+}
+
+// bindExecution associates an execution with the VM running it
+func (p *Plugin) bindExecution(vm *otto.Otto, exec *execution) {
+	p.executions.Store(vm, exec)
+}
+
+// unbindExecution removes the VM's execution before the VM is returned to the pool
+func (p *Plugin) unbindExecution(vm *otto.Otto) {
+	p.executions.Delete(vm)
+}
+
+// executionFor returns the execution currently running on vm (nil if none)
+func (p *Plugin) executionFor(vm *otto.Otto) *execution {
+	exec, ok := p.executions.Load(vm)
+	if !ok {
+		return nil
+	}
+	return exec.(*execution)
+}
+
+// profileLabels returns ctx with pprof labels attributing CPU time to the execution's script and request
+func (exec *execution) profileLabels(ctx context.Context) context.Context {
+	script := exec.script
+	if script == "" {
+		script = traceAdHoc
+	}
+
+	labels := []string{"js_pool", exec.pool.name, "js_script", script}
+	if exec.version != "" {
+		labels = append(labels, "js_script_version", exec.version)
+	}
+	if exec.requestID != "" {
+		labels = append(labels, "js_request_id", exec.requestID)
+	}
+	if exec.tag != "" {
+		labels = append(labels, "js_tag", exec.tag)
+	}
+	return pprof.WithLabels(ctx, pprof.Labels(labels...))
+}
+
+// mocked reports whether the execution running on vm is in mock mode
+func (p *Plugin) mocked(vm *otto.Otto) bool {
+	exec := p.executionFor(vm)
+	return exec != nil && exec.mock
+}
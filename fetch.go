@@ -0,0 +1,191 @@
+package jsmachine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fetchStatusError labels fetch requests that failed without a response (connection refused, timeout, ...)
+const fetchStatusError = "error"
+
+// Redirect policies of the fetch binding
+const (
+	redirectFollow   = "follow"    // follow redirects to allowed hosts
+	redirectSameHost = "same_host" // follow redirects to the host of the original request only
+	redirectNone     = "none"      // return redirect responses to the script
+)
+
+// newFetchClient creates the HTTP client shared by every fetch call, so connections are pooled across executions
+// and the per-host limit holds no matter how many VMs fetch from the same host
+// Connections only go to addresses permitted by the SSRF policy; proxies from the environment are ignored since
+// they would resolve hosts on the plugin's behalf
+// A non-nil profile adds its TLS identity, CA bundle, proxy and allowed ranges to the shared settings
+func newFetchClient(cfg *FetchConfig, profile *FetchClientConfig, requests *prometheus.CounterVec, duration *prometheus.HistogramVec) (*http.Client, error) {
+	cidrs := cfg.AllowedCIDRs
+	if profile != nil {
+		cidrs = append(slices.Clone(cidrs), profile.AllowedCIDRs...)
+	}
+	allowed, _ := parsePrefixes("fetch.allowed_cidrs", cidrs) // validated with the configuration
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		DialContext:           addressPolicy{allowed: allowed}.dialContext(dialer, net.DefaultResolver),
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(cfg.IdleConnTimeoutMs) * time.Millisecond,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+	if profile != nil {
+		if err := configureProfile(transport, dialer, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Client{
+		Transport: &instrumentedTransport{next: transport, requests: requests, duration: duration},
+		// Every redirect is checked like the original request
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch {
+			case cfg.Redirects == redirectNone || len(via) >= cfg.MaxRedirects:
+				return http.ErrUseLastResponse
+			case cfg.Redirects == redirectSameHost && !strings.EqualFold(req.URL.Host, via[0].URL.Host):
+				return fmt.Errorf("redirect to %s: %w", req.URL.Host, errRedirectDenied)
+			case !slices.Contains(cfg.AllowedSchemes, req.URL.Scheme):
+				return fmt.Errorf("redirect to %s: %w", req.URL.Scheme, errSchemeNotAllowed)
+			case !hostAllowed(strings.ToLower(req.URL.Hostname()), requestAllowedHosts(req.Context(), cfg.AllowedHosts)):
+				return fmt.Errorf("redirect to %s: %w", req.URL.Hostname(), errHostNotAllowed)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// configureProfile applies a client profile to its transport
+func configureProfile(transport *http.Transport, dialer *net.Dialer, profile *FetchClientConfig) error {
+	if profile.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(profile.CertFile, profile.KeyFile)
+		if err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if profile.CAFile != "" {
+		pem, err := os.ReadFile(profile.CAFile)
+		if err != nil {
+			return fmt.Errorf("CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA bundle: no certificates found in %s", profile.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	// Behind a proxy the plugin only connects to the proxy, which is trusted configuration; the proxy resolves and
+	// vets the hosts requested through it
+	if profile.Proxy != "" {
+		proxy, _ := url.Parse(profile.Proxy) // validated with the configuration
+		transport.Proxy = http.ProxyURL(proxy)
+		transport.DialContext = dialer.DialContext
+	}
+	return nil
+}
+
+// fetchClients holds the default fetch client and the clients of the configured profiles
+type fetchClients struct {
+	shared   *http.Client
+	profiles map[string]*http.Client
+}
+
+// newFetchClients creates the default client and one client per profile
+// Requests of profiles in a concurrency group hold a slot of the group (groups may be nil without groups)
+func newFetchClients(cfg *FetchConfig, groups *concurrencyLimiter, requests *prometheus.CounterVec, duration *prometheus.HistogramVec) (*fetchClients, error) {
+	shared, err := newFetchClient(cfg, nil, requests, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := &fetchClients{shared: shared, profiles: make(map[string]*http.Client, len(cfg.Clients))}
+	for name, profile := range cfg.Clients {
+		client, err := newFetchClient(cfg, profile, requests, duration)
+		if err != nil {
+			return nil, fmt.Errorf("fetch.clients.%s: %w", name, err)
+		}
+		if profile.ConcurrencyGroup != "" {
+			client.Transport = &groupTransport{next: client.Transport, limiter: groups, group: profile.ConcurrencyGroup}
+		}
+		clients.profiles[name] = client
+	}
+	return clients, nil
+}
+
+// get returns the client of a profile, or the default client for an empty name
+func (c *fetchClients) get(name string) (*http.Client, bool) {
+	if name == "" {
+		return c.shared, true
+	}
+	client, ok := c.profiles[name]
+	return client, ok
+}
+
+// closeIdle closes pooled connections of every client that are not in use
+func (c *fetchClients) closeIdle() {
+	closeIdle(c.shared)
+	for _, client := range c.profiles {
+		closeIdle(client)
+	}
+}
+
+// instrumentedTransport records requests and their latency by host
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+	t.duration.WithLabelValues(host).Observe(time.Since(start).Seconds())
+	if err != nil {
+		t.requests.WithLabelValues(host, fetchStatusError).Inc()
+		return nil, err
+	}
+	t.requests.WithLabelValues(host, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
+}
+
+// closeIdle closes pooled connections that are not in use
+func closeIdle(client *http.Client) {
+	transport := client.Transport
+	if t, ok := transport.(*groupTransport); ok {
+		transport = t.next
+	}
+	if t, ok := transport.(*instrumentedTransport); ok {
+		if next, ok := t.next.(*http.Transport); ok {
+			next.CloseIdleConnections()
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package jsmachine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/robertkrimen/otto"
+)
+
+// minStreamChunkBytes is the smallest fetch.stream_chunk_bytes, above the size of any UTF-8 character
+const minStreamChunkBytes = 64
+
+// Errors of streamed response bodies
+var (
+	errStreamTooLarge = errors.New("response exceeds fetch.max_stream_bytes")
+	errLineTooLong    = errors.New("line exceeds fetch.max_response_bytes")
+)
+
+// streamEachScript defines forEachChunk and forEachLine on a body object in JavaScript, so exceptions thrown by the
+// callback propagate unchanged
+const streamEachScript = `(function (body) {
+	function each(next, name) {
+		return function (fn) {
+			if (typeof fn !== "function") throw new TypeError("fetch: body." + name + ": callback must be a function");
+			var count = 0, value;
+			while ((value = next()) !== null) {
+				if (fn(value, count++) === false) { body.close(); break; }
+			}
+			return count;
+		};
+	}
+	body.forEachChunk = each(body.read, "forEachChunk");
+	body.forEachLine = each(body.readLine, "forEachLine");
+	return body;
+})`
+
+// fetchStream is the body of a response fetched with {stream: true}, read by the script in chunks or lines so only
+// the part being processed is held in memory
+// It is only used on the VM's goroutine; the execution closes it when the script did not
+type fetchStream struct {
+	resp    *http.Response
+	cancel  context.CancelFunc
+	reader  *bufio.Reader
+	maxLine int
+	closed  bool
+}
+
+// limitedBody fails reads once the body turns out to be longer than its limit, rather than ending it silently
+type limitedBody struct {
+	body io.Reader
+	left int64
+}
+
+// Read implements io.Reader
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		var probe [1]byte
+		n, err := b.body.Read(probe[:])
+		if n > 0 {
+			return 0, errStreamTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.body.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+// newFetchStream wraps the body of resp; cancel ends the request
+func newFetchStream(resp *http.Response, cancel context.CancelFunc, cfg *FetchConfig) *fetchStream {
+	body := &limitedBody{body: resp.Body, left: int64(cfg.MaxStreamBytes)}
+	return &fetchStream{
+		resp:    resp,
+		cancel:  cancel,
+		reader:  bufio.NewReaderSize(body, cfg.StreamChunkBytes),
+		maxLine: cfg.MaxResponseBytes,
+	}
+}
+
+// chunk returns the next chunk of at most fetch.stream_chunk_bytes, ending on a UTF-8 character boundary
+// It returns io.EOF once the body is exhausted or closed
+func (s *fetchStream) chunk() (string, error) {
+	if s.closed {
+		return "", io.EOF
+	}
+
+	// Wait for at least one byte, then take what arrived
+	want := 1
+	for {
+		_, err := s.reader.Peek(want)
+		data, _ := s.reader.Peek(s.reader.Buffered())
+		if len(data) == 0 {
+			return "", s.finish(err)
+		}
+		// The rest of the body is returned as is once it ended
+		n := len(data)
+		if err == nil {
+			n = completeRunes(data)
+		}
+		if n > 0 {
+			chunk := string(data[:n])
+			_, _ = s.reader.Discard(n)
+			return chunk, nil
+		}
+		// Only the start of a multi-byte character is buffered
+		want = len(data) + 1
+	}
+}
+
+// line returns the next line without its line ending, io.EOF once the body is exhausted or closed
+func (s *fetchStream) line() (string, error) {
+	if s.closed {
+		return "", io.EOF
+	}
+
+	var line []byte
+	for {
+		data, err := s.reader.ReadSlice('\n')
+		line = append(line, data...)
+		if len(line) > s.maxLine+len("\r\n") {
+			return "", errLineTooLong
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && (len(line) == 0 || !errors.Is(err, io.EOF)) {
+			return "", s.finish(err)
+		}
+		break
+	}
+
+	n := len(line)
+	if n > 0 && line[n-1] == '\n' {
+		n--
+		if n > 0 && line[n-1] == '\r' {
+			n--
+		}
+	}
+	if n > s.maxLine {
+		return "", errLineTooLong
+	}
+	return string(line[:n]), nil
+}
+
+// finish closes the stream at the end of the body and returns err
+func (s *fetchStream) finish(err error) error {
+	if errors.Is(err, io.EOF) {
+		s.close()
+	}
+	return err
+}
+
+// close releases the connection of the response
+func (s *fetchStream) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	_ = s.resp.Body.Close()
+	s.cancel()
+}
+
+// completeRunes returns the length of the longest prefix of data not ending within a UTF-8 character
+func completeRunes(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if utf8.FullRune(data[i:]) {
+				return len(data)
+			}
+			return i
+		}
+	}
+	return len(data)
+}
+
+// streamBody returns the body object of a streamed response, registering the stream with the execution
+func (f *FetchBinding) streamBody(vm *otto.Otto, exec *execution, stream *fetchStream) otto.Value {
+	exec.streams = append(exec.streams, stream)
+
+	obj, err := vm.Object(`({})`)
+	if err != nil {
+		stream.close()
+		panic(vm.MakeCustomError("Error", "fetch: "+err.Error()))
+	}
+
+	// read() - returns the next chunk, or null at the end of the body
+	_ = obj.Set("read", func(call otto.FunctionCall) otto.Value {
+		return streamValue(call.Otto, stream.chunk)
+	})
+
+	// readLine() - returns the next line without its line ending, or null at the end of the body
+	_ = obj.Set("readLine", func(call otto.FunctionCall) otto.Value {
+		return streamValue(call.Otto, stream.line)
+	})
+
+	// close() - releases the connection before the body was read to the end
+	_ = obj.Set("close", func(call otto.FunctionCall) otto.Value {
+		stream.close()
+		return otto.UndefinedValue()
+	})
+
+	// forEachChunk(fn) / forEachLine(fn) - call fn(value, index) until the end, or until it returns false
+	each, err := vm.Run(streamEachScript)
+	if err != nil {
+		stream.close()
+		panic(vm.MakeCustomError("Error", "fetch: "+err.Error()))
+	}
+	body, err := each.Call(otto.UndefinedValue(), obj)
+	if err != nil {
+		stream.close()
+		panic(vm.MakeCustomError("Error", "fetch: "+err.Error()))
+	}
+	return body
+}
+
+// streamValue converts the next value read from a stream, null at its end
+func streamValue(vm *otto.Otto, next func() (string, error)) otto.Value {
+	value, err := next()
+	if errors.Is(err, io.EOF) {
+		return otto.NullValue()
+	}
+	if err != nil {
+		panic(vm.MakeCustomError("Error", "fetch: "+err.Error()))
+	}
+	result, _ := vm.ToValue(value)
+	return result
+}
+
+// closeStreams releases the response bodies an execution left open
+func (exec *execution) closeStreams() {
+	for _, stream := range exec.streams {
+		stream.close()
+	}
+	exec.streams = nil
+}
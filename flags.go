@@ -0,0 +1,209 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flagDefinition is a feature flag: a default value and targeting rules evaluated against the caller's context
+// In flag sources a definition is an object with a "value" key; any other JSON value is shorthand for {"value": v}
+type flagDefinition struct {
+	Value interface{} `json:"value"`
+	Rules []flagRule  `json:"rules,omitempty"`
+}
+
+// flagRule returns Value when the context attribute equals one of In
+type flagRule struct {
+	Attribute string        `json:"attribute"`
+	In        []interface{} `json:"in"`
+	Value     interface{}   `json:"value"`
+}
+
+// UnmarshalJSON accepts both full definitions and plain values
+func (d *flagDefinition) UnmarshalJSON(data []byte) error {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err == nil {
+		if _, ok := probe["value"]; ok {
+			type plain flagDefinition
+			return json.Unmarshal(data, (*plain)(d))
+		}
+	}
+	return json.Unmarshal(data, &d.Value)
+}
+
+// evaluate resolves the flag's value for an evaluation context
+func (d *flagDefinition) evaluate(evalCtx map[string]interface{}) interface{} {
+	for _, rule := range d.Rules {
+		attr, ok := evalCtx[rule.Attribute]
+		if !ok {
+			continue
+		}
+		for _, candidate := range rule.In {
+			if fmt.Sprint(candidate) == fmt.Sprint(attr) {
+				return rule.Value
+			}
+		}
+	}
+	return d.Value
+}
+
+// flagProvider loads flag definitions from a source
+type flagProvider interface {
+	load(ctx context.Context) (map[string]*flagDefinition, error)
+}
+
+// newFlagProvider creates the provider for the configured source
+func newFlagProvider(cfg *FlagsConfig) (flagProvider, error) {
+	switch cfg.Provider {
+	case "file":
+		return &fileFlagProvider{path: cfg.Path}, nil
+	case "env":
+		return &envFlagProvider{prefix: cfg.Prefix}, nil
+	case "http":
+		return &httpFlagProvider{
+			url:     cfg.URL,
+			headers: cfg.Headers,
+			client:  &http.Client{Timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown flags provider %q", cfg.Provider)
+	}
+}
+
+// fileFlagProvider reads a JSON file mapping flag names to definitions
+type fileFlagProvider struct {
+	path string
+}
+
+func (f *fileFlagProvider) load(context.Context) (map[string]*flagDefinition, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags map[string]*flagDefinition
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("invalid flags file: %w", err)
+	}
+	return flags, nil
+}
+
+// envFlagProvider reads flags from environment variables: PREFIX_NEW_CHECKOUT=true defines new_checkout
+// Values are parsed as JSON when possible and used as strings otherwise
+type envFlagProvider struct {
+	prefix string
+}
+
+func (e *envFlagProvider) load(context.Context) (map[string]*flagDefinition, error) {
+	flags := make(map[string]*flagDefinition)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, e.prefix) || len(key) == len(e.prefix) {
+			continue
+		}
+
+		def := &flagDefinition{}
+		if err := json.Unmarshal([]byte(value), &def.Value); err != nil {
+			def.Value = value
+		}
+		flags[strings.ToLower(strings.TrimPrefix(key, e.prefix))] = def
+	}
+	return flags, nil
+}
+
+// httpFlagProvider fetches the same JSON document as the file provider from a URL
+type httpFlagProvider struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (h *httpFlagProvider) load(ctx context.Context) (map[string]*flagDefinition, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("flags provider responded with %s", resp.Status)
+	}
+
+	var flags map[string]*flagDefinition
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, fmt.Errorf("invalid flags document: %w", err)
+	}
+	return flags, nil
+}
+
+// flagStore caches the provider's flags for the configured TTL
+// When a refresh fails the previous flags are kept and the error is logged
+type flagStore struct {
+	provider flagProvider
+	ttl      time.Duration
+	log      *zap.Logger
+
+	mu       sync.Mutex
+	flags    map[string]*flagDefinition
+	loadedAt time.Time
+}
+
+// newFlagStore creates a store for the configured provider
+func newFlagStore(cfg *FlagsConfig, log *zap.Logger) (*flagStore, error) {
+	provider, err := newFlagProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &flagStore{
+		provider: provider,
+		ttl:      time.Duration(cfg.CacheTTLMs) * time.Millisecond,
+		log:      log,
+	}, nil
+}
+
+// invalidate drops the cached flags so the next lookup reloads them from the provider
+func (s *flagStore) invalidate() {
+	s.mu.Lock()
+	s.flags = nil
+	s.mu.Unlock()
+}
+
+// get returns a flag definition, refreshing the cache when it is stale
+func (s *flagStore) get(name string) (*flagDefinition, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flags == nil || time.Since(s.loadedAt) >= s.ttl {
+		flags, err := s.provider.load(context.Background())
+		if err != nil {
+			s.log.Warn("failed to load feature flags", zap.Error(err))
+		} else {
+			s.flags = flags
+		}
+		// Failures are retried after the TTL as well, so a broken provider is not hit on every call
+		s.loadedAt = time.Now()
+	}
+
+	def, ok := s.flags[name]
+	return def, ok && def != nil
+}
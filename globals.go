@@ -0,0 +1,68 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// globalNamePattern matches names usable as JavaScript globals
+var globalNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// validateGlobals checks that js.globals can be injected
+func validateGlobals(globals map[string]interface{}) error {
+	for name, value := range globals {
+		if !globalNamePattern.MatchString(name) {
+			return fmt.Errorf("globals: %q is not a valid identifier", name)
+		}
+		for _, binding := range bindingNames {
+			if name == binding {
+				return fmt.Errorf("globals: %q conflicts with the %s binding", name, binding)
+			}
+		}
+		if name == bindingNamespace {
+			return fmt.Errorf("globals: %q conflicts with the binding namespace", name)
+		}
+		if _, err := json.Marshal(value); err != nil {
+			return fmt.Errorf("globals: %q is not JSON-serializable: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// globalsScript builds the script defining js.globals as frozen, read-only globals
+func globalsScript(globals map[string]interface{}) (string, error) {
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("(function (global) {\n")
+	sb.WriteString("function freeze(v) { if (v !== null && typeof v === 'object') { for (var k in v) { freeze(v[k]); } Object.freeze(v); } return v; }\n")
+	for _, name := range names {
+		value, err := json.Marshal(globals[name])
+		if err != nil {
+			return "", fmt.Errorf("global %s: %w", name, err)
+		}
+		// Non-writable and non-configurable, so scripts cannot change them for later executions on the same VM
+		fmt.Fprintf(&sb, "Object.defineProperty(global, %q, {value: freeze(%s), enumerable: true});\n", name, value)
+	}
+	sb.WriteString("})(this);")
+
+	return sb.String(), nil
+}
+
+// injectGlobals defines js.globals in the VM
+func injectGlobals(vm *otto.Otto, script string) error {
+	if script == "" {
+		return nil
+	}
+	_, err := vm.Run(script)
+	return err
+}
@@ -3,13 +3,43 @@ module github.com/roadrunner-plugins/js-machine
 go 1.23
 
 require (
+	github.com/Masterminds/semver/v3 v3.5.0
+	github.com/brianvoe/gofakeit/v7 v7.14.0
+	github.com/eclipse/paho.golang v0.22.0
+	github.com/evanphx/json-patch/v5 v5.9.11
+	github.com/getsentry/sentry-go v0.29.1
+	github.com/gorilla/websocket v1.5.3
+	github.com/jmespath/go-jmespath v0.4.0
+	github.com/ledongthuc/pdf v0.0.0-20240201131950-da5b75280b06
+	github.com/mssola/useragent v1.0.0
+	github.com/nyaruka/phonenumbers v1.5.0
+	github.com/ohler55/ojg v1.22.0
 	github.com/prometheus/client_golang v1.20.0
+	github.com/redis/go-redis/v9 v9.7.3
 	github.com/roadrunner-server/api/v4 v4.0.0
 	github.com/roadrunner-server/endure/v2 v2.0.0
 	github.com/robertkrimen/otto v0.4.0
+	github.com/shopspring/decimal v1.4.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	go.uber.org/zap v1.27.0
+	golang.org/x/text v0.16.0
+	google.golang.org/grpc v1.65.0
+	google.golang.org/protobuf v1.34.2
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/exp v0.0.0-20240525044651-4c93da0ed11d // indirect
+	golang.org/x/net v0.27.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 )
@@ -0,0 +1,263 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// graphqlPersistedQueryNotFound is the error servers return for a persisted query hash they do not know yet
+const graphqlPersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+
+// graphqlOperation is one GraphQL request as sent to the server
+type graphqlOperation struct {
+	Query         string          `json:"query,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	OperationName string          `json:"operationName,omitempty"`
+	Extensions    map[string]any  `json:"extensions,omitempty"`
+}
+
+// graphqlResponse is a GraphQL response as servers send it
+type graphqlResponse struct {
+	Data       json.RawMessage   `json:"data"`
+	Errors     []json.RawMessage `json:"errors"`
+	Extensions json.RawMessage   `json:"extensions"`
+}
+
+// graphqlResult is a response normalized for scripts: errors is always an array and every error has the same shape
+type graphqlResult struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     []graphqlError  `json:"errors"`
+	Extensions json.RawMessage `json:"extensions,omitempty"`
+}
+
+// graphqlError is a normalized GraphQL error
+type graphqlError struct {
+	Message string `json:"message"`
+
+	// extensions.code, e.g. UNAUTHENTICATED (empty when the server sets none)
+	Code string `json:"code"`
+
+	// Response path joined with dots, e.g. orders.0.total (empty for request errors)
+	Path string `json:"path"`
+
+	Locations  []graphqlLocation `json:"locations"`
+	Extensions json.RawMessage   `json:"extensions,omitempty"`
+}
+
+// graphqlLocation is a position in the query an error refers to
+type graphqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// graphqlClient sends operations to a configured endpoint
+type graphqlClient struct {
+	endpoint *GraphQLEndpointConfig
+	client   *http.Client
+	maxBytes int
+	headers  map[string]string
+}
+
+// execute sends operations and returns their normalized results in order
+// Batches are sent as one request when the endpoint supports batching, one request per operation otherwise;
+// with persisted queries, only hashes are sent first and queries the server does not know yet are sent again in full
+func (c *graphqlClient) execute(ctx context.Context, operations []*graphqlOperation) ([]*graphqlResult, error) {
+	queries := make([]string, len(operations))
+	if c.endpoint.PersistedQueries {
+		for i, op := range operations {
+			queries[i] = op.Query
+			sum := sha256.Sum256([]byte(op.Query))
+			op.Extensions = map[string]any{"persistedQuery": map[string]any{"version": 1, "sha256Hash": hex.EncodeToString(sum[:])}}
+			op.Query = ""
+		}
+	}
+
+	results, err := c.send(ctx, operations)
+	if err != nil || !c.endpoint.PersistedQueries {
+		return results, err
+	}
+
+	var retry []*graphqlOperation
+	var indexes []int
+	for i, result := range results {
+		if persistedQueryMissing(result) {
+			operations[i].Query = queries[i]
+			retry = append(retry, operations[i])
+			indexes = append(indexes, i)
+		}
+	}
+	if len(retry) == 0 {
+		return results, nil
+	}
+	retried, err := c.send(ctx, retry)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range retried {
+		results[indexes[i]] = result
+	}
+	return results, nil
+}
+
+// send posts operations, in batches of at most max_batch_size when batching is enabled
+func (c *graphqlClient) send(ctx context.Context, operations []*graphqlOperation) ([]*graphqlResult, error) {
+	size := 1
+	if c.endpoint.Batching {
+		size = c.endpoint.MaxBatchSize
+	}
+
+	results := make([]*graphqlResult, 0, len(operations))
+	for start := 0; start < len(operations); start += size {
+		chunk := operations[start:min(start+size, len(operations))]
+
+		var payload any = chunk[0]
+		if c.endpoint.Batching && len(operations) > 1 {
+			payload = chunk
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.post(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+
+		responses := make([]*graphqlResponse, 0, len(chunk))
+		if _, batched := payload.([]*graphqlOperation); batched {
+			if err := json.Unmarshal(data, &responses); err != nil {
+				return nil, fmt.Errorf("invalid batch response: %w", err)
+			}
+			if len(responses) != len(chunk) {
+				return nil, fmt.Errorf("batch of %d operations got %d responses", len(chunk), len(responses))
+			}
+		} else {
+			response := &graphqlResponse{}
+			if err := json.Unmarshal(data, response); err != nil {
+				return nil, fmt.Errorf("invalid response: %w", err)
+			}
+			responses = append(responses, response)
+		}
+
+		for _, response := range responses {
+			results = append(results, normalizeGraphQL(response))
+		}
+	}
+	return results, nil
+}
+
+// post sends one HTTP request and returns the response body
+// Non-2xx responses carrying a GraphQL body are returned like 200 ones, since servers report errors both ways
+func (c *graphqlClient) post(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/graphql-response+json, application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read one byte more than allowed to tell a body of exactly the limit from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(c.maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > c.maxBytes {
+		return nil, errors.New("response exceeds fetch.max_response_bytes")
+	}
+
+	if resp.StatusCode >= 300 {
+		trimmed := bytes.TrimSpace(data)
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/") || len(trimmed) == 0 ||
+			(trimmed[0] != '{' && trimmed[0] != '[') {
+			return nil, fmt.Errorf("server responded with %s", resp.Status)
+		}
+	}
+	return data, nil
+}
+
+// normalizeGraphQL converts a response into the shape returned to scripts
+func normalizeGraphQL(response *graphqlResponse) *graphqlResult {
+	result := &graphqlResult{
+		Data:       response.Data,
+		Errors:     make([]graphqlError, 0, len(response.Errors)),
+		Extensions: response.Extensions,
+	}
+	if len(result.Data) == 0 {
+		result.Data = json.RawMessage("null")
+	}
+
+	for _, raw := range response.Errors {
+		var parsed struct {
+			Message    string            `json:"message"`
+			Path       []any             `json:"path"`
+			Locations  []graphqlLocation `json:"locations"`
+			Extensions json.RawMessage   `json:"extensions"`
+		}
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			// Some servers send bare strings as errors
+			var message string
+			if json.Unmarshal(raw, &message) != nil {
+				message = string(raw)
+			}
+			parsed.Message = message
+		}
+
+		normalized := graphqlError{
+			Message:    parsed.Message,
+			Locations:  parsed.Locations,
+			Extensions: parsed.Extensions,
+		}
+		if normalized.Locations == nil {
+			normalized.Locations = []graphqlLocation{}
+		}
+		var extensions struct {
+			Code string `json:"code"`
+		}
+		if len(parsed.Extensions) > 0 && json.Unmarshal(parsed.Extensions, &extensions) == nil {
+			normalized.Code = extensions.Code
+		}
+		segments := make([]string, 0, len(parsed.Path))
+		for _, segment := range parsed.Path {
+			switch s := segment.(type) {
+			case string:
+				segments = append(segments, s)
+			case float64:
+				segments = append(segments, strconv.Itoa(int(s)))
+			}
+		}
+		normalized.Path = strings.Join(segments, ".")
+		result.Errors = append(result.Errors, normalized)
+	}
+	return result
+}
+
+// persistedQueryMissing reports whether the server asked for the full query of a persisted query
+func persistedQueryMissing(result *graphqlResult) bool {
+	for _, err := range result.Errors {
+		if err.Code == graphqlPersistedQueryNotFound || err.Message == "PersistedQueryNotFound" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package jsmachine
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/js/v1/js.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	jsv1 "github.com/roadrunner-plugins/js-machine/proto/js/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServiceRegistrar is implemented by the RR gRPC plugin and serves the services registered with it
+type grpcServiceRegistrar interface {
+	grpc.ServiceRegistrar
+}
+
+// grpcServer implements the js.v1.JSMachine service of proto/js/v1/js.proto, mirroring the RPC API
+type grpcServer struct {
+	jsv1.UnimplementedJSMachineServer
+
+	rpc *rpc
+	log *zap.Logger
+
+	// Server of the js.grpc.listen endpoint (nil when the service is registered with the RR gRPC plugin)
+	server *grpc.Server
+}
+
+// newGRPCServer creates the service; it is served by listen or registered with register
+func newGRPCServer(p *Plugin) *grpcServer {
+	return &grpcServer{
+		rpc: &rpc{plugin: p, log: p.log},
+		log: p.log,
+	}
+}
+
+// register adds the service to a server that is not ours, such as the RR gRPC plugin's
+func (s *grpcServer) register(registrar grpc.ServiceRegistrar) {
+	jsv1.RegisterJSMachineServer(registrar, s)
+}
+
+// serve listens on address until the server is stopped
+func (s *grpcServer) serve(address string) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	s.server = grpc.NewServer()
+	jsv1.RegisterJSMachineServer(s.server, s)
+	s.log.Info("gRPC server started", zap.String("address", address))
+
+	if err := s.server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// stop drains in-flight calls of the js.grpc.listen endpoint, falling back to a hard stop when ctx expires
+func (s *grpcServer) stop(ctx context.Context) {
+	if s.server == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+}
+
+// Execute implements the unary Execute call
+func (s *grpcServer) Execute(ctx context.Context, in *jsv1.ExecuteRequest) (*jsv1.ExecuteResponse, error) {
+	return s.execute(ctx, in)
+}
+
+// ExecuteStream implements the bidirectional ExecuteStream call
+// Requests are executed concurrently, at most as many as the pools have VMs, and responses are sent as they
+// complete; while all slots are taken, no further request is read from the stream
+func (s *grpcServer) ExecuteStream(stream jsv1.JSMachine_ExecuteStreamServer) error {
+	var (
+		wg     sync.WaitGroup
+		sendMu sync.Mutex
+		errMu  sync.Mutex
+		outErr error
+	)
+
+	slots := 0
+	for _, pool := range s.rpc.plugin.pools() {
+		slots += pool.size
+	}
+	sem := make(chan struct{}, slots)
+
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-stream.Context().Done():
+			wg.Wait()
+			return stream.Context().Err()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			out, err := s.execute(stream.Context(), in)
+			if err != nil {
+				// Invalid requests are reported inline so the stream keeps going
+				out = s.errorResponse(in, err)
+			}
+
+			sendMu.Lock()
+			err = stream.Send(out)
+			sendMu.Unlock()
+
+			if err != nil {
+				errMu.Lock()
+				if outErr == nil {
+					outErr = err
+				}
+				errMu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return outErr
+}
+
+// execute converts the request, runs it through the RPC handler and converts the response
+func (s *grpcServer) execute(ctx context.Context, in *jsv1.ExecuteRequest) (*jsv1.ExecuteResponse, error) {
+	req := &ExecuteRequest{
+		Code:            in.GetCode(),
+		TimeoutMs:       int(in.GetTimeoutMs()),
+		RequestID:       in.GetRequestId(),
+		Script:          in.GetScript(),
+		Caller:          in.GetCaller(),
+		Tag:             in.GetTag(),
+		DedupeKey:       in.GetDedupeKey(),
+		Profile:         in.GetProfile(),
+		AcceptResultRef: in.GetAcceptResultRef(),
+	}
+
+	// Trace context travels in metadata, as with HTTP headers
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tp := md.Get("traceparent"); len(tp) > 0 {
+			req.TraceParent = tp[0]
+		}
+	}
+
+	resp := &ExecuteResponse{}
+	if err := s.rpc.handleExecute(ctx, req, resp); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.AcceptResultRef && s.rpc.plugin.results != nil && resp.Error == "" {
+		s.rpc.plugin.results.offload(resp)
+	}
+
+	return toMessage(resp), nil
+}
+
+// toMessage converts an ExecuteResponse to its protobuf representation
+func toMessage(resp *ExecuteResponse) *jsv1.ExecuteResponse {
+	out := &jsv1.ExecuteResponse{
+		DurationMs:   resp.DurationMs,
+		RequestId:    resp.RequestID,
+		QueueDepth:   int32(resp.QueueDepth),
+		WaitedMs:     resp.WaitedMs,
+		RetryAfterMs: resp.RetryAfterMs,
+		Partial:      resp.Partial,
+		ErrorCode:    resp.ErrorCode,
+		Deduplicated: resp.Deduplicated,
+		Attempts:     int32(resp.Attempts),
+		AuditId:      resp.AuditID,
+	}
+
+	if (resp.Error == "" || resp.Partial) && resp.ResultRef == nil {
+		data, err := json.Marshal(resp.Result)
+		if err != nil {
+			resp.Error = fmt.Sprintf("failed to encode result: %v", err)
+		} else {
+			out.ResultJson = string(data)
+		}
+	}
+	out.Error = resp.Error
+
+	for _, record := range resp.Records {
+		out.RecordsJson = append(out.RecordsJson, string(record))
+	}
+	for _, v := range resp.Violations {
+		out.Violations = append(out.Violations, &jsv1.FieldViolation{Field: v.Field, Code: v.Code, Message: v.Message})
+	}
+	if p := resp.Profile; p != nil {
+		out.Profile = &jsv1.ProfileReport{Samples: int32(p.Samples), IntervalUs: int32(p.IntervalUs)}
+		for _, h := range p.Hotspots {
+			out.Profile.Hotspots = append(out.Profile.Hotspots, &jsv1.Hotspot{
+				Function: h.Function,
+				Line:     int32(h.Line),
+				Samples:  int32(h.Samples),
+				Percent:  h.Percent,
+			})
+		}
+	}
+	if ref := resp.ResultRef; ref != nil {
+		out.ResultRef = &jsv1.ResultRef{
+			Id:        ref.ID,
+			Kind:      ref.Kind,
+			Total:     int64(ref.Total),
+			Size:      int64(ref.Size),
+			ExpiresAt: timestamppb.New(ref.ExpiresAt),
+		}
+	}
+
+	return out
+}
+
+// errorResponse builds a response carrying err for the given request
+func (s *grpcServer) errorResponse(in *jsv1.ExecuteRequest, err error) *jsv1.ExecuteResponse {
+	if st, ok := status.FromError(err); ok {
+		return toMessage(&ExecuteResponse{Error: st.Message(), RequestID: in.GetRequestId()})
+	}
+	return toMessage(&ExecuteResponse{Error: err.Error(), RequestID: in.GetRequestId()})
+}
@@ -0,0 +1,241 @@
+package jsmachine
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// grpcTarget is a configured gRPC server scripts call with grpc.call
+type grpcTarget struct {
+	cfg  *GRPCTargetConfig
+	conn *grpc.ClientConn
+
+	// Descriptors from the descriptor set, nil for targets using server reflection
+	files *protoregistry.Files
+
+	mu      sync.Mutex
+	methods map[string]protoreflect.MethodDescriptor // by full method name, e.g. orders.v1.Orders/GetOrder
+}
+
+// grpcTargets holds the connections of every configured target
+type grpcTargets struct {
+	targets map[string]*grpcTarget
+}
+
+// newGRPCTargets loads descriptor sets and creates the connections; servers are only dialed on the first call
+// Calls to targets in a concurrency group hold a slot of the group (groups may be nil without groups)
+func newGRPCTargets(cfg map[string]*GRPCTargetConfig, groups *concurrencyLimiter) (*grpcTargets, error) {
+	t := &grpcTargets{targets: make(map[string]*grpcTarget, len(cfg))}
+	for name, targetCfg := range cfg {
+		target, err := newGRPCTarget(targetCfg, groups)
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("grpc_client.targets.%s: %w", name, err)
+		}
+		t.targets[name] = target
+	}
+	return t, nil
+}
+
+// newGRPCTarget creates the connection of one target
+func newGRPCTarget(cfg *GRPCTargetConfig, groups *concurrencyLimiter) (*grpcTarget, error) {
+	creds := insecure.NewCredentials()
+	if cfg.TLS != nil {
+		tlsConfig, err := grpcTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	target := &grpcTarget{cfg: cfg, methods: make(map[string]protoreflect.MethodDescriptor)}
+	if cfg.DescriptorSet != "" {
+		data, err := os.ReadFile(cfg.DescriptorSet)
+		if err != nil {
+			return nil, err
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(data, set); err != nil {
+			return nil, fmt.Errorf("descriptor set: %w", err)
+		}
+		if target.files, err = buildFiles(set.GetFile()); err != nil {
+			return nil, fmt.Errorf("descriptor set: %w", err)
+		}
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if cfg.ConcurrencyGroup != "" {
+		opts = append(opts, grpc.WithUnaryInterceptor(groupInterceptor(groups, cfg.ConcurrencyGroup)))
+	}
+	conn, err := grpc.NewClient(cfg.Addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	target.conn = conn
+	return target, nil
+}
+
+// grpcTLSConfig builds the TLS settings of a target
+func grpcTLSConfig(cfg *GRPCTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: cfg.ServerName}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle: no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// get returns a configured target
+func (t *grpcTargets) get(name string) (*grpcTarget, bool) {
+	target, ok := t.targets[name]
+	return target, ok
+}
+
+// invalidate drops the method descriptors resolved through server reflection
+func (t *grpcTargets) invalidate() {
+	for _, target := range t.targets {
+		if target.files == nil {
+			target.mu.Lock()
+			clear(target.methods)
+			target.mu.Unlock()
+		}
+	}
+}
+
+// close closes every connection
+func (t *grpcTargets) close() {
+	for _, target := range t.targets {
+		_ = target.conn.Close()
+	}
+}
+
+// method returns the descriptor of a unary method given as "package.Service/Method" (a leading slash is accepted)
+func (t *grpcTarget) method(ctx context.Context, fullMethod string) (protoreflect.MethodDescriptor, error) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	service, name, ok := strings.Cut(fullMethod, "/")
+	if !ok || service == "" || name == "" {
+		return nil, fmt.Errorf("method must be package.Service/Method, got %q", fullMethod)
+	}
+
+	t.mu.Lock()
+	md, ok := t.methods[fullMethod]
+	t.mu.Unlock()
+	if ok {
+		return md, nil
+	}
+
+	files := t.files
+	if files == nil {
+		var err error
+		if files, err = t.reflect(ctx, service); err != nil {
+			return nil, fmt.Errorf("server reflection: %w", err)
+		}
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("unknown service %s", service)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	md = sd.Methods().ByName(protoreflect.Name(name))
+	if md == nil {
+		return nil, fmt.Errorf("service %s has no method %s", service, name)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%s is a streaming method, only unary methods can be called", fullMethod)
+	}
+
+	t.mu.Lock()
+	t.methods[fullMethod] = md
+	t.mu.Unlock()
+	return md, nil
+}
+
+// reflect fetches the file defining service and its dependencies with the server reflection API
+func (t *grpcTarget) reflect(ctx context.Context, service string) (*protoregistry.Files, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(t.conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, errors.New(e.GetErrorMessage())
+	}
+
+	var protos []*descriptorpb.FileDescriptorProto
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, fd); err != nil {
+			return nil, err
+		}
+		protos = append(protos, fd)
+	}
+	return buildFiles(protos)
+}
+
+// buildFiles builds a registry from file descriptors; dependencies that are missing, such as the well-known types
+// of descriptor sets built without --include_imports, are taken from the files compiled into the plugin
+func buildFiles(protos []*descriptorpb.FileDescriptorProto) (*protoregistry.Files, error) {
+	known := make(map[string]bool, len(protos))
+	for _, fd := range protos {
+		known[fd.GetName()] = true
+	}
+	for i := 0; i < len(protos); i++ {
+		for _, dep := range protos[i].GetDependency() {
+			if known[dep] {
+				continue
+			}
+			global, err := protoregistry.GlobalFiles.FindFileByPath(dep)
+			if err != nil {
+				return nil, fmt.Errorf("missing dependency %s", dep)
+			}
+			known[dep] = true
+			protos = append(protos, protodesc.ToFileDescriptorProto(global))
+		}
+	}
+	return protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: protos})
+}
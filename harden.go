@@ -0,0 +1,166 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+)
+
+// maxHardenDepth bounds the walk over global objects looking for Go values (bindings are shallow)
+const maxHardenDepth = 8
+
+// hardenScript deep-freezes every enumerable global and makes it non-writable, so an execution cannot replace or
+// patch a binding (or a js.globals constant) for the executions that later reuse the VM
+const hardenScript = `(function () {
+	var global = Function("return this")();
+	var seen = [];
+	function freeze(value) {
+		if (value === null || (typeof value !== "object" && typeof value !== "function") || seen.indexOf(value) >= 0) {
+			return;
+		}
+		seen.push(value);
+		// Object.getOwnPropertyDescriptor panics on accessors in otto, read the values instead; the built-in
+		// properties of functions (caller, prototype) are slow to read and hold no binding state
+		var names = typeof value === "function" ? Object.keys(value) : Object.getOwnPropertyNames(value);
+		for (var i = 0; i < names.length; i++) {
+			freeze(value[names[i]]);
+		}
+		Object.freeze(value);
+	}
+	var names = Object.keys(global);
+	for (var i = 0; i < names.length; i++) {
+		freeze(global[names[i]]);
+		Object.defineProperty(global, names[i], {writable: false, configurable: false});
+	}
+})()`
+
+// builtinNames are the standard globals frozen with js.freeze_builtins
+var builtinNames = []string{
+	"Object", "Function", "Array", "String", "Boolean", "Number", "Math", "Date", "RegExp", "JSON",
+	"Error", "EvalError", "RangeError", "ReferenceError", "SyntaxError", "TypeError", "URIError",
+	"parseInt", "parseFloat", "isNaN", "isFinite", "decodeURI", "decodeURIComponent", "encodeURI",
+	"encodeURIComponent", "escape", "unescape", "eval",
+}
+
+// freezeBuiltinsScript freezes the built-ins named by its argument, their static members and prototypes, and
+// makes the globals holding them non-writable
+const freezeBuiltinsScript = `(function (names) {
+	var global = Function("return this")();
+	function freeze(value) {
+		var keys = Object.getOwnPropertyNames(value);
+		for (var i = 0; i < keys.length; i++) {
+			var member = value[keys[i]];
+			if (member !== null && (typeof member === "object" || typeof member === "function") && member !== value) {
+				Object.freeze(member);
+			}
+		}
+		Object.freeze(value);
+	}
+	for (var i = 0; i < names.length; i++) {
+		var builtin = global[names[i]];
+		if (typeof builtin === "function" && builtin.prototype) {
+			freeze(builtin.prototype);
+		}
+		freeze(builtin);
+		Object.defineProperty(global, names[i], {writable: false, configurable: false});
+	}
+})`
+
+// HardenVM strips escape hatches from a VM once its bindings and globals are injected: Go values reachable from
+// globals are replaced by plain JavaScript copies, so scripts cannot call methods of the Go objects behind them,
+// and every global is deep-frozen, so state cannot leak from one execution to the next through a shared binding
+// Globals defined after HardenVM are not affected
+func HardenVM(vm *otto.Otto) error {
+	global, err := vm.Object(`Function("return this")()`)
+	if err != nil {
+		return err
+	}
+
+	names, err := globalNames(vm)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := stripGoValues(vm, global, name, maxHardenDepth); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	_, err = vm.Run(hardenScript)
+	return err
+}
+
+// freezeBuiltins freezes the standard built-ins of a VM (js.freeze_builtins)
+// Assigning a property that an object inherits from a frozen prototype, e.g. toString, fails silently afterwards
+func freezeBuiltins(vm *otto.Otto) error {
+	fn, err := vm.Run(freezeBuiltinsScript)
+	if err != nil {
+		return err
+	}
+	_, err = fn.Call(otto.UndefinedValue(), builtinNames)
+	return err
+}
+
+// globalNames returns the enumerable globals of a VM: its bindings and js.globals constants
+func globalNames(vm *otto.Otto) ([]string, error) {
+	value, err := vm.Run(`Object.keys(Function("return this")())`)
+	if err != nil {
+		return nil, err
+	}
+	exported, err := value.Export()
+	if err != nil {
+		return nil, err
+	}
+	names, _ := exported.([]string)
+	return names, nil
+}
+
+// stripGoValues replaces the Go value held by obj[key], and those reachable from it, by plain JavaScript copies
+// Values that cannot be copied are removed (set to undefined)
+func stripGoValues(vm *otto.Otto, obj *otto.Object, key string, depth int) error {
+	value, err := obj.Get(key)
+	if err != nil || !value.IsObject() {
+		return err
+	}
+
+	if isGoValue(value) {
+		exported, _ := value.Export()
+		copied := otto.UndefinedValue()
+		if data, err := json.Marshal(exported); err == nil {
+			if copied, err = vm.Call("JSON.parse", nil, string(data)); err != nil {
+				return err
+			}
+		}
+		return obj.Set(key, copied)
+	}
+
+	if depth == 0 {
+		return nil
+	}
+	child := value.Object()
+	for _, name := range child.Keys() {
+		if err := stripGoValues(vm, child, name, depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isGoValue reports whether an object is backed by a Go struct, map, array or slice rather than being a plain
+// JavaScript object; otto exposes the exported methods of such values to scripts
+// Go maps of interface values only hold data and are walked like plain objects
+func isGoValue(value otto.Value) bool {
+	switch value.Class() {
+	case "GoArray", "GoSlice":
+		return true
+	case "Object":
+		exported, err := value.Export()
+		if err != nil || exported == nil {
+			return false
+		}
+		_, plain := exported.(map[string]interface{})
+		return !plain
+	}
+	return false
+}
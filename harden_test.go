@@ -0,0 +1,190 @@
+package jsmachine
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+)
+
+// hardeningProbe is an adversarial script run against a hardened VM; it returns a description of the escape it
+// achieved, or an empty string when the VM withstood it
+type hardeningProbe struct {
+	name   string
+	script string
+}
+
+// hardeningProbes are regression checks of HardenVM: each attempts an escape it is supposed to prevent
+// They run on the global names present when the VM was hardened, so they adapt to the pool's bindings
+var hardeningProbes = []hardeningProbe{
+	{"reassign_global", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var before = global[names[i]];
+			try { global[names[i]] = null; } catch (e) {}
+			if (global[names[i]] !== before) return names[i] + " was reassigned";
+		}
+		return "";
+	})`},
+	{"delete_global", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			try { delete global[names[i]]; } catch (e) {}
+			if (!(names[i] in global)) return names[i] + " was deleted";
+		}
+		return "";
+	})`},
+	{"redefine_global", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var before = global[names[i]];
+			try { Object.defineProperty(global, names[i], {get: function () { return null; }}); } catch (e) {}
+			if (global[names[i]] !== before) return names[i] + " was redefined";
+		}
+		return "";
+	})`},
+	{"patch_member", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var binding = global[names[i]];
+			if (binding === null || (typeof binding !== "object" && typeof binding !== "function")) continue;
+			for (var key in binding) {
+				var before = binding[key];
+				try { binding[key] = function () { return "patched"; }; } catch (e) {}
+				if (binding[key] !== before) return names[i] + "." + key + " was replaced";
+			}
+		}
+		return "";
+	})`},
+	{"declare_global", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var before = global[names[i]];
+			// Indirect eval runs in the global scope, like the top level of a script
+			try { (0, eval)("function " + names[i] + "() {}"); } catch (e) {}
+			try { (0, eval)("var " + names[i] + " = null;"); } catch (e) {}
+			if (global[names[i]] !== before) return names[i] + " was redeclared";
+		}
+		return "";
+	})`},
+	{"with_scope", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var binding = global[names[i]];
+			if (binding === null || typeof binding !== "object") continue;
+			for (var key in binding) {
+				var before = binding[key];
+				try { (0, eval)("with (" + names[i] + ") { " + key + " = null; }"); } catch (e) {}
+				if (binding[key] !== before) return names[i] + "." + key + " was replaced through with";
+				break;
+			}
+		}
+		return "";
+	})`},
+	{"extend_binding", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var binding = global[names[i]];
+			if (binding === null || (typeof binding !== "object" && typeof binding !== "function")) continue;
+			try { binding.__probe = true; } catch (e) {}
+			if (binding.__probe === true) return "property added to " + names[i];
+			try { binding.call = null; } catch (e) {}
+			if (typeof binding === "function" && binding.call === null) return names[i] + ".call was shadowed";
+		}
+		return "";
+	})`},
+}
+
+// builtinProbes additionally check the built-ins frozen with js.freeze_builtins
+var builtinProbes = []hardeningProbe{
+	{"patch_prototype", `(function (global, names) {
+		for (var i = 0; i < names.length; i++) {
+			var proto = global[names[i]].prototype;
+			if (!proto) continue;
+			var keys = Object.getOwnPropertyNames(proto);
+			for (var j = 0; j < keys.length; j++) {
+				var before = proto[keys[j]];
+				try { proto[keys[j]] = function () { return "patched"; }; } catch (e) {}
+				if (proto[keys[j]] !== before) return names[i] + ".prototype." + keys[j] + " was replaced";
+			}
+			try { proto.__probe = true; } catch (e) {}
+			if (({}).__probe === true || proto.__probe === true) return "property added to " + names[i] + ".prototype";
+		}
+		return "";
+	})`},
+}
+
+func TestHardenVM(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    *Config
+		probes []hardeningProbe
+		// Globals the probes attack (nil: the enumerable globals of the VM, its bindings and js.globals)
+		names []string
+	}{
+		{
+			name:   "bindings and globals",
+			cfg:    &Config{PoolSize: 1, Globals: map[string]interface{}{"LIMITS": map[string]interface{}{"max": 10}}},
+			probes: hardeningProbes,
+		},
+		{
+			name:   "frozen built-ins",
+			cfg:    &Config{PoolSize: 1, FreezeBuiltins: true},
+			probes: append(slices.Clone(hardeningProbes), builtinProbes...),
+			names:  builtinNames,
+		},
+	}
+
+	for _, tt := range tests {
+		p := newTestPlugin(t, tt.cfg)
+		for _, probe := range tt.probes {
+			t.Run(tt.name+"/"+probe.name, func(t *testing.T) {
+				// A fresh VM per probe, so an escape cannot hide or cause the next one
+				vm, err := p.newVM(p.poolFor(""))
+				if err != nil {
+					t.Fatal(err)
+				}
+				global, err := vm.Run(`Function("return this")()`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				names := tt.names
+				if names == nil {
+					if names, err = globalNames(vm); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				fn, err := vm.Run(probe.script)
+				if err != nil {
+					t.Fatal(err)
+				}
+				escaped, err := fn.Call(otto.UndefinedValue(), global, names)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s := escaped.String(); s != "" {
+					t.Error(s)
+				}
+			})
+		}
+	}
+}
+
+func TestHardenVMBindingsSurviveExecutions(t *testing.T) {
+	// One VM, so the second execution runs where the first one tried to replace the bindings
+	p := serveTestPlugin(t, &Config{PoolSize: 1, Bindings: []string{"log", "helpers"}})
+
+	const probe = `[typeof log, typeof helpers, log.info("probe") !== "patched"].join(",")`
+	original := execute(t, p, &ExecuteRequest{Code: probe})
+
+	execute(t, p, &ExecuteRequest{Code: `
+		log = null;
+		helpers = {};
+		try { log.info = function () { return "patched"; }; } catch (e) {}
+		var log = "redeclared";
+		function helpers() { return "redeclared"; }
+		true
+	`})
+
+	got := execute(t, p, &ExecuteRequest{Code: probe})
+	if got.Result != original.Result {
+		t.Errorf("bindings after reassignment: got %v, want %v", got.Result, original.Result)
+	}
+	if got.Result != "object,object,true" {
+		t.Errorf("bindings: got %v, want object,object,true", got.Result)
+	}
+}
@@ -0,0 +1,267 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// helperFunc is a sprig-style helper shared by the helpers binding and templates
+// Arguments follow sprig's order, with the piped value last (e.g. trimPrefix(prefix, s))
+type helperFunc func(args ...interface{}) (interface{}, error)
+
+// helperFuncs are the helpers available as helpers.* in scripts and as functions in templates
+var helperFuncs = map[string]helperFunc{
+	"trim":       stringHelper(1, func(a []string) interface{} { return strings.TrimSpace(a[0]) }),
+	"trimPrefix": stringHelper(2, func(a []string) interface{} { return strings.TrimPrefix(a[1], a[0]) }),
+	"trimSuffix": stringHelper(2, func(a []string) interface{} { return strings.TrimSuffix(a[1], a[0]) }),
+	"upper":      stringHelper(1, func(a []string) interface{} { return strings.ToUpper(a[0]) }),
+	"lower":      stringHelper(1, func(a []string) interface{} { return strings.ToLower(a[0]) }),
+	"replace":    stringHelper(3, func(a []string) interface{} { return strings.ReplaceAll(a[2], a[0], a[1]) }),
+	"contains":   stringHelper(2, func(a []string) interface{} { return strings.Contains(a[1], a[0]) }),
+	"hasPrefix":  stringHelper(2, func(a []string) interface{} { return strings.HasPrefix(a[1], a[0]) }),
+	"hasSuffix":  stringHelper(2, func(a []string) interface{} { return strings.HasSuffix(a[1], a[0]) }),
+	"split":      stringHelper(2, func(a []string) interface{} { return strings.Split(a[1], a[0]) }),
+	"repeat":     helperRepeat,
+	"indent":     helperIndent,
+	"join":       helperJoin,
+	"default":    helperDefault,
+	"empty":      helperEmpty,
+	"coalesce":   helperCoalesce,
+	"ternary":    helperTernary,
+	"dict":       helperDict,
+	"list":       helperList,
+	"toJson":     helperToJSON,
+	"toPrettyJson": func(args ...interface{}) (interface{}, error) {
+		if err := helperArgs("toPrettyJson", args, 1); err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(args[0], "", "  ")
+		return string(data), err
+	},
+	"fromJson": stringHelperErr(1, func(a []string) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal([]byte(a[0]), &v)
+		return v, err
+	}),
+}
+
+// helperFuncMap returns the helpers as a text/template FuncMap
+func helperFuncMap() template.FuncMap {
+	funcs := make(template.FuncMap, len(helperFuncs))
+	for name, fn := range helperFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// helperArgs checks the number of arguments
+func helperArgs(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s expects %d arguments, got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// stringHelper adapts a function of n string arguments
+func stringHelper(n int, fn func([]string) interface{}) helperFunc {
+	return stringHelperErr(n, func(a []string) (interface{}, error) {
+		return fn(a), nil
+	})
+}
+
+// stringHelperErr adapts a fallible function of n string arguments
+func stringHelperErr(n int, fn func([]string) (interface{}, error)) helperFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != n {
+			return nil, fmt.Errorf("expected %d arguments, got %d", n, len(args))
+		}
+		strs := make([]string, n)
+		for i, arg := range args {
+			strs[i] = helperString(arg)
+		}
+		return fn(strs)
+	}
+}
+
+// helperString converts a value to a string (nil becomes "")
+func helperString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// helperInt converts a numeric value to an int
+func helperInt(v interface{}) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	case string:
+		return strconv.Atoi(n)
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+}
+
+// helperRepeat implements repeat(count, s)
+func helperRepeat(args ...interface{}) (interface{}, error) {
+	if err := helperArgs("repeat", args, 2); err != nil {
+		return nil, err
+	}
+	count, err := helperInt(args[0])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("repeat: invalid count %v", args[0])
+	}
+	return strings.Repeat(helperString(args[1]), count), nil
+}
+
+// helperIndent implements indent(spaces, s): every line of s is indented
+func helperIndent(args ...interface{}) (interface{}, error) {
+	if err := helperArgs("indent", args, 2); err != nil {
+		return nil, err
+	}
+	spaces, err := helperInt(args[0])
+	if err != nil || spaces < 0 {
+		return nil, fmt.Errorf("indent: invalid width %v", args[0])
+	}
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(helperString(args[1]), "\n", "\n"+pad), nil
+}
+
+// helperJoin implements join(separator, list)
+func helperJoin(args ...interface{}) (interface{}, error) {
+	if err := helperArgs("join", args, 2); err != nil {
+		return nil, err
+	}
+
+	list := reflect.ValueOf(args[1])
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return helperString(args[1]), nil
+	}
+
+	parts := make([]string, list.Len())
+	for i := range parts {
+		parts[i] = helperString(list.Index(i).Interface())
+	}
+	return strings.Join(parts, helperString(args[0])), nil
+}
+
+// helperDefault implements default(fallback, value): value unless it is empty
+func helperDefault(args ...interface{}) (interface{}, error) {
+	switch len(args) {
+	case 1:
+		// Piping a missing value passes no argument
+		return args[0], nil
+	case 2:
+		if isEmptyValue(args[1]) {
+			return args[0], nil
+		}
+		return args[1], nil
+	default:
+		return nil, fmt.Errorf("default expects 2 arguments, got %d", len(args))
+	}
+}
+
+// helperEmpty implements empty(value)
+func helperEmpty(args ...interface{}) (interface{}, error) {
+	if err := helperArgs("empty", args, 1); err != nil {
+		return nil, err
+	}
+	return isEmptyValue(args[0]), nil
+}
+
+// helperCoalesce returns the first non-empty argument
+func helperCoalesce(args ...interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if !isEmptyValue(arg) {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+// helperTernary implements ternary(ifTrue, ifFalse, condition)
+func helperTernary(args ...interface{}) (interface{}, error) {
+	if err := helperArgs("ternary", args, 3); err != nil {
+		return nil, err
+	}
+	if isEmptyValue(args[2]) {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+// helperDict builds a map from key/value pairs
+func helperDict(args ...interface{}) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("dict expects key/value pairs, got %d arguments", len(args))
+	}
+	dict := make(map[string]interface{}, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		dict[helperString(args[i])] = args[i+1]
+	}
+	return dict, nil
+}
+
+// helperList builds a list from its arguments
+func helperList(args ...interface{}) (interface{}, error) {
+	return append([]interface{}{}, args...), nil
+}
+
+// helperToJSON encodes a value as compact JSON
+func helperToJSON(args ...interface{}) (interface{}, error) {
+	if err := helperArgs("toJson", args, 1); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(args[0])
+	return string(data), err
+}
+
+// isEmptyValue follows sprig: nil, false, 0, "" and empty collections are empty
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// helperNames returns the helper names in alphabetical order
+func helperNames() []string {
+	names := make([]string, 0, len(helperFuncs))
+	for name := range helperFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,280 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// catalogExt is the extension of translation catalogs (one file per locale, e.g. de.json)
+const catalogExt = ".json"
+
+// i18nCatalogs holds translation catalogs loaded from js.i18n.dir and reloads them when files change
+// Catalogs are nested JSON objects; keys are addressed with dots ("email.welcome.subject")
+type i18nCatalogs struct {
+	cfg *I18nConfig
+	log *zap.Logger
+
+	mu       sync.RWMutex
+	messages map[string]map[string]interface{} // locale -> flattened key -> string or plural forms
+	state    string                            // fingerprint of the loaded files
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newI18nCatalogs loads the catalogs in cfg.Dir
+func newI18nCatalogs(cfg *I18nConfig, log *zap.Logger) (*i18nCatalogs, error) {
+	c := &i18nCatalogs{
+		cfg:  cfg,
+		log:  log,
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	state, err := c.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(state); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// fingerprint summarizes names, sizes and modification times of the catalog files
+func (c *i18nCatalogs) fingerprint() (string, error) {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read i18n directory: %w", err)
+	}
+
+	var sb strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != catalogExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return sb.String(), nil
+}
+
+// load reads all catalogs and replaces the current ones
+func (c *i18nCatalogs) load(state string) error {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read i18n directory: %w", err)
+	}
+
+	messages := make(map[string]map[string]interface{})
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != catalogExt {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(c.cfg.Dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		var catalog map[string]interface{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return fmt.Errorf("invalid catalog %s: %w", entry.Name(), err)
+		}
+
+		locale := normalizeLocale(strings.TrimSuffix(entry.Name(), catalogExt))
+		messages[locale] = make(map[string]interface{})
+		flattenCatalog("", catalog, messages[locale])
+	}
+
+	c.mu.Lock()
+	c.messages = messages
+	c.state = state
+	c.mu.Unlock()
+
+	return nil
+}
+
+// flattenCatalog converts nested objects to dotted keys
+// Objects whose keys are all plural forms (zero, one, other) are kept as a message with plural forms
+func flattenCatalog(prefix string, node map[string]interface{}, out map[string]interface{}) {
+	for key, value := range node {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case string:
+			out[key] = v
+		case map[string]interface{}:
+			if isPluralForms(v) {
+				out[key] = v
+			} else {
+				flattenCatalog(key, v, out)
+			}
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// isPluralForms reports whether a catalog object holds plural forms of one message
+func isPluralForms(node map[string]interface{}) bool {
+	if _, ok := node["other"]; !ok {
+		return false
+	}
+	for key, value := range node {
+		if _, ok := value.(string); !ok {
+			return false
+		}
+		if key != "zero" && key != "one" && key != "other" {
+			return false
+		}
+	}
+	return true
+}
+
+// translate looks up key for locale, falling back to the base language and the default locale
+// Params replace {name} placeholders; a numeric "count" param selects the plural form
+// Returns the key itself when no catalog contains it
+func (c *i18nCatalogs) translate(key string, params map[string]interface{}, locale string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, candidate := range localeFallbacks(locale, c.cfg.DefaultLocale) {
+		message, ok := c.messages[candidate][key]
+		if !ok {
+			continue
+		}
+
+		text, ok := message.(string)
+		if !ok {
+			text = pluralForm(message.(map[string]interface{}), params["count"])
+		}
+		return interpolate(text, params)
+	}
+
+	return key
+}
+
+// localeFallbacks returns the lookup order for a locale: de-AT, de, default locale and its base language
+func localeFallbacks(locale, defaultLocale string) []string {
+	var fallbacks []string
+	for _, l := range []string{normalizeLocale(locale), normalizeLocale(defaultLocale)} {
+		if l == "" {
+			continue
+		}
+		fallbacks = append(fallbacks, l)
+		if base, _, found := strings.Cut(l, "-"); found {
+			fallbacks = append(fallbacks, base)
+		}
+	}
+	return fallbacks
+}
+
+// normalizeLocale converts "de_AT" and "DE-at" to "de-at"
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// pluralForm selects zero, one or other for count
+func pluralForm(forms map[string]interface{}, count interface{}) string {
+	var n float64
+	switch v := count.(type) {
+	case float64:
+		n = v
+	case int64:
+		n = float64(v)
+	case int:
+		n = float64(v)
+	}
+
+	if form, ok := forms["zero"].(string); ok && n == 0 {
+		return form
+	}
+	if form, ok := forms["one"].(string); ok && n == 1 {
+		return form
+	}
+	return forms["other"].(string)
+}
+
+// interpolate replaces {name} placeholders with params; unknown placeholders are left as they are
+func interpolate(text string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(params)*2)
+	for _, name := range names {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(params[name]))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// run polls the catalog directory and reloads catalogs when files change, until stop is called
+func (c *i18nCatalogs) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(time.Duration(c.cfg.ReloadIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.reloadIfChanged()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// reloadIfChanged reloads catalogs when the directory fingerprint changed
+// A failed reload keeps the previous catalogs
+func (c *i18nCatalogs) reloadIfChanged() {
+	state, err := c.fingerprint()
+	if err != nil {
+		c.log.Warn("failed to check i18n catalogs", zap.Error(err))
+		return
+	}
+
+	c.mu.RLock()
+	changed := state != c.state
+	c.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := c.load(state); err != nil {
+		c.log.Warn("failed to reload i18n catalogs, keeping previous catalogs", zap.Error(err))
+		return
+	}
+	c.log.Info("i18n catalogs reloaded", zap.String("dir", c.cfg.Dir))
+}
+
+// stop stops watching the catalog directory (bounded by ctx)
+func (c *i18nCatalogs) stop(ctx context.Context) {
+	close(c.quit)
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,48 @@
+package jsmachine
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// instanceNamePattern keeps instance names usable as configuration keys and metric prefixes
+var instanceNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// InstanceName names a further instance of the plugin. The container tells plugins apart by their type, so each
+// instance is declared with its own (empty) type implementing it
+type InstanceName interface {
+	InstanceName() string
+}
+
+// Instance is a further instance of the plugin, named by N, that runs alongside the default one (and any other
+// instance) with nothing shared: it reads the configuration section of its name and has its own pools, bindings,
+// caches and schedules, its own RPC service (<name>.Execute, ...), HTTP middleware and metrics (<name>_*), e.g.
+//
+//	type Staging struct{}
+//
+//	func (Staging) InstanceName() string { return "js_staging" }
+//
+//	// next to &jsmachine.Plugin{} in the container's plugin list
+//	&jsmachine.Instance[Staging]{}
+type Instance[N InstanceName] struct {
+	Plugin
+}
+
+// Init initializes the instance from the configuration section of its name
+func (i *Instance[N]) Init(cfg Configurer, log Logger) error {
+	name := i.Name()
+	if !instanceNamePattern.MatchString(name) {
+		return fmt.Errorf("js_plugin_init: invalid instance name %q: must match %s", name, instanceNamePattern)
+	}
+	if name == PluginName {
+		return fmt.Errorf("js_plugin_init: instance name %q is taken by the default instance", name)
+	}
+	i.name = name
+	return i.Plugin.Init(cfg, log)
+}
+
+// Name returns the instance name
+func (i *Instance[N]) Name() string {
+	var n N
+	return n.InstanceName()
+}
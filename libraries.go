@@ -0,0 +1,73 @@
+package jsmachine
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/robertkrimen/otto"
+)
+
+// libraryPrefix is the require() namespace of shared libraries
+const libraryPrefix = "lib/"
+
+// libraryNamePattern restricts library names to slash-separated path segments
+var libraryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+
+// library is a shared script compiled once and evaluated in every VM
+type library struct {
+	name string
+
+	// Compiled CommonJS-style wrapper: function (module, exports) { <source> }
+	script *otto.Script
+}
+
+// librarySet is one generation of compiled libraries; flushing the module cache swaps in a new set
+type librarySet struct {
+	libraries map[string]*library
+}
+
+// loadLibraries reads and compiles the configured libraries (name -> path)
+func loadLibraries(paths map[string]string) (*librarySet, error) {
+	compiler := otto.New()
+	libraries := make(map[string]*library, len(paths))
+
+	for name, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read library %s: %w", name, err)
+		}
+
+		script, err := compiler.Compile(path, "(function (module, exports) { "+normalizeCode(string(src), false)+"\n})")
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile library %s: %w", name, err)
+		}
+
+		libraries[name] = &library{name: name, script: script}
+	}
+	return &librarySet{libraries: libraries}, nil
+}
+
+// libraryNames returns the library names in load order
+func libraryNames(libraries map[string]*library) []string {
+	names := make([]string, 0, len(libraries))
+	for name := range libraries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateLibraries checks library names and paths
+func validateLibraries(paths map[string]string) error {
+	for name, path := range paths {
+		if !libraryNamePattern.MatchString(name) {
+			return fmt.Errorf("libraries: %q is not a valid library name", name)
+		}
+		if path == "" {
+			return fmt.Errorf("libraries.%s: path is required", name)
+		}
+	}
+	return nil
+}
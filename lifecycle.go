@@ -0,0 +1,98 @@
+package jsmachine
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// lifecycleState is the phase of the plugin's life; transitions only move forward
+type lifecycleState int32
+
+const (
+	// stateInit: configured, pools not created yet
+	stateInit lifecycleState = iota
+	// stateServing: pools are filled and executions are accepted
+	stateServing
+	// stateDraining: Stop was called, in-flight executions are finishing and new ones are refused
+	stateDraining
+	// stateStopped: all executions finished (or the drain timed out)
+	stateStopped
+)
+
+// String returns the state name used in logs and errors
+func (s lifecycleState) String() string {
+	switch s {
+	case stateInit:
+		return "init"
+	case stateServing:
+		return "serving"
+	case stateDraining:
+		return "draining"
+	case stateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// errCodeNotReady is the error code of requests arriving before Serve or after Stop
+const errCodeNotReady = "not_ready"
+
+// errNotReady is returned for executions outside the serving state
+var errNotReady = errors.New("plugin is not serving")
+
+// lifecycle tracks the plugin state and the executions running in it
+type lifecycle struct {
+	state atomic.Int32
+
+	// Held for reading while an execution registers, for writing while the state changes,
+	// so no execution can register after draining started
+	mu       sync.RWMutex
+	inflight sync.WaitGroup
+}
+
+// current returns the current state
+func (l *lifecycle) current() lifecycleState {
+	return lifecycleState(l.state.Load())
+}
+
+// serving reports whether executions are accepted
+func (l *lifecycle) serving() bool {
+	return l.current() == stateServing
+}
+
+// transition moves from one state to another, reporting false if the plugin was not in from
+func (l *lifecycle) transition(from, to lifecycleState) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state.CompareAndSwap(int32(from), int32(to))
+}
+
+// begin registers an execution, failing with errNotReady outside the serving state
+// Every successful begin must be paired with done
+func (l *lifecycle) begin() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if !l.serving() {
+		return errNotReady
+	}
+	l.inflight.Add(1)
+	return nil
+}
+
+// done unregisters an execution
+func (l *lifecycle) done() {
+	l.inflight.Done()
+}
+
+// wait returns a channel closed once all registered executions are done
+func (l *lifecycle) wait() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		l.inflight.Wait()
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,140 @@
+package jsmachine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// executor runs Execute from several goroutines until stopped, checking that every response either succeeded or was
+// refused with not_ready
+type executor struct {
+	succeeded atomic.Int64
+	refused   atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startExecutor starts the goroutines; successful executions fail the test unless allowSuccess is set
+func startExecutor(t *testing.T, p *Plugin, allowSuccess bool) *executor {
+	t.Helper()
+
+	e := &executor{stop: make(chan struct{})}
+	r := p.RPC().(*rpc)
+	for i := 0; i < 8; i++ {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			for {
+				select {
+				case <-e.stop:
+					return
+				default:
+				}
+
+				resp := &ExecuteResponse{}
+				if err := r.Execute(&ExecuteRequest{Code: "1 + 1"}, resp); err != nil {
+					t.Errorf("Execute returned an error: %v", err)
+					return
+				}
+				switch {
+				case resp.ErrorCode == errCodeNotReady:
+					e.refused.Add(1)
+					// Refusals are cheap; pause so they do not starve Serve of CPU on small machines
+					time.Sleep(50 * time.Microsecond)
+				case allowSuccess && resp.Error == "":
+					e.succeeded.Add(1)
+				default:
+					t.Errorf("unexpected response: error %q, code %q", resp.Error, resp.ErrorCode)
+					return
+				}
+			}
+		}()
+	}
+	t.Cleanup(e.halt)
+	return e
+}
+
+// halt stops the goroutines and waits for them
+func (e *executor) halt() {
+	select {
+	case <-e.stop:
+	default:
+		close(e.stop)
+	}
+	e.wg.Wait()
+}
+
+// waitFor polls until counter reaches n
+func waitFor(t *testing.T, counter *atomic.Int64, n int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for counter.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("counter reached %d of %d", counter.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLifecycleExecuteBeforeServe(t *testing.T) {
+	p := newTestPlugin(t, &Config{PoolSize: 2})
+
+	e := startExecutor(t, p, false)
+	waitFor(t, &e.refused, 100)
+}
+
+func TestLifecycleExecuteAcrossServeAndStop(t *testing.T) {
+	p := newTestPlugin(t, &Config{PoolSize: 2})
+
+	e := startExecutor(t, p, true)
+	waitFor(t, &e.refused, 10)
+
+	select {
+	case err := <-p.Serve():
+		t.Fatal(err)
+	default:
+	}
+	waitFor(t, &e.succeeded, 100)
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state := p.lifecycle.current(); state != stateStopped {
+		t.Errorf("state after Stop is %s", state)
+	}
+
+	// Nothing succeeds once Stop returned
+	succeeded := e.succeeded.Load()
+	waitFor(t, &e.refused, e.refused.Load()+100)
+	e.halt()
+	if got := e.succeeded.Load(); got != succeeded {
+		t.Errorf("%d executions succeeded after Stop returned", got-succeeded)
+	}
+}
+
+func TestLifecycleStopDuringExecutions(t *testing.T) {
+	p := serveTestPlugin(t, &Config{PoolSize: 2})
+
+	e := startExecutor(t, p, true)
+	waitFor(t, &e.succeeded, 10)
+
+	// Concurrent and repeated Stop calls are safe
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.Stop(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitFor(t, &e.refused, e.refused.Load()+100)
+}
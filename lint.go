@@ -0,0 +1,308 @@
+package jsmachine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/file"
+	"github.com/robertkrimen/otto/parser"
+	"github.com/robertkrimen/otto/token"
+)
+
+// Lint rule names
+const (
+	lintRuleSyntax            = "syntax"
+	lintRuleUnusedVariables   = "unused_variables"
+	lintRuleDisallowedGlobals = "disallowed_globals"
+	lintRuleImplicitGlobals   = "implicit_globals"
+	lintRuleComplexity        = "complexity"
+)
+
+// lintRules lists every rule that can be enabled in js.lint.rules
+var lintRules = []string{
+	lintRuleUnusedVariables,
+	lintRuleDisallowedGlobals,
+	lintRuleImplicitGlobals,
+	lintRuleComplexity,
+}
+
+// LintFinding is a single problem reported by the linter
+type LintFinding struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// LintRequest contains the code to check
+type LintRequest struct {
+	Code string `json:"code"`
+}
+
+// LintResponse contains the findings, ordered by position
+type LintResponse struct {
+	Findings []LintFinding `json:"findings"`
+
+	// False when the code does not parse (the syntax error is reported as a finding)
+	Valid bool `json:"valid"`
+}
+
+// Lint runs the configured static checks against code without executing it
+func (r *rpc) Lint(req *LintRequest, resp *LintResponse) error {
+	if req.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+
+	resp.Findings, resp.Valid = lint(normalizeCode(req.Code, false), r.plugin.cfg.Lint)
+	return nil
+}
+
+// lint parses code and applies the enabled rules
+func lint(code string, cfg *LintConfig) ([]LintFinding, bool) {
+	program, err := parser.ParseFile(nil, "", code, 0)
+	if err != nil {
+		finding := LintFinding{Rule: lintRuleSyntax, Message: err.Error()}
+		if list, ok := err.(*parser.ErrorList); ok && len(*list) > 0 {
+			first := (*list)[0]
+			finding.Message = first.Message
+			finding.Line = first.Position.Line
+			finding.Column = first.Position.Column
+		}
+		return []LintFinding{finding}, false
+	}
+
+	l := &linter{
+		cfg:        cfg,
+		file:       program.File,
+		enabled:    make(map[string]bool, len(cfg.Rules)),
+		disallowed: make(map[string]bool, len(cfg.DisallowedGlobals)),
+	}
+	for _, rule := range cfg.Rules {
+		l.enabled[rule] = true
+	}
+	for _, name := range cfg.DisallowedGlobals {
+		l.disallowed[name] = true
+	}
+
+	l.pushScope("<program>", program.DeclarationList, nil, 0)
+	for _, stmt := range program.Body {
+		ast.Walk(l, stmt)
+	}
+	l.popScope()
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		if l.findings[i].Line != l.findings[j].Line {
+			return l.findings[i].Line < l.findings[j].Line
+		}
+		return l.findings[i].Column < l.findings[j].Column
+	})
+
+	if l.findings == nil {
+		l.findings = []LintFinding{}
+	}
+	return l.findings, true
+}
+
+// lintDecl is a name declared in a scope
+type lintDecl struct {
+	idx  file.Idx
+	kind string // variable, function, parameter
+	used bool
+}
+
+// lintScope is a function (or program) scope
+type lintScope struct {
+	name       string
+	idx        file.Idx
+	decls      map[string]*lintDecl
+	complexity int
+	parent     *lintScope
+}
+
+// linter walks the AST keeping a stack of function scopes
+type linter struct {
+	cfg        *LintConfig
+	file       *file.File
+	enabled    map[string]bool
+	disallowed map[string]bool
+
+	scope    *lintScope
+	findings []LintFinding
+}
+
+// report adds a finding for an enabled rule
+func (l *linter) report(rule string, idx file.Idx, format string, args ...interface{}) {
+	if !l.enabled[rule] {
+		return
+	}
+
+	finding := LintFinding{Rule: rule, Message: fmt.Sprintf(format, args...)}
+	if pos := l.file.Position(idx); pos != nil {
+		finding.Line = pos.Line
+		finding.Column = pos.Column
+	}
+	l.findings = append(l.findings, finding)
+}
+
+// pushScope enters a function scope with its hoisted declarations and parameters
+func (l *linter) pushScope(name string, decls []ast.Declaration, params *ast.ParameterList, idx file.Idx) {
+	scope := &lintScope{
+		name:       name,
+		idx:        idx,
+		decls:      make(map[string]*lintDecl),
+		complexity: 1,
+		parent:     l.scope,
+	}
+
+	if params != nil {
+		for _, p := range params.List {
+			scope.decls[p.Name] = &lintDecl{idx: p.Idx, kind: "parameter"}
+		}
+	}
+
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ast.VariableDeclaration:
+			for _, v := range d.List {
+				if _, exists := scope.decls[v.Name]; !exists {
+					scope.decls[v.Name] = &lintDecl{idx: v.Idx, kind: "variable"}
+				}
+			}
+		case *ast.FunctionDeclaration:
+			if d.Function.Name != nil {
+				scope.decls[d.Function.Name.Name] = &lintDecl{idx: d.Function.Name.Idx, kind: "function"}
+			}
+		}
+	}
+
+	l.scope = scope
+}
+
+// popScope leaves the current scope, reporting unused declarations and complexity
+func (l *linter) popScope() {
+	scope := l.scope
+	l.scope = scope.parent
+
+	names := make([]string, 0, len(scope.decls))
+	for name := range scope.decls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		decl := scope.decls[name]
+		// Unused parameters are common in callbacks, only variables and functions are reported
+		if !decl.used && decl.kind != "parameter" {
+			l.report(lintRuleUnusedVariables, decl.idx, "%s '%s' is declared but never used", decl.kind, name)
+		}
+	}
+
+	if scope.complexity > l.cfg.MaxComplexity {
+		l.report(lintRuleComplexity, scope.idx, "function '%s' has cyclomatic complexity %d (max %d)",
+			scope.name, scope.complexity, l.cfg.MaxComplexity)
+	}
+}
+
+// resolve finds the declaration of name in the scope chain
+func (l *linter) resolve(name string) *lintDecl {
+	for scope := l.scope; scope != nil; scope = scope.parent {
+		if decl, ok := scope.decls[name]; ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+// read handles a reference to an identifier
+func (l *linter) read(id *ast.Identifier) {
+	if decl := l.resolve(id.Name); decl != nil {
+		decl.used = true
+		return
+	}
+
+	if l.disallowed[id.Name] {
+		l.report(lintRuleDisallowedGlobals, id.Idx, "use of disallowed global '%s'", id.Name)
+	}
+}
+
+// Enter implements ast.Visitor
+func (l *linter) Enter(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.FunctionLiteral:
+		name := "<anonymous>"
+		if n.Name != nil {
+			name = n.Name.Name
+		}
+
+		l.pushScope(name, n.DeclarationList, n.ParameterList, n.Function)
+		// A named function expression can refer to itself
+		if n.Name != nil {
+			if _, exists := l.scope.decls[n.Name.Name]; !exists {
+				l.scope.decls[n.Name.Name] = &lintDecl{idx: n.Name.Idx, kind: "function", used: true}
+			}
+		}
+		ast.Walk(l, n.Body)
+		l.popScope()
+		return nil
+
+	case *ast.Identifier:
+		if n != nil {
+			l.read(n)
+		}
+		return nil
+
+	case *ast.DotExpression:
+		// The property name is not a variable reference
+		ast.Walk(l, n.Left)
+		return nil
+
+	case *ast.AssignExpression:
+		if id, ok := n.Left.(*ast.Identifier); ok {
+			if decl := l.resolve(id.Name); decl == nil {
+				l.report(lintRuleImplicitGlobals, id.Idx, "assignment to undeclared variable '%s' creates a global", id.Name)
+			} else if n.Operator != token.ASSIGN {
+				// Compound assignments (+=, ...) read the variable too
+				decl.used = true
+			}
+		} else {
+			ast.Walk(l, n.Left)
+		}
+		ast.Walk(l, n.Right)
+		return nil
+
+	case *ast.CatchStatement:
+		if n.Parameter != nil {
+			l.scope.decls[n.Parameter.Name] = &lintDecl{idx: n.Parameter.Idx, kind: "parameter", used: true}
+		}
+		l.scope.complexity++
+		ast.Walk(l, n.Body)
+		return nil
+
+	case *ast.LabelledStatement:
+		ast.Walk(l, n.Statement)
+		return nil
+
+	case *ast.BranchStatement:
+		return nil
+
+	case *ast.IfStatement, *ast.ForStatement, *ast.ForInStatement, *ast.WhileStatement,
+		*ast.DoWhileStatement, *ast.ConditionalExpression:
+		l.scope.complexity++
+
+	case *ast.CaseStatement:
+		if n.Test != nil {
+			l.scope.complexity++
+		}
+
+	case *ast.BinaryExpression:
+		if n.Operator == token.LOGICAL_AND || n.Operator == token.LOGICAL_OR {
+			l.scope.complexity++
+		}
+	}
+
+	return l
+}
+
+// Exit implements ast.Visitor
+func (l *linter) Exit(ast.Node) {}
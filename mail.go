@@ -0,0 +1,318 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Mail drivers
+const (
+	mailSMTP = "smtp"
+	mailLog  = "log"
+)
+
+// SMTP connection security
+const (
+	smtpStartTLS = "starttls"
+	smtpTLS      = "tls"
+	smtpNone     = "none"
+)
+
+// Mail template files in js.mail.templates_dir: <name>.subject.tmpl, <name>.text.tmpl and <name>.html.tmpl
+const (
+	mailSubjectExt = ".subject.tmpl"
+	mailTextExt    = ".text.tmpl"
+	mailHTMLExt    = ".html.tmpl"
+)
+
+// mailMessage is a message composed by a script
+type mailMessage struct {
+	from    *netmail.Address
+	to      []*netmail.Address
+	cc      []*netmail.Address
+	bcc     []*netmail.Address
+	replyTo *netmail.Address
+	subject string
+	text    string
+	html    string
+}
+
+// recipients returns the envelope addresses of every recipient
+func (m *mailMessage) recipients() []string {
+	all := make([]string, 0, len(m.to)+len(m.cc)+len(m.bcc))
+	for _, list := range [][]*netmail.Address{m.to, m.cc, m.bcc} {
+		for _, addr := range list {
+			all = append(all, addr.Address)
+		}
+	}
+	return all
+}
+
+// mailer delivers composed messages
+type mailer interface {
+	send(ctx context.Context, msg *mailMessage, id string, data []byte) error
+}
+
+// newMailer creates the mailer of the configured driver
+func newMailer(cfg *MailConfig, log *zap.Logger) mailer {
+	if cfg.Driver == mailLog {
+		return &logMailer{log: log}
+	}
+	return &smtpMailer{cfg: cfg.SMTP}
+}
+
+// logMailer logs messages instead of sending them, for development
+type logMailer struct {
+	log *zap.Logger
+}
+
+// send implements mailer
+func (m *logMailer) send(_ context.Context, msg *mailMessage, id string, _ []byte) error {
+	m.log.Info("mail not sent (mail.driver: log)",
+		zap.String("message_id", id),
+		zap.Strings("to", msg.recipients()),
+		zap.String("subject", msg.subject),
+	)
+	return nil
+}
+
+// smtpMailer sends each message over a new SMTP connection
+type smtpMailer struct {
+	cfg *SMTPConfig
+}
+
+// send implements mailer
+func (m *smtpMailer) send(ctx context.Context, msg *mailMessage, _ string, data []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+	var err error
+	if m.cfg.Security == smtpTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tlsConfig()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support, the deadline bounds the whole session instead
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.cfg.Security == smtpStartTLS {
+		if err := client.StartTLS(m.tlsConfig()); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("AUTH: %w", err)
+		}
+	}
+
+	if err := client.Mail(msg.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range msg.recipients() {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// tlsConfig returns the TLS settings for the SMTP server
+func (m *smtpMailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}
+}
+
+// composeMail renders msg as an RFC 5322 message: text, html or a multipart/alternative of both
+func composeMail(msg *mailMessage, id string, now time.Time) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+
+	header("From", msg.from.String())
+	header("To", formatAddresses(msg.to))
+	if len(msg.cc) > 0 {
+		header("Cc", formatAddresses(msg.cc))
+	}
+	if msg.replyTo != nil {
+		header("Reply-To", msg.replyTo.String())
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", id)
+	header("MIME-Version", "1.0")
+
+	switch {
+	case msg.text != "" && msg.html != "":
+		boundary := randomHex(16)
+		header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+		buf.WriteString("\r\n")
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain", msg.text},
+			{"text/html", msg.html},
+		} {
+			buf.WriteString("--" + boundary + "\r\n")
+			writeMailBody(&buf, part.contentType, part.body)
+		}
+		buf.WriteString("--" + boundary + "--\r\n")
+	case msg.html != "":
+		writeMailBody(&buf, "text/html", msg.html)
+	default:
+		writeMailBody(&buf, "text/plain", msg.text)
+	}
+	return buf.Bytes()
+}
+
+// writeMailBody writes the headers and quoted-printable body of one part
+func writeMailBody(buf *bytes.Buffer, contentType, body string) {
+	buf.WriteString("Content-Type: " + contentType + "; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(buf)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+	buf.WriteString("\r\n")
+}
+
+// formatAddresses formats an address list header value, encoding non-ASCII names
+func formatAddresses(addrs []*netmail.Address) string {
+	formatted := make([]string, len(addrs))
+	for i, addr := range addrs {
+		formatted[i] = addr.String()
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// newMessageID creates a unique Message-ID in the domain of the sender
+func newMessageID(from *netmail.Address) string {
+	domain := "localhost"
+	if i := strings.LastIndexByte(from.Address, '@'); i != -1 {
+		domain = from.Address[i+1:]
+	}
+	return "<" + randomHex(16) + "@" + domain + ">"
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// mailTemplate is a named template from js.mail.templates_dir; parts without a file are nil
+type mailTemplate struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// loadMailTemplates parses the templates in dir; html parts are escaped with html/template
+func loadMailTemplates(dir string) (map[string]*mailTemplate, error) {
+	subjects, err := filepath.Glob(filepath.Join(dir, "*"+mailSubjectExt))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]*mailTemplate, len(subjects))
+	for _, path := range subjects {
+		name := strings.TrimSuffix(filepath.Base(path), mailSubjectExt)
+		tpl := &mailTemplate{}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if tpl.subject, err = template.New(name).Funcs(helperFuncMap()).Parse(string(src)); err != nil {
+			return nil, err
+		}
+
+		if src, err := os.ReadFile(filepath.Join(dir, name+mailTextExt)); err == nil {
+			if tpl.text, err = template.New(name).Funcs(helperFuncMap()).Parse(string(src)); err != nil {
+				return nil, err
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		if src, err := os.ReadFile(filepath.Join(dir, name+mailHTMLExt)); err == nil {
+			if tpl.html, err = htmltemplate.New(name).Funcs(htmltemplate.FuncMap(helperFuncMap())).Parse(string(src)); err != nil {
+				return nil, err
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		if tpl.text == nil && tpl.html == nil {
+			return nil, fmt.Errorf("mail template %s has a subject but no %s or %s file", name, mailTextExt, mailHTMLExt)
+		}
+		templates[name] = tpl
+	}
+	return templates, nil
+}
+
+// render fills msg with the parts of the template rendered with data; parts the script set are kept
+func (t *mailTemplate) render(msg *mailMessage, data interface{}) error {
+	var buf strings.Builder
+	if msg.subject == "" {
+		if err := t.subject.Execute(&buf, data); err != nil {
+			return err
+		}
+		msg.subject = strings.TrimSpace(buf.String())
+	}
+	if msg.text == "" && t.text != nil {
+		buf.Reset()
+		if err := t.text.Execute(&buf, data); err != nil {
+			return err
+		}
+		msg.text = buf.String()
+	}
+	if msg.html == "" && t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, data); err != nil {
+			return err
+		}
+		msg.html = buf.String()
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Ceilings of map/reduce jobs
+	maxMapReduceInputs      = 10000
+	maxMapReduceConcurrency = 64
+
+	// errCodeMapFailed marks jobs abandoned because map executions failed
+	errCodeMapFailed = "map_failed"
+)
+
+// Policies for failed map executions
+const (
+	mapFailFast = "fail_fast" // stop at the first failure, the reduce script does not run
+	mapSkip     = "skip"      // reduce the results of the successful executions
+	mapCollect  = "collect"   // reduce an entry per input, holding its result or error
+)
+
+// MapReduceRequest runs a map script once per input across the pool and reduces the results with a second script
+type MapReduceRequest struct {
+	// Map step: JavaScript code or the name of a registered script (mutually exclusive), run once per input
+	// with the input as ctx.input
+	MapCode   string `json:"map_code"`
+	MapScript string `json:"map_script,omitempty"`
+
+	// Reduce step: JavaScript code or the name of a registered script (mutually exclusive), run once with the
+	// collected map results as ctx.input
+	ReduceCode   string `json:"reduce_code"`
+	ReduceScript string `json:"reduce_script,omitempty"`
+
+	// JSON-serializable inputs (maximum: 10000)
+	Inputs []interface{} `json:"inputs"`
+
+	// Map executions running at the same time (default: size of the pool the map step runs in, maximum: 64)
+	Concurrency int `json:"concurrency"`
+
+	// Timeout of each map execution and of the reduce execution in milliseconds (0 = use default)
+	TimeoutMs       int `json:"timeout_ms"`
+	ReduceTimeoutMs int `json:"reduce_timeout_ms"`
+
+	// Handling of failed map executions: fail_fast (default), skip or collect
+	OnFailure string `json:"on_failure,omitempty"`
+
+	// Failed map executions tolerated by skip and collect before the job is abandoned (0 = unlimited)
+	MaxFailures int `json:"max_failures,omitempty"`
+
+	// Passed to every execution; map executions use <request_id>-map-<index>, the reduce execution
+	// <request_id>-reduce
+	RequestID string `json:"request_id,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+
+	// Retry policy of every execution (nil = use js.retry)
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// MapFailure is a failed map execution
+type MapFailure struct {
+	Index     int    `json:"index"`
+	Error     string `json:"error"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// MapReduceResponse reports the reduced result and the outcome of the map executions
+type MapReduceResponse struct {
+	// Value returned by the reduce script
+	Result interface{} `json:"result"`
+
+	// Set when the job was abandoned because of map failures (error_code map_failed) or the reduce execution failed
+	// (error_code of the reduce execution)
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"`
+
+	// Map executions by outcome; inputs not run because the job was abandoned are counted as skipped
+	Inputs    int `json:"inputs"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+
+	// Failed map executions in input order
+	Failures []*MapFailure `json:"failures,omitempty"`
+
+	// Durations of the map phase, the reduce execution and the whole job in milliseconds
+	MapDurationMs    int64 `json:"map_duration_ms"`
+	ReduceDurationMs int64 `json:"reduce_duration_ms"`
+	DurationMs       int64 `json:"duration_ms"`
+}
+
+// mapOutcome is the result of the map execution of one input
+type mapOutcome struct {
+	ran    bool
+	result json.RawMessage
+	err    *MapFailure
+}
+
+// collectedEntry is an element of the reduce input under the collect policy
+type collectedEntry struct {
+	Index  int             `json:"index"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ExecuteMapReduce shards inputs across the pool, runs the map script for each of them in parallel and feeds the
+// collected results to the reduce script
+// Every execution goes through the same validation, budgets, retries and audit as Execute
+func (r *rpc) ExecuteMapReduce(req *MapReduceRequest, resp *MapReduceResponse) error {
+	if !r.plugin.lifecycle.serving() {
+		return errNotReady
+	}
+	if err := r.validateMapReduce(req); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	resp.RequestID = req.RequestID
+	resp.Inputs = len(req.Inputs)
+
+	outcomes := r.runMaps(req)
+	resp.MapDurationMs = time.Since(start).Milliseconds()
+
+	for _, outcome := range outcomes {
+		switch {
+		case !outcome.ran:
+			resp.Skipped++
+		case outcome.err != nil:
+			resp.Failed++
+			resp.Failures = append(resp.Failures, outcome.err)
+		default:
+			resp.Succeeded++
+		}
+	}
+
+	defer func() {
+		resp.DurationMs = time.Since(start).Milliseconds()
+		r.log.Debug("map/reduce completed",
+			zap.String("request_id", req.RequestID),
+			zap.Int("inputs", resp.Inputs),
+			zap.Int("failed", resp.Failed),
+			zap.Int("skipped", resp.Skipped),
+			zap.String("error_code", resp.ErrorCode),
+			zap.Int64("duration_ms", resp.DurationMs),
+		)
+	}()
+
+	if r.mapsExceeded(req, resp.Failed) {
+		first := resp.Failures[0]
+		resp.Error = fmt.Sprintf("map of input %d failed: %s", first.Index, first.Error)
+		resp.ErrorCode = errCodeMapFailed
+		return nil
+	}
+	if resp.Skipped > 0 {
+		// Dispatching stopped because the plugin is stopping, do not reduce a partial result
+		resp.Error = errNotReady.Error()
+		resp.ErrorCode = errCodeNotReady
+		return nil
+	}
+
+	input, err := reduceInput(req.OnFailure, outcomes)
+	if err != nil {
+		return err
+	}
+
+	reduced := &ExecuteResponse{}
+	reduceStart := time.Now()
+	err = r.handleExecute(context.Background(), &ExecuteRequest{
+		Code:      req.ReduceCode,
+		Script:    req.ReduceScript,
+		TimeoutMs: req.ReduceTimeoutMs,
+		RequestID: stepRequestID(req.RequestID, "reduce"),
+		Caller:    req.Caller,
+		Tag:       req.Tag,
+		Retry:     req.Retry,
+		input:     input,
+	}, reduced)
+	resp.ReduceDurationMs = time.Since(reduceStart).Milliseconds()
+	if err != nil {
+		return err
+	}
+
+	resp.Result = reduced.Result
+	resp.Error = reduced.Error
+	resp.ErrorCode = reduced.ErrorCode
+	return nil
+}
+
+// validateMapReduce applies defaults and checks a job before anything runs, including both steps as Execute would
+func (r *rpc) validateMapReduce(req *MapReduceRequest) error {
+	if req.OnFailure == "" {
+		req.OnFailure = mapFailFast
+	}
+	switch req.OnFailure {
+	case mapFailFast, mapSkip, mapCollect:
+	default:
+		return fmt.Errorf("on_failure must be %s, %s or %s, got %q", mapFailFast, mapSkip, mapCollect, req.OnFailure)
+	}
+	if req.MaxFailures < 0 {
+		return fmt.Errorf("max_failures must not be negative, got %d", req.MaxFailures)
+	}
+	if len(req.Inputs) == 0 || len(req.Inputs) > maxMapReduceInputs {
+		return fmt.Errorf("inputs must hold between 1 and %d values, got %d", maxMapReduceInputs, len(req.Inputs))
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = min(r.plugin.poolFor(req.MapScript).size, maxMapReduceConcurrency)
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxMapReduceConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d, got %d", maxMapReduceConcurrency, req.Concurrency)
+	}
+
+	steps := []struct {
+		name string
+		req  *ExecuteRequest
+	}{
+		{"map", &ExecuteRequest{Code: req.MapCode, Script: req.MapScript, TimeoutMs: req.TimeoutMs}},
+		{"reduce", &ExecuteRequest{Code: req.ReduceCode, Script: req.ReduceScript, TimeoutMs: req.ReduceTimeoutMs}},
+	}
+	for _, step := range steps {
+		step.req.RequestID, step.req.Caller, step.req.Tag, step.req.Retry = req.RequestID, req.Caller, req.Tag, req.Retry
+		if err := r.plugin.validateRequest(step.req); err != nil {
+			return fmt.Errorf("%s step: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// runMaps runs the map step for every input on req.Concurrency workers; outcomes are in input order
+// Under fail_fast, or once max_failures is exceeded, the remaining inputs are not dispatched
+func (r *rpc) runMaps(req *MapReduceRequest) []mapOutcome {
+	outcomes := make([]mapOutcome, len(req.Inputs))
+
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	abandon := make(chan struct{})
+	var abandonOnce sync.Once
+	jobs := make(chan int)
+
+	for w := 0; w < req.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outcome := r.runMap(req, i)
+
+				mu.Lock()
+				outcomes[i] = outcome
+				if outcome.err != nil {
+					failed++
+					if r.mapsExceeded(req, failed) {
+						abandonOnce.Do(func() { close(abandon) })
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for i := range req.Inputs {
+		select {
+		case jobs <- i:
+		case <-abandon:
+			break dispatch
+		case <-r.plugin.stopCh:
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return outcomes
+}
+
+// runMap runs the map step for input i
+func (r *rpc) runMap(req *MapReduceRequest, i int) mapOutcome {
+	input, err := json.Marshal(req.Inputs[i])
+	if err != nil {
+		return mapOutcome{ran: true, err: &MapFailure{Index: i, Error: "input is not JSON-serializable: " + err.Error()}}
+	}
+
+	resp := &ExecuteResponse{}
+	err = r.handleExecute(context.Background(), &ExecuteRequest{
+		Code:      req.MapCode,
+		Script:    req.MapScript,
+		TimeoutMs: req.TimeoutMs,
+		RequestID: stepRequestID(req.RequestID, fmt.Sprintf("map-%d", i)),
+		Caller:    req.Caller,
+		Tag:       req.Tag,
+		Retry:     req.Retry,
+		input:     string(input),
+	}, resp)
+	if err == nil && resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	if err != nil {
+		return mapOutcome{ran: true, err: &MapFailure{Index: i, Error: err.Error(), ErrorCode: resp.ErrorCode}}
+	}
+
+	result, err := json.Marshal(resp.Result)
+	if err != nil {
+		return mapOutcome{ran: true, err: &MapFailure{Index: i, Error: "result is not JSON-serializable: " + err.Error()}}
+	}
+	return mapOutcome{ran: true, result: result}
+}
+
+// mapsExceeded reports whether failed map executions abandon the job
+func (r *rpc) mapsExceeded(req *MapReduceRequest, failed int) bool {
+	if failed == 0 {
+		return false
+	}
+	if req.OnFailure == mapFailFast {
+		return true
+	}
+	return req.MaxFailures > 0 && failed > req.MaxFailures
+}
+
+// reduceInput builds the JSON document the reduce step receives as ctx.input: the results of successful map
+// executions in input order, or under collect an entry per input holding its result or error
+func reduceInput(policy string, outcomes []mapOutcome) (string, error) {
+	var input interface{}
+	if policy == mapCollect {
+		entries := make([]collectedEntry, 0, len(outcomes))
+		for i, outcome := range outcomes {
+			entry := collectedEntry{Index: i, Result: outcome.result}
+			if outcome.err != nil {
+				entry.Error = outcome.err.Error
+			}
+			entries = append(entries, entry)
+		}
+		input = entries
+	} else {
+		results := make([]json.RawMessage, 0, len(outcomes))
+		for _, outcome := range outcomes {
+			if outcome.err == nil {
+				results = append(results, outcome.result)
+			}
+		}
+		input = results
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("collecting map results: %w", err)
+	}
+	return string(data), nil
+}
+
+// stepRequestID derives the request ID of one execution of a job (empty when the job has none)
+func stepRequestID(requestID, step string) string {
+	if requestID == "" {
+		return ""
+	}
+	return requestID + "-" + step
+}
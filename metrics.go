@@ -1,76 +1,315 @@
 package jsmachine
 
 import (
-	"github.com/prometheus/client_golang/prometheus"
-)
+	"errors"
+	"strconv"
+	"time"
 
-const (
-	namespace = "js"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
 )
 
-// initMetrics initializes Prometheus metrics
+// initMetrics initializes Prometheus metrics, named after the plugin instance (js_* for the default one)
 func (p *Plugin) initMetrics() {
 	// Counter: Total number of JavaScript executions
 	p.executionsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: namespace,
+			Namespace: p.Name(),
 			Name:      "executions_total",
 			Help:      "Total number of JavaScript executions",
 		},
-		[]string{"status"}, // success, error, timeout
+		[]string{"status", "pool", "tag"}, // status: success, error, timeout, invalid_request; tag: "" when untagged
 	)
 
 	// Histogram: Execution duration in seconds
 	p.executionDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: namespace,
+			Namespace: p.Name(),
 			Name:      "execution_duration_seconds",
 			Help:      "JavaScript execution duration in seconds",
-			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+			Buckets:   p.cfg.HistogramBuckets.ExecutionDuration,
+		},
+		[]string{"status", "pool", "tag"},
+	)
+
+	// Histogram: Time spent parsing code (registered scripts are parsed once when loaded)
+	p.compileDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: p.Name(),
+			Name:      "compile_duration_seconds",
+			Help:      "Time spent compiling JavaScript code in seconds",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, 1},
+		},
+	)
+
+	// Histogram: Time spent running compiled programs
+	p.runDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: p.Name(),
+			Name:      "run_duration_seconds",
+			Help:      "JavaScript run duration in seconds, excluding compilation and queueing",
+			Buckets:   p.cfg.HistogramBuckets.ExecutionDuration,
+		},
+		[]string{"status", "pool"},
+	)
+
+	// Counter: Programs run by whether they were compiled for the execution or cached
+	p.programsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "programs_total",
+			Help:      "Total number of programs run, by source",
+		},
+		[]string{"source"}, // cached, compiled
+	)
+
+	// Counter: Retried executions by the status of the failed attempt
+	p.executionRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "execution_retries_total",
+			Help:      "Total number of JavaScript execution retries",
+		},
+		[]string{"status", "pool"}, // status: error, timeout
+	)
+
+	// Counter: Executions slower than each configured SLO threshold
+	p.overThreshold = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "executions_over_threshold_total",
+			Help:      "Total number of JavaScript executions that took longer than the threshold",
+		},
+		[]string{"threshold", "pool"}, // threshold: slo_thresholds_ms value, e.g. 250ms
+	)
+
+	// Histogram: Time spent waiting for a per-script concurrency slot
+	p.scriptConcurrencyWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: p.Name(),
+			Name:      "script_concurrency_wait_seconds",
+			Help:      "Time executions of concurrency-limited scripts wait for a slot",
+			Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30},
+		},
+		[]string{"script"},
+	)
+
+	// Gauge: Executions currently waiting for a per-script concurrency slot
+	p.scriptConcurrencyWaiting = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: p.Name(),
+			Name:      "script_concurrency_waiting",
+			Help:      "Number of executions waiting for a per-script concurrency slot",
+		},
+		[]string{"script"},
+	)
+
+	// Histogram: Time spent waiting for a concurrency group slot
+	p.groupWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: p.Name(),
+			Name:      "concurrency_group_wait_seconds",
+			Help:      "Time executions and outbound calls wait for a concurrency group slot",
+			Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30},
+		},
+		[]string{"group"},
+	)
+
+	// Gauge: Executions and outbound calls currently waiting for a concurrency group slot
+	p.groupWaiting = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: p.Name(),
+			Name:      "concurrency_group_waiting",
+			Help:      "Number of executions and outbound calls waiting for a concurrency group slot",
+		},
+		[]string{"group"},
+	)
+
+	// Counter: fetch requests by host and response status
+	p.fetchRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "fetch_requests_total",
+			Help:      "Total number of HTTP requests sent by the fetch binding",
+		},
+		[]string{"host", "status"}, // status: HTTP status code, or error when no response was received
+	)
+
+	// Histogram: fetch latency by host, until the response headers arrived
+	p.fetchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: p.Name(),
+			Name:      "fetch_duration_seconds",
+			Help:      "Latency of HTTP requests sent by the fetch binding in seconds",
+			Buckets:   p.cfg.HistogramBuckets.ExecutionDuration,
+		},
+		[]string{"host"},
+	)
+
+	// Counter: messages handed to mail.send by outcome
+	p.mailMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "mail_messages_total",
+			Help:      "Total number of messages scripts sent with mail.send",
+		},
+		[]string{"status"}, // status: sent, failed or rate_limited
+	)
+
+	// Counter: webhook.dispatch deliveries by outcome
+	p.webhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "webhook_deliveries_total",
+			Help:      "Total number of webhook deliveries queued by scripts, by outcome",
+		},
+		[]string{"status"}, // status: delivered, failed or dropped
+	)
+
+	// Counter: messages published with mqtt.publish by outcome
+	p.mqttMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "mqtt_messages_total",
+			Help:      "Total number of MQTT messages scripts published with mqtt.publish",
+		},
+		[]string{"status"}, // status: published or failed
+	)
+
+	// Counter: scheduled runs by schedule and outcome
+	p.scheduledRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "scheduled_runs_total",
+			Help:      "Total number of runs of js.schedules, including missed runs skipped by the catch-up policy",
+		},
+		[]string{"schedule", "status"}, // status: success, failed, skipped or blocked
+	)
+
+	// Counter: execution costs by caller and kind
+	p.callerCosts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "caller_cost_seconds_total",
+			Help:      "Total CPU, wall and binding time of executions by caller, when js.costs is configured",
 		},
-		[]string{"status"},
+		[]string{"caller", "kind"}, // kind: cpu, wall or binding
 	)
 
-	// Gauge: Number of VMs in the pool
-	p.poolSizeGauge = prometheus.NewGauge(
+	// Counter: executions rejected because the caller used up a daily budget
+	p.budgetRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "caller_budget_rejections_total",
+			Help:      "Total number of executions rejected because the caller used up a daily budget",
+		},
+		[]string{"caller"},
+	)
+
+	// Counter: grpc.call calls by target, method and status code
+	p.grpcCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "grpc_calls_total",
+			Help:      "Total number of gRPC calls made by scripts with grpc.call",
+		},
+		[]string{"target", "method", "code"},
+	)
+
+	// Gauge: Number of VMs in each pool
+	p.poolSizeGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Namespace: namespace,
+			Namespace: p.Name(),
 			Name:      "pool_size",
 			Help:      "Number of JavaScript VMs in the pool",
 		},
+		[]string{"pool"},
 	)
 
-	// Gauge: Number of available (idle) VMs
-	p.poolAvailable = prometheus.NewGauge(
+	// Gauge: Number of available (idle) VMs in each pool
+	p.poolAvailable = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Namespace: namespace,
+			Namespace: p.Name(),
 			Name:      "pool_available",
 			Help:      "Number of available JavaScript VMs in the pool",
 		},
+		[]string{"pool"},
+	)
+
+	// Gauge: Number of VMs built in each pool, below pool_size while a lazy pool grows
+	p.poolVMs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: p.Name(),
+			Name:      "pool_vms",
+			Help:      "Number of JavaScript VMs built in the pool",
+		},
+		[]string{"pool"},
+	)
+
+	// Gauge: Size of the results kept in the result store
+	p.storedResultBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: p.Name(),
+			Name:      "stored_result_bytes",
+			Help:      "Size of the results kept for FetchResult in bytes",
+		},
+	)
+
+	// Counter: Jobs pushed by script sinks
+	p.sinkJobs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "sink_jobs_total",
+			Help:      "Total number of jobs pushed to the Jobs pipelines of script sinks",
+		},
+		[]string{"script", "status"},
+	)
+
+	// Counter: Requests with a dedupe key
+	p.dedupeRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: p.Name(),
+			Name:      "dedupe_requests_total",
+			Help:      "Total number of requests with a dedupe key, by whether they executed or received a shared result",
+		},
+		[]string{"outcome"},
 	)
 
-	// Gauge: Number of active executions
-	p.activeExecutions = prometheus.NewGauge(
+	// Gauge: Number of active executions in each pool
+	p.activeExecutions = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Namespace: namespace,
+			Namespace: p.Name(),
 			Name:      "active_executions",
 			Help:      "Number of currently active JavaScript executions",
 		},
+		[]string{"pool"},
 	)
 
 	// Histogram: Code size in bytes
 	p.codeSize = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: namespace,
+			Namespace: p.Name(),
 			Name:      "code_size_bytes",
 			Help:      "Size of JavaScript code in bytes",
-			Buckets:   []float64{100, 500, 1000, 5000, 10000, 50000, 100000, 500000},
+			Buckets:   p.cfg.HistogramBuckets.CodeSize,
 		},
 	)
 
-	// Set initial pool size gauge
-	p.poolSizeGauge.Set(float64(p.cfg.PoolSize))
-	p.poolAvailable.Set(float64(p.cfg.PoolSize))
+	// Set pool size gauges (available VMs are counted as the pools are filled)
+	pools := []string{trustedPool}
+	p.poolSizeGauge.WithLabelValues(trustedPool).Set(float64(p.cfg.PoolSize))
+	if u := p.cfg.Untrusted; u != nil {
+		pools = append(pools, untrustedPool)
+		p.poolSizeGauge.WithLabelValues(untrustedPool).Set(float64(u.PoolSize))
+	}
+
+	// Export zero values so rates and ratios exist before the first execution
+	for _, pool := range pools {
+		p.activeExecutions.WithLabelValues(pool)
+		for _, threshold := range p.cfg.SLOThresholds {
+			p.overThreshold.WithLabelValues(thresholdLabel(threshold), pool)
+		}
+	}
 }
 
 // MetricsCollector returns prometheus collectors for the metrics plugin
@@ -78,9 +317,70 @@ func (p *Plugin) MetricsCollector() []prometheus.Collector {
 	return []prometheus.Collector{
 		p.executionsTotal,
 		p.executionDuration,
+		p.compileDuration,
+		p.runDuration,
+		p.programsTotal,
+		p.executionRetries,
+		p.overThreshold,
+		p.scriptConcurrencyWait,
+		p.scriptConcurrencyWaiting,
+		p.groupWait,
+		p.groupWaiting,
+		p.fetchRequests,
+		p.fetchDuration,
+		p.mailMessages,
+		p.webhookDeliveries,
+		p.grpcCalls,
+		p.mqttMessages,
+		p.scheduledRuns,
+		p.callerCosts,
+		p.budgetRejections,
 		p.poolSizeGauge,
 		p.poolAvailable,
+		p.poolVMs,
+		p.storedResultBytes,
+		p.sinkJobs,
+		p.dedupeRequests,
 		p.activeExecutions,
 		p.codeSize,
 	}
 }
+
+// collectorRegistrar is implemented by the metrics plugin to register collectors with its registry
+type collectorRegistrar interface {
+	Register(c prometheus.Collector) error
+}
+
+// registerCollectors registers the js_* collectors with the metrics plugin
+// Collectors that are already registered (e.g. before a reset) are kept as they are
+func (p *Plugin) registerCollectors() {
+	var registered int
+	for _, collector := range p.MetricsCollector() {
+		err := p.metricsRegistrar.Register(collector)
+		var already prometheus.AlreadyRegisteredError
+		switch {
+		case err == nil:
+			registered++
+		case errors.As(err, &already):
+		default:
+			p.log.Warn("failed to register metrics collector", zap.Error(err))
+		}
+	}
+	p.log.Debug("metrics collectors registered", zap.Int("registered", registered))
+}
+
+// thresholdLabel formats an SLO threshold for the threshold label
+func thresholdLabel(thresholdMs int) string {
+	return strconv.Itoa(thresholdMs) + "ms"
+}
+
+// countOverThreshold counts an execution against every SLO threshold it exceeded
+func (p *Plugin) countOverThreshold(pool *vmPool, duration time.Duration) {
+	for _, threshold := range p.cfg.SLOThresholds {
+		if duration <= time.Duration(threshold)*time.Millisecond {
+			// Thresholds are increasing, later ones are not exceeded either
+			return
+		}
+		p.overThreshold.WithLabelValues(thresholdLabel(threshold), pool.name).Inc()
+	}
+}
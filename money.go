@@ -0,0 +1,216 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// Exchange rate sources
+const (
+	moneyFile = "file"
+	moneyHTTP = "http"
+)
+
+// moneyMaxResponseBytes bounds rate provider responses
+const moneyMaxResponseBytes = 1 << 20
+
+// currencyCode matches ISO 4217 alphabetic codes
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// exchangeRates are the rates of one snapshot: the amount of each currency one unit of base buys
+type exchangeRates struct {
+	base  string
+	rates map[string]decimal.Decimal
+}
+
+// rate returns how much of to one unit of from buys, going through the base currency
+func (r *exchangeRates) rate(from, to string) (decimal.Decimal, error) {
+	fromRate, err := r.get(from)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	toRate, err := r.get(to)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+	return toRate.DivRound(fromRate, defaultDivisionPlaces), nil
+}
+
+// get returns the rate of a currency against base
+func (r *exchangeRates) get(currency string) (decimal.Decimal, error) {
+	if currency == r.base {
+		return decimal.NewFromInt(1), nil
+	}
+	rate, ok := r.rates[currency]
+	if !ok {
+		return decimal.Decimal{}, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return rate, nil
+}
+
+// rateSource provides the exchange rates behind money.convert
+type rateSource interface {
+	rates() (*exchangeRates, error)
+}
+
+// newRateSource creates the source of the configured kind; the file source reads its file right away
+func newRateSource(cfg *MoneyConfig, clients *fetchClients, log *zap.Logger) (rateSource, error) {
+	if cfg.Source == moneyHTTP {
+		client, _ := clients.get(cfg.HTTP.Client) // validated with the configuration
+		return &httpRates{cfg: cfg, client: client, log: log}, nil
+	}
+	return newFileRates(cfg)
+}
+
+// fileRates are read from a JSON file at startup
+type fileRates struct {
+	current *exchangeRates
+}
+
+// newFileRates reads the rates file of cfg
+func newFileRates(cfg *MoneyConfig) (*fileRates, error) {
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("money.file: %w", err)
+	}
+	rates, err := parseExchangeRates(cfg.Base, data, "")
+	if err != nil {
+		return nil, fmt.Errorf("money.file: %s: %w", cfg.File, err)
+	}
+	return &fileRates{current: rates}, nil
+}
+
+// rates implements rateSource
+func (f *fileRates) rates() (*exchangeRates, error) {
+	return f.current, nil
+}
+
+// httpRates are fetched from a provider and kept for the configured TTL, shared by every execution of the node
+type httpRates struct {
+	cfg    *MoneyConfig
+	client *http.Client
+	log    *zap.Logger
+
+	// Held during a fetch, so concurrent callers wait for a single request
+	mu      sync.Mutex
+	current *exchangeRates
+	expires time.Time
+}
+
+// rates implements rateSource
+// When a refresh fails, the previous rates keep being used until the next attempt, one TTL later
+func (h *httpRates) rates() (*exchangeRates, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current != nil && time.Now().Before(h.expires) {
+		return h.current, nil
+	}
+
+	// The rates are shared, so their request is not bound to the execution that happens to trigger it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.cfg.HTTP.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	rates, err := h.fetch(ctx)
+	if err != nil {
+		if h.current == nil {
+			return nil, err
+		}
+		h.log.Warn("exchange rate refresh failed, using the previous rates", zap.Error(err))
+		rates = h.current
+	}
+	h.current = rates
+	h.expires = time.Now().Add(time.Duration(h.cfg.HTTP.TTLMs) * time.Millisecond)
+	return rates, nil
+}
+
+// fetch requests the rates from the provider
+func (h *httpRates) fetch(ctx context.Context) (*exchangeRates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.cfg.HTTP.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range h.cfg.HTTP.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rate provider returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, moneyMaxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > moneyMaxResponseBytes {
+		return nil, fmt.Errorf("rate provider response exceeds %d bytes", moneyMaxResponseBytes)
+	}
+	return parseExchangeRates(h.cfg.Base, body, h.cfg.HTTP.RatesPath)
+}
+
+// parseExchangeRates reads an object of rates by currency code from the JSON document data, at the dot-separated
+// path (the document itself when empty); rates are numbers or decimal strings, kept exactly as written
+func parseExchangeRates(base string, data []byte, path string) (*exchangeRates, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			obj, ok := doc.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("no rates object at %q", path)
+			}
+			doc = obj[key]
+		}
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("rates must be an object of rates by currency code")
+	}
+
+	rates := &exchangeRates{base: base, rates: make(map[string]decimal.Decimal, len(obj))}
+	for currency, value := range obj {
+		if !currencyCode.MatchString(currency) {
+			return nil, fmt.Errorf("invalid currency code %q", currency)
+		}
+		var text string
+		switch v := value.(type) {
+		case json.Number:
+			text = v.String()
+		case string:
+			text = v
+		}
+		rate, err := decimal.NewFromString(text)
+		if err != nil || !rate.IsPositive() {
+			return nil, fmt.Errorf("rate of %s must be a positive number", currency)
+		}
+		rates.rates[currency] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,190 @@
+package jsmachine
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"go.uber.org/zap"
+)
+
+// MQTT protocol limits: strings carry a two-byte length, and the remaining length of a packet is a variable byte
+// integer of at most four bytes
+const (
+	mqttMaxString          = 65535
+	mqttMaxRemainingLength = 268435455
+)
+
+// mqttClient publishes messages over one shared MQTT 5 connection
+// The connection is managed by autopaho: it is opened on first use, kept alive with pings and opened again in the
+// background after it failed, while publishes wait for it no longer than their context allows
+type mqttClient struct {
+	cfg *MQTTConfig
+	log *zap.Logger
+
+	mu sync.Mutex
+	cm *autopaho.ConnectionManager // nil until the first publish
+}
+
+// newMQTTClient creates a client; the broker is dialed on the first publish
+func newMQTTClient(cfg *MQTTConfig, log *zap.Logger) *mqttClient {
+	return &mqttClient{cfg: cfg, log: log}
+}
+
+// publish sends a message and, for QoS 1 and 2, waits until the broker acknowledged it or ctx is done
+func (c *mqttClient) publish(ctx context.Context, topic string, payload []byte, qos byte, retain bool) error {
+	if len(topic) > mqttMaxString {
+		return fmt.Errorf("topic exceeds %d bytes", mqttMaxString)
+	}
+	if mqttPublishLength(topic, payload, qos) > mqttMaxRemainingLength {
+		return fmt.Errorf("message exceeds the maximum packet size of %d bytes", mqttMaxRemainingLength)
+	}
+
+	cm, err := c.manager()
+	if err != nil {
+		return err
+	}
+	if err := cm.AwaitConnection(ctx); err != nil {
+		return fmt.Errorf("broker not connected: %w", err)
+	}
+
+	resp, err := cm.Publish(ctx, &paho.Publish{Topic: topic, Payload: payload, QoS: qos, Retain: retain})
+	if err != nil {
+		return err
+	}
+	// A PUBREC carrying an error ends a QoS 2 exchange without an error from paho
+	if resp != nil && resp.ReasonCode >= 0x80 {
+		reason := fmt.Sprintf("reason code 0x%02x", resp.ReasonCode)
+		if resp.Properties != nil && resp.Properties.ReasonString != "" {
+			reason = resp.Properties.ReasonString
+		}
+		return errors.New("rejected by the broker: " + reason)
+	}
+	return nil
+}
+
+// manager returns the connection manager, starting it on first use
+// Starting it does not wait for the network, so holding mu here never blocks a publish behind a dial
+func (c *mqttClient) manager() (*autopaho.ConnectionManager, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cm != nil {
+		return c.cm, nil
+	}
+
+	broker, _ := url.Parse(c.cfg.Broker) // validated with the configuration
+	timeout := time.Duration(c.cfg.TimeoutMs) * time.Millisecond
+	cfg := autopaho.ClientConfig{
+		ServerUrls: []*url.URL{broker},
+		KeepAlive:  uint16(c.cfg.KeepAliveS),
+		// Clean session: nothing is subscribed and unacknowledged messages are reported to scripts, not redelivered
+		CleanStartOnInitialConnection: true,
+		ConnectTimeout:                timeout,
+		ReconnectBackoff:              autopaho.NewExponentialBackoff(time.Second, time.Minute, 2*time.Second, 2),
+		ConnectUsername:               c.cfg.Username,
+		OnConnectError: func(err error) {
+			c.log.Warn("mqtt connection failed", zap.String("broker", broker.Redacted()), zap.Error(err))
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID:      c.cfg.ClientID,
+			PacketTimeout: timeout,
+		},
+	}
+	if c.cfg.Password != "" {
+		cfg.ConnectPassword = []byte(c.cfg.Password)
+	}
+	if broker.Scheme == "ssl" || broker.Scheme == "mqtts" {
+		tlsConfig, err := mqttTLSConfig(c.cfg, broker.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		cfg.TlsCfg = tlsConfig
+	}
+
+	// The manager outlives the publish that started it and is stopped by close
+	cm, err := autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.cm = cm
+	return cm, nil
+}
+
+// close disconnects from the broker, waiting at most timeout_ms for the DISCONNECT to be sent
+func (c *mqttClient) close() {
+	c.mu.Lock()
+	cm := c.cm
+	c.cm = nil
+	c.mu.Unlock()
+
+	if cm == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cfg.TimeoutMs)*time.Millisecond)
+	defer cancel()
+	_ = cm.Disconnect(ctx)
+}
+
+// mqttPublishLength returns the remaining length of a PUBLISH packet without properties
+func mqttPublishLength(topic string, payload []byte, qos byte) int {
+	n := 2 + len(topic) + 1 + len(payload) // topic, empty property length, payload
+	if qos > 0 {
+		n += 2 // packet identifier
+	}
+	return n
+}
+
+// mqttTLSConfig builds the TLS settings of ssl:// and mqtts:// brokers
+func mqttTLSConfig(cfg *MQTTConfig, serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle: no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// mqttTopicAllowed reports whether topic matches one of the patterns, which may use the + and # wildcards
+func mqttTopicAllowed(topic string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if mqttTopicMatch(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// mqttTopicMatch matches a topic against a filter: + matches one level, a trailing # any number of levels
+func mqttTopicMatch(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
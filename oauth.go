@@ -0,0 +1,156 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth client authentication methods
+const (
+	oauthAuthBasic = "basic" // client_secret_basic: credentials in the Authorization header
+	oauthAuthPost  = "post"  // client_secret_post: credentials in the form body
+)
+
+// oauthDefaultTTL applies to tokens whose response has no expires_in
+const oauthDefaultTTL = 5 * time.Minute
+
+// oauthMaxResponseBytes bounds token endpoint responses
+const oauthMaxResponseBytes = 64 << 10
+
+// oauthToken is a cached access token
+type oauthToken struct {
+	value   string
+	expires time.Time
+}
+
+// oauthTokens caches client-credentials tokens per provider, shared by every execution of the node
+type oauthTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*oauthToken
+
+	// One lock per provider, so a refresh does not hold up callers of other providers and concurrent callers
+	// wait for a single token request
+	locks map[string]*sync.Mutex
+}
+
+// newOAuthTokens creates an empty token cache
+func newOAuthTokens() *oauthTokens {
+	return &oauthTokens{
+		tokens: make(map[string]*oauthToken),
+		locks:  make(map[string]*sync.Mutex),
+	}
+}
+
+// get returns a token of provider valid for at least margin, requesting a new one when needed
+// When the refresh fails, a token that has not expired yet is returned along with the error
+func (t *oauthTokens) get(name string, margin time.Duration, request func() (*oauthToken, error)) (string, error) {
+	t.mu.Lock()
+	lock, ok := t.locks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		t.locks[name] = lock
+	}
+	t.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	t.mu.Lock()
+	cached := t.tokens[name]
+	t.mu.Unlock()
+	if cached != nil && time.Until(cached.expires) > margin {
+		return cached.value, nil
+	}
+
+	token, err := request()
+	if err != nil {
+		if cached != nil && time.Now().Before(cached.expires) {
+			return cached.value, err
+		}
+		return "", err
+	}
+
+	t.mu.Lock()
+	t.tokens[name] = token
+	t.mu.Unlock()
+	return token.value, nil
+}
+
+// clear drops every cached token
+func (t *oauthTokens) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clear(t.tokens)
+}
+
+// requestOAuthToken obtains a token from the provider's token endpoint with the client credentials grant
+func requestOAuthToken(ctx context.Context, client *http.Client, provider *OAuthProviderConfig) (*oauthToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(provider.Scopes) > 0 {
+		form.Set("scope", strings.Join(provider.Scopes, " "))
+	}
+	if provider.Audience != "" {
+		form.Set("audience", provider.Audience)
+	}
+	if provider.AuthMethod == oauthAuthPost {
+		form.Set("client_id", provider.ClientID)
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.AuthMethod == oauthAuthBasic {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oauthMaxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if parsed.Error != "" {
+			return nil, fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, parsed.Error, parsed.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+	if parsed.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+
+	// Lifetimes count from when the request was sent, so a slow response does not extend them
+	ttl := oauthDefaultTTL
+	if parsed.ExpiresIn > 0 {
+		ttl = time.Duration(parsed.ExpiresIn) * time.Second
+	}
+	return &oauthToken{value: parsed.AccessToken, expires: started.Add(ttl)}, nil
+}
@@ -0,0 +1,236 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// otlpQueueSize is the number of log records buffered before new records are dropped
+const otlpQueueSize = 8192
+
+// OTLP severity numbers (https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber)
+var otlpSeverity = map[string]int{
+	"debug": 5,
+	"info":  9,
+	"warn":  13,
+	"error": 17,
+}
+
+// otlpRecord is a script log entry queued for export
+type otlpRecord struct {
+	time      time.Time
+	level     string
+	message   string
+	fields    map[string]interface{}
+	requestID string
+	tag       string
+	traceID   string
+	spanID    string
+}
+
+// otlpExporter batches script logs and ships them to an OTLP/HTTP collector using the JSON encoding
+type otlpExporter struct {
+	cfg    *OTLPConfig
+	log    *zap.Logger
+	client *http.Client
+
+	records chan otlpRecord
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// newOTLPExporter creates an exporter for the given configuration
+func newOTLPExporter(cfg *OTLPConfig, log *zap.Logger) *otlpExporter {
+	return &otlpExporter{
+		cfg:     cfg,
+		log:     log,
+		client:  &http.Client{Timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond},
+		records: make(chan otlpRecord, otlpQueueSize),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// emit queues a record without blocking the script; records are dropped when the queue is full
+func (e *otlpExporter) emit(rec otlpRecord) {
+	select {
+	case e.records <- rec:
+	default:
+	}
+}
+
+// run batches records until stop is called
+func (e *otlpExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(time.Duration(e.cfg.FlushIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	batch := make([]otlpRecord, 0, e.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.log.Warn("failed to export script logs", zap.Int("records", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case rec := <-e.records:
+			batch = append(batch, rec)
+			if len(batch) >= e.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.quit:
+			// Drain whatever is still queued
+			for {
+				select {
+				case rec := <-e.records:
+					batch = append(batch, rec)
+					if len(batch) >= e.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// stop flushes pending records and waits for the exporter to finish (bounded by ctx)
+func (e *otlpExporter) stop(ctx context.Context) {
+	close(e.quit)
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+}
+
+// export sends a batch to the collector
+func (e *otlpExporter) export(batch []otlpRecord) error {
+	records := make([]map[string]interface{}, 0, len(batch))
+	for _, rec := range batch {
+		attrs := make([]map[string]interface{}, 0, len(rec.fields)+1)
+		if rec.requestID != "" {
+			attrs = append(attrs, otlpAttribute("request_id", rec.requestID))
+		}
+		if rec.tag != "" {
+			attrs = append(attrs, otlpAttribute("tag", rec.tag))
+		}
+		for k, v := range rec.fields {
+			attrs = append(attrs, otlpAttribute(k, v))
+		}
+
+		record := map[string]interface{}{
+			"timeUnixNano":   strconv.FormatInt(rec.time.UnixNano(), 10),
+			"severityNumber": otlpSeverity[rec.level],
+			"severityText":   strings.ToUpper(rec.level),
+			"body":           map[string]interface{}{"stringValue": rec.message},
+			"attributes":     attrs,
+		}
+		if rec.traceID != "" {
+			record["traceId"] = rec.traceID
+			record["spanId"] = rec.spanID
+		}
+		records = append(records, record)
+	}
+
+	payload := map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []interface{}{otlpAttribute("service.name", e.cfg.ServiceName)},
+				},
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope":      map[string]interface{}{"name": PluginName},
+						"logRecords": records,
+					},
+				},
+			},
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(e.cfg.Endpoint, "/")+"/v1/logs", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpAttribute converts a key/value pair to an OTLP KeyValue
+func otlpAttribute(key string, value interface{}) map[string]interface{} {
+	var v map[string]interface{}
+	switch val := value.(type) {
+	case string:
+		v = map[string]interface{}{"stringValue": val}
+	case bool:
+		v = map[string]interface{}{"boolValue": val}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(val)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": val}
+	default:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			encoded = []byte(fmt.Sprint(val))
+		}
+		v = map[string]interface{}{"stringValue": string(encoded)}
+	}
+	return map[string]interface{}{"key": key, "value": v}
+}
+
+// parseTraceParent extracts trace and span IDs from a W3C traceparent header
+// Returns empty strings if the header is malformed
+func parseTraceParent(header string) (traceID, spanID string) {
+	// version-traceid-parentid-flags
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return "", ""
+	}
+	if _, err := hex.DecodeString(parts[2]); err != nil {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
@@ -0,0 +1,96 @@
+package jsmachine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PartialResult is the latest intermediate result a running script published with control.partial
+type PartialResult struct {
+	Result interface{} `json:"result"`
+
+	// Number of partial results published so far by the execution
+	Sequence int `json:"sequence"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// partialEntry is a published partial result and the execution owning it
+type partialEntry struct {
+	exec   *execution
+	result *PartialResult
+}
+
+// partialStore keeps the latest partial result of running executions by request ID
+type partialStore struct {
+	mu      sync.Mutex
+	entries map[string]*partialEntry
+}
+
+// newPartialStore creates an empty store
+func newPartialStore() *partialStore {
+	return &partialStore{
+		entries: make(map[string]*partialEntry),
+	}
+}
+
+// publish replaces the execution's partial result and returns it
+func (s *partialStore) publish(exec *execution, result interface{}) *PartialResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sequence := 1
+	if entry, ok := s.entries[exec.requestID]; ok && entry.exec == exec {
+		sequence = entry.result.Sequence + 1
+	}
+
+	partial := &PartialResult{Result: result, Sequence: sequence, UpdatedAt: time.Now()}
+	s.entries[exec.requestID] = &partialEntry{exec: exec, result: partial}
+	return partial
+}
+
+// get returns the latest partial result published for a request ID
+func (s *partialStore) get(requestID string) (*PartialResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[requestID]
+	if !ok {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// remove drops the execution's partial result once it completed
+func (s *partialStore) remove(exec *execution) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[exec.requestID]; ok && entry.exec == exec {
+		delete(s.entries, exec.requestID)
+	}
+}
+
+// GetPartialRequest selects a running execution by request ID
+type GetPartialRequest struct {
+	RequestID string `json:"request_id"`
+}
+
+// GetPartialResponse contains the latest partial result, if any
+type GetPartialResponse struct {
+	// False when the execution published nothing yet or has already completed
+	Found bool `json:"found"`
+
+	Partial *PartialResult `json:"partial,omitempty"`
+}
+
+// GetPartial returns the latest intermediate result of a running execution
+func (r *rpc) GetPartial(req *GetPartialRequest, resp *GetPartialResponse) error {
+	if req.RequestID == "" {
+		return fmt.Errorf("request_id is required")
+	}
+
+	resp.Partial, resp.Found = r.plugin.partials.get(req.RequestID)
+	return nil
+}
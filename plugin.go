@@ -3,26 +3,33 @@ package jsmachine
 import (
 	"context"
 	"fmt"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/api/v4/plugins/v1/kv"
+	"github.com/roadrunner-server/endure/v2/dep"
 	"github.com/robertkrimen/otto"
 	"go.uber.org/zap"
 )
 
 const (
 	PluginName = "js"
+
+	// reporterFlushTimeout bounds how long Stop waits for error reports to be delivered
+	reporterFlushTimeout = 2 * time.Second
 )
 
-// MetricsPlugin interface for accessing metrics plugin's collectors
-type MetricsPlugin interface {
-	Name() string
-}
+// MetricsRegistry is implemented by the metrics plugin to expose the collectors declared in it
+type MetricsRegistry interface {
+	// Collector returns the collector declared under name
+	Collector(name string) (prometheus.Collector, bool)
 
-// metricsPluginInternal provides access to metrics plugin's internal collectors sync.Map
-type metricsPluginInternal struct {
-	collectors sync.Map // name -> *collector
+	// Generation changes whenever collectors are unregistered or the registry is reset,
+	// invalidating collectors looked up before
+	Generation() uint64
 }
 
 // Plugin represents the JavaScript execution plugin
@@ -30,28 +37,177 @@ type Plugin struct {
 	log *zap.Logger
 	cfg *Config
 
-	// VM pool management
-	vmPool     chan *otto.Otto
-	vmPoolSize int
-	mu         sync.RWMutex
+	// Instance name, set by Instance; empty for the default instance
+	name string
+
+	// Source of the configuration, read again by reloads; reloads run one at a time
+	configurer Configurer
+	reloadMu   sync.Mutex
+
+	// VM pools: registered scripts and, unless untrusted is configured, ad-hoc code run in pool
+	// Replaced by configuration reloads, so they are read through poolFor and pools
+	pool      *vmPool
+	untrusted *vmPool
+	mu        sync.RWMutex
+
+	// Serializes js_pool_available updates with pool retirement (see vmPool.availableMu)
+	availableMu sync.Mutex
 
 	// Go bindings for JavaScript
 	bindings *Bindings
 
+	// gRPC endpoint (nil when disabled)
+	grpcServer *grpcServer
+
+	// Per-VM execution state (*otto.Otto -> *execution)
+	executions sync.Map
+
+	// Execution event subscribers (WebSocket streaming)
+	streams *streamHub
+
+	// Latest control.partial results of running executions
+	partials *partialStore
+
+	// OTLP exporter for script logs (nil when disabled)
+	otlp *otlpExporter
+
+	// Script failure reporting (nil when disabled)
+	reporter       errorReporter
+	failureWebhook *failureWebhook
+
+	// Storage for executions that exhausted retries (nil when disabled)
+	deadLetters deadLetterStore
+
+	// Audit log of completed executions (nil when disabled)
+	auditLog *auditLog
+
+	// Binding-level traces of recent executions (nil when disabled)
+	traces *traceRing
+
+	// RR lock plugin (nil when not available, locks are then process-local)
+	lockPlugin distributedLocker
+
+	// RR jobs plugin (nil when not available, script sinks then fail their pushes)
+	jobsPlugin jobsPusher
+
+	// KV drivers of the RR kv plugin by name, used to open the storages of features keeping state in KV
+	kvDrivers map[string]kv.Constructor
+
+	// Feature flags (nil when disabled)
+	flags *flagStore
+
+	// Limiter behind ratelimit.allow (in-process or Redis, per js.ratelimit.driver)
+	rateLimiter rateLimiter
+
+	// HTTP clients shared by fetch calls of all VMs, the default one and one per profile
+	fetchClients *fetchClients
+
+	// Exchange rates behind money.convert (nil when disabled)
+	rates rateSource
+
+	// Resolver behind discovery.resolve (nil when disabled)
+	discovery *serviceDiscovery
+
+	// Background delivery of webhook.dispatch (nil when disabled)
+	webhooks *webhookDispatcher
+
+	// Connections and method descriptors behind grpc.call (nil when disabled)
+	grpcTargets *grpcTargets
+
+	// Broker connection behind mqtt.publish (nil when disabled)
+	mqtt *mqttClient
+
+	// Cron schedules of registered scripts (nil when disabled)
+	scheduler *scheduler
+
+	// Execution costs and daily budgets per caller (nil when disabled)
+	costs *costLedger
+
+	// Driver and templates behind mail.send (nil when disabled)
+	mailer        mailer
+	mailTemplates map[string]*mailTemplate
+
+	// Scrubs script data written to logs, audit records and error reports (nil when disabled)
+	redactor *redactor
+
+	// Translation catalogs (nil when disabled)
+	i18n *i18nCatalogs
+
+	// Shared libraries compiled at startup, replaced when the module cache is flushed
+	libraries atomic.Pointer[librarySet]
+
+	// Registered scripts (nil when disabled)
+	scripts *scriptRegistry
+
+	// Compiled ad-hoc code (nil when disabled)
+	programs *programCache
+
+	// Large results returned by handle (nil when disabled)
+	results *resultStore
+
+	// Deduplication of executions by dedupe_key (nil when disabled or its storage is not available)
+	dedupe *deduper
+
+	// Per-script concurrency limits (nil when no script registry is configured)
+	limiter *concurrencyLimiter
+
+	// Concurrency groups shared by scripts and outbound clients (empty when none are configured)
+	groups *concurrencyLimiter
+
+	// Overlay globals of scripts (script -> name -> JSON value) and the names defined in every VM
+	overlayValues map[string]map[string]string
+	overlayNames  []string
+
+	// Readiness, withheld until script self-tests pass
+	ready atomic.Bool
+
+	// Backpressure: moving average of execution durations (ns)
+	avgDuration atomic.Int64
+
 	// Graceful shutdown
 	stopCh chan struct{}
-	wg     sync.WaitGroup
+
+	// Plugin state and in-flight executions
+	lifecycle lifecycle
 
 	// Prometheus metrics
 	executionsTotal   *prometheus.CounterVec
 	executionDuration *prometheus.HistogramVec
-	poolSizeGauge     prometheus.Gauge
-	poolAvailable     prometheus.Gauge
-	activeExecutions  prometheus.Gauge
+	compileDuration   prometheus.Histogram
+	runDuration       *prometheus.HistogramVec
+	programsTotal     *prometheus.CounterVec
+	executionRetries  *prometheus.CounterVec
+	overThreshold     *prometheus.CounterVec
+	poolSizeGauge     *prometheus.GaugeVec
+	poolAvailable     *prometheus.GaugeVec
+	poolVMs           *prometheus.GaugeVec
+	storedResultBytes prometheus.Gauge
+	sinkJobs          *prometheus.CounterVec
+	dedupeRequests    *prometheus.CounterVec
+	activeExecutions  *prometheus.GaugeVec
 	codeSize          prometheus.Histogram
 
+	scriptConcurrencyWait    *prometheus.HistogramVec
+	scriptConcurrencyWaiting *prometheus.GaugeVec
+	groupWait                *prometheus.HistogramVec
+	groupWaiting             *prometheus.GaugeVec
+
+	fetchRequests *prometheus.CounterVec
+	fetchDuration *prometheus.HistogramVec
+	mailMessages  *prometheus.CounterVec
+
+	webhookDeliveries *prometheus.CounterVec
+	grpcCalls         *prometheus.CounterVec
+	mqttMessages      *prometheus.CounterVec
+	scheduledRuns     *prometheus.CounterVec
+	callerCosts       *prometheus.CounterVec
+	budgetRejections  *prometheus.CounterVec
+
 	// Metrics plugin reference (for accessing user-defined metrics)
-	metricsPlugin *metricsPluginInternal
+	metricsPlugin MetricsRegistry
+
+	// Metrics plugin registry the js_* collectors are registered with (nil when the plugin is absent)
+	metricsRegistrar collectorRegistrar
 }
 
 // Configurer interface for configuration access
@@ -71,31 +227,268 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 
 	// Initialize configuration with defaults
 	p.cfg = &Config{}
+	p.configurer = cfg
 
 	// Try to unmarshal config if it exists
-	if cfg.Has(PluginName) {
-		if err := cfg.UnmarshalKey(PluginName, p.cfg); err != nil {
+	if cfg.Has(p.Name()) {
+		if err := cfg.UnmarshalKey(p.Name(), p.cfg); err != nil {
 			return fmt.Errorf("%s: failed to unmarshal config: %w", op, err)
 		}
 	}
 
 	// Always set defaults (fills in missing values)
-	p.cfg.InitDefaults()
+	p.cfg.initDefaults(p.Name())
 
 	// Validate configuration
 	if err := p.cfg.Validate(); err != nil {
 		return fmt.Errorf("%s: config validation failed: %w", op, err)
 	}
+	// The RR gRPC plugin can serve the js.v1.JSMachine service of one instance only
+	if p.name != "" && p.cfg.GRPC != nil && p.cfg.GRPC.Listen == "" {
+		return fmt.Errorf("%s: config validation failed: grpc.listen is required for instance %s", op, p.name)
+	}
 
 	// Initialize logger
-	p.log = log.NamedLogger(PluginName)
+	p.log = log.NamedLogger(p.Name())
 
 	// Initialize metrics
 	p.initMetrics()
 
+	// Initialize event streaming hub
+	p.streams = newStreamHub()
+	p.partials = newPartialStore()
+
+	// Initialize execution tracing
+	if p.cfg.Tracing != nil {
+		p.traces = newTraceRing(p.cfg.Tracing.Capacity)
+	}
+
+	// Initialize the result store
+	if p.cfg.Results != nil {
+		p.results = newResultStore(p.cfg.Results, p.storedResultBytes)
+	}
+
+	// Initialize cost accounting
+	if p.cfg.Costs != nil {
+		p.costs = newCostLedger(p.cfg.Costs, p.callerCosts, p.budgetRejections)
+	}
+
+	// Initialize OTLP log exporter
+	if p.cfg.OTLP != nil {
+		p.otlp = newOTLPExporter(p.cfg.OTLP, p.log)
+	}
+
+	// Compile redaction rules before anything that writes script data
+	if p.cfg.Redaction != nil {
+		redactor, err := newRedactor(p.cfg.Redaction)
+		if err != nil {
+			return fmt.Errorf("%s: invalid redaction pattern: %w", op, err)
+		}
+		p.redactor = redactor
+	}
+
+	// Initialize error reporter
+	if p.cfg.Sentry != nil {
+		reporter, err := newSentryReporter(p.cfg.Sentry, p.log)
+		if err != nil {
+			return fmt.Errorf("%s: failed to initialize sentry: %w", op, err)
+		}
+		p.reporter = reporter
+	}
+
+	// Initialize failure webhook
+	if p.cfg.OnFailureWebhook != nil {
+		p.failureWebhook = newFailureWebhook(p.cfg.OnFailureWebhook, p.log)
+	}
+
+	// Initialize dead-letter store (the kv driver is opened by Serve, once the KV drivers are collected)
+	if p.cfg.DeadLetter != nil && p.cfg.DeadLetter.Driver != "kv" {
+		store, err := p.newDeadLetterStore(p.cfg.DeadLetter)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.deadLetters = store
+	}
+
+	// Initialize audit log
+	if p.cfg.Audit != nil {
+		audit, err := newAuditLog(p.cfg.Audit, p.log)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.auditLog = audit
+	}
+
+	// Initialize feature flags
+	if p.cfg.Flags != nil {
+		flags, err := newFlagStore(p.cfg.Flags, p.log)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.flags = flags
+	}
+
+	// Initialize concurrency groups before the clients holding their slots
+	p.groups = newConcurrencyLimiter(p.cfg.ConcurrencyGroups, p.groupWait, p.groupWaiting)
+
+	// Initialize the rate limiter (Redis connections are opened on first use)
+	p.rateLimiter = newRateLimiter(p.cfg.RateLimit)
+
+	// Initialize the pooled HTTP clients behind fetch, loading profile certificates
+	fetchClients, err := newFetchClients(p.cfg.Fetch, p.groups, p.fetchRequests, p.fetchDuration)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	p.fetchClients = fetchClients
+
+	// Load the exchange rates of the file source (the http source fetches them on first use)
+	if p.cfg.Money != nil {
+		rates, err := newRateSource(p.cfg.Money, p.fetchClients, p.log)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.rates = rates
+	}
+
+	// Initialize service discovery
+	if p.cfg.Discovery != nil {
+		p.discovery = newServiceDiscovery(p.cfg.Discovery)
+	}
+
+	// Load descriptor sets and create the gRPC client connections
+	if p.cfg.GRPCClient != nil {
+		targets, err := newGRPCTargets(p.cfg.GRPCClient.Targets, p.groups)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.grpcTargets = targets
+	}
+
+	// Initialize the MQTT client (the broker is dialed on the first publish)
+	if p.cfg.MQTT != nil {
+		p.mqtt = newMQTTClient(p.cfg.MQTT, p.log)
+	}
+
+	// Initialize the webhook dispatcher (workers start with Serve)
+	if p.cfg.Webhooks != nil {
+		p.webhooks = newWebhookDispatcher(p.cfg.Webhooks, p.log, p.webhookDeliveries)
+	}
+
+	// Initialize the mail driver and parse mail templates
+	if p.cfg.Mail != nil {
+		p.mailer = newMailer(p.cfg.Mail, p.log)
+		if dir := p.cfg.Mail.TemplatesDir; dir != "" {
+			templates, err := loadMailTemplates(dir)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			p.mailTemplates = templates
+		}
+	}
+
+	// Load translation catalogs
+	if p.cfg.I18n != nil {
+		catalogs, err := newI18nCatalogs(p.cfg.I18n, p.log)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.i18n = catalogs
+	}
+
+	// Compile shared libraries
+	libraries, err := loadLibraries(p.cfg.Libraries)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	p.libraries.Store(libraries)
+
+	// Load script registry
+	if p.cfg.Scripts != nil {
+		scripts, err := newScriptRegistry(p.cfg.Scripts.Dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.scripts = scripts
+		p.limiter = newConcurrencyLimiter(p.cfg.Scripts.MaxConcurrency, p.scriptConcurrencyWait, p.scriptConcurrencyWaiting)
+		p.overlayValues, p.overlayNames = p.overlayGlobals()
+
+		for name := range p.cfg.Scripts.Environments {
+			if _, err := scripts.get(name); err != nil {
+				p.log.Warn("environment overlay configured for an unknown script", zap.String("script", name))
+			}
+		}
+
+		for name := range p.cfg.Scripts.MaxConcurrency {
+			if _, err := scripts.get(name); err != nil {
+				p.log.Warn("max_concurrency configured for an unknown script", zap.String("script", name))
+			}
+		}
+
+		for name := range p.cfg.Scripts.Sinks {
+			if _, err := scripts.get(name); err != nil {
+				p.log.Warn("sink configured for an unknown script", zap.String("script", name))
+			}
+		}
+
+		if w := p.cfg.OnFailureWebhook; w != nil {
+			for _, name := range w.Scripts {
+				if _, err := scripts.get(name); err != nil {
+					p.log.Warn("failure webhook configured for an unknown script", zap.String("script", name))
+				}
+			}
+		}
+	}
+
+	// Scripts are prewarmed on the VMs filled by Serve
+	for _, name := range p.cfg.Prewarm {
+		if _, err := p.scripts.get(name); err != nil {
+			return fmt.Errorf("%s: prewarm: %w", op, err)
+		}
+	}
+
+	// Compile the ad-hoc code kept by the previous run, so its first executions do not parse it again
+	if c := p.cfg.ProgramCache; c != nil {
+		p.programs = newProgramCache(c.MaxEntries)
+		if c.Path != "" {
+			loaded, err := p.programs.load(c.Path)
+			if err != nil {
+				p.log.Warn("program cache not restored", zap.Error(err))
+			} else {
+				p.log.Info("program cache restored", zap.Int("programs", loaded))
+			}
+		}
+	}
+
+	// Parse schedules and load their last runs (loops start with Serve)
+	if p.cfg.Schedules != nil {
+		for name, job := range p.cfg.Schedules.Jobs {
+			if _, err := p.scripts.get(job.Script); err != nil {
+				return fmt.Errorf("%s: schedules.jobs.%s: %w", op, name, err)
+			}
+		}
+		sched, err := newScheduler(p.cfg.Schedules, p.log, p.scheduledRuns)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if p.cfg.Schedules.State.Driver != scheduleStateKV {
+			if sched.store, err = p.newScheduleStore(p.cfg.Schedules.State); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		sched.run = p.runScheduled
+		p.scheduler = sched
+	}
+
 	// Initialize bindings
 	p.bindings = newBindings(p.log, p)
 
+	// Create the VM pools (filled with Serve)
+	trusted, untrusted, err := newPools(p.cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	p.pool, p.untrusted = trusted, untrusted
+
 	p.log.Info("JavaScript plugin initialized",
 		zap.Int("pool_size", p.cfg.PoolSize),
 		zap.Int("max_memory_mb", p.cfg.MaxMemoryMB),
@@ -105,8 +498,11 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 	return nil
 }
 
-// Name returns plugin name
+// Name returns plugin name, the name of the instance for further instances
 func (p *Plugin) Name() string {
+	if p.name != "" {
+		return p.name
+	}
 	return PluginName
 }
 
@@ -114,58 +510,225 @@ func (p *Plugin) Name() string {
 func (p *Plugin) Serve() chan error {
 	errCh := make(chan error, 1)
 
-	p.vmPoolSize = p.cfg.PoolSize
-	p.vmPool = make(chan *otto.Otto, p.vmPoolSize)
 	p.stopCh = make(chan struct{})
 
-	// Initialize VM pool
-	for i := 0; i < p.vmPoolSize; i++ {
-		vm := otto.New()
+	// Initialize VM pools
+	for _, pool := range p.pools() {
+		if err := p.fillPool(pool); err != nil {
+			p.log.Error("failed to initialize VM pool", zap.String("pool", pool.name), zap.Error(err))
+			errCh <- err
+			return errCh
+		}
+		pool.available.Set(float64(len(pool.vms)))
+	}
+
+	if p.metricsRegistrar == nil {
+		p.log.Info("metrics plugin not available, js_* metrics are not exported")
+	}
+
+	// Open the storage shared results are kept in; without it dedupe keys are ignored
+	if d := p.cfg.Dedupe; d != nil {
+		storage, err := p.kvStorage(d.Storage)
+		if err != nil {
+			p.log.Warn("dedupe storage not available, dedupe_key is ignored", zap.String("storage", d.Storage), zap.Error(err))
+		} else {
+			p.dedupe = &deduper{cfg: d, storage: storage, locker: p.bindings.lock.locker(), requests: p.dedupeRequests, log: p.log}
+		}
+	}
+
+	// Open the kv dead-letter store
+	if d := p.cfg.DeadLetter; d != nil && d.Driver == "kv" {
+		store, err := p.newDeadLetterStore(d)
+		if err != nil {
+			errCh <- err
+			return errCh
+		}
+		p.deadLetters = store
+	}
 
-		// Set up interrupt channel for timeout handling
-		vm.Interrupt = make(chan func(), 1)
+	// Keep cache binding values in the kv storage
+	if c := p.cfg.Cache; c.Storage != "" {
+		storage, err := p.kvStorage(c.Storage)
+		if err != nil {
+			errCh <- fmt.Errorf("cache storage: %w", err)
+			return errCh
+		}
+		p.bindings.cache.store = &kvCache{storage: storage, prefix: c.Prefix}
+	}
 
-		// Inject Go bindings into VM
-		if err := p.bindings.injectIntoVM(vm); err != nil {
-			p.log.Error("failed to inject bindings into VM", zap.Error(err))
-			errCh <- fmt.Errorf("failed to inject bindings: %w", err)
+	// Open the kv schedule state store
+	if s := p.cfg.Schedules; s != nil && s.State.Driver == scheduleStateKV {
+		store, err := p.newScheduleStore(s.State)
+		if err != nil {
+			errCh <- err
 			return errCh
 		}
+		p.scheduler.store = store
+	}
+
+	// Pools are ready, start accepting executions
+	p.lifecycle.transition(stateInit, stateServing)
+
+	// Self-test registered scripts before reporting ready
+	if p.scripts != nil && p.cfg.Scripts.SelfTest != nil {
+		go p.runSelfTests()
+	} else {
+		p.ready.Store(true)
+	}
+
+	// Reload the configuration on SIGHUP
+	go p.watchHangup()
 
-		p.vmPool <- vm
+	// Start OTLP log exporter
+	if p.otlp != nil {
+		go p.otlp.run()
+	}
+
+	// Start failure webhook delivery
+	if p.failureWebhook != nil {
+		go p.failureWebhook.run()
+	}
+
+	// Start dispatching webhooks queued by scripts
+	if p.webhooks != nil {
+		p.webhooks.run()
+	}
+
+	// Watch translation catalogs for changes
+	if p.i18n != nil {
+		go p.i18n.run()
+	}
+
+	// Catch up on missed scheduled runs and start the schedules
+	if p.scheduler != nil {
+		p.scheduler.start()
+	}
+
+	// Start the gRPC endpoint when it has its own listen address; otherwise the service was registered with the RR
+	// gRPC plugin when it was collected
+	if p.cfg.GRPC != nil && p.cfg.GRPC.Listen != "" {
+		srv := newGRPCServer(p)
+		p.grpcServer = srv
+
+		go func() {
+			if err := srv.serve(p.cfg.GRPC.Listen); err != nil {
+				errCh <- fmt.Errorf("gRPC server error: %w", err)
+			}
+		}()
+	} else if p.cfg.GRPC != nil && p.grpcServer == nil {
+		errCh <- fmt.Errorf("grpc.listen is required when the RR gRPC plugin is not available")
+		return errCh
 	}
 
 	p.log.Info("JavaScript plugin started",
-		zap.Int("pool_size", p.vmPoolSize),
+		zap.Int("pool_size", p.pool.size),
+		zap.Int64("vms_built", p.pool.built.Load()),
 		zap.Int("default_timeout_ms", p.cfg.DefaultTimeout),
 	)
+	if p.untrusted != nil {
+		p.log.Info("ad-hoc code runs in the untrusted pool",
+			zap.Int("pool_size", p.untrusted.size),
+			zap.Int("default_timeout_ms", p.cfg.Untrusted.DefaultTimeout),
+			zap.Strings("bindings", p.untrusted.bindings),
+		)
+	}
 
 	return errCh
 }
 
 // Stop gracefully shuts down the plugin
 func (p *Plugin) Stop(ctx context.Context) error {
+	// Stop before a successful Serve has nothing to shut down, and Stop is idempotent
+	if !p.lifecycle.transition(stateServing, stateDraining) {
+		if p.lifecycle.transition(stateInit, stateStopped) {
+			if p.scripts != nil {
+				p.scripts.close()
+			}
+			p.log.Info("JavaScript plugin stopped before serving")
+		}
+		return nil
+	}
+
 	p.log.Info("Stopping JavaScript plugin...")
 
+	// Stop accepting gRPC calls and drain in-flight ones first
+	if p.grpcServer != nil {
+		p.grpcServer.stop(ctx)
+	}
+
+	// Stop starting scheduled runs; runs in progress finish with the other executions
+	if p.scheduler != nil {
+		p.scheduler.stop(ctx)
+	}
+
 	// Signal shutdown
 	close(p.stopCh)
 
 	// Wait for active executions with timeout
-	done := make(chan struct{})
-	go func() {
-		p.wg.Wait()
-		close(done)
-	}()
-
 	select {
-	case <-done:
+	case <-p.lifecycle.wait():
 		p.log.Info("All JavaScript executions completed")
 	case <-ctx.Done():
 		p.log.Warn("Timeout waiting for JavaScript executions, forcing shutdown")
 	}
 
-	// Close VM pool
-	close(p.vmPool)
+	// Close idle rate limiter and fetch connections, and the gRPC client and MQTT connections
+	if p.rateLimiter != nil {
+		p.rateLimiter.close()
+	}
+	if p.fetchClients != nil {
+		p.fetchClients.closeIdle()
+	}
+	if p.grpcTargets != nil {
+		p.grpcTargets.close()
+	}
+	if p.mqtt != nil {
+		p.mqtt.close()
+	}
+
+	// Flush script logs still queued for export
+	if p.otlp != nil {
+		p.otlp.stop(ctx)
+	}
+
+	// Deliver pending error reports
+	if p.reporter != nil {
+		p.reporter.flush(reporterFlushTimeout)
+	}
+	if p.failureWebhook != nil {
+		p.failureWebhook.stop(ctx)
+	}
+	if p.webhooks != nil {
+		p.webhooks.stop(ctx)
+	}
+
+	// Stop watching translation catalogs
+	if p.i18n != nil {
+		p.i18n.stop(ctx)
+	}
+
+	if p.auditLog != nil {
+		p.auditLog.close()
+	}
+	if p.scripts != nil {
+		p.scripts.close()
+	}
+	if p.dedupe != nil {
+		p.dedupe.storage.Stop()
+	}
+	if store, ok := p.bindings.cache.store.(*kvCache); ok {
+		store.storage.Stop()
+	}
+
+	// Keep the compiled ad-hoc code for the next start
+	if p.programs != nil && p.cfg.ProgramCache.Path != "" {
+		if err := p.programs.save(p.cfg.ProgramCache.Path); err != nil {
+			p.log.Error("failed to save the program cache", zap.Error(err))
+		}
+	}
+
+	// VM pools are not closed: executions still running after a drain timeout return their VM to them
+	p.lifecycle.transition(stateDraining, stateStopped)
 
 	return nil
 }
@@ -178,144 +741,257 @@ func (p *Plugin) RPC() interface{} {
 	}
 }
 
-// Collects declares plugin dependencies - collects metrics plugin if available
-func (p *Plugin) Collects() []interface{} {
-	return []interface{}{
-		// Collect metrics plugin (optional dependency)
-		// This allows JavaScript to manipulate metrics registered in the metrics plugin
-		func(plugin interface{}) {
-			// Type assert to get access to the metrics plugin
-			// We need access to its internal collectors sync.Map
-			if mp, ok := plugin.(interface{ Name() string }); ok {
-				if mp.Name() == "metrics" {
-					// Use type assertion to access the internal structure
-					// This is safe because we control both plugins
-					if internal, ok := plugin.(interface {
-						GetCollectors() *sync.Map
-					}); ok {
-						p.metricsPlugin = &metricsPluginInternal{
-							collectors: *internal.GetCollectors(),
-						}
-						p.log.Info("metrics plugin collected, JavaScript can now access user metrics")
-					}
-				}
+// Collects declares the optional plugins the JavaScript plugin integrates with
+func (p *Plugin) Collects() []*dep.In {
+	return []*dep.In{
+		// Metrics plugin: lets JavaScript manipulate metrics declared in it
+		dep.Fits(func(plugin any) {
+			p.metricsPlugin = plugin.(MetricsRegistry)
+			p.log.Info("metrics plugin collected, JavaScript can now access user metrics")
+		}, (*MetricsRegistry)(nil)),
+		// Metrics plugin: exports the js_* collectors
+		dep.Fits(func(plugin any) {
+			p.metricsRegistrar = plugin.(collectorRegistrar)
+			p.registerCollectors()
+		}, (*collectorRegistrar)(nil)),
+		// Jobs plugin: receives the output of scripts with a sink
+		dep.Fits(func(plugin any) {
+			p.jobsPlugin = plugin.(jobsPusher)
+			p.log.Info("jobs plugin collected, script sinks can now push jobs")
+		}, (*jobsPusher)(nil)),
+		// KV drivers: open the storage of js.dedupe
+		dep.Fits(func(plugin any) {
+			driver := plugin.(kv.Constructor)
+			if p.kvDrivers == nil {
+				p.kvDrivers = make(map[string]kv.Constructor)
 			}
-		},
+			p.kvDrivers[driver.Name()] = driver
+		}, (*kv.Constructor)(nil)),
+		// Lock plugin: without it, lock.acquire/lock.release only coordinate executions within this process
+		dep.Fits(func(plugin any) {
+			p.lockPlugin = plugin.(distributedLocker)
+			p.log.Info("lock plugin collected, JavaScript locks are now distributed")
+		}, (*distributedLocker)(nil)),
+		// gRPC plugin: serves the js.v1.JSMachine service unless js.grpc.listen is set
+		dep.Fits(func(plugin any) {
+			if p.cfg.GRPC == nil || p.cfg.GRPC.Listen != "" {
+				return
+			}
+			p.grpcServer = newGRPCServer(p)
+			p.grpcServer.register(plugin.(grpcServiceRegistrar))
+			p.log.Info("gRPC plugin collected, js.v1.JSMachine is served by it")
+		}, (*grpcServiceRegistrar)(nil)),
 	}
 }
 
-// acquireVM gets a VM from the pool
-func (p *Plugin) acquireVM(ctx context.Context) (*otto.Otto, error) {
-	select {
-	case vm := <-p.vmPool:
-		return vm, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-p.stopCh:
-		return nil, fmt.Errorf("plugin is shutting down")
+// Reset drops state derived from other plugins, re-registers the js_* collectors and reloads the configuration
+// (called by rr reset)
+func (p *Plugin) Reset() error {
+	p.bindings.metrics.invalidate()
+	if p.metricsRegistrar != nil {
+		p.registerCollectors()
 	}
+	return p.reload()
 }
 
-// releaseVM returns a VM to the pool
-func (p *Plugin) releaseVM(vm *otto.Otto) {
-	select {
-	case p.vmPool <- vm:
-	case <-p.stopCh:
-		// Plugin is shutting down, don't return to pool
+// execute runs JavaScript code with timeout
+func (p *Plugin) execute(ctx context.Context, exec *execution, script string, timeout time.Duration) (interface{}, error) {
+	if err := p.lifecycle.begin(); err != nil {
+		exec.status = "error"
+		return nil, err
 	}
-}
+	defer p.lifecycle.done()
 
-// execute runs JavaScript code with timeout
-func (p *Plugin) execute(ctx context.Context, script string, timeout time.Duration) (interface{}, error) {
-	p.wg.Add(1)
-	defer p.wg.Done()
+	// Select the pool matching the script's trust level
+	exec.pool = p.poolFor(exec.script)
 
 	start := time.Now()
 	var status string
 	defer func() {
+		exec.status = status
 		duration := time.Since(start)
-		p.executionDuration.WithLabelValues(status).Observe(duration.Seconds())
-		p.executionsTotal.WithLabelValues(status).Inc()
+		p.recordDuration(duration)
+		p.executionDuration.WithLabelValues(status, exec.pool.name, exec.tag).Observe(duration.Seconds())
+		p.executionsTotal.WithLabelValues(status, exec.pool.name, exec.tag).Inc()
+		p.countOverThreshold(exec.pool, duration)
+		var trace *executionTrace
+		if exec.tracer != nil {
+			trace = exec.tracer.finish(exec)
+		}
+		if p.traces != nil && trace != nil {
+			p.traces.record(trace)
+		}
+		if p.costs != nil && !exec.mock && trace != nil {
+			p.costs.record(exec.caller, duration, trace)
+		}
 	}()
 
 	// Track code size
 	p.codeSize.Observe(float64(len(script)))
 
 	// Track active executions
-	p.activeExecutions.Inc()
-	defer p.activeExecutions.Dec()
+	active := p.activeExecutions.WithLabelValues(exec.pool.name)
+	active.Inc()
+	defer active.Dec()
 
 	// Ensure we have a valid context
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	// Acquire VM from pool
-	p.poolAvailable.Dec()
-	vm, err := p.acquireVM(ctx)
+	// Wait for a slot if the script's concurrency is limited
+	if exec.script != "" && p.limiter != nil {
+		release, err := p.limiter.acquire(ctx, p.stopCh, exec.script)
+		if err != nil {
+			status = "error"
+			return nil, err
+		}
+		defer release()
+	}
+
+	// Wait for the concurrency groups of the script; bindings see them as held through ctx
+	if groups := p.scriptGroups(exec.script); len(groups) > 0 {
+		groupCtx, release, err := p.groups.acquireGroups(ctx, p.stopCh, groups)
+		if err != nil {
+			status = "error"
+			return nil, err
+		}
+		defer release()
+		ctx = groupCtx
+	}
+
+	// Acquire VM from the execution's pool
+	vm, err := p.acquireVM(ctx, exec)
 	if err != nil {
 		status = "error"
-		p.poolAvailable.Inc()
 		return nil, fmt.Errorf("failed to acquire VM: %w", err)
 	}
-	defer func() {
-		p.releaseVM(vm)
-		p.poolAvailable.Inc()
-	}()
+
+	// Time binding calls from here on (VM time only, queueing is taken from exec.waited)
+	if p.traces != nil || p.costs != nil {
+		exec.tracer = newTracer()
+	}
+
+	// Expose execution state to bindings for the duration of the run (unbound by the run goroutine)
+	p.bindExecution(vm, exec)
+
+	// Locks are owned by the execution and must not outlive it
+	defer p.bindings.lock.releaseHeld(exec)
+
+	// Partial results are only served while the execution runs
+	defer p.partials.remove(exec)
 
 	// Create execution context with timeout
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+	exec.deadline, _ = execCtx.Deadline()
+	exec.ctx = execCtx
 
-	// Result channels
-	resultCh := make(chan otto.Value, 1)
+	// Result channels, plus ran which is closed once the script no longer runs on the VM
+	resultCh := make(chan interface{}, 1)
 	errCh := make(chan error, 1)
+	ran := make(chan struct{})
 
-	// Execute JavaScript in goroutine
+	// deliver converts a result to Go while the VM still belongs to this execution
+	deliver := func(value otto.Value) {
+		exported, err := exportResult(value)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to export result: %w", err)
+			return
+		}
+		resultCh <- exported
+	}
+
+	// Compile and execute JavaScript in goroutine, labelled for CPU profiles of the process
 	go func() {
+		pprof.SetGoroutineLabels(exec.profileLabels(execCtx))
+
+		// The VM is reusable only once the script stopped running on it, which after a timeout
+		// can be later than execute returns
 		defer func() {
-			if caught := recover(); caught != nil {
+			exec.closeStreams()
+			p.unbindExecution(vm)
+			close(ran)
+			p.releaseVM(exec.pool, vm)
+		}()
+
+		var running time.Time
+		observeRun := func(failed bool) {
+			if !running.IsZero() {
+				p.observeRun(exec, time.Since(running), failed)
+				running = time.Time{}
+			}
+		}
+
+		defer func() {
+			caught := recover()
+			observeRun(caught != nil)
+			if caught != nil {
+				// An onAbort handler returned a partial result
+				if partial, ok := caught.(abortResult); ok {
+					deliver(partial.value)
+					return
+				}
 				errCh <- fmt.Errorf("execution panic: %v", caught)
 			}
 		}()
 
-		value, err := vm.Run(script)
+		program, err := p.compile(vm, exec, script)
 		if err != nil {
 			errCh <- err
 			return
 		}
-		resultCh <- value
-	}()
 
-	// Timeout watchdog - only interrupt if context times out
-	go func() {
-		<-execCtx.Done()
-		if execCtx.Err() == context.DeadlineExceeded {
-			// Only interrupt on actual timeout, not cancellation
-			vm.Interrupt <- func() {
-				panic("execution timeout")
-			}
+		running = time.Now()
+		value, err := vm.Run(program)
+		observeRun(err != nil)
+		if err != nil {
+			errCh <- err
+			return
 		}
+		deliver(value)
 	}()
 
+	// Timeout watchdog - aborts the script on timeout or cancellation
+	finished := make(chan struct{})
+	killed := make(chan struct{})
+	defer close(finished)
+	go p.watchdog(execCtx, vm, exec, finished, killed, ran)
+
+	// Sample the script's location for the hot-spot report
+	if exec.profiler != nil {
+		go p.sample(vm, exec, finished)
+	}
+
 	// Wait for result or timeout
 	select {
-	case value := <-resultCh:
-		// Convert otto.Value to Go interface{}
-		exported, err := value.Export()
-		if err != nil {
-			status = "error"
-			return nil, fmt.Errorf("failed to export result: %w", err)
+	case exported := <-resultCh:
+		if exec.isAborted() {
+			// Returned during the abort grace period
+			status = "timeout"
+			exec.partial = true
+			return exported, exec.abortError(timeout)
 		}
 		status = "success"
 		return exported, nil
 
 	case err := <-errCh:
+		if exec.isAborted() {
+			status = "timeout"
+			return nil, exec.abortError(timeout)
+		}
 		status = "error"
 		return nil, fmt.Errorf("execution error: %w", err)
 
-	case <-execCtx.Done():
+	case <-killed:
 		status = "timeout"
-		return nil, fmt.Errorf("execution timeout after %v", timeout)
+		return nil, exec.abortError(timeout)
 	}
 }
+
+// exportResult converts the value a script returned to Go
+// Export already returns strings, numbers, booleans and null from a switch on the value's kind, without reflection,
+// so scalars need no separate fast path (see BenchmarkExportResult); only objects and arrays are walked
+func exportResult(value otto.Value) (interface{}, error) {
+	return value.Export()
+}
@@ -0,0 +1,126 @@
+package jsmachine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// testConfigurer serves cfg as the js section
+type testConfigurer struct {
+	cfg *Config
+}
+
+func (c testConfigurer) UnmarshalKey(name string, out interface{}) error {
+	*out.(*Config) = *c.cfg
+	return nil
+}
+
+func (c testConfigurer) Has(name string) bool {
+	return name == PluginName && c.cfg != nil
+}
+
+// testLogger discards the plugin's logs
+type testLogger struct{}
+
+func (testLogger) NamedLogger(string) *zap.Logger {
+	return zap.NewNop()
+}
+
+// newTestPlugin initializes a plugin with cfg (defaults when nil) and stops it when the test ends
+// The plugin is not served, so tests can observe it before Serve
+func newTestPlugin(t testing.TB, cfg *Config) *Plugin {
+	t.Helper()
+
+	p := &Plugin{}
+	if err := p.Init(testConfigurer{cfg: cfg}, testLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Stop(context.Background()) })
+	return p
+}
+
+// serveTestPlugin initializes and serves a plugin with cfg (defaults when nil)
+func serveTestPlugin(t testing.TB, cfg *Config) *Plugin {
+	t.Helper()
+
+	p := newTestPlugin(t, cfg)
+	select {
+	case err := <-p.Serve():
+		t.Fatal(err)
+	default:
+	}
+	return p
+}
+
+// execute runs req through the RPC API, failing the test when the request is rejected or the execution fails
+func execute(t testing.TB, p *Plugin, req *ExecuteRequest) *ExecuteResponse {
+	t.Helper()
+
+	resp := &ExecuteResponse{}
+	if err := p.RPC().(*rpc).Execute(req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "" {
+		t.Fatalf("execution failed: %s", resp.Error)
+	}
+	return resp
+}
+
+// scalarResult converts scalars through the public accessors, the fast path exportResult could take instead of Export
+func scalarResult(value otto.Value) (interface{}, bool) {
+	switch {
+	case value.IsNull(), value.IsUndefined():
+		return nil, true
+	case value.IsString():
+		return value.String(), true
+	case value.IsBoolean():
+		b, err := value.ToBoolean()
+		return b, err == nil
+	case value.IsNumber():
+		f, err := value.ToFloat()
+		return f, err == nil
+	}
+	return nil, false
+}
+
+func BenchmarkExportResult(b *testing.B) {
+	vm := otto.New()
+	results := []struct {
+		name string
+		code string
+	}{
+		{"string", `"hello world"`},
+		{"number", `42.5`},
+		{"bool", `true`},
+		{"null", `null`},
+		{"object", `({id: 1, name: "a", tags: ["x", "y"]})`},
+	}
+
+	for _, r := range results {
+		value, err := vm.Run(r.code)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(r.name+"/export", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := exportResult(value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		if _, ok := scalarResult(value); !ok {
+			continue
+		}
+		b.Run(r.name+"/accessors", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				scalarResult(value)
+			}
+		})
+	}
+}
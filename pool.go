@@ -0,0 +1,333 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+const (
+	// trustedPool runs registered scripts (and all code when no untrusted pool is configured)
+	trustedPool = "trusted"
+
+	// untrustedPool runs ad-hoc code when js.untrusted is configured
+	untrustedPool = "untrusted"
+)
+
+// vmPool is a set of interchangeable VMs sharing a binding set and execution limits
+// Its settings never change: a configuration reload installs a new pool (see reload.go)
+type vmPool struct {
+	name string
+	vms  chan *otto.Otto
+	size int
+
+	// VMs built by fillPool (size unless the pool is lazy); executions build the others when they find no idle VM
+	initial int
+
+	// VMs built so far, including those being built, and the gauge reporting them
+	built       *atomic.Int64
+	constructed prometheus.Gauge
+
+	// Timeout applied when a request does not set one, and the ceiling for requested ones (0 = none)
+	defaultTimeout time.Duration
+	maxTimeout     time.Duration
+
+	// Global objects injected into the pool's VMs
+	bindings []string
+
+	// Script defining the configured constants, and whether built-ins are frozen
+	globals        string
+	freezeBuiltins bool
+
+	// Executions waiting for a VM of this pool
+	waiting atomic.Int64
+
+	// Idle VMs gauge, set to the length of vms whenever a VM enters or leaves it, and the lock serializing those
+	// updates with retirement (shared by the pools of a name, which report to the same gauge)
+	available   prometheus.Gauge
+	availableMu *sync.Mutex
+
+	// Closed once a reload replaced the pool's VMs
+	retired chan struct{}
+}
+
+// newVMPool creates an empty pool, filled by Plugin.fillPool
+func newVMPool(name string, size, initial int, defaultTimeout, maxTimeout time.Duration, bindings []string, globals string, freezeBuiltins bool) *vmPool {
+	return &vmPool{
+		name:           name,
+		vms:            make(chan *otto.Otto, size),
+		size:           size,
+		initial:        initial,
+		built:          &atomic.Int64{},
+		defaultTimeout: defaultTimeout,
+		maxTimeout:     maxTimeout,
+		bindings:       bindings,
+		globals:        globals,
+		freezeBuiltins: freezeBuiltins,
+		retired:        make(chan struct{}),
+	}
+}
+
+// newPools creates the empty pools of a configuration: the trusted pool and, when configured, the untrusted one
+func newPools(cfg *Config) (trusted, untrusted *vmPool, err error) {
+	globals := ""
+	if len(cfg.Globals) > 0 {
+		if globals, err = globalsScript(cfg.Globals); err != nil {
+			return nil, nil, fmt.Errorf("failed to prepare globals: %w", err)
+		}
+	}
+
+	trusted = newVMPool(trustedPool, cfg.PoolSize, initialVMs(cfg, cfg.PoolSize),
+		time.Duration(cfg.DefaultTimeout)*time.Millisecond, time.Duration(cfg.MaxTimeout)*time.Millisecond, cfg.Bindings,
+		globals, cfg.FreezeBuiltins)
+	if u := cfg.Untrusted; u != nil {
+		untrusted = newVMPool(untrustedPool, u.PoolSize, initialVMs(cfg, u.PoolSize),
+			time.Duration(u.DefaultTimeout)*time.Millisecond, time.Duration(u.MaxTimeout)*time.Millisecond, u.Bindings,
+			globals, cfg.FreezeBuiltins)
+	}
+	return trusted, untrusted, nil
+}
+
+// initialVMs returns the number of VMs of a pool of size built at startup
+func initialVMs(cfg *Config, size int) int {
+	if cfg.LazyPool == nil {
+		return size
+	}
+	return min(cfg.LazyPool.Initial, size)
+}
+
+// timeout resolves a requested timeout in milliseconds (0 = default) against the pool limits
+// exceeded reports that the requested timeout was above the ceiling and has been clamped
+func (vp *vmPool) timeout(requestedMs int) (timeout time.Duration, exceeded bool) {
+	timeout = vp.defaultTimeout
+	if requestedMs > 0 {
+		timeout = time.Duration(requestedMs) * time.Millisecond
+	}
+	if vp.maxTimeout > 0 && timeout > vp.maxTimeout {
+		return vp.maxTimeout, true
+	}
+	return timeout, false
+}
+
+// requestTimeout resolves the timeout of a request in pool, clamping timeouts above the ceiling
+// (with timeout_policy reject, such requests are refused by validateRequest)
+func (p *Plugin) requestTimeout(pool *vmPool, req *ExecuteRequest) time.Duration {
+	timeout, exceeded := pool.timeout(req.TimeoutMs)
+	if !exceeded {
+		return timeout
+	}
+
+	p.log.Warn("requested timeout exceeds the maximum, clamping",
+		zap.String("request_id", req.RequestID),
+		zap.String("pool", pool.name),
+		zap.Int("timeout_ms", req.TimeoutMs),
+		zap.Int64("max_timeout_ms", pool.maxTimeout.Milliseconds()),
+	)
+	return timeout
+}
+
+// hasBinding reports whether the pool's VMs get the named global object
+func (vp *vmPool) hasBinding(name string) bool {
+	return slices.Contains(vp.bindings, name)
+}
+
+// poolFor selects the pool for an execution: registered scripts are trusted,
+// ad-hoc code is routed to the untrusted pool when one is configured
+func (p *Plugin) poolFor(script string) *vmPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if script == "" && p.untrusted != nil {
+		return p.untrusted
+	}
+	return p.pool
+}
+
+// pools returns the configured pools
+func (p *Plugin) pools() []*vmPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.untrusted != nil {
+		return []*vmPool{p.pool, p.untrusted}
+	}
+	return []*vmPool{p.pool}
+}
+
+// fillPool creates the pool's initial VMs; the idle VMs gauge is set once the pool is in use
+func (p *Plugin) fillPool(pool *vmPool) error {
+	pool.available = p.poolAvailable.WithLabelValues(pool.name)
+	pool.availableMu = &p.availableMu
+	pool.constructed = p.poolVMs.WithLabelValues(pool.name)
+
+	for range pool.initial {
+		pool.built.Add(1)
+		vm, err := p.newVM(pool)
+		if err != nil {
+			pool.built.Add(-1)
+			return err
+		}
+		pool.vms <- vm
+	}
+	pool.constructed.Set(float64(pool.built.Load()))
+	return nil
+}
+
+// growPool builds a VM for an execution that found no idle one, reporting false when the pool has all its VMs
+func (p *Plugin) growPool(pool *vmPool) (*otto.Otto, bool, error) {
+	var built int64
+	for {
+		built = pool.built.Load()
+		if built >= int64(pool.size) {
+			return nil, false, nil
+		}
+		if pool.built.CompareAndSwap(built, built+1) {
+			break
+		}
+	}
+
+	start := time.Now()
+	vm, err := p.newVM(pool)
+	if err != nil {
+		pool.built.Add(-1)
+		return nil, true, fmt.Errorf("failed to build a VM: %w", err)
+	}
+	pool.constructed.Inc()
+	p.log.Debug("VM built on demand", zap.String("pool", pool.name), zap.Duration("duration", time.Since(start)))
+	return vm, true, nil
+}
+
+// newVM creates a VM of the pool with its bindings and globals
+func (p *Plugin) newVM(pool *vmPool) (*otto.Otto, error) {
+	vm := otto.New()
+
+	// Set up interrupt channel for timeout handling
+	vm.Interrupt = make(chan func(), 1)
+
+	// Inject Go bindings into VM
+	if err := p.bindings.injectIntoVM(vm, pool.hasBinding); err != nil {
+		return nil, fmt.Errorf("failed to inject bindings: %w", err)
+	}
+
+	// Time binding calls for flamegraphs and cost accounting
+	if p.traces != nil || p.costs != nil {
+		if err := p.instrumentBindings(vm, pool.bindings); err != nil {
+			return nil, fmt.Errorf("failed to instrument bindings: %w", err)
+		}
+	}
+
+	// Publish the bindings under their versioned namespaces (after instrumentation, so calls are timed)
+	if err := injectNamespace(vm); err != nil {
+		return nil, fmt.Errorf("failed to inject binding namespace: %w", err)
+	}
+
+	// Define configured constants
+	if err := injectGlobals(vm, pool.globals); err != nil {
+		return nil, fmt.Errorf("failed to inject globals: %w", err)
+	}
+
+	// Define the overlay globals of registered scripts
+	if err := p.injectOverlays(vm); err != nil {
+		return nil, fmt.Errorf("failed to inject script overlays: %w", err)
+	}
+
+	// Strip Go values and freeze the globals
+	if err := HardenVM(vm); err != nil {
+		return nil, fmt.Errorf("failed to harden VM: %w", err)
+	}
+	if pool.freezeBuiltins {
+		if err := freezeBuiltins(vm); err != nil {
+			return nil, fmt.Errorf("failed to freeze built-ins: %w", err)
+		}
+	}
+
+	// Registered scripts only run in the main pool
+	if pool.name == trustedPool {
+		p.prewarm(pool, vm)
+	}
+
+	return vm, nil
+}
+
+// take accounts for a VM received from the pool, reporting false when the pool was retired and the VM is dropped
+func (vp *vmPool) take() bool {
+	vp.availableMu.Lock()
+	defer vp.availableMu.Unlock()
+
+	select {
+	case <-vp.retired:
+		return false
+	default:
+		vp.available.Set(float64(len(vp.vms)))
+		return true
+	}
+}
+
+// acquireVM gets a VM from the execution's pool, recording in exec whether and how long it had to queue
+// When a reload retires the pool, exec moves to the pool replacing it
+func (p *Plugin) acquireVM(ctx context.Context, exec *execution) (*otto.Otto, error) {
+	// Fast path: a VM is idle
+	select {
+	case vm := <-exec.pool.vms:
+		if exec.pool.take() {
+			return vm, nil
+		}
+	default:
+	}
+
+	// A lazy pool builds a VM unless it has all of them
+	if vm, built, err := p.growPool(exec.pool); built {
+		return vm, err
+	}
+
+	// The pool is saturated, queue behind other waiting executions
+	queued := exec.pool
+	exec.queueDepth = int(queued.waiting.Add(1))
+	start := time.Now()
+	defer func() {
+		queued.waiting.Add(-1)
+		exec.waited = time.Since(start)
+	}()
+
+	for {
+		select {
+		case vm := <-exec.pool.vms:
+			if exec.pool.take() {
+				return vm, nil
+			}
+		case <-exec.pool.retired:
+			exec.pool = p.poolFor(exec.script)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.stopCh:
+			return nil, errNotReady
+		}
+	}
+}
+
+// releaseVM returns a VM to its pool; VMs of a retired pool are dropped
+// The pool has room for every VM it created, so this never blocks unless a VM is released twice
+func (p *Plugin) releaseVM(pool *vmPool, vm *otto.Otto) {
+	pool.availableMu.Lock()
+	defer pool.availableMu.Unlock()
+
+	select {
+	case <-pool.retired:
+		return
+	default:
+	}
+
+	select {
+	case pool.vms <- vm:
+		pool.available.Set(float64(len(pool.vms)))
+	default:
+		p.log.Error("VM released to a full pool, dropping it", zap.String("pool", pool.name))
+	}
+}
@@ -0,0 +1,191 @@
+package jsmachine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/robertkrimen/otto"
+)
+
+// gaugeSampler checks that the idle VMs gauge of a pool name never goes negative while a test runs
+type gaugeSampler struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func sampleAvailable(t *testing.T, p *Plugin, name string) *gaugeSampler {
+	t.Helper()
+
+	s := &gaugeSampler{stop: make(chan struct{}), done: make(chan struct{})}
+	gauge := p.poolAvailable.WithLabelValues(name)
+	go func() {
+		defer close(s.done)
+		for {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+			if v := testutil.ToFloat64(gauge); v < 0 {
+				t.Errorf("js_pool_available{pool=%q} is %v", name, v)
+				return
+			}
+			time.Sleep(10 * time.Microsecond)
+		}
+	}()
+	return s
+}
+
+func (s *gaugeSampler) halt() {
+	close(s.stop)
+	<-s.done
+}
+
+// assertAvailable checks that the idle VMs gauge matches the idle VMs of pool
+func assertAvailable(t *testing.T, pool *vmPool) {
+	t.Helper()
+
+	if got, want := testutil.ToFloat64(pool.available), float64(len(pool.vms)); got != want {
+		t.Errorf("js_pool_available{pool=%q} is %v with %v idle VMs", pool.name, got, want)
+	}
+}
+
+func TestPoolConcurrentAcquireRelease(t *testing.T) {
+	p := serveTestPlugin(t, &Config{PoolSize: 4})
+	pool := p.pools()[0]
+	sampler := sampleAvailable(t, p, trustedPool)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				exec := &execution{pool: pool}
+				vm, err := p.acquireVM(context.Background(), exec)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				p.releaseVM(exec.pool, vm)
+			}
+		}()
+	}
+	wg.Wait()
+	sampler.halt()
+
+	if len(pool.vms) != pool.size {
+		t.Errorf("%d of %d VMs are idle", len(pool.vms), pool.size)
+	}
+	assertAvailable(t, pool)
+}
+
+func TestPoolReloadRetiresPool(t *testing.T) {
+	cfg := &Config{PoolSize: 2}
+	p := serveTestPlugin(t, cfg)
+	sampler := sampleAvailable(t, p, trustedPool)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				execute(t, p, &ExecuteRequest{Code: "1 + 1"})
+			}
+		}()
+	}
+
+	// Every change of the pool size fills a new pool and retires the previous one
+	var retired []*vmPool
+	for _, size := range []int{3, 2, 4, 1} {
+		previous := p.pools()[0]
+		cfg.PoolSize = size
+		if err := p.reload(); err != nil {
+			t.Fatal(err)
+		}
+		if p.pools()[0] == previous {
+			t.Fatalf("pool of size %d was not replaced", size)
+		}
+		retired = append(retired, previous)
+	}
+	close(stop)
+	wg.Wait()
+	sampler.halt()
+
+	for _, pool := range retired {
+		select {
+		case <-pool.retired:
+		default:
+			t.Errorf("pool of size %d was not retired", pool.size)
+		}
+		if n := len(pool.vms); n != 0 {
+			t.Errorf("retired pool of size %d keeps %d idle VMs", pool.size, n)
+		}
+	}
+
+	pool := p.pools()[0]
+	if pool.size != 1 || len(pool.vms) != 1 {
+		t.Errorf("installed pool has %d of %d VMs idle, want 1 of 1", len(pool.vms), pool.size)
+	}
+	assertAvailable(t, pool)
+}
+
+func TestPoolStop(t *testing.T) {
+	p := serveTestPlugin(t, &Config{PoolSize: 2})
+	pool := p.pools()[0]
+	sampler := sampleAvailable(t, p, trustedPool)
+
+	// Hold every VM, so further executions wait
+	held := make([]*execution, 0, pool.size)
+	vms := make([]*otto.Otto, 0, pool.size)
+	for range pool.size {
+		exec := &execution{pool: pool}
+		vm, err := p.acquireVM(context.Background(), exec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held, vms = append(held, exec), append(vms, vm)
+	}
+
+	errs := make(chan error, 4)
+	for range cap(errs) {
+		go func() {
+			_, err := p.acquireVM(context.Background(), &execution{pool: pool})
+			errs <- err
+		}()
+	}
+	for pool.waiting.Load() < int64(cap(errs)) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for range cap(errs) {
+		if err := <-errs; !errors.Is(err, errNotReady) {
+			t.Errorf("waiting acquire returned %v, want %v", err, errNotReady)
+		}
+	}
+
+	// VMs released after Stop go back to the pool
+	for i, exec := range held {
+		p.releaseVM(exec.pool, vms[i])
+	}
+	sampler.halt()
+
+	if len(pool.vms) != pool.size {
+		t.Errorf("%d of %d VMs are idle", len(pool.vms), pool.size)
+	}
+	assertAvailable(t, pool)
+}
@@ -0,0 +1,72 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+// prewarm runs the js.prewarm scripts once on a VM before it joins pool, in mock mode and with the pool's default
+// timeout, so the libraries they require, the templates they render and the like are cached when traffic arrives
+// Prewarming is best-effort: a failing script is logged and the VM joins the pool all the same
+func (p *Plugin) prewarm(pool *vmPool, vm *otto.Otto) {
+	for _, name := range p.cfg.Prewarm {
+		script, err := p.scripts.get(name)
+		if err == nil {
+			err = p.prewarmScript(pool, vm, script)
+		}
+		if err != nil {
+			p.log.Warn("script prewarm failed", zap.String("script", name), zap.Error(err))
+		}
+	}
+}
+
+// prewarmScript runs a registered script on vm, interrupting it after the pool's default timeout
+func (p *Plugin) prewarmScript(pool *vmPool, vm *otto.Otto, script *registeredScript) (err error) {
+	name := script.info.Name
+	exec := &execution{requestID: "prewarm-" + name, script: name, version: script.info.Hash, attempt: 1, mock: true, pool: pool}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pool.defaultTimeout)
+	defer cancel()
+	exec.deadline, _ = ctx.Deadline()
+	exec.ctx = ctx
+
+	p.bindExecution(vm, exec)
+	ran := make(chan struct{})
+	defer func() {
+		if caught := recover(); caught != nil {
+			err = fmt.Errorf("execution panic: %v", caught)
+		}
+		close(ran)
+		exec.closeStreams()
+		p.bindings.lock.releaseHeld(exec)
+		p.unbindExecution(vm)
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			exec.abort(ctx.Err())
+			interrupt(vm, ran, func() {
+				if p.executionFor(vm) == exec {
+					panic("execution timeout")
+				}
+			})
+		case <-ran:
+		}
+	}()
+
+	program := script.program()
+	if program == nil {
+		if program, err = vm.Compile("", script.code); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	_, err = vm.Run(program)
+	p.log.Debug("script prewarmed", zap.String("script", name), zap.Duration("duration", time.Since(start)))
+	return err
+}
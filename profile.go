@@ -0,0 +1,120 @@
+package jsmachine
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// topLevel labels samples taken outside any function
+const topLevel = "(top level)"
+
+// Hotspot is a script location and the share of samples taken there
+type Hotspot struct {
+	Function string  `json:"function"`
+	Line     int     `json:"line"`
+	Samples  int     `json:"samples"`
+	Percent  float64 `json:"percent"`
+}
+
+// ProfileReport lists the locations where an execution spent most of its time
+type ProfileReport struct {
+	Samples    int       `json:"samples"`
+	IntervalUs int       `json:"interval_us"`
+	Hotspots   []Hotspot `json:"hotspots"`
+}
+
+// hotspotKey identifies a sampled location
+type hotspotKey struct {
+	function string
+	line     int
+}
+
+// profiler counts samples of the location a script is executing
+type profiler struct {
+	mu      sync.Mutex
+	samples map[hotspotKey]int
+	total   int
+}
+
+// newProfiler creates an empty profiler
+func newProfiler() *profiler {
+	return &profiler{
+		samples: make(map[hotspotKey]int),
+	}
+}
+
+// record counts a sample at the VM's current location (called on the VM's goroutine)
+func (pr *profiler) record(vm *otto.Otto) {
+	// A limit of 0 skips the stack walk and symbol collection
+	ctx := vm.ContextLimit(0)
+
+	key := hotspotKey{function: ctx.Callee, line: ctx.Line}
+	if key.function == "" {
+		key.function = topLevel
+	}
+
+	pr.mu.Lock()
+	pr.samples[key]++
+	pr.total++
+	pr.mu.Unlock()
+}
+
+// report returns the top n locations by samples
+func (pr *profiler) report(n int, interval time.Duration) *ProfileReport {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	hotspots := make([]Hotspot, 0, len(pr.samples))
+	for key, count := range pr.samples {
+		hotspots = append(hotspots, Hotspot{
+			Function: key.function,
+			Line:     key.line,
+			Samples:  count,
+			Percent:  float64(count) * 100 / float64(pr.total),
+		})
+	}
+	sort.Slice(hotspots, func(i, j int) bool {
+		if hotspots[i].Samples != hotspots[j].Samples {
+			return hotspots[i].Samples > hotspots[j].Samples
+		}
+		return hotspots[i].Line < hotspots[j].Line
+	})
+	if len(hotspots) > n {
+		hotspots = hotspots[:n]
+	}
+
+	return &ProfileReport{
+		Samples:    pr.total,
+		IntervalUs: int(interval.Microseconds()),
+		Hotspots:   hotspots,
+	}
+}
+
+// sample periodically asks the VM to record its current location until finished is closed
+// Samples are delivered through the interrupt channel and skipped while it is busy (e.g. a pending timeout)
+func (p *Plugin) sample(vm *otto.Otto, exec *execution, finished <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(p.cfg.Profiling.IntervalUs) * time.Microsecond)
+	defer ticker.Stop()
+
+	record := func() {
+		// The script may have finished and the VM moved on to another execution
+		if p.executionFor(vm) == exec {
+			exec.profiler.record(vm)
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case vm.Interrupt <- record:
+			default:
+			}
+		case <-finished:
+			return
+		}
+	}
+}
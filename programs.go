@@ -0,0 +1,124 @@
+package jsmachine
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/robertkrimen/otto"
+)
+
+// programCache is a size-bounded LRU of compiled ad-hoc code, keyed by the code itself so a lookup can never
+// return another submission's program
+// otto cannot serialize compiled programs, so persisting the cache keeps the code and compiles it again at startup,
+// before the first execution rather than during it
+type programCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+}
+
+// cachedProgram is a compiled program and its code
+type cachedProgram struct {
+	code    string
+	program *otto.Script
+}
+
+// newProgramCache creates an empty cache
+func newProgramCache(maxEntries int) *programCache {
+	return &programCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get returns the compiled program of code, nil when it is not cached
+func (c *programCache) get(code string) *otto.Script {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[code]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cachedProgram).program
+}
+
+// put caches the compiled program of code, evicting the least recently used programs beyond maxEntries
+func (c *programCache) put(code string, program *otto.Script) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[code]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[code] = c.order.PushFront(&cachedProgram{code: code, program: program})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedProgram).code)
+	}
+}
+
+// clear removes all programs
+func (c *programCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.order.Init()
+}
+
+// save writes the code of the cached programs to path, most recently used first
+func (c *programCache) save(path string) error {
+	c.mu.Lock()
+	codes := make([]string, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		codes = append(codes, elem.Value.(*cachedProgram).code)
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(codes)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// load compiles the code saved at path into the cache and returns the number of programs cached; a missing file
+// leaves the cache empty, and code that no longer compiles is skipped
+func (c *programCache) load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var codes []string
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return 0, fmt.Errorf("invalid program cache file %s: %w", path, err)
+	}
+	if len(codes) > c.maxEntries {
+		codes = codes[:c.maxEntries]
+	}
+
+	// Oldest first, so the most recently used programs end up at the front again
+	loaded := 0
+	for i := len(codes) - 1; i >= 0; i-- {
+		if program := compileProgram(codes[i]); program != nil {
+			c.put(codes[i], program)
+			loaded++
+		}
+	}
+	return loaded, nil
+}
@@ -0,0 +1,193 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promSample is one value of a Prometheus series
+type promSample struct {
+	timestamp time.Time
+	value     float64
+}
+
+// promSeries is a series of a query result; instant vectors have one sample, matrices any number
+type promSeries struct {
+	metric  map[string]string
+	samples []promSample
+}
+
+// promResult is a decoded query result
+type promResult struct {
+	resultType string // vector, matrix or scalar
+	series     []promSeries
+	warnings   []string
+}
+
+// promClient queries the HTTP API of a Prometheus compatible server
+type promClient struct {
+	cfg      *PrometheusConfig
+	client   *http.Client
+	maxBytes int
+}
+
+// query runs an instant query evaluated at ts
+func (c *promClient) query(ctx context.Context, expr string, ts time.Time) (*promResult, error) {
+	params := url.Values{"query": {expr}, "time": {promTime(ts)}}
+	return c.get(ctx, "/api/v1/query", params)
+}
+
+// queryRange runs a range query over [start, end] with one sample per step
+func (c *promClient) queryRange(ctx context.Context, expr string, start, end time.Time, step time.Duration) (*promResult, error) {
+	params := url.Values{
+		"query": {expr},
+		"start": {promTime(start)},
+		"end":   {promTime(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+	return c.get(ctx, "/api/v1/query_range", params)
+}
+
+// get calls an API endpoint and decodes its result
+func (c *promClient) get(ctx context.Context, path string, params url.Values) (*promResult, error) {
+	endpoint := strings.TrimSuffix(c.cfg.URL, "/") + path + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read one byte more than allowed to tell a body of exactly the limit from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(c.maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > c.maxBytes {
+		return nil, errors.New("response exceeds fetch.max_response_bytes")
+	}
+
+	var parsed struct {
+		Status    string   `json:"status"`
+		ErrorType string   `json:"errorType"`
+		Error     string   `json:"error"`
+		Warnings  []string `json:"warnings"`
+		Data      struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		// Proxies in front of Prometheus answer errors without an API body
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("server responded with %s", resp.Status)
+		}
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if parsed.Status != "success" {
+		return nil, fmt.Errorf("%s: %s", parsed.ErrorType, parsed.Error)
+	}
+
+	result := &promResult{resultType: parsed.Data.ResultType, warnings: parsed.Warnings}
+	if result.series, err = decodePromResult(parsed.Data.ResultType, parsed.Data.Result); err != nil {
+		return nil, fmt.Errorf("invalid %s result: %w", parsed.Data.ResultType, err)
+	}
+	return result, nil
+}
+
+// decodePromResult converts a result into series; a scalar becomes one unlabeled series
+func decodePromResult(resultType string, raw json.RawMessage) ([]promSeries, error) {
+	switch resultType {
+	case "vector":
+		var items []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []any             `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		series := make([]promSeries, 0, len(items))
+		for _, item := range items {
+			sample, err := decodePromSample(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			series = append(series, promSeries{metric: item.Metric, samples: []promSample{sample}})
+		}
+		return series, nil
+
+	case "matrix":
+		var items []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]any           `json:"values"`
+		}
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		series := make([]promSeries, 0, len(items))
+		for _, item := range items {
+			samples := make([]promSample, 0, len(item.Values))
+			for _, value := range item.Values {
+				sample, err := decodePromSample(value)
+				if err != nil {
+					return nil, err
+				}
+				samples = append(samples, sample)
+			}
+			series = append(series, promSeries{metric: item.Metric, samples: samples})
+		}
+		return series, nil
+
+	case "scalar":
+		var value []any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		sample, err := decodePromSample(value)
+		if err != nil {
+			return nil, err
+		}
+		return []promSeries{{metric: map[string]string{}, samples: []promSample{sample}}}, nil
+	}
+	return nil, errors.New("unsupported result type")
+}
+
+// decodePromSample decodes a [unix seconds, "value"] pair; values are strings so NaN and infinities survive JSON
+func decodePromSample(pair []any) (promSample, error) {
+	if len(pair) != 2 {
+		return promSample{}, errors.New("malformed sample")
+	}
+	seconds, ok := pair[0].(float64)
+	text, isString := pair[1].(string)
+	if !ok || !isString {
+		return promSample{}, errors.New("malformed sample")
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return promSample{}, fmt.Errorf("malformed sample value %q", text)
+	}
+	return promSample{timestamp: time.UnixMilli(int64(math.Round(seconds * 1000))), value: value}, nil
+}
+
+// promTime formats a time as the unix seconds the API expects
+func promTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
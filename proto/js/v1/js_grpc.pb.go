@@ -0,0 +1,191 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: proto/js/v1/js.proto
+
+package jsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	JSMachine_Execute_FullMethodName       = "/js.v1.JSMachine/Execute"
+	JSMachine_ExecuteStream_FullMethodName = "/js.v1.JSMachine/ExecuteStream"
+)
+
+// JSMachineClient is the client API for JSMachine service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// JSMachine mirrors the `js.*` RPC API for gRPC clients.
+type JSMachineClient interface {
+	// Execute runs JavaScript code and returns the result.
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	// ExecuteStream runs every request received on the stream and sends back
+	// one response per request, in completion order (correlate by request_id).
+	ExecuteStream(ctx context.Context, opts ...grpc.CallOption) (JSMachine_ExecuteStreamClient, error)
+}
+
+type jSMachineClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJSMachineClient(cc grpc.ClientConnInterface) JSMachineClient {
+	return &jSMachineClient{cc}
+}
+
+func (c *jSMachineClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExecuteResponse)
+	err := c.cc.Invoke(ctx, JSMachine_Execute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jSMachineClient) ExecuteStream(ctx context.Context, opts ...grpc.CallOption) (JSMachine_ExecuteStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JSMachine_ServiceDesc.Streams[0], JSMachine_ExecuteStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jSMachineExecuteStreamClient{ClientStream: stream}
+	return x, nil
+}
+
+type JSMachine_ExecuteStreamClient interface {
+	Send(*ExecuteRequest) error
+	Recv() (*ExecuteResponse, error)
+	grpc.ClientStream
+}
+
+type jSMachineExecuteStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *jSMachineExecuteStreamClient) Send(m *ExecuteRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *jSMachineExecuteStreamClient) Recv() (*ExecuteResponse, error) {
+	m := new(ExecuteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// JSMachineServer is the server API for JSMachine service.
+// All implementations must embed UnimplementedJSMachineServer
+// for forward compatibility
+//
+// JSMachine mirrors the `js.*` RPC API for gRPC clients.
+type JSMachineServer interface {
+	// Execute runs JavaScript code and returns the result.
+	Execute(context.Context, *ExecuteRequest) (*ExecuteResponse, error)
+	// ExecuteStream runs every request received on the stream and sends back
+	// one response per request, in completion order (correlate by request_id).
+	ExecuteStream(JSMachine_ExecuteStreamServer) error
+	mustEmbedUnimplementedJSMachineServer()
+}
+
+// UnimplementedJSMachineServer must be embedded to have forward compatible implementations.
+type UnimplementedJSMachineServer struct {
+}
+
+func (UnimplementedJSMachineServer) Execute(context.Context, *ExecuteRequest) (*ExecuteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedJSMachineServer) ExecuteStream(JSMachine_ExecuteStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExecuteStream not implemented")
+}
+func (UnimplementedJSMachineServer) mustEmbedUnimplementedJSMachineServer() {}
+
+// UnsafeJSMachineServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JSMachineServer will
+// result in compilation errors.
+type UnsafeJSMachineServer interface {
+	mustEmbedUnimplementedJSMachineServer()
+}
+
+func RegisterJSMachineServer(s grpc.ServiceRegistrar, srv JSMachineServer) {
+	s.RegisterService(&JSMachine_ServiceDesc, srv)
+}
+
+func _JSMachine_Execute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JSMachineServer).Execute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JSMachine_Execute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JSMachineServer).Execute(ctx, req.(*ExecuteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JSMachine_ExecuteStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JSMachineServer).ExecuteStream(&jSMachineExecuteStreamServer{ServerStream: stream})
+}
+
+type JSMachine_ExecuteStreamServer interface {
+	Send(*ExecuteResponse) error
+	Recv() (*ExecuteRequest, error)
+	grpc.ServerStream
+}
+
+type jSMachineExecuteStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *jSMachineExecuteStreamServer) Send(m *ExecuteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *jSMachineExecuteStreamServer) Recv() (*ExecuteRequest, error) {
+	m := new(ExecuteRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// JSMachine_ServiceDesc is the grpc.ServiceDesc for JSMachine service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JSMachine_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "js.v1.JSMachine",
+	HandlerType: (*JSMachineServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Execute",
+			Handler:    _JSMachine_Execute_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExecuteStream",
+			Handler:       _JSMachine_ExecuteStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/js/v1/js.proto",
+}
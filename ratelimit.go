@@ -0,0 +1,170 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate limiter drivers
+const (
+	rateLimitMemory = "memory"
+	rateLimitRedis  = "redis"
+)
+
+// rateLimiter decides whether an action under key is within limit per window
+// Both drivers use the sliding window counter algorithm: the previous fixed window's count is weighted by how much
+// of it still overlaps the sliding window, which keeps the state per key constant regardless of the limit
+type rateLimiter interface {
+	allow(key string, limit int, window time.Duration) (bool, error)
+	close()
+}
+
+// newRateLimiter creates the limiter of the configured driver
+func newRateLimiter(cfg *RateLimitConfig) rateLimiter {
+	if cfg.Driver == rateLimitRedis {
+		return newRedisLimiter(cfg)
+	}
+	return newMemoryLimiter(cfg.MaxKeys)
+}
+
+// slidingWindow holds the counters of one key in the memory limiter
+type slidingWindow struct {
+	windowMs int64
+	index    int64 // current fixed window (time / window)
+	current  int
+	previous int
+}
+
+// memoryLimiter keeps windows in process memory; limits apply per RoadRunner instance
+type memoryLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*slidingWindow
+	maxKeys int
+}
+
+// newMemoryLimiter creates an empty limiter tracking at most maxKeys keys
+func newMemoryLimiter(maxKeys int) *memoryLimiter {
+	return &memoryLimiter{
+		windows: make(map[string]*slidingWindow),
+		maxKeys: maxKeys,
+	}
+}
+
+// allow implements rateLimiter
+func (l *memoryLimiter) allow(key string, limit int, window time.Duration) (bool, error) {
+	now := time.Now().UnixMilli()
+	windowMs := window.Milliseconds()
+	index := now / windowMs
+	// The same key may be used with different windows, each gets its own counters
+	id := key + "\x00" + strconv.FormatInt(windowMs, 10)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[id]
+	if !ok {
+		if len(l.windows) >= l.maxKeys {
+			l.sweep(now)
+			if len(l.windows) >= l.maxKeys {
+				return false, fmt.Errorf("rate limiter already tracks the maximum of %d keys", l.maxKeys)
+			}
+		}
+		w = &slidingWindow{windowMs: windowMs, index: index}
+		l.windows[id] = w
+	}
+
+	switch {
+	case index == w.index+1:
+		w.index, w.previous, w.current = index, w.current, 0
+	case index > w.index+1:
+		w.index, w.previous, w.current = index, 0, 0
+	}
+
+	weight := 1 - float64(now%windowMs)/float64(windowMs)
+	if float64(w.previous)*weight+float64(w.current) >= float64(limit) {
+		return false, nil
+	}
+	w.current++
+	return true, nil
+}
+
+// close implements rateLimiter
+func (l *memoryLimiter) close() {}
+
+// sweep drops keys whose counters no longer affect any decision (l.mu held)
+func (l *memoryLimiter) sweep(now int64) {
+	for id, w := range l.windows {
+		if now/w.windowMs > w.index+1 {
+			delete(l.windows, id)
+		}
+	}
+}
+
+// redisLimiterScript applies the sliding window counter atomically, using the server clock so nodes agree
+// KEYS[1] = key prefix, ARGV[1] = window in ms, ARGV[2] = limit; returns 1 when allowed
+const redisLimiterScript = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local index = math.floor(now / window)
+local current = KEYS[1] .. ":" .. index
+local previous = KEYS[1] .. ":" .. (index - 1)
+local weight = 1 - (now % window) / window
+local count = tonumber(redis.call("GET", current) or "0") + tonumber(redis.call("GET", previous) or "0") * weight
+if count >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("INCR", current)
+redis.call("PEXPIRE", current, window * 2)
+return 1
+`
+
+// redisLimiter shares limits between RoadRunner instances through Redis
+type redisLimiter struct {
+	prefix string
+	client *redis.Client
+	script *redis.Script
+}
+
+// newRedisLimiter creates a limiter; connections are opened on first use
+func newRedisLimiter(cfg *RateLimitConfig) *redisLimiter {
+	return &redisLimiter{
+		prefix: cfg.Redis.Prefix,
+		client: newRedisClient(cfg.Redis),
+		script: redis.NewScript(redisLimiterScript),
+	}
+}
+
+// newRedisClient creates a client of the configured server; connections are opened on first use
+func newRedisClient(cfg *RateLimitRedisConfig) *redis.Client {
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+	return redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		PoolSize:     cfg.PoolSize,
+	})
+}
+
+// allow implements rateLimiter
+func (l *redisLimiter) allow(key string, limit int, window time.Duration) (bool, error) {
+	prefix := l.prefix + key + ":" + strconv.FormatInt(window.Milliseconds(), 10)
+	allowed, err := l.script.Run(context.Background(), l.client, []string{prefix}, window.Milliseconds(), limit).Int64()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
+
+// close implements rateLimiter, closing the connections
+func (l *redisLimiter) close() {
+	_ = l.client.Close()
+}
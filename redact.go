@@ -0,0 +1,132 @@
+package jsmachine
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// redactor scrubs secrets and personal data from script input and output before it is logged or stored
+// A nil redactor (js.redaction not configured) returns its input unchanged
+type redactor struct {
+	// Lower-cased field names whose values are replaced as a whole
+	fields map[string]struct{}
+
+	// Patterns whose matches are replaced in every string
+	patterns []*regexp.Regexp
+}
+
+// newRedactor compiles the configured redaction rules
+func newRedactor(cfg *RedactionConfig) (*redactor, error) {
+	patterns, err := compilePatterns(cfg.Patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]struct{}, len(cfg.Fields))
+	for _, name := range cfg.Fields {
+		fields[strings.ToLower(name)] = struct{}{}
+	}
+	return &redactor{fields: fields, patterns: patterns}, nil
+}
+
+// field reports whether values under name are redacted as a whole
+func (r *redactor) field(name string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.fields[strings.ToLower(name)]
+	return ok
+}
+
+// string replaces the pattern matches in s
+func (r *redactor) string(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redactedPlaceholder)
+	}
+	return s
+}
+
+// json returns a redacted copy of a JSON document, such as ctx.input; a document that does not parse is treated as
+// a plain string, and one with nothing to redact is returned as is
+func (r *redactor) json(doc string) string {
+	if r == nil || doc == "" {
+		return doc
+	}
+
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return r.string(doc)
+	}
+
+	redacted := r.value(v)
+	if reflect.DeepEqual(redacted, v) {
+		return doc
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redacted); err != nil {
+		return r.string(doc)
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// value returns a redacted copy of an exported JavaScript value: values of redacted fields are replaced and
+// pattern matches are replaced in strings, at any depth
+func (r *redactor) value(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+
+	switch v := v.(type) {
+	case string:
+		return r.string(v)
+	case map[string]interface{}:
+		return r.object(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.value(item)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.object(item)
+		}
+		return out
+	case []string:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.string(item)
+		}
+		return out
+	}
+	return v
+}
+
+// object returns a redacted copy of an exported JavaScript object
+func (r *redactor) object(m map[string]interface{}) map[string]interface{} {
+	if r == nil || m == nil {
+		return m
+	}
+
+	out := make(map[string]interface{}, len(m))
+	for key, item := range m {
+		if r.field(key) {
+			out[key] = redactedPlaceholder
+			continue
+		}
+		out[key] = r.value(item)
+	}
+	return out
+}
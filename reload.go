@@ -0,0 +1,197 @@
+package jsmachine
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// reloadableSettings are the settings a reload applies to the running plugin; changes to any other section are
+// logged and take effect with the next restart
+var reloadableSettings = []string{"pool_size", "default_timeout_ms", "max_timeout_ms", "bindings", "globals", "freeze_builtins", "untrusted"}
+
+// configReloader is implemented by configurers able to re-read their source, asked to before a reload
+type configReloader interface {
+	Reload() error
+}
+
+// reload reads the configuration again and applies its pool settings without dropping executions:
+//   - changed timeouts apply to executions starting after the reload, on the same VMs
+//   - a changed pool size, binding set, globals or freeze_builtins fills a new pool with new VMs; executions already
+//     running finish on the old ones, which are then dropped, and executions waiting for a VM move to the new pool
+//   - the untrusted pool is created or removed when js.untrusted is added or removed
+//
+// An invalid configuration is rejected as a whole, and the running one stays in use
+func (p *Plugin) reload() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	if !p.lifecycle.serving() {
+		return nil
+	}
+
+	if r, ok := p.configurer.(configReloader); ok {
+		if err := r.Reload(); err != nil {
+			return fmt.Errorf("failed to re-read the configuration: %w", err)
+		}
+	}
+	next := &Config{}
+	if p.configurer.Has(p.Name()) {
+		if err := p.configurer.UnmarshalKey(p.Name(), next); err != nil {
+			return fmt.Errorf("failed to unmarshal config: %w", err)
+		}
+	}
+	next.initDefaults(p.Name())
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("config validation failed, keeping the running configuration: %w", err)
+	}
+
+	trusted, untrusted, err := newPools(next)
+	if err != nil {
+		return err
+	}
+	current := p.pools()
+	previousTrusted, previousUntrusted := current[0], (*vmPool)(nil)
+	if len(current) > 1 {
+		previousUntrusted = current[1]
+	}
+	if trusted, err = p.nextPool(previousTrusted, trusted); err != nil {
+		return err
+	}
+	if untrusted, err = p.nextPool(previousUntrusted, untrusted); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.pool, p.untrusted = trusted, untrusted
+	applyReloadable(p.cfg, next)
+	p.mu.Unlock()
+
+	// Replaced VMs are retired only once executions can no longer pick their pool
+	p.retirePool(trustedPool, previousTrusted, trusted)
+	p.retirePool(untrustedPool, previousUntrusted, untrusted)
+
+	fields := []zap.Field{
+		zap.Int("pool_size", trusted.size),
+		zap.Int64("default_timeout_ms", trusted.defaultTimeout.Milliseconds()),
+		zap.Strings("bindings", trusted.bindings),
+	}
+	if untrusted != nil {
+		fields = append(fields, zap.Int("untrusted_pool_size", untrusted.size))
+	}
+	p.log.Info("configuration reloaded", fields...)
+	if changed := restartRequired(p.cfg, next); len(changed) > 0 {
+		p.log.Warn("changed settings take effect after a restart", zap.Strings("sections", changed))
+	}
+	return nil
+}
+
+// nextPool returns the pool to install in place of current: current itself when its settings are unchanged, next
+// using current's VMs when only timeouts changed, and otherwise next filled with new VMs
+func (p *Plugin) nextPool(current, next *vmPool) (*vmPool, error) {
+	if next == nil {
+		return nil, nil
+	}
+	if current != nil && current.sameVMs(next) {
+		if current.defaultTimeout == next.defaultTimeout && current.maxTimeout == next.maxTimeout {
+			return current, nil
+		}
+		next.vms, next.available, next.availableMu, next.retired = current.vms, current.available, current.availableMu, current.retired
+		next.built, next.constructed = current.built, current.constructed
+		return next, nil
+	}
+
+	if err := p.fillPool(next); err != nil {
+		return nil, fmt.Errorf("failed to fill the %s pool: %w", next.name, err)
+	}
+	return next, nil
+}
+
+// retirePool retires the VMs of previous unless installed uses them, and updates the gauges of the pool name
+func (p *Plugin) retirePool(name string, previous, installed *vmPool) {
+	p.availableMu.Lock()
+	defer p.availableMu.Unlock()
+
+	if previous != nil && (installed == nil || installed.vms != previous.vms) {
+		previous.retire()
+	}
+
+	switch {
+	case installed == nil:
+		p.poolSizeGauge.WithLabelValues(name).Set(0)
+		p.poolAvailable.WithLabelValues(name).Set(0)
+		p.poolVMs.WithLabelValues(name).Set(0)
+	case previous == nil || installed.vms != previous.vms:
+		p.poolSizeGauge.WithLabelValues(name).Set(float64(installed.size))
+		installed.available.Set(float64(len(installed.vms)))
+	}
+}
+
+// sameVMs reports whether two pools create identical VMs, so one can use the other's
+func (vp *vmPool) sameVMs(other *vmPool) bool {
+	return vp.size == other.size && slices.Equal(vp.bindings, other.bindings) &&
+		vp.globals == other.globals && vp.freezeBuiltins == other.freezeBuiltins
+}
+
+// retire stops the pool from handing out VMs: idle ones are dropped, waiting executions move to the pool replacing
+// it, and the VMs of running executions are dropped when released
+func (vp *vmPool) retire() {
+	close(vp.retired)
+	for {
+		select {
+		case <-vp.vms:
+		default:
+			return
+		}
+	}
+}
+
+// restartRequired returns the top-level settings that differ between two configurations and are not reloadable
+func restartRequired(current, next *Config) []string {
+	var changed []string
+	a, b := reflect.ValueOf(current).Elem(), reflect.ValueOf(next).Elem()
+	for i := range a.NumField() {
+		name := a.Type().Field(i).Tag.Get("mapstructure")
+		if slices.Contains(reloadableSettings, name) {
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// applyReloadable copies the reloadable settings of next into current, so the running configuration reflects what a
+// reload applied and later reloads only report the sections still waiting for a restart
+func applyReloadable(current, next *Config) {
+	a, b := reflect.ValueOf(current).Elem(), reflect.ValueOf(next).Elem()
+	for i := range a.NumField() {
+		if slices.Contains(reloadableSettings, a.Type().Field(i).Tag.Get("mapstructure")) {
+			a.Field(i).Set(b.Field(i))
+		}
+	}
+}
+
+// watchHangup reloads the configuration on SIGHUP until the plugin stops
+func (p *Plugin) watchHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-hangup:
+			if err := p.reload(); err != nil {
+				p.log.Error("configuration reload failed", zap.Error(err))
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
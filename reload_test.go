@@ -0,0 +1,35 @@
+package jsmachine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReloadStoresReloadableSettings(t *testing.T) {
+	cfg := &Config{PoolSize: 1, DefaultTimeout: 1000}
+	p := serveTestPlugin(t, cfg)
+
+	cfg.PoolSize, cfg.DefaultTimeout, cfg.Globals = 2, 2000, map[string]interface{}{"REGION": "eu"}
+	cfg.StrictEncoding = true
+	if err := p.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.mu.RLock()
+	running := *p.cfg
+	p.mu.RUnlock()
+	if running.PoolSize != 2 || running.DefaultTimeout != 2000 || running.Globals["REGION"] != "eu" {
+		t.Errorf("reloadable settings not applied: pool_size %d, default_timeout_ms %d, globals %v",
+			running.PoolSize, running.DefaultTimeout, running.Globals)
+	}
+	if running.StrictEncoding {
+		t.Error("strict_encoding applied without a restart")
+	}
+
+	// A second reload of the same configuration reports only the section still waiting for a restart
+	next := *cfg
+	next.initDefaults(p.Name())
+	if changed := restartRequired(&running, &next); !slices.Equal(changed, []string{"strict_encoding"}) {
+		t.Errorf("restart required for %v, want [strict_encoding]", changed)
+	}
+}
@@ -0,0 +1,250 @@
+package jsmachine
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/robertkrimen/otto"
+	"go.uber.org/zap"
+)
+
+const (
+	// codeExcerptLimit caps how much of the script and of its input is attached to a report
+	codeExcerptLimit = 2048
+
+	redactedPlaceholder = "[REDACTED]"
+)
+
+// executionFailure describes a failed execution for error reporting
+type executionFailure struct {
+	RequestID  string   `json:"request_id,omitempty"`
+	Status     string   `json:"status"`
+	Script     string   `json:"script,omitempty"`
+	ScriptHash string   `json:"script_hash"`
+	Message    string   `json:"message"`
+	Stack      []string `json:"stack,omitempty"`
+	Code       string   `json:"code,omitempty"`
+	Input      string   `json:"input,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+
+	// Release of a registered script (name@version); empty for ad-hoc code, which reports the configured release
+	Release string `json:"release,omitempty"`
+}
+
+// newExecutionFailure builds a failure description from the execution error
+func newExecutionFailure(exec *execution, script string, err error, duration time.Duration) *executionFailure {
+	failure := &executionFailure{
+		RequestID:  exec.requestID,
+		Status:     exec.status,
+		Script:     exec.script,
+		ScriptHash: scriptHash(script),
+		Message:    err.Error(),
+		Code:       script,
+		Input:      exec.input,
+		DurationMs: duration.Milliseconds(),
+	}
+	if exec.script != "" && exec.version != "" {
+		failure.Release = exec.script + "@" + exec.version
+	}
+
+	// otto runtime errors carry a JavaScript stack trace
+	var jsErr *otto.Error
+	if errors.As(err, &jsErr) {
+		lines := strings.Split(jsErr.String(), "\n")
+		failure.Message = lines[0]
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				failure.Stack = append(failure.Stack, line)
+			}
+		}
+	}
+
+	if len(failure.Code) > codeExcerptLimit {
+		failure.Code = failure.Code[:codeExcerptLimit]
+	}
+	return failure
+}
+
+// redact applies redaction patterns to the free-form parts of the failure
+func (f *executionFailure) redact(patterns []*regexp.Regexp) {
+	for _, re := range patterns {
+		f.Message = re.ReplaceAllString(f.Message, redactedPlaceholder)
+		f.Code = re.ReplaceAllString(f.Code, redactedPlaceholder)
+		f.Input = re.ReplaceAllString(f.Input, redactedPlaceholder)
+	}
+}
+
+// scrub applies js.redaction to everything the failure carries from the script
+func (f *executionFailure) scrub(r *redactor) {
+	f.Message = r.string(f.Message)
+	f.Code = r.string(f.Code)
+	f.Input = r.json(f.Input)
+	for i, line := range f.Stack {
+		f.Stack[i] = r.string(line)
+	}
+}
+
+// reportFailure hands a failed execution to the configured failure sinks
+// Every sink gets its own copy since sinks may redact or trim fields
+func (p *Plugin) reportFailure(exec *execution, script string, err error, duration time.Duration) {
+	if p.reporter == nil && p.failureWebhook == nil {
+		return
+	}
+
+	// The input is trimmed once scrubbed, so the redactor sees the whole document
+	failure := newExecutionFailure(exec, script, err, duration)
+	failure.scrub(p.redactor)
+	if len(failure.Input) > codeExcerptLimit {
+		failure.Input = failure.Input[:codeExcerptLimit]
+	}
+	if len(failure.Input) > codeExcerptLimit {
+		failure.Input = failure.Input[:codeExcerptLimit]
+	}
+
+	// Only script errors go to the error tracker (timeouts and pool failures are covered by metrics)
+	if p.reporter != nil && failure.Status == "error" {
+		f := *failure
+		p.reporter.report(&f)
+	}
+
+	if p.failureWebhook != nil {
+		f := *failure
+		p.failureWebhook.notify(&f)
+	}
+}
+
+// errorReporter sends script failures to an error tracking service
+type errorReporter interface {
+	// report submits a failure asynchronously
+	report(failure *executionFailure)
+	// flush waits for queued reports to be delivered
+	flush(timeout time.Duration)
+}
+
+// sentryReporter reports failures to Sentry
+type sentryReporter struct {
+	hub      *sentry.Hub
+	redact   []*regexp.Regexp
+	withCode bool
+	release  string
+}
+
+// newSentryReporter creates a Sentry client from the configuration
+func newSentryReporter(cfg *SentryConfig, log *zap.Logger) (*sentryReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  cfg.SampleRate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := compilePatterns(cfg.Redact)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("sentry error reporting enabled", zap.String("environment", cfg.Environment), zap.String("release", cfg.Release))
+
+	return &sentryReporter{
+		hub:      sentry.NewHub(client, sentry.NewScope()),
+		redact:   patterns,
+		withCode: cfg.AttachCode,
+		release:  cfg.Release,
+	}, nil
+}
+
+// report sends the failure to Sentry
+func (s *sentryReporter) report(failure *executionFailure) {
+	s.hub.CaptureEvent(s.event(failure))
+}
+
+// event converts the failure to a Sentry event, redacting it first
+func (s *sentryReporter) event(failure *executionFailure) *sentry.Event {
+	failure.redact(s.redact)
+
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	event.Platform = "javascript"
+	event.Message = failure.Message
+	event.Fingerprint = []string{failure.ScriptHash, failure.Status}
+	event.Tags["status"] = failure.Status
+	event.Tags["script_hash"] = failure.ScriptHash
+	if failure.Script != "" {
+		event.Tags["script"] = failure.Script
+	}
+	event.Release = s.release
+	if failure.Release != "" {
+		event.Release = failure.Release
+	}
+	event.Extra["request_id"] = failure.RequestID
+	event.Extra["duration_ms"] = failure.DurationMs
+	if failure.Input != "" {
+		event.Extra["input"] = failure.Input
+	}
+	if s.withCode {
+		event.Extra["code"] = failure.Code
+	}
+
+	event.Exception = []sentry.Exception{{
+		Type:       "ScriptError",
+		Value:      failure.Message,
+		Stacktrace: sentryStacktrace(failure.Stack),
+	}}
+
+	return event
+}
+
+// flush waits for buffered events to be sent
+func (s *sentryReporter) flush(timeout time.Duration) {
+	s.hub.Flush(timeout)
+}
+
+// stackFrameRe matches otto stack lines: "at fn (<anonymous>:3:9)" or "at <anonymous>:7:1"
+var stackFrameRe = regexp.MustCompile(`^at (?:(\S+) \()?([^:()]+):(\d+):(\d+)\)?$`)
+
+// sentryStacktrace converts otto stack lines to Sentry frames (oldest call first)
+func sentryStacktrace(stack []string) *sentry.Stacktrace {
+	if len(stack) == 0 {
+		return nil
+	}
+
+	frames := make([]sentry.Frame, 0, len(stack))
+	for i := len(stack) - 1; i >= 0; i-- {
+		m := stackFrameRe.FindStringSubmatch(stack[i])
+		if m == nil {
+			frames = append(frames, sentry.Frame{Function: stack[i], InApp: true})
+			continue
+		}
+		line, _ := strconv.Atoi(m[3])
+		col, _ := strconv.Atoi(m[4])
+		frames = append(frames, sentry.Frame{
+			Function: m[1],
+			Filename: m[2],
+			Lineno:   line,
+			Colno:    col,
+			InApp:    true,
+		})
+	}
+
+	return &sentry.Stacktrace{Frames: frames}
+}
+
+// compilePatterns compiles a list of regular expressions
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,65 @@
+package jsmachine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSentryReporterEvent(t *testing.T) {
+	redactor, err := newRedactor(&RedactionConfig{Fields: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter, err := newSentryReporter(&SentryConfig{Release: "scripts-2024.06.1", SampleRate: 1}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const code = `throw new Error("declined")`
+	tests := []struct {
+		name        string
+		exec        *execution
+		wantScript  string
+		wantInput   string
+		wantRelease string
+	}{
+		{
+			name: "registered script",
+			exec: &execution{
+				status:  "error",
+				script:  "charge",
+				version: scriptHash(code),
+				input:   `{"user":"ann","password":"hunter2","amount":12.50}`,
+			},
+			wantScript:  "charge",
+			wantInput:   `{"amount":12.50,"password":"[REDACTED]","user":"ann"}`,
+			wantRelease: "charge@" + scriptHash(code),
+		},
+		{
+			name:        "ad-hoc code",
+			exec:        &execution{status: "error"},
+			wantRelease: "scripts-2024.06.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := newExecutionFailure(tt.exec, code, errors.New("Error: declined"), time.Millisecond)
+			failure.scrub(redactor)
+			event := reporter.event(failure)
+
+			if got := event.Tags["script"]; got != tt.wantScript {
+				t.Errorf("got script %q, want %q", got, tt.wantScript)
+			}
+			if got, _ := event.Extra["input"].(string); got != tt.wantInput {
+				t.Errorf("got input %q, want %q", got, tt.wantInput)
+			}
+			if event.Release != tt.wantRelease {
+				t.Errorf("got release %q, want %q", event.Release, tt.wantRelease)
+			}
+		})
+	}
+}
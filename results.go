@@ -0,0 +1,283 @@
+package jsmachine
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// How a stored result is paged
+const (
+	resultItems = "items"
+	resultBytes = "bytes"
+)
+
+// Page sizes of FetchResult when the request sets no limit
+const (
+	defaultResultItems = 1000
+	defaultResultBytes = 1 << 20
+)
+
+// ResultRef is the handle of a result kept in the result store instead of being returned by Execute
+type ResultRef struct {
+	ID string `json:"id"`
+
+	// items for array results, paged by item, and bytes for other results, paged through their JSON encoding
+	Kind string `json:"kind"`
+
+	// Number of items or bytes to page through
+	Total int `json:"total"`
+
+	// Size of the result's JSON encoding in bytes
+	Size int `json:"size"`
+
+	// Time the result is dropped unless released before
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// storedResult is the JSON encoding of a result and, for arrays, where each item ends
+type storedResult struct {
+	data    []byte
+	ends    []int
+	expires time.Time
+}
+
+// resultStore keeps large results for a while so callers page through them instead of receiving them at once
+type resultStore struct {
+	cfg   *ResultStoreConfig
+	gauge prometheus.Gauge
+
+	mu      sync.Mutex
+	entries map[string]*storedResult
+	size    int64
+}
+
+// newResultStore creates an empty store
+func newResultStore(cfg *ResultStoreConfig, gauge prometheus.Gauge) *resultStore {
+	return &resultStore{
+		cfg:     cfg,
+		gauge:   gauge,
+		entries: make(map[string]*storedResult),
+	}
+}
+
+// offload moves the result of resp into the store when its JSON encoding exceeds the threshold; results that
+// cannot be encoded or that would not fit in the store at all stay in resp
+func (s *resultStore) offload(resp *ExecuteResponse) {
+	data, err := json.Marshal(resp.Result)
+	if err != nil || len(data) <= s.cfg.ThresholdBytes || int64(len(data)) > s.cfg.MaxBytes {
+		return
+	}
+
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	entry := &storedResult{data: data, ends: itemEnds(data), expires: time.Now().Add(time.Duration(s.cfg.TTLMs) * time.Millisecond)}
+	ref := &ResultRef{ID: hex.EncodeToString(id[:]), Kind: resultBytes, Total: len(data), Size: len(data), ExpiresAt: entry.expires}
+	if entry.ends != nil {
+		ref.Kind, ref.Total = resultItems, len(entry.ends)
+	}
+
+	s.mu.Lock()
+	s.prune(int64(len(data)))
+	s.entries[ref.ID] = entry
+	s.size += int64(len(data))
+	s.gauge.Set(float64(s.size))
+	s.mu.Unlock()
+
+	resp.Result, resp.ResultRef = nil, ref
+}
+
+// prune drops expired results, then the results closest to expiry until needed bytes fit (s.mu held)
+func (s *resultStore) prune(needed int64) {
+	now := time.Now()
+	for id, entry := range s.entries {
+		if now.After(entry.expires) {
+			s.drop(id, entry)
+		}
+	}
+	for s.size+needed > s.cfg.MaxBytes && len(s.entries) > 0 {
+		var oldestID string
+		var oldest *storedResult
+		for id, entry := range s.entries {
+			if oldest == nil || entry.expires.Before(oldest.expires) {
+				oldestID, oldest = id, entry
+			}
+		}
+		s.drop(oldestID, oldest)
+	}
+}
+
+// drop removes a result (s.mu held)
+func (s *resultStore) drop(id string, entry *storedResult) {
+	delete(s.entries, id)
+	s.size -= int64(len(entry.data))
+	s.gauge.Set(float64(s.size))
+}
+
+// get returns a stored result unless it expired
+func (s *resultStore) get(id string) (*storedResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		s.drop(id, entry)
+		return nil, false
+	}
+	return entry, true
+}
+
+// release drops a result, reporting whether it was stored
+func (s *resultStore) release(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if ok {
+		s.drop(id, entry)
+	}
+	return ok
+}
+
+// itemEnds returns the offsets at which the items of a JSON array end, nil when data is not an array
+// data is compact, as produced by json.Marshal, so item i starts right after the comma following item i-1
+func itemEnds(data []byte) []int {
+	if len(data) == 0 || data[0] != '[' {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil
+	}
+	ends := []int{}
+	for decoder.More() {
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil
+		}
+		ends = append(ends, int(decoder.InputOffset()))
+	}
+	return ends
+}
+
+// items returns the items of an array result from offset, at most limit
+func (r *storedResult) items(offset, limit int) []json.RawMessage {
+	end := min(offset+limit, len(r.ends))
+	items := make([]json.RawMessage, 0, max(end-offset, 0))
+	for i := offset; i < end; i++ {
+		start := 1
+		if i > 0 {
+			start = r.ends[i-1] + 1
+		}
+		items = append(items, r.data[start:r.ends[i]])
+	}
+	return items
+}
+
+// chunk returns the JSON encoding of a result from offset, at most limit bytes; the chunk ends on a character
+// boundary so it survives the JSON-encoded response, and next is where the following chunk starts
+func (r *storedResult) chunk(offset, limit int) (chunk string, next int) {
+	if offset >= len(r.data) {
+		return "", len(r.data)
+	}
+	end := min(offset+limit, len(r.data))
+	for end < len(r.data) && end > offset && !utf8.RuneStart(r.data[end]) {
+		end--
+	}
+	if end == offset {
+		// A limit smaller than the character at offset still makes progress
+		_, width := utf8.DecodeRune(r.data[offset:])
+		end = offset + width
+	}
+	return string(r.data[offset:end]), end
+}
+
+// FetchResultRequest selects a page of a stored result
+type FetchResultRequest struct {
+	// ID of the ResultRef returned by Execute
+	Ref string `json:"ref"`
+
+	// First item or byte of the page
+	Offset int `json:"offset"`
+
+	// Maximum number of items or bytes of the page (0 = 1000 items or 1 MiB)
+	Limit int `json:"limit"`
+}
+
+// FetchResultResponse is a page of a stored result
+type FetchResultResponse struct {
+	// Items of an array result
+	Items []json.RawMessage `json:"items,omitempty"`
+
+	// Part of the JSON encoding of other results; the parts concatenated form the whole encoding
+	Data string `json:"data,omitempty"`
+
+	// Offset of the next page, equal to Total once the last page was fetched
+	Next int `json:"next"`
+
+	// Number of items or bytes of the result
+	Total int `json:"total"`
+}
+
+// FetchResult returns a page of a result stored by Execute
+func (r *rpc) FetchResult(req *FetchResultRequest, resp *FetchResultResponse) error {
+	if r.plugin.results == nil {
+		return fmt.Errorf("result store is not configured (js.results)")
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return fmt.Errorf("offset and limit must not be negative")
+	}
+	entry, ok := r.plugin.results.get(req.Ref)
+	if !ok {
+		return fmt.Errorf("result %q not found, it expired or was released", req.Ref)
+	}
+
+	if entry.ends != nil {
+		limit := req.Limit
+		if limit == 0 {
+			limit = defaultResultItems
+		}
+		resp.Items = entry.items(req.Offset, limit)
+		resp.Total = len(entry.ends)
+		resp.Next = min(req.Offset+len(resp.Items), resp.Total)
+		return nil
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultResultBytes
+	}
+	resp.Data, resp.Next = entry.chunk(req.Offset, limit)
+	resp.Total = len(entry.data)
+	return nil
+}
+
+// ReleaseResultRequest selects a stored result
+type ReleaseResultRequest struct {
+	Ref string `json:"ref"`
+}
+
+// ReleaseResultResponse reports whether the result was still stored
+type ReleaseResultResponse struct {
+	Released bool `json:"released"`
+}
+
+// ReleaseResult drops a stored result before it expires
+func (r *rpc) ReleaseResult(req *ReleaseResultRequest, resp *ReleaseResultResponse) error {
+	if r.plugin.results == nil {
+		return fmt.Errorf("result store is not configured (js.results)")
+	}
+	resp.Released = r.plugin.results.release(req.Ref)
+	return nil
+}
@@ -0,0 +1,119 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxRetryAttempts caps the attempts a policy may request
+const maxRetryAttempts = 10
+
+// RetryPolicy describes how failed executions are retried
+type RetryPolicy struct {
+	// Attempts including the first one (1 = no retries)
+	MaxAttempts int `mapstructure:"max_attempts" json:"max_attempts"`
+
+	// Initial backoff in milliseconds, doubled after every attempt
+	BackoffMs int `mapstructure:"backoff_ms" json:"backoff_ms"`
+
+	// Upper bound for the backoff in milliseconds (0 = unbounded)
+	MaxBackoffMs int `mapstructure:"max_backoff_ms" json:"max_backoff_ms"`
+
+	// Statuses that trigger a retry (error, timeout)
+	RetryOn []string `mapstructure:"retry_on" json:"retry_on"`
+}
+
+// validate checks the policy for consistency
+func (rp *RetryPolicy) validate() error {
+	if rp.MaxAttempts < 1 {
+		return fmt.Errorf("max_attempts must be at least 1, got %d", rp.MaxAttempts)
+	}
+	if rp.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("max_attempts cannot exceed %d, got %d", maxRetryAttempts, rp.MaxAttempts)
+	}
+	if rp.BackoffMs < 0 || rp.MaxBackoffMs < 0 {
+		return fmt.Errorf("backoff_ms and max_backoff_ms cannot be negative")
+	}
+	for _, status := range rp.RetryOn {
+		if status != "error" && status != "timeout" {
+			return fmt.Errorf("retry_on: unknown status %q", status)
+		}
+	}
+	return nil
+}
+
+// shouldRetry reports whether an attempt that ended with status may be retried
+func (rp *RetryPolicy) shouldRetry(status string, attempt int) bool {
+	return attempt < rp.MaxAttempts && rp.retryable(status)
+}
+
+// exhausted reports whether a request that ended with status after attempt used up its retries
+func (rp *RetryPolicy) exhausted(status string, attempt int) bool {
+	return rp.MaxAttempts > 1 && attempt >= rp.MaxAttempts && rp.retryable(status)
+}
+
+// retryable reports whether status is retried by the policy
+func (rp *RetryPolicy) retryable(status string) bool {
+	for _, s := range rp.RetryOn {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the attempt following the given one
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := time.Duration(rp.BackoffMs) * time.Millisecond
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+	}
+
+	limit := time.Duration(rp.MaxBackoffMs) * time.Millisecond
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// retryPolicy returns the request's policy, falling back to the policy of the script it runs (js.scripts.retry) and
+// then to the configured default; ad-hoc code has no script policy
+func (p *Plugin) retryPolicy(req *ExecuteRequest) (*RetryPolicy, error) {
+	override := req.Retry
+	if override == nil {
+		if req.Script != "" && p.cfg.Scripts != nil {
+			if policy, ok := p.cfg.Scripts.Retry[req.Script]; ok {
+				return policy, nil
+			}
+		}
+		return p.cfg.Retry, nil
+	}
+
+	policy := *override
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = 1
+	}
+	if len(policy.RetryOn) == 0 {
+		policy.RetryOn = p.cfg.Retry.RetryOn
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// waitBackoff sleeps for delay unless the caller goes away or the plugin stops
+func (p *Plugin) waitBackoff(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.stopCh:
+		return errNotReady
+	}
+}
@@ -2,9 +2,11 @@ package jsmachine
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"time"
 
+	"github.com/robertkrimen/otto"
 	"go.uber.org/zap"
 )
 
@@ -19,11 +21,47 @@ type ExecuteRequest struct {
 	// JavaScript code to execute
 	Code string `json:"code"`
 
+	// Name of a registered script to execute instead of code
+	Script string `json:"script,omitempty"`
+
 	// Execution timeout in milliseconds (0 = use default)
 	TimeoutMs int `json:"timeout_ms"`
 
 	// Request context for logging/tracing
 	RequestID string `json:"request_id,omitempty"`
+
+	// Identity of the calling service or user, exposed to scripts as ctx.caller
+	Caller string `json:"caller,omitempty"`
+
+	// Purpose of the execution (one of js.tags), added to logs, traces and metrics
+	Tag string `json:"tag,omitempty"`
+
+	// W3C traceparent of the caller, used to correlate exported script logs
+	TraceParent string `json:"traceparent,omitempty"`
+
+	// Return a hot-spot report of the execution (requires js.profiling)
+	Profile bool `json:"profile,omitempty"`
+
+	// Retry policy for this request (nil = use js.retry)
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// Return a result larger than js.results.threshold_bytes as ResultRef, to page through with FetchResult
+	AcceptResultRef bool `json:"accept_result_ref,omitempty"`
+
+	// Requests with the same key run once across nodes while the result is shared (requires js.dedupe)
+	DedupeKey string `json:"dedupe_key,omitempty"`
+
+	// Set when re-driving a dead-letter entry, so failures are not stored twice
+	redrive bool
+
+	// Program of the requested script compiled by the registry (nil while its compilation is pending)
+	program *otto.Script
+
+	// JSON document exposed to the script as ctx.input (set by ExecuteMapReduce and script chains)
+	input string
+
+	// Number of scripts run before this one in an on_success/on_failure chain
+	chainDepth int
 }
 
 // ExecuteResponse represents the execution result
@@ -39,53 +77,245 @@ type ExecuteResponse struct {
 
 	// Request ID for correlation
 	RequestID string `json:"request_id,omitempty"`
+
+	// Number of attempts made (greater than 1 when retried)
+	Attempts int `json:"attempts"`
+
+	// Audit record ID (when the audit log is enabled), usable with Replay
+	AuditID string `json:"audit_id,omitempty"`
+
+	// Executions queued for a VM when the last attempt arrived (0 when a VM was idle)
+	QueueDepth int `json:"queue_depth"`
+
+	// Time the last attempt waited for a VM in milliseconds
+	WaitedMs int64 `json:"waited_ms"`
+
+	// Set to invalid_request for requests rejected by validation, with the offending fields in Violations,
+	// to not_ready for requests arriving before the plugin serves or while it stops, or to budget_exceeded for
+	// callers that used up a daily budget of js.costs
+	ErrorCode  string           `json:"error_code,omitempty"`
+	Violations []FieldViolation `json:"violations,omitempty"`
+
+	// Suggested delay before sending more work, set only when the pool was saturated
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+
+	// Hot spots of the last attempt (when requested with profile)
+	Profile *ProfileReport `json:"profile,omitempty"`
+
+	// Result holds what the script returned after it timed out or was cancelled (Error is set as well)
+	Partial bool `json:"partial,omitempty"`
+
+	// Handle of the result, which is then kept in the result store instead of being returned in Result
+	ResultRef *ResultRef `json:"result_ref,omitempty"`
+
+	// Records added with emit(record), in emit order
+	Records []json.RawMessage `json:"records,omitempty"`
+
+	// Result and Records are those of another request with the same dedupe key
+	Deduplicated bool `json:"deduplicated,omitempty"`
+}
+
+// rejectRequest encodes a request that failed validation in resp and counts it separately from executions
+func (r *rpc) rejectRequest(req *ExecuteRequest, resp *ExecuteResponse, err error) {
+	resp.Error = err.Error()
+	resp.ErrorCode = errCodeInvalidRequest
+	resp.Violations = violations(err)
+	resp.RequestID = req.RequestID
+
+	// The tag is only trusted once validated
+	r.plugin.executionsTotal.WithLabelValues(errCodeInvalidRequest, r.plugin.poolFor(req.Script).name, "").Inc()
+	r.log.Debug("JavaScript execution request rejected",
+		zap.String("request_id", req.RequestID),
+		zap.Error(err),
+	)
 }
 
-// Execute runs JavaScript code and returns the result
+// rejectNotReady encodes a request that arrived before Serve or after Stop in resp
+func (r *rpc) rejectNotReady(req *ExecuteRequest, resp *ExecuteResponse) {
+	resp.Error = errNotReady.Error()
+	resp.ErrorCode = errCodeNotReady
+	resp.RequestID = req.RequestID
+
+	r.log.Debug("JavaScript execution request refused",
+		zap.String("request_id", req.RequestID),
+		zap.Stringer("state", r.plugin.lifecycle.current()),
+	)
+}
+
+// Execute runs JavaScript code and returns the result, or a handle to it for large results when accepted
 func (r *rpc) Execute(req *ExecuteRequest, resp *ExecuteResponse) error {
+	if err := r.handleExecute(context.Background(), req, resp); err != nil {
+		return err
+	}
+	if req.AcceptResultRef && r.plugin.results != nil && resp.Error == "" {
+		r.plugin.results.offload(resp)
+	}
+	return nil
+}
+
+// handleExecute runs an execute request under ctx (shared by the RPC and gRPC transports)
+func (r *rpc) handleExecute(ctx context.Context, req *ExecuteRequest, resp *ExecuteResponse) error {
 	start := time.Now()
 
+	// Pools only exist between Serve and Stop
+	if !r.plugin.lifecycle.serving() {
+		r.rejectNotReady(req, resp)
+		return nil
+	}
+
+	// Strip BOMs and Windows line endings that confuse the parser
+	if req.Code != "" && !req.redrive {
+		req.Code = normalizeCode(req.Code, r.plugin.cfg.StrictEncoding)
+	}
+
 	// Validate request
-	if req.Code == "" {
-		resp.Error = "code is required"
-		return fmt.Errorf("code is required")
+	if err := r.plugin.validateRequest(req); err != nil {
+		r.rejectRequest(req, resp, err)
+		return nil
+	}
+
+	// Resolve registered scripts (re-drives carry the code that originally failed)
+	if req.Script != "" && !req.redrive {
+		if err := r.plugin.resolveScript(req); err != nil {
+			r.rejectRequest(req, resp, err)
+			return nil
+		}
+	}
+
+	// Determine timeout within the limits of the pool the code runs in
+	timeout := r.plugin.requestTimeout(r.plugin.poolFor(req.Script), req)
+
+	// Determine retry policy (validated above)
+	policy, err := r.plugin.retryPolicy(req)
+	if err != nil {
+		r.rejectRequest(req, resp, err)
+		return nil
+	}
+
+	// Refuse callers that used up their daily budget
+	if r.plugin.costs != nil {
+		if err := r.plugin.costs.admit(req.Caller); err != nil {
+			r.rejectBudget(req, resp, err)
+			return nil
+		}
 	}
 
-	// Determine timeout
-	timeout := time.Duration(r.plugin.cfg.DefaultTimeout) * time.Millisecond
-	if req.TimeoutMs > 0 {
-		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	// Return the result of a request with the same dedupe key instead of running again
+	if req.DedupeKey != "" && r.plugin.dedupe != nil {
+		claim, done := r.plugin.dedupe.claim(ctx, &r.plugin.lifecycle, req, resp)
+		if done {
+			return nil
+		}
+		if claim != nil {
+			defer claim.finish(resp)
+		}
 	}
 
 	// Log execution start
 	r.log.Debug("executing JavaScript",
 		zap.String("request_id", req.RequestID),
+		zap.String("tag", req.Tag),
 		zap.Int("code_length", len(req.Code)),
 		zap.Duration("timeout", timeout),
 	)
 
-	// Execute JavaScript with background context
-	ctx := context.Background()
-	result, err := r.plugin.execute(ctx, req.Code, timeout)
+	// Execute JavaScript, retrying according to the policy
+	var (
+		exec   *execution
+		result interface{}
+	)
+	for attempt := 1; ; attempt++ {
+		exec = &execution{requestID: req.RequestID, script: req.Script, caller: req.Caller, tag: req.Tag, attempt: attempt, program: req.program, input: req.input}
+		if req.Script != "" {
+			exec.version = scriptHash(req.Code)
+		}
+		exec.traceID, exec.spanID = parseTraceParent(req.TraceParent)
+		if req.Profile && r.plugin.cfg.Profiling != nil {
+			exec.profiler = newProfiler()
+		}
+
+		result, err = r.plugin.execute(ctx, exec, req.Code, timeout)
+		if err == nil || !policy.shouldRetry(exec.status, attempt) {
+			break
+		}
+
+		delay := policy.backoff(attempt)
+		r.plugin.executionRetries.WithLabelValues(exec.status, exec.pool.name).Inc()
+		r.log.Debug("retrying JavaScript execution",
+			zap.String("request_id", req.RequestID),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay),
+			zap.String("error", r.plugin.redactor.string(err.Error())),
+		)
+
+		if werr := r.plugin.waitBackoff(ctx, delay); werr != nil {
+			break
+		}
+	}
 
 	duration := time.Since(start)
 	resp.DurationMs = duration.Milliseconds()
 	resp.RequestID = req.RequestID
+	resp.Attempts = exec.attempt
+	resp.QueueDepth = exec.queueDepth
+	resp.WaitedMs = exec.waited.Milliseconds()
+	resp.RetryAfterMs = r.plugin.retryAfter(exec.pool, exec.queueDepth).Milliseconds()
+	if exec.profiler != nil {
+		cfg := r.plugin.cfg.Profiling
+		resp.Profile = exec.profiler.report(cfg.Top, time.Duration(cfg.IntervalUs)*time.Microsecond)
+	}
+
+	// Notify stream subscribers and record the outcome
+	defer func() {
+		if r.plugin.auditLog != nil {
+			r.plugin.audit(req, resp, exec.status)
+		}
+
+		r.plugin.streams.publish(streamEvent{
+			Type:       streamEventResult,
+			RequestID:  resp.RequestID,
+			Result:     resp.Result,
+			Error:      resp.Error,
+			DurationMs: resp.DurationMs,
+		})
+
+		r.plugin.chain(req, resp, exec.status)
+	}()
 
 	if err != nil {
 		resp.Error = err.Error()
+		if errors.Is(err, errNotReady) {
+			// Admitted before Stop, but still waiting for a VM or a concurrency slot when it began
+			resp.ErrorCode = errCodeNotReady
+		}
+		if exec.partial {
+			resp.Result = result
+			resp.Records = r.plugin.outputRecords(exec)
+			resp.Partial = true
+		}
 		r.log.Error("JavaScript execution failed",
 			zap.String("request_id", req.RequestID),
-			zap.Error(err),
+			zap.String("tag", req.Tag),
+			zap.String("error", r.plugin.redactor.string(err.Error())),
 			zap.Duration("duration", duration),
 		)
+
+		r.plugin.reportFailure(exec, req.Code, err, duration)
+
+		// Keep requests that exhausted their retries for later inspection or re-drive
+		if r.plugin.deadLetters != nil && !req.redrive && policy.exhausted(exec.status, exec.attempt) {
+			r.plugin.deadLetter(req, resp, exec.status)
+		}
 		return nil // Don't return error to RPC, encode it in response
 	}
 
 	resp.Result = result
+	resp.Records = r.plugin.outputRecords(exec)
+	r.plugin.sink(exec, result)
 
 	r.log.Debug("JavaScript execution completed",
 		zap.String("request_id", req.RequestID),
+		zap.String("tag", req.Tag),
 		zap.Duration("duration", duration),
 	)
 
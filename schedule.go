@@ -0,0 +1,626 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/api/v4/plugins/v1/kv"
+	"go.uber.org/zap"
+)
+
+// Catch-up policies for runs missed while RoadRunner was down
+const (
+	catchUpSkip = "skip" // missed runs are dropped
+	catchUpOnce = "once" // one run replaces all missed runs
+	catchUpAll  = "all"  // every missed run is made, oldest first, up to max_catch_up
+)
+
+// Schedule state drivers
+const (
+	scheduleStateMemory = "memory"
+	scheduleStateFile   = "file"
+	scheduleStateKV     = "kv"
+)
+
+// Outcomes counted by js_scheduled_runs_total
+const (
+	scheduledSuccess = "success"
+	scheduledFailed  = "failed"
+	scheduledSkipped = "skipped"
+	scheduledBlocked = "blocked"
+)
+
+// scheduleStore records the last run of every schedule, so restarts neither skip nor repeat runs
+type scheduleStore interface {
+	// last returns the time of the last run slot of a schedule (zero when it never ran)
+	last(name string) (time.Time, error)
+
+	// claim records slot as the last run, reporting false when the slot or a later one is already recorded,
+	// e.g. by another instance sharing the store
+	claim(name string, slot time.Time) (bool, error)
+
+	close()
+}
+
+// newScheduleStore creates the store of the configured driver; the kv driver is only available from Serve
+func (p *Plugin) newScheduleStore(cfg *ScheduleStateConfig) (scheduleStore, error) {
+	switch cfg.Driver {
+	case scheduleStateFile:
+		return newFileScheduleStore(cfg.Path)
+	case scheduleStateKV:
+		storage, err := p.kvStorage(cfg.Storage)
+		if err != nil {
+			return nil, fmt.Errorf("schedule state storage: %w", err)
+		}
+		return &kvScheduleStore{storage: storage, prefix: cfg.Prefix, locker: p.bindings.lock.locker()}, nil
+	}
+	return &memoryScheduleStore{runs: make(map[string]time.Time)}, nil
+}
+
+// memoryScheduleStore keeps last runs in memory; they are lost on restart, so nothing is caught up
+type memoryScheduleStore struct {
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+// last implements scheduleStore
+func (s *memoryScheduleStore) last(name string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.runs[name], nil
+}
+
+// claim implements scheduleStore
+func (s *memoryScheduleStore) claim(name string, slot time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slot.After(s.runs[name]) {
+		return false, nil
+	}
+	s.runs[name] = slot
+	return true, nil
+}
+
+// close implements scheduleStore
+func (s *memoryScheduleStore) close() {}
+
+// fileScheduleStore keeps last runs in a JSON file, rewritten atomically after every claim
+type fileScheduleStore struct {
+	memoryScheduleStore
+	path string
+}
+
+// newFileScheduleStore loads the state file; a missing file is an empty state
+func newFileScheduleStore(path string) (*fileScheduleStore, error) {
+	s := &fileScheduleStore{memoryScheduleStore: memoryScheduleStore{runs: make(map[string]time.Time)}, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.runs); err != nil {
+		return nil, fmt.Errorf("invalid schedule state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// claim implements scheduleStore
+func (s *fileScheduleStore) claim(name string, slot time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.runs[name]
+	if !slot.After(previous) {
+		return false, nil
+	}
+	s.runs[name] = slot
+
+	if err := s.write(); err != nil {
+		s.runs[name] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// write replaces the state file
+func (s *fileScheduleStore) write() error {
+	data, err := json.MarshalIndent(s.runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces a file through a temporary one, so a crash mid-write cannot leave it truncated
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// kvScheduleStore keeps last runs in a storage of the kv plugin, as unix milliseconds under <prefix><schedule>
+// Claims are made under a lock per schedule, so instances sharing the storage and the lock plugin run every slot once
+type kvScheduleStore struct {
+	storage kv.Storage
+	prefix  string
+	locker  distributedLocker
+}
+
+// kvScheduleLockWait bounds the wait for a schedule's claim lock held by another instance
+const kvScheduleLockWait = 5 * time.Second
+
+// last implements scheduleStore
+func (s *kvScheduleStore) last(name string) (time.Time, error) {
+	data, err := s.storage.Get(s.prefix + name)
+	if err != nil || data == nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("corrupted last run of schedule %s: %w", name, err)
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// claim implements scheduleStore
+func (s *kvScheduleStore) claim(name string, slot time.Time) (bool, error) {
+	lockKey, owner := s.prefix+name+":lock", newRecordID()
+	deadline := time.Now().Add(kvScheduleLockWait)
+	for {
+		acquired, err := s.locker.Lock(lockKey, owner, kvScheduleLockWait)
+		if err != nil {
+			return false, err
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("schedule %s is locked", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() { _, _ = s.locker.Release(lockKey, owner) }()
+
+	last, err := s.last(name)
+	if err != nil {
+		return false, err
+	}
+	if !slot.After(last) {
+		return false, nil
+	}
+	if err := s.storage.Set(&kvItem{key: s.prefix + name, value: []byte(strconv.FormatInt(slot.UnixMilli(), 10))}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// close implements scheduleStore
+func (s *kvScheduleStore) close() {
+	s.storage.Stop()
+}
+
+// Reasons a slot is not run
+const (
+	blockedOutsideWindows = "outside windows"
+	blockedBlackout       = "blackout"
+)
+
+// scheduledJob is a configured schedule and its parsed expression, windows and blackouts
+type scheduledJob struct {
+	name      string
+	cfg       *ScheduleConfig
+	cron      *cronSchedule
+	windows   []*scheduleWindow
+	blackouts []*scheduleBlackout
+}
+
+// newScheduledJob parses a schedule in its time zone; errors name the configuration key
+func newScheduledJob(name string, cfg *ScheduleConfig) (*scheduledJob, error) {
+	key := "schedules.jobs." + name
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%s.timezone: %w", key, err)
+	}
+
+	job := &scheduledJob{name: name, cfg: cfg}
+	if job.cron, err = parseCron(cfg.Cron, loc); err != nil {
+		return nil, fmt.Errorf("%s.cron: %w", key, err)
+	}
+	for i, windowCfg := range cfg.Windows {
+		if windowCfg == nil {
+			return nil, fmt.Errorf("%s.windows[%d]: empty window", key, i)
+		}
+		window, err := parseScheduleWindow(windowCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s.windows[%d]: %w", key, i, err)
+		}
+		job.windows = append(job.windows, window)
+	}
+	for i, blackoutCfg := range cfg.Blackouts {
+		if blackoutCfg == nil {
+			return nil, fmt.Errorf("%s.blackouts[%d]: empty blackout", key, i)
+		}
+		blackout, err := parseScheduleBlackout(blackoutCfg, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s.blackouts[%d]: %w", key, i, err)
+		}
+		job.blackouts = append(job.blackouts, blackout)
+	}
+	return job, nil
+}
+
+// blocked returns why a slot may not run, or an empty string when it may
+func (j *scheduledJob) blocked(slot time.Time) string {
+	slot = slot.In(j.cron.loc)
+	for _, blackout := range j.blackouts {
+		if blackout.contains(slot) {
+			return blockedBlackout
+		}
+	}
+	if len(j.windows) == 0 {
+		return ""
+	}
+	for _, window := range j.windows {
+		if window.contains(slot) {
+			return ""
+		}
+	}
+	return blockedOutsideWindows
+}
+
+// scheduleWindow is a daily time range on some days of the week, in minutes since midnight
+type scheduleWindow struct {
+	days     uint64 // bit set of weekdays, Sunday is 0
+	from, to int
+}
+
+// parseScheduleWindow parses days (cron day-of-week syntax per entry) and the HH:MM range
+func parseScheduleWindow(cfg *ScheduleWindowConfig) (*scheduleWindow, error) {
+	w := &scheduleWindow{}
+	days := cfg.Days
+	if len(days) == 0 {
+		days = []string{"*"}
+	}
+	for _, day := range days {
+		set, err := parseCronField(day, cronFields[4])
+		if err != nil {
+			return nil, fmt.Errorf("days: %w", err)
+		}
+		w.days |= set
+	}
+	// Sunday is 0 or 7
+	if w.days&(1<<7) != 0 {
+		w.days |= 1
+	}
+
+	var err error
+	if w.from, err = parseClock(cfg.From); err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	if w.to, err = parseClock(cfg.To); err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+	if w.from == w.to {
+		return nil, errors.New("from and to must differ")
+	}
+	return w, nil
+}
+
+// contains reports whether t is in the window; a window ending before it starts continues past midnight,
+// counting as the day it started on
+func (w *scheduleWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := w.days&(1<<uint(t.Weekday())) != 0
+	if w.from < w.to {
+		return day && minute >= w.from && minute < w.to
+	}
+	previousDay := w.days&(1<<uint((t.Weekday()+6)%7)) != 0
+	return (day && minute >= w.from) || (previousDay && minute < w.to)
+}
+
+// parseClock parses HH:MM into minutes since midnight; 24:00 is the end of the day
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// scheduleBlackout is a recurring window or a one-off period runs are not allowed in
+type scheduleBlackout struct {
+	window     *scheduleWindow
+	start, end time.Time
+}
+
+// parseScheduleBlackout parses either form; start and end without an offset are in loc
+func parseScheduleBlackout(cfg *ScheduleBlackoutConfig, loc *time.Location) (*scheduleBlackout, error) {
+	recurring := cfg.From != "" || cfg.To != "" || len(cfg.Days) > 0
+	oneOff := cfg.Start != "" || cfg.End != ""
+	if recurring == oneOff {
+		return nil, errors.New("set either days, from and to, or start and end")
+	}
+
+	if recurring {
+		window, err := parseScheduleWindow(&cfg.ScheduleWindowConfig)
+		if err != nil {
+			return nil, err
+		}
+		return &scheduleBlackout{window: window}, nil
+	}
+
+	b := &scheduleBlackout{}
+	var err error
+	if b.start, err = parseScheduleTime(cfg.Start, loc); err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	if b.end, err = parseScheduleTime(cfg.End, loc); err != nil {
+		return nil, fmt.Errorf("end: %w", err)
+	}
+	if !b.end.After(b.start) {
+		return nil, errors.New("end must be after start")
+	}
+	return b, nil
+}
+
+// contains reports whether t is in the blackout; the end of a period is excluded
+func (b *scheduleBlackout) contains(t time.Time) bool {
+	if b.window != nil {
+		return b.window.contains(t)
+	}
+	return !t.Before(b.start) && t.Before(b.end)
+}
+
+// parseScheduleTime parses "2006-01-02 15:04" in loc, or an RFC 3339 time
+func parseScheduleTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected \"2006-01-02 15:04\" or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// scheduler runs registered scripts on their cron schedules
+type scheduler struct {
+	jobs  []*scheduledJob
+	store scheduleStore
+	run   func(job *scheduledJob, slot time.Time) error
+	log   *zap.Logger
+	runs  *prometheus.CounterVec
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newScheduler parses the schedules (validated with the configuration); the state store is set before start
+func newScheduler(cfg *SchedulesConfig, log *zap.Logger, runs *prometheus.CounterVec) (*scheduler, error) {
+	s := &scheduler{log: log, runs: runs, quit: make(chan struct{})}
+	for name, jobCfg := range cfg.Jobs {
+		job, err := newScheduledJob(name, jobCfg)
+		if err != nil {
+			return nil, err
+		}
+		s.jobs = append(s.jobs, job)
+	}
+	return s, nil
+}
+
+// start starts one loop per schedule
+func (s *scheduler) start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(job)
+		}()
+	}
+}
+
+// stop stops the loops, waiting for runs in progress (bounded by ctx)
+func (s *scheduler) stop(ctx context.Context) {
+	close(s.quit)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	if s.store != nil {
+		s.store.close()
+	}
+}
+
+// loop catches up on missed runs, then runs the schedule until the scheduler stops
+func (s *scheduler) loop(job *scheduledJob) {
+	s.catchUp(job)
+
+	for {
+		slot := job.cron.next(time.Now())
+		if slot.IsZero() {
+			s.log.Warn("schedule never fires, it is disabled", zap.String("schedule", job.name))
+			return
+		}
+
+		timer := time.NewTimer(time.Until(slot))
+		select {
+		case <-timer.C:
+			s.fire(job, slot)
+		case <-s.quit:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// catchUp makes the runs missed since the last recorded run according to the schedule's policy
+// Nothing is caught up for a schedule that never ran: its first run is the next slot
+func (s *scheduler) catchUp(job *scheduledJob) {
+	last, err := s.store.last(job.name)
+	if err != nil {
+		s.log.Error("failed to read the last run of a schedule, missed runs are not caught up",
+			zap.String("schedule", job.name), zap.Error(err))
+		return
+	}
+	if last.IsZero() {
+		return
+	}
+
+	// Keep the newest max_catch_up missed slots that windows and blackouts allow, counting the others
+	var missed []time.Time
+	var newest time.Time
+	dropped, blocked := 0, 0
+	now := time.Now()
+	for slot := job.cron.next(last); !slot.IsZero() && !slot.After(now); slot = job.cron.next(slot) {
+		newest = slot
+		if job.blocked(slot) != "" {
+			blocked++
+			continue
+		}
+		missed = append(missed, slot)
+		if len(missed) > job.cfg.MaxCatchUp {
+			missed = missed[1:]
+			dropped++
+		}
+	}
+	if newest.IsZero() {
+		return
+	}
+	if len(missed) == 0 {
+		// Every missed slot was blocked: record them, so a later restart does not count them again
+		s.runs.WithLabelValues(job.name, scheduledBlocked).Add(float64(blocked))
+		if _, err := s.store.claim(job.name, newest); err != nil {
+			s.log.Warn("failed to record blocked scheduled runs", zap.String("schedule", job.name), zap.Error(err))
+		}
+		return
+	}
+
+	switch job.cfg.CatchUp {
+	case catchUpOnce:
+		dropped += len(missed) - 1
+		missed = missed[len(missed)-1:]
+	case catchUpSkip:
+		dropped += len(missed)
+		missed = nil
+	}
+
+	if dropped > 0 {
+		s.runs.WithLabelValues(job.name, scheduledSkipped).Add(float64(dropped))
+	}
+	if blocked > 0 {
+		s.runs.WithLabelValues(job.name, scheduledBlocked).Add(float64(blocked))
+	}
+	s.log.Info("catching up on missed scheduled runs",
+		zap.String("schedule", job.name),
+		zap.String("policy", job.cfg.CatchUp),
+		zap.Time("last_run", last),
+		zap.Int("runs", len(missed)),
+		zap.Int("skipped", dropped),
+		zap.Int("blocked", blocked),
+	)
+
+	if len(missed) == 0 {
+		// Record the skipped and blocked slots, so a later restart does not count them again
+		if _, err := s.store.claim(job.name, newest); err != nil {
+			s.log.Warn("failed to record skipped scheduled runs", zap.String("schedule", job.name), zap.Error(err))
+		}
+		return
+	}
+	for _, slot := range missed {
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+		s.fire(job, slot)
+	}
+}
+
+// fire claims a slot and runs the schedule's script for it
+// Slots are recorded before the run, so a restart never repeats a run that started; a run interrupted by a crash
+// is not made again (failures are covered by js.retry and the dead-letter store)
+func (s *scheduler) fire(job *scheduledJob, slot time.Time) {
+	claimed, err := s.store.claim(job.name, slot)
+	if err != nil {
+		s.runs.WithLabelValues(job.name, scheduledFailed).Inc()
+		s.log.Error("failed to record a scheduled run, the run is skipped",
+			zap.String("schedule", job.name), zap.Time("slot", slot), zap.Error(err))
+		return
+	}
+	if !claimed {
+		s.log.Debug("scheduled run already made", zap.String("schedule", job.name), zap.Time("slot", slot))
+		return
+	}
+
+	// Blocked slots are claimed too, so a restart does not catch them up
+	if reason := job.blocked(slot); reason != "" {
+		s.runs.WithLabelValues(job.name, scheduledBlocked).Inc()
+		s.log.Info("scheduled run not allowed now, it is skipped",
+			zap.String("schedule", job.name), zap.Time("slot", slot), zap.String("reason", reason))
+		return
+	}
+
+	if err := s.run(job, slot); err != nil {
+		s.runs.WithLabelValues(job.name, scheduledFailed).Inc()
+		s.log.Warn("scheduled run failed", zap.String("schedule", job.name), zap.Time("slot", slot), zap.Error(err))
+		return
+	}
+	s.runs.WithLabelValues(job.name, scheduledSuccess).Inc()
+}
+
+// runScheduled executes the schedule's script for a slot, as a request with retries, dead letters and audit applied
+// The request ID is derived from the slot, so retried and replayed runs of a slot are recognizable
+func (p *Plugin) runScheduled(job *scheduledJob, slot time.Time) error {
+	req := &ExecuteRequest{
+		Script:    job.cfg.Script,
+		TimeoutMs: job.cfg.TimeoutMs,
+		Tag:       job.cfg.Tag,
+		RequestID: "schedule-" + job.name + "-" + slot.UTC().Format("20060102T150405Z"),
+		Caller:    "schedule:" + job.name,
+	}
+	resp := &ExecuteResponse{}
+	if err := (&rpc{plugin: p, log: p.log}).handleExecute(context.Background(), req, resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
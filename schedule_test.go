@@ -0,0 +1,51 @@
+package jsmachine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func TestSchedulerCatchUpAllSlotsBlocked(t *testing.T) {
+	for _, policy := range []string{catchUpSkip, catchUpOnce, catchUpAll} {
+		t.Run(policy, func(t *testing.T) {
+			runs := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "scheduled_runs_total"}, []string{"schedule", "status"})
+			s, err := newScheduler(&SchedulesConfig{Jobs: map[string]*ScheduleConfig{
+				"report": {
+					Script:     "report",
+					Cron:       "* * * * *",
+					CatchUp:    policy,
+					MaxCatchUp: 100,
+					Timezone:   "UTC",
+					Blackouts:  []*ScheduleBlackoutConfig{{ScheduleWindowConfig: ScheduleWindowConfig{From: "00:00", To: "24:00"}}},
+				},
+			}}, zap.NewNop(), runs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			last := time.Now().Add(-10 * time.Minute).Truncate(time.Minute)
+			store := &memoryScheduleStore{runs: map[string]time.Time{"report": last}}
+			s.store = store
+			s.run = func(job *scheduledJob, slot time.Time) error {
+				t.Errorf("blocked slot %s was run", slot)
+				return nil
+			}
+
+			s.catchUp(s.jobs[0])
+
+			if got := store.runs["report"]; !got.After(last) {
+				t.Errorf("last run not advanced past the blocked slots: %s", got)
+			}
+			if got := testutil.ToFloat64(runs.WithLabelValues("report", scheduledBlocked)); got < 9 {
+				t.Errorf("got %v blocked runs, want at least 9", got)
+			}
+			if got := testutil.ToFloat64(runs.WithLabelValues("report", scheduledSkipped)); got != 0 {
+				t.Errorf("got %v skipped runs, want 0", got)
+			}
+		})
+	}
+}
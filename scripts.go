@@ -0,0 +1,453 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/robertkrimen/otto"
+)
+
+// scriptExt is the extension of script files in the scripts directory
+const scriptExt = ".js"
+
+// manifestExt is the extension of optional manifest files next to scripts (name.manifest.json)
+const manifestExt = ".manifest.json"
+
+// Compile statuses of registered scripts
+const (
+	compilePending  = "pending"  // queued for the background compiler; executions compile the code themselves
+	compileCompiled = "compiled" // executions run the compiled program
+	compileFailed   = "failed"   // the code does not compile, executions report the error
+)
+
+// scriptCompileQueue bounds the registry loads waiting for the background compiler
+const scriptCompileQueue = 4
+
+// errCompileQueueFull is returned when the registry is reloaded faster than the compiler keeps up
+var errCompileQueueFull = errors.New("script compile queue is full, retry once pending scripts are compiled")
+
+// ScriptInfo is the metadata of a registered script
+type ScriptInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// JSON schema describing the script's input
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+
+	// Registered scripts run with the outcome of a successful or failed execution as input
+	OnSuccess string `json:"on_success,omitempty"`
+	OnFailure string `json:"on_failure,omitempty"`
+
+	// Where the metadata came from: jsdoc, manifest or none
+	Source string `json:"source"`
+
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+
+	// Compile status (pending, compiled or failed) and the compiler's error for failed scripts
+	CompileStatus string `json:"compile_status"`
+	CompileError  string `json:"compile_error,omitempty"`
+}
+
+// scriptManifest is the format of name.manifest.json files
+type scriptManifest struct {
+	Description string          `json:"description"`
+	Owner       string          `json:"owner"`
+	Tags        []string        `json:"tags"`
+	InputSchema json.RawMessage `json:"input_schema"`
+	OnSuccess   string          `json:"on_success"`
+	OnFailure   string          `json:"on_failure"`
+}
+
+// registeredScript is a script loaded from the scripts directory
+type registeredScript struct {
+	info ScriptInfo
+	code string
+
+	// Set by the background compiler (nil while pending)
+	compilation atomic.Pointer[scriptCompilation]
+}
+
+// scriptCompilation is the outcome of compiling a registered script
+type scriptCompilation struct {
+	program *otto.Script // nil when the script does not compile
+	err     string
+}
+
+// program returns the compiled program, nil while the script is pending or when it does not compile
+func (s *registeredScript) program() *otto.Script {
+	if c := s.compilation.Load(); c != nil {
+		return c.program
+	}
+	return nil
+}
+
+// scriptInfo returns the script's metadata with its current compile status
+func (s *registeredScript) scriptInfo() ScriptInfo {
+	info := s.info
+	switch c := s.compilation.Load(); {
+	case c == nil:
+		info.CompileStatus = compilePending
+	case c.program == nil:
+		info.CompileStatus, info.CompileError = compileFailed, c.err
+	default:
+		info.CompileStatus = compileCompiled
+	}
+	return info
+}
+
+// compile parses the script's code and records the outcome
+func (s *registeredScript) compile() {
+	program, err := otto.New().Compile("", s.code)
+	if err != nil {
+		s.compilation.Store(&scriptCompilation{err: err.Error()})
+		return
+	}
+	s.compilation.Store(&scriptCompilation{program: program})
+}
+
+// errScriptNotFound is returned for unknown script names
+var errScriptNotFound = errors.New("script not found")
+
+// scriptRegistry holds the scripts loaded from js.scripts.dir
+// Scripts are compiled by a background compiler, so loading the registry never waits for the parser
+type scriptRegistry struct {
+	mu      sync.RWMutex
+	dir     string
+	scripts map[string]*registeredScript
+
+	// Scripts of each load waiting for the compiler
+	queue chan []*registeredScript
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// newScriptRegistry loads all scripts from dir and starts compiling them
+func newScriptRegistry(dir string) (*scriptRegistry, error) {
+	r := &scriptRegistry{
+		dir:   dir,
+		queue: make(chan []*registeredScript, scriptCompileQueue),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go r.compileLoop()
+
+	if err := r.load(); err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// compileLoop compiles queued scripts until the registry is closed, skipping those a later load replaced
+func (r *scriptRegistry) compileLoop() {
+	defer close(r.done)
+
+	for {
+		select {
+		case <-r.quit:
+			return
+		case scripts := <-r.queue:
+			for _, script := range scripts {
+				select {
+				case <-r.quit:
+					return
+				default:
+				}
+				if r.registered(script) {
+					script.compile()
+				}
+			}
+		}
+	}
+}
+
+// registered reports whether script is still the registered version of its name
+func (r *scriptRegistry) registered(script *registeredScript) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.scripts[script.info.Name] == script
+}
+
+// close stops the compiler; scripts still pending stay so
+func (r *scriptRegistry) close() {
+	close(r.quit)
+	<-r.done
+}
+
+// load (re)reads the scripts directory, replacing the registered scripts, and queues the new and changed ones for
+// compilation; it returns once they are queued, and unchanged scripts keep their compiled program
+func (r *scriptRegistry) load() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read scripts directory: %w", err)
+	}
+
+	scripts := make(map[string]*registeredScript)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != scriptExt {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), scriptExt)
+		script, err := loadScript(r.dir, name)
+		if err != nil {
+			return fmt.Errorf("script %s: %w", name, err)
+		}
+		scripts[name] = script
+	}
+
+	for name, script := range scripts {
+		for trigger, next := range map[string]string{"on_success": script.info.OnSuccess, "on_failure": script.info.OnFailure} {
+			if _, ok := scripts[next]; next != "" && !ok {
+				return fmt.Errorf("script %s: %s names unknown script %q", name, trigger, next)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pending []*registeredScript
+	for name, script := range scripts {
+		if previous, ok := r.scripts[name]; ok && previous.info.Hash == script.info.Hash && previous.compilation.Load() != nil {
+			script.compilation.Store(previous.compilation.Load())
+			continue
+		}
+		pending = append(pending, script)
+	}
+
+	// The registry is replaced before the compiler can look at the queued scripts, as it holds r.mu
+	if len(pending) > 0 {
+		select {
+		case r.queue <- pending:
+		default:
+			return errCompileQueueFull
+		}
+	}
+	r.scripts = scripts
+	return nil
+}
+
+// get returns a script by name
+func (r *scriptRegistry) get(name string) (*registeredScript, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	script, ok := r.scripts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errScriptNotFound, name)
+	}
+	return script, nil
+}
+
+// list returns all scripts ordered by name
+func (r *scriptRegistry) list() []*registeredScript {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scripts := make([]*registeredScript, 0, len(r.scripts))
+	for _, script := range r.scripts {
+		scripts = append(scripts, script)
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].info.Name < scripts[j].info.Name
+	})
+	return scripts
+}
+
+// loadScript reads a script and its metadata; a manifest takes precedence over the JSDoc block
+func loadScript(dir, name string) (*registeredScript, error) {
+	src, err := os.ReadFile(filepath.Join(dir, name+scriptExt))
+	if err != nil {
+		return nil, err
+	}
+	code := normalizeCode(string(src), false)
+
+	script := &registeredScript{
+		code: code,
+		info: ScriptInfo{
+			Name:   name,
+			Source: "none",
+			Hash:   scriptHash(code),
+			Size:   len(code),
+		},
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name+manifestExt))
+	switch {
+	case err == nil:
+		var manifest scriptManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		script.info.Description = manifest.Description
+		script.info.Owner = manifest.Owner
+		script.info.Tags = manifest.Tags
+		script.info.InputSchema = manifest.InputSchema
+		script.info.OnSuccess = manifest.OnSuccess
+		script.info.OnFailure = manifest.OnFailure
+		script.info.Source = "manifest"
+	case errors.Is(err, os.ErrNotExist):
+		if block, ok := leadingDocBlock(script.code); ok {
+			if err := parseDocBlock(block, &script.info); err != nil {
+				return nil, err
+			}
+			script.info.Source = "jsdoc"
+		}
+	default:
+		return nil, err
+	}
+
+	return script, nil
+}
+
+// leadingDocBlock returns the body of a /** ... */ comment at the start of code
+func leadingDocBlock(code string) (string, bool) {
+	code = strings.TrimLeft(code, " \t\r\n\ufeff")
+	if !strings.HasPrefix(code, "/**") {
+		return "", false
+	}
+
+	end := strings.Index(code, "*/")
+	if end < 0 {
+		return "", false
+	}
+	return code[3:end], true
+}
+
+// parseDocBlock reads @description, @owner, @tag(s), @input, @on_success and @on_failure tags from a JSDoc block
+// Text before the first tag is used as the description
+// The @input value is a JSON schema and may span several lines
+func parseDocBlock(block string, info *ScriptInfo) error {
+	var (
+		tag   string
+		value []string
+	)
+
+	apply := func() error {
+		text := strings.TrimSpace(strings.Join(value, "\n"))
+		switch tag {
+		case "", "description":
+			if text != "" {
+				info.Description = text
+			}
+		case "owner":
+			info.Owner = text
+		case "tag", "tags":
+			for _, t := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if !slices.Contains(info.Tags, t) {
+					info.Tags = append(info.Tags, t)
+				}
+			}
+		case "input":
+			if !json.Valid([]byte(text)) {
+				return fmt.Errorf("@input is not valid JSON")
+			}
+			info.InputSchema = json.RawMessage(text)
+		case "on_success":
+			info.OnSuccess = text
+		case "on_failure":
+			info.OnFailure = text
+		}
+		return nil
+	}
+
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+
+		if strings.HasPrefix(line, "@") {
+			if err := apply(); err != nil {
+				return err
+			}
+			name, rest, _ := strings.Cut(line[1:], " ")
+			tag, value = name, []string{rest}
+			continue
+		}
+		value = append(value, line)
+	}
+
+	return apply()
+}
+
+// resolveScript replaces the request's script name with the registered script's code
+func (p *Plugin) resolveScript(req *ExecuteRequest) error {
+	if req.Code != "" {
+		return fmt.Errorf("code and script are mutually exclusive")
+	}
+	if p.scripts == nil {
+		return fmt.Errorf("script registry is not enabled")
+	}
+
+	script, err := p.scripts.get(req.Script)
+	if err != nil {
+		return err
+	}
+
+	req.Code = script.code
+	req.program = script.program()
+	return nil
+}
+
+// GetScriptInfoRequest selects a registered script
+type GetScriptInfoRequest struct {
+	Name string `json:"name"`
+}
+
+// GetScriptInfoResponse contains the script's metadata
+type GetScriptInfoResponse struct {
+	Script ScriptInfo `json:"script"`
+}
+
+// GetScriptInfo returns the metadata of a registered script
+func (r *rpc) GetScriptInfo(req *GetScriptInfoRequest, resp *GetScriptInfoResponse) error {
+	if r.plugin.scripts == nil {
+		return fmt.Errorf("script registry is not enabled")
+	}
+
+	script, err := r.plugin.scripts.get(req.Name)
+	if err != nil {
+		return err
+	}
+
+	resp.Script = script.scriptInfo()
+	return nil
+}
+
+// ListScriptsRequest optionally filters scripts by tag
+type ListScriptsRequest struct {
+	Tag string `json:"tag,omitempty"`
+}
+
+// ListScriptsResponse contains registered scripts ordered by name
+type ListScriptsResponse struct {
+	Scripts []ScriptInfo `json:"scripts"`
+}
+
+// ListScripts returns the metadata of all registered scripts
+func (r *rpc) ListScripts(req *ListScriptsRequest, resp *ListScriptsResponse) error {
+	if r.plugin.scripts == nil {
+		return fmt.Errorf("script registry is not enabled")
+	}
+
+	resp.Scripts = []ScriptInfo{}
+	for _, script := range r.plugin.scripts.list() {
+		if req.Tag != "" && !slices.Contains(script.info.Tags, req.Tag) {
+			continue
+		}
+		resp.Scripts = append(resp.Scripts, script.scriptInfo())
+	}
+	return nil
+}
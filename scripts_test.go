@@ -0,0 +1,79 @@
+package jsmachine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// compiledScripts polls ListScripts until no registered script is pending and returns them by name
+func compiledScripts(t *testing.T, r *rpc) map[string]ScriptInfo {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := &ListScriptsResponse{}
+		if err := r.ListScripts(&ListScriptsRequest{}, resp); err != nil {
+			t.Fatal(err)
+		}
+
+		scripts := make(map[string]ScriptInfo, len(resp.Scripts))
+		pending := false
+		for _, script := range resp.Scripts {
+			scripts[script.Name] = script
+			pending = pending || script.CompileStatus == compilePending
+		}
+		if !pending {
+			return scripts
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scripts still pending: %+v", resp.Scripts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestScriptRegistryCompilesInBackground(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, code string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ok.js", `"ok"`)
+	write("broken.js", `function (`)
+
+	p := serveTestPlugin(t, &Config{PoolSize: 1, Scripts: &ScriptsConfig{Dir: dir}})
+	r := p.RPC().(*rpc)
+
+	scripts := compiledScripts(t, r)
+	if got := scripts["ok"].CompileStatus; got != compileCompiled {
+		t.Errorf("ok: got status %q, want %q", got, compileCompiled)
+	}
+	if got := scripts["broken"]; got.CompileStatus != compileFailed || got.CompileError == "" {
+		t.Errorf("broken: got status %q with error %q, want %q with the parser's error", got.CompileStatus, got.CompileError, compileFailed)
+	}
+	if resp := execute(t, p, &ExecuteRequest{Script: "ok"}); resp.Result != "ok" {
+		t.Errorf("ok: got result %v, want ok", resp.Result)
+	}
+
+	// Fix the broken script and add one, then pick them up without a restart
+	write("broken.js", `"fixed"`)
+	write("added.js", `"added"`)
+	flushed := &FlushCachesResponse{}
+	if err := r.FlushCaches(&FlushCachesRequest{Kind: cachePrograms}, flushed); err != nil {
+		t.Fatal(err)
+	}
+
+	scripts = compiledScripts(t, r)
+	for _, name := range []string{"ok", "broken", "added"} {
+		if got := scripts[name]; got.CompileStatus != compileCompiled || got.CompileError != "" {
+			t.Errorf("%s: got status %q with error %q, want %q", name, got.CompileStatus, got.CompileError, compileCompiled)
+		}
+	}
+	if resp := execute(t, p, &ExecuteRequest{Script: "broken"}); resp.Result != "fixed" {
+		t.Errorf("broken: got result %v, want fixed", resp.Result)
+	}
+}
@@ -0,0 +1,66 @@
+package jsmachine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/roadrunner-server/api/v4/plugins/v1/status"
+	"go.uber.org/zap"
+)
+
+// selfTestScript wraps a registered script so that its self-test function runs after the script body
+// The result is true (passed), false (the function returned false) or null (no self-test declared)
+func selfTestScript(code, function string) string {
+	return "(function () {\n" + code + "\n;return typeof " + function + ` === "function" ? ` + function + "() !== false : null;\n})()"
+}
+
+// runSelfTests runs the self-test function of every registered script in mock mode
+// The plugin reports ready once all self-tests passed
+func (p *Plugin) runSelfTests() {
+	cfg := p.cfg.Scripts.SelfTest
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+
+	var passed, skipped int
+	var failed []string
+	for _, script := range p.scripts.list() {
+		name := script.info.Name
+		exec := &execution{requestID: "self-test-" + name, script: name, attempt: 1, mock: true}
+
+		result, err := p.execute(context.Background(), exec, selfTestScript(script.code, cfg.Function), timeout)
+		switch {
+		case err != nil:
+			p.log.Error("script self-test failed", zap.String("script", name), zap.Error(err))
+			failed = append(failed, name)
+		case result == false:
+			p.log.Error("script self-test failed", zap.String("script", name),
+				zap.String("error", fmt.Sprintf("%s() returned false", cfg.Function)))
+			failed = append(failed, name)
+		case result == nil:
+			skipped++
+		default:
+			passed++
+		}
+	}
+
+	if len(failed) > 0 {
+		p.log.Error("registered scripts failed their self-test, the plugin will not report ready",
+			zap.Strings("failed", failed),
+			zap.Int("passed", passed),
+			zap.Int("skipped", skipped),
+		)
+		return
+	}
+
+	p.log.Info("script self-tests passed", zap.Int("passed", passed), zap.Int("skipped", skipped))
+	p.ready.Store(true)
+}
+
+// Ready reports readiness to the status plugin: not ready while self-tests run or after one failed
+func (p *Plugin) Ready() (*status.Status, error) {
+	if !p.lifecycle.serving() || !p.ready.Load() {
+		return &status.Status{Code: http.StatusServiceUnavailable}, nil
+	}
+	return &status.Status{Code: http.StatusOK}, nil
+}
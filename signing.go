@@ -0,0 +1,148 @@
+package jsmachine
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// signedRequest is a request as scripts describe it to fetch: {method, url, headers, body}
+type signedRequest struct {
+	method  string
+	url     *url.URL
+	headers map[string]string
+	body    string
+}
+
+// sigv4 signs req with AWS Signature Version 4 at time now, adding the X-Amz-Date, X-Amz-Content-Sha256,
+// X-Amz-Security-Token (for temporary credentials) and Authorization headers
+func sigv4(req *signedRequest, creds *AWSCredentials, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hexSHA256(req.body)
+
+	req.headers["X-Amz-Date"] = amzDate
+	req.headers["X-Amz-Content-Sha256"] = payloadHash
+	if creds.SessionToken != "" {
+		req.headers["X-Amz-Security-Token"] = creds.SessionToken
+	}
+
+	// Every header the script sets is signed, together with the Host header Go sends
+	canonical := map[string]string{"host": req.url.Host}
+	for name, value := range req.headers {
+		canonical[strings.ToLower(name)] = strings.Join(strings.Fields(value), " ")
+	}
+	names := make([]string, 0, len(canonical))
+	for name := range canonical {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + canonical[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// S3 signs the path as sent, every other service signs it escaped once more
+	path := req.url.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if creds.Service != "s3" {
+		path = awsEscape(path, false)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.method,
+		path,
+		canonicalQuery(req.url.Query()),
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + creds.Region + "/" + creds.Service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSum(sha256.New, []byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSum(sha256.New, key, creds.Region)
+	key = hmacSum(sha256.New, key, creds.Service)
+	key = hmacSum(sha256.New, key, "aws4_request")
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, stringToSign))
+
+	req.headers["Authorization"] = fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature)
+}
+
+// canonicalQuery encodes query parameters sorted by name, then value
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		values = append([]string(nil), values...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(name, true)+"="+awsEscape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes every byte except the RFC 3986 unreserved characters and, unless encodeSlash, '/'
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacHashes are the algorithms accepted by the HMAC helpers
+var hmacHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hmacSum computes the HMAC of data with key
+func hmacSum(h func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(h, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// encodeDigest encodes a digest as hex or base64
+func encodeDigest(sum []byte, encoding string) (string, error) {
+	switch encoding {
+	case "hex":
+		return hex.EncodeToString(sum), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum), nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q (hex, base64)", encoding)
+}
+
+// hexSHA256 returns the hex encoded SHA-256 of s
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,158 @@
+package jsmachine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/roadrunner-server/api/v4/plugins/v1/jobs"
+	"go.uber.org/zap"
+)
+
+// What a script sink pushes
+const (
+	sinkResult = "result"
+	sinkItems  = "items"
+)
+
+// sinkPushTimeout bounds the pushes of one execution
+const sinkPushTimeout = 10 * time.Second
+
+// jobsPusher is implemented by the RR jobs plugin
+type jobsPusher interface {
+	// Push sends a job to the pipeline it names
+	Push(ctx context.Context, job jobs.Job) error
+}
+
+// sinkJob is a job pushed by a script sink
+type sinkJob struct {
+	id       string
+	name     string
+	payload  string
+	headers  map[string][]string
+	pipeline string
+	priority int64
+	delay    int64
+}
+
+// Name implements jobs.Job
+func (j *sinkJob) Name() string { return j.name }
+
+// ID implements jobs.Job
+func (j *sinkJob) ID() string { return j.id }
+
+// Payload implements jobs.Job
+func (j *sinkJob) Payload() string { return j.payload }
+
+// Headers implements jobs.Job
+func (j *sinkJob) Headers() map[string][]string { return j.headers }
+
+// Pipeline implements jobs.Job
+func (j *sinkJob) Pipeline() string { return j.pipeline }
+
+// Priority implements jobs.Job
+func (j *sinkJob) Priority() int64 { return j.priority }
+
+// Delay implements jobs.Job
+func (j *sinkJob) Delay() int64 { return j.delay }
+
+// AutoAck implements jobs.Job
+func (j *sinkJob) AutoAck() bool { return false }
+
+// Offset implements jobs.Job (Kafka only)
+func (j *sinkJob) Offset() int64 { return 0 }
+
+// Partition implements jobs.Job (Kafka only)
+func (j *sinkJob) Partition() int32 { return 0 }
+
+// Topic implements jobs.Job (Kafka only)
+func (j *sinkJob) Topic() string { return "" }
+
+// Metadata implements jobs.Job (Kafka only)
+func (j *sinkJob) Metadata() string { return "" }
+
+// UpdatePriority implements jobs.Job
+func (j *sinkJob) UpdatePriority(priority int64) { j.priority = priority }
+
+// sinkFor returns the sink of a registered script (nil when it has none)
+func (p *Plugin) sinkFor(script string) *ScriptSinkConfig {
+	if script == "" || p.cfg.Scripts == nil {
+		return nil
+	}
+	return p.cfg.Scripts.Sinks[script]
+}
+
+// sink pushes what a successful execution produced to its script's pipeline: the result, or the records it emitted
+// Failed pushes are logged and counted; the execution's result is returned to the caller all the same
+func (p *Plugin) sink(exec *execution, result interface{}) {
+	cfg := p.sinkFor(exec.script)
+	if cfg == nil || exec.mock {
+		return
+	}
+
+	payloads := exec.records
+	if cfg.Mode == sinkResult {
+		data, err := json.Marshal(result)
+		if err != nil {
+			p.sinkFailed(exec, fmt.Errorf("failed to encode the result: %w", err))
+			return
+		}
+		payloads = []json.RawMessage{data}
+	}
+	if len(payloads) == 0 {
+		return
+	}
+	if p.jobsPlugin == nil {
+		p.sinkFailed(exec, fmt.Errorf("jobs plugin not available"))
+		return
+	}
+
+	headers := map[string][]string{"js-script": {exec.script}}
+	if exec.requestID != "" {
+		headers["js-request-id"] = []string{exec.requestID}
+	}
+	for name, value := range cfg.Headers {
+		headers[name] = []string{value}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkPushTimeout)
+	defer cancel()
+
+	for _, payload := range payloads {
+		job := &sinkJob{
+			id:       newRecordID(),
+			name:     cfg.Job,
+			payload:  string(payload),
+			headers:  headers,
+			pipeline: cfg.Pipeline,
+			priority: cfg.Priority,
+			delay:    cfg.Delay,
+		}
+		if err := p.jobsPlugin.Push(ctx, job); err != nil {
+			p.sinkFailed(exec, err)
+			continue
+		}
+		p.sinkJobs.WithLabelValues(exec.script, "pushed").Inc()
+	}
+}
+
+// outputRecords returns the records of exec for the response; those of scripts with an items sink go to the
+// pipeline instead
+func (p *Plugin) outputRecords(exec *execution) []json.RawMessage {
+	if cfg := p.sinkFor(exec.script); cfg != nil && cfg.Mode == sinkItems {
+		return nil
+	}
+	return exec.records
+}
+
+// sinkFailed logs and counts a job that could not be pushed
+func (p *Plugin) sinkFailed(exec *execution, err error) {
+	p.sinkJobs.WithLabelValues(exec.script, "failed").Inc()
+	p.log.Error("failed to push script output to the jobs pipeline",
+		zap.String("script", exec.script),
+		zap.String("request_id", exec.requestID),
+		zap.String("pipeline", p.sinkFor(exec.script).Pipeline),
+		zap.Error(err),
+	)
+}
@@ -0,0 +1,116 @@
+package jsmachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// deniedPrefixes are address ranges network bindings refuse unless allowed_cidrs lists them: loopback, private,
+// link-local (including cloud metadata endpoints), shared, benchmarking, multicast and reserved ranges
+var deniedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// errAddressDenied is returned when a host resolves to an address in a denied range
+var errAddressDenied = errors.New("resolves to a denied address")
+
+// addressPolicy decides which resolved addresses network bindings may use
+type addressPolicy struct {
+	// Denied ranges that are allowed anyway (js.fetch.allowed_cidrs, js.net.allowed_cidrs)
+	allowed []netip.Prefix
+}
+
+// permits reports whether addr may be used
+func (p addressPolicy) permits(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialContext resolves the host itself, checks every address and connects to the checked addresses only, so a
+// DNS answer cannot change between the check and the connection (DNS rebinding)
+func (p addressPolicy) dialContext(dialer *net.Dialer, resolver *net.Resolver) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		// A single denied answer rejects the host: mixed answers are a rebinding technique
+		for _, addr := range addrs {
+			if !p.permits(addr) {
+				return nil, fmt.Errorf("%s %w %s", host, errAddressDenied, addr.Unmap())
+			}
+		}
+
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%s has no addresses", host)
+		}
+		return nil, lastErr
+	}
+}
+
+// parsePrefixes parses a list of CIDRs from the configuration
+func parsePrefixes(key string, cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// mustParsePrefixes parses built-in CIDRs
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes, err := parsePrefixes("built-in", cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return prefixes
+}
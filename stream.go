@@ -0,0 +1,180 @@
+package jsmachine
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// streamBufferSize is the number of events buffered per subscriber before events are dropped
+	streamBufferSize = 256
+
+	streamWriteTimeout = 5 * time.Second
+)
+
+// Stream event types
+const (
+	streamEventLog      = "log"
+	streamEventProgress = "progress"
+	streamEventResult   = "result"
+	streamEventPartial  = "partial"
+	streamEventRecord   = "record"
+)
+
+// streamEvent is a single message delivered to execution subscribers
+type streamEvent struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id"`
+
+	// log events
+	Level   string                 `json:"level,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+
+	// progress and record events
+	Data interface{} `json:"data,omitempty"`
+
+	// partial and record events
+	Sequence int `json:"sequence,omitempty"`
+
+	// result and partial events
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms,omitempty"`
+}
+
+// streamHub fans out execution events to subscribers keyed by request ID
+type streamHub struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan streamEvent]struct{}
+}
+
+// newStreamHub creates an empty hub
+func newStreamHub() *streamHub {
+	return &streamHub{
+		subs: make(map[string]map[chan streamEvent]struct{}),
+	}
+}
+
+// subscribe registers a subscriber for requestID; the returned func must be called to unsubscribe
+func (h *streamHub) subscribe(requestID string) (chan streamEvent, func()) {
+	ch := make(chan streamEvent, streamBufferSize)
+
+	h.mu.Lock()
+	if h.subs[requestID] == nil {
+		h.subs[requestID] = make(map[chan streamEvent]struct{})
+	}
+	h.subs[requestID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[requestID], ch)
+		if len(h.subs[requestID]) == 0 {
+			delete(h.subs, requestID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// hasSubscribers reports whether anybody listens to requestID
+// Used by bindings to skip building events nobody will receive
+func (h *streamHub) hasSubscribers(requestID string) bool {
+	if requestID == "" {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs[requestID]) > 0
+}
+
+// publish delivers ev to all subscribers of its request ID without blocking
+// Slow subscribers lose events rather than stalling the execution
+func (h *streamHub) publish(ev streamEvent) {
+	if ev.RequestID == "" {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs[ev.RequestID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Middleware implements the HTTP plugin middleware interface
+// Requests to the configured websocket path are upgraded; everything else is passed through
+func (p *Plugin) Middleware(next http.Handler) http.Handler {
+	upgrader := websocket.Upgrader{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.cfg.WebSocket == nil || r.URL.Path != p.cfg.WebSocket.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestID := r.URL.Query().Get("request_id")
+		if requestID == "" {
+			http.Error(w, "request_id query parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			p.log.Debug("websocket upgrade failed", zap.Error(err))
+			return
+		}
+
+		p.serveStream(conn, requestID)
+	})
+}
+
+// serveStream forwards events for requestID to conn until the result is sent or the client goes away
+func (p *Plugin) serveStream(conn *websocket.Conn, requestID string) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	events, unsubscribe := p.streams.subscribe(requestID)
+	defer unsubscribe()
+
+	// Reader loop: detects client disconnects (incoming messages are ignored)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case ev := <-events:
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(ev); err != nil {
+				p.log.Debug("websocket write failed", zap.String("request_id", requestID), zap.Error(err))
+				return
+			}
+			if ev.Type == streamEventResult {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					time.Now().Add(streamWriteTimeout))
+				return
+			}
+		case <-closed:
+			return
+		case <-p.stopCh:
+			return
+		}
+	}
+}
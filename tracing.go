@@ -0,0 +1,245 @@
+package jsmachine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	// Frames of folded stacks that are not bindings
+	traceRoot   = "js"
+	traceAdHoc  = "(ad-hoc)"
+	traceQueued = "(queued)"
+)
+
+// instrumentScript wraps the functions of the enabled bindings so calls are timed per binding
+// Frozen objects (ctx) are left alone; they only expose accessors and cheap functions
+const instrumentScript = `(function (enter, exit, names) {
+	var global = Function("return this")();
+	function wrap(fn, name) {
+		return function () {
+			enter(name);
+			try {
+				return fn.apply(this, arguments);
+			} finally {
+				exit();
+			}
+		};
+	}
+	for (var i = 0; i < names.length; i++) {
+		var binding = global[names[i]];
+		if (typeof binding === "function") {
+			global[names[i]] = wrap(binding, names[i]);
+		} else if (binding !== null && typeof binding === "object" && !Object.isFrozen(binding)) {
+			for (var key in binding) {
+				if (typeof binding[key] === "function") {
+					binding[key] = wrap(binding[key], names[i] + "." + key);
+				}
+			}
+		}
+	}
+})`
+
+// executionTrace is the time an execution spent queued, in bindings and in its own code
+type executionTrace struct {
+	script string
+	pool   string
+	tag    string
+	queued time.Duration
+
+	// Exclusive time by binding call stack (frames joined with ";"), "" is the script's own code
+	spent map[string]time.Duration
+}
+
+// traceFrame is a binding call in progress
+type traceFrame struct {
+	name  string
+	start time.Time
+}
+
+// tracer times the binding calls of one execution
+type tracer struct {
+	mu    sync.Mutex
+	stack []traceFrame
+	path  []string
+	spent map[string]time.Duration
+	start time.Time
+}
+
+// newTracer starts timing an execution's own code
+func newTracer() *tracer {
+	return &tracer{
+		spent: make(map[string]time.Duration),
+		start: time.Now(),
+	}
+}
+
+// enter pauses the current frame and starts timing a binding call
+func (t *tracer) enter(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.pause(now)
+	t.stack = append(t.stack, traceFrame{name: name, start: now})
+	t.path = append(t.path, name)
+}
+
+// exit ends the innermost binding call and resumes its caller
+func (t *tracer) exit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.stack) == 0 {
+		return
+	}
+	now := time.Now()
+	t.pause(now)
+	t.stack = t.stack[:len(t.stack)-1]
+	t.path = t.path[:len(t.path)-1]
+	if len(t.stack) > 0 {
+		t.stack[len(t.stack)-1].start = now
+	} else {
+		t.start = now
+	}
+}
+
+// pause adds the time since the current frame was (re)started to its stack (t.mu held)
+func (t *tracer) pause(now time.Time) {
+	if len(t.stack) == 0 {
+		t.spent[""] += now.Sub(t.start)
+		return
+	}
+	frame := t.stack[len(t.stack)-1]
+	t.spent[strings.Join(t.path, ";")] += now.Sub(frame.start)
+}
+
+// finish stops timing (calls interrupted by a timeout are closed at this point) and returns the trace
+func (t *tracer) finish(exec *execution) *executionTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pause(time.Now())
+	t.stack, t.path = nil, nil
+
+	script := exec.script
+	if script == "" {
+		script = traceAdHoc
+	}
+	return &executionTrace{script: script, pool: exec.pool.name, tag: exec.tag, queued: exec.waited, spent: t.spent}
+}
+
+// traceRing keeps the traces of the most recent executions
+type traceRing struct {
+	mu   sync.Mutex
+	ring []*executionTrace
+	next int
+}
+
+// newTraceRing creates a ring holding capacity traces
+func newTraceRing(capacity int) *traceRing {
+	return &traceRing{ring: make([]*executionTrace, capacity)}
+}
+
+// record stores a trace, evicting the oldest one when the ring is full
+func (r *traceRing) record(trace *executionTrace) {
+	r.mu.Lock()
+	r.ring[r.next] = trace
+	r.next = (r.next + 1) % len(r.ring)
+	r.mu.Unlock()
+}
+
+// folded aggregates the stored traces of script and tag (all when empty) into folded stacks weighted in microseconds
+func (r *traceRing) folded(script, tag string) (stacks map[string]int64, executions int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stacks = make(map[string]int64)
+	for _, trace := range r.ring {
+		if trace == nil || (script != "" && trace.script != script) || (tag != "" && trace.tag != tag) {
+			continue
+		}
+		executions++
+
+		prefix := traceRoot + ";" + trace.pool + ";" + trace.script
+		if trace.queued > 0 {
+			stacks[prefix+";"+traceQueued] += trace.queued.Microseconds()
+		}
+		for path, spent := range trace.spent {
+			stack := prefix
+			if path != "" {
+				stack += ";" + path
+			}
+			stacks[stack] += spent.Microseconds()
+		}
+	}
+	return stacks, executions
+}
+
+// instrumentBindings wraps the named bindings of vm for tracing
+func (p *Plugin) instrumentBindings(vm *otto.Otto, names []string) error {
+	instrument, err := vm.Run(instrumentScript)
+	if err != nil {
+		return err
+	}
+
+	enter := func(call otto.FunctionCall) otto.Value {
+		if exec := p.executionFor(call.Otto); exec != nil && exec.tracer != nil {
+			exec.tracer.enter(call.Argument(0).String())
+		}
+		return otto.UndefinedValue()
+	}
+	exit := func(call otto.FunctionCall) otto.Value {
+		if exec := p.executionFor(call.Otto); exec != nil && exec.tracer != nil {
+			exec.tracer.exit()
+		}
+		return otto.UndefinedValue()
+	}
+
+	_, err = instrument.Call(otto.NullValue(), enter, exit, names)
+	return err
+}
+
+// FlamegraphRequest selects the executions to aggregate
+type FlamegraphRequest struct {
+	// Registered script name, "(ad-hoc)" for submitted code (empty = all executions)
+	Script string `json:"script,omitempty"`
+
+	// Request tag (empty = all executions)
+	Tag string `json:"tag,omitempty"`
+}
+
+// FlamegraphResponse contains the aggregated traces in folded stack format
+type FlamegraphResponse struct {
+	// Executions aggregated
+	Executions int `json:"executions"`
+
+	// One "js;<pool>;<script>[;<binding>...] <microseconds>" line per stack, sorted by stack
+	Folded string `json:"folded"`
+}
+
+// Flamegraph aggregates the traces of recent executions by script and binding for flamegraph tools
+func (r *rpc) Flamegraph(req *FlamegraphRequest, resp *FlamegraphResponse) error {
+	if r.plugin.traces == nil {
+		return fmt.Errorf("tracing is not configured")
+	}
+
+	stacks, executions := r.plugin.traces.folded(req.Script, req.Tag)
+
+	lines := make([]string, 0, len(stacks))
+	for stack, us := range stacks {
+		if us > 0 {
+			lines = append(lines, fmt.Sprintf("%s %d", stack, us))
+		}
+	}
+	sort.Strings(lines)
+
+	resp.Executions = executions
+	resp.Folded = strings.Join(lines, "\n")
+	return nil
+}
@@ -0,0 +1,144 @@
+package jsmachine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLabelLength bounds request_id and caller
+const maxLabelLength = 256
+
+// Violation codes reported for rejected request fields
+const (
+	violationRequired        = "required"
+	violationExclusive       = "mutually_exclusive"
+	violationNotFound        = "not_found"
+	violationUnavailable     = "unavailable"
+	violationTooLarge        = "too_large"
+	violationInvalidUTF8     = "invalid_utf8"
+	violationInvalid         = "invalid"
+	violationTimeoutExceeded = "timeout_exceeded"
+	violationNotAllowed      = "not_allowed"
+)
+
+// errCodeInvalidRequest is the error code and execution status of requests rejected by validation
+const errCodeInvalidRequest = "invalid_request"
+
+// FieldViolation describes why a request field was rejected
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationError lists every violation found in a request
+type validationError struct {
+	violations []FieldViolation
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, 0, len(e.violations))
+	for _, v := range e.violations {
+		messages = append(messages, v.Field+": "+v.Message)
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// violations returns the field violations of a validation error (nil for other errors)
+func violations(err error) []FieldViolation {
+	var verr *validationError
+	if errors.As(err, &verr) {
+		return verr.violations
+	}
+	return nil
+}
+
+// requestValidator collects violations of a single request
+type requestValidator struct {
+	violations []FieldViolation
+}
+
+// add records a violation
+func (v *requestValidator) add(field, code, format string, args ...interface{}) {
+	v.violations = append(v.violations, FieldViolation{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// label checks an identifier-like field: bounded length, valid UTF-8, no control characters
+func (v *requestValidator) label(field, value string) {
+	switch {
+	case len(value) > maxLabelLength:
+		v.add(field, violationTooLarge, "must not exceed %d bytes, got %d", maxLabelLength, len(value))
+	case !utf8.ValidString(value):
+		v.add(field, violationInvalidUTF8, "must be valid UTF-8")
+	case strings.IndexFunc(value, unicode.IsControl) >= 0:
+		v.add(field, violationInvalid, "must not contain control characters")
+	}
+}
+
+// err returns the collected violations as an error (nil when the request is valid)
+func (v *requestValidator) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &validationError{violations: v.violations}
+}
+
+// validateRequest checks an execute request before anything runs
+func (p *Plugin) validateRequest(req *ExecuteRequest) error {
+	v := &requestValidator{}
+
+	switch {
+	case req.Code == "" && req.Script == "":
+		v.add("code", violationRequired, "code or script is required")
+	case req.Code != "" && req.Script != "" && !req.redrive:
+		v.add("script", violationExclusive, "code and script are mutually exclusive")
+	}
+
+	if req.Code != "" && !req.redrive {
+		if limit := p.cfg.MaxCodeSize; limit > 0 && len(req.Code) > limit {
+			v.add("code", violationTooLarge, "must not exceed %d bytes, got %d", limit, len(req.Code))
+		}
+		// Without strict_encoding invalid sequences have already been replaced
+		if !utf8.ValidString(req.Code) {
+			v.add("code", violationInvalidUTF8, "must be valid UTF-8")
+		}
+	}
+
+	if req.Script != "" && req.Code == "" {
+		if p.scripts == nil {
+			v.add("script", violationUnavailable, "script registry is not enabled")
+		} else if _, err := p.scripts.get(req.Script); err != nil {
+			v.add("script", violationNotFound, "script %q is not registered", req.Script)
+		}
+	}
+
+	if req.TimeoutMs < 0 {
+		v.add("timeout_ms", violationInvalid, "must not be negative, got %d", req.TimeoutMs)
+	} else if pool := p.poolFor(req.Script); p.cfg.TimeoutPolicy == timeoutPolicyReject {
+		if _, exceeded := pool.timeout(req.TimeoutMs); exceeded {
+			v.add("timeout_ms", violationTimeoutExceeded, "must not exceed %d, got %d", pool.maxTimeout.Milliseconds(), req.TimeoutMs)
+		}
+	}
+
+	if req.Profile && p.cfg.Profiling == nil {
+		v.add("profile", violationUnavailable, "profiling is not enabled")
+	}
+
+	v.label("request_id", req.RequestID)
+	v.label("caller", req.Caller)
+	v.label("dedupe_key", req.DedupeKey)
+
+	if req.Tag != "" && !slices.Contains(p.cfg.Tags, req.Tag) {
+		v.add("tag", violationNotAllowed, "tag %q is not in js.tags", req.Tag)
+	}
+
+	if _, err := p.retryPolicy(req); err != nil {
+		v.add("retry", violationInvalid, "%v", err)
+	}
+
+	return v.err()
+}
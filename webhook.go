@@ -0,0 +1,153 @@
+package jsmachine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// failureWebhookQueueSize is the number of notifications buffered before new ones are dropped
+const failureWebhookQueueSize = 1024
+
+// failureWebhook POSTs failed executions to a configured URL with retries
+type failureWebhook struct {
+	cfg      *FailureWebhookConfig
+	log      *zap.Logger
+	client   *http.Client
+	statuses map[string]bool
+	scripts  map[string]bool // nil notifies failures of every script and of ad-hoc code
+
+	queue chan *executionFailure
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// newFailureWebhook creates the notifier for the given configuration
+func newFailureWebhook(cfg *FailureWebhookConfig, log *zap.Logger) *failureWebhook {
+	statuses := make(map[string]bool, len(cfg.Statuses))
+	for _, status := range cfg.Statuses {
+		statuses[status] = true
+	}
+	var scripts map[string]bool
+	if len(cfg.Scripts) > 0 {
+		scripts = make(map[string]bool, len(cfg.Scripts))
+		for _, name := range cfg.Scripts {
+			scripts[name] = true
+		}
+	}
+
+	return &failureWebhook{
+		cfg:      cfg,
+		log:      log,
+		client:   &http.Client{Timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond},
+		statuses: statuses,
+		scripts:  scripts,
+		queue:    make(chan *executionFailure, failureWebhookQueueSize),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// notify queues the failure if it passes the status and script filters
+func (w *failureWebhook) notify(failure *executionFailure) {
+	if !w.statuses[failure.Status] {
+		return
+	}
+	if w.scripts != nil && !w.scripts[failure.Script] {
+		return
+	}
+	if !w.cfg.IncludeCode {
+		failure.Code, failure.Input = "", ""
+	}
+
+	select {
+	case w.queue <- failure:
+	default:
+		w.log.Warn("failure webhook queue is full, dropping notification",
+			zap.String("request_id", failure.RequestID))
+	}
+}
+
+// run delivers queued notifications until stop is called
+func (w *failureWebhook) run() {
+	defer close(w.done)
+
+	for {
+		select {
+		case failure := <-w.queue:
+			w.deliver(failure)
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// stop stops delivering notifications, waiting for an in-flight delivery (bounded by ctx)
+func (w *failureWebhook) stop(ctx context.Context) {
+	close(w.quit)
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+	}
+}
+
+// deliver posts a single notification, retrying with exponential backoff
+func (w *failureWebhook) deliver(failure *executionFailure) {
+	body, err := json.Marshal(failure)
+	if err != nil {
+		w.log.Error("failed to encode failure webhook payload", zap.Error(err))
+		return
+	}
+
+	backoff := time.Duration(w.cfg.BackoffMs) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err = w.post(body)
+		if err == nil {
+			return
+		}
+
+		if attempt >= w.cfg.MaxAttempts {
+			w.log.Error("failure webhook delivery failed",
+				zap.String("request_id", failure.RequestID),
+				zap.Int("attempts", attempt),
+				zap.Error(err))
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// post sends the payload once
+func (w *failureWebhook) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package jsmachine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFailureWebhookScriptFilter(t *testing.T) {
+	posted := make(chan *executionFailure, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failure := &executionFailure{}
+		if err := json.NewDecoder(r.Body).Decode(failure); err != nil {
+			t.Error(err)
+		}
+		posted <- failure
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"listed", "other"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".js"), []byte(`throw new Error("failed")`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := serveTestPlugin(t, &Config{
+		PoolSize:         1,
+		Scripts:          &ScriptsConfig{Dir: dir},
+		OnFailureWebhook: &FailureWebhookConfig{URL: server.URL, Scripts: []string{"listed"}},
+	})
+	r := p.RPC().(*rpc)
+
+	// Notifications are delivered in order, so the listed script's arrives after the other's would have
+	for _, name := range []string{"other", "listed"} {
+		resp := &ExecuteResponse{}
+		if err := r.Execute(&ExecuteRequest{Script: name}, resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == "" {
+			t.Fatalf("%s: execution did not fail", name)
+		}
+	}
+
+	select {
+	case failure := <-posted:
+		if failure.Script != "listed" {
+			t.Errorf("got a notification for script %q, want only listed", failure.Script)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was posted for the listed script")
+	}
+}